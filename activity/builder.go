@@ -9,6 +9,7 @@ import (
 	"sync/atomic"
 
 	"github.com/pkg/errors"
+	"github.com/qor5/admin/v3/notification"
 	"github.com/qor5/admin/v3/presets"
 	"github.com/qor5/x/v3/perm"
 	"github.com/samber/lo"
@@ -37,6 +38,8 @@ type Builder struct {
 	findUsersFunc           func(ctx context.Context, ids []string) (map[string]*User, error)
 	maxCountShowInTimeline  int
 	findLogsForTimelineFunc func(ctx context.Context, db *gorm.DB, modelName, modelKeys string) (logs []*ActivityLog, hasMore bool, err error)
+	nb                      *notification.Builder
+	mentionedUsersFunc      func(ctx context.Context, mentions []string) ([]string, error)
 
 	mu               sync.RWMutex
 	logModelBuilders map[*presets.Builder]*presets.ModelBuilder
@@ -74,6 +77,18 @@ func (ab *Builder) FindLogsForTimelineFunc(v func(ctx context.Context, db *gorm.
 	return ab
 }
 
+// Notification sets Notification Builder to notify users mentioned with @ in notes
+func (ab *Builder) Notification(v *notification.Builder) *Builder {
+	ab.nb = v
+	return ab
+}
+
+// MentionedUsersFunc resolves the @mentions in a note to user ids, by default mentions are matched against activity users by id or name
+func (ab *Builder) MentionedUsersFunc(v func(ctx context.Context, mentions []string) ([]string, error)) *Builder {
+	ab.mentionedUsersFunc = v
+	return ab
+}
+
 func New(db *gorm.DB, currentUserFunc func(ctx context.Context) (*User, error)) *Builder {
 	ab := &Builder{
 		dbPrimitive:     db,
@@ -71,12 +71,20 @@ type DiffBuilder struct {
 	diffs []Diff
 }
 
+// NewDiffBuilder mb can be nil, then only the default ignored fields and type handlers are used
 func NewDiffBuilder(mb *ModelBuilder) *DiffBuilder {
 	return &DiffBuilder{
 		mb: mb,
 	}
 }
 
+func (db *DiffBuilder) typeHandler(t reflect.Type) TypeHandler {
+	if db.mb == nil {
+		return nil
+	}
+	return db.mb.typeHandlers[t]
+}
+
 func (db *DiffBuilder) Diff(old, new any) ([]Diff, error) {
 	err := db.diffLoop(reflect.Indirect(reflect.ValueOf(old)), reflect.Indirect(reflect.ValueOf(new)), "")
 	return db.diffs, err
@@ -128,10 +136,12 @@ func (db *DiffBuilder) diffLoop(old, new reflect.Value, prefixField string) erro
 				}
 			}
 
-			for _, ignoredField := range db.mb.ignoredFields {
-				if ignoredField == field.Name {
-					needContinue = true
-					continue
+			if db.mb != nil {
+				for _, ignoredField := range db.mb.ignoredFields {
+					if ignoredField == field.Name {
+						needContinue = true
+						continue
+					}
 				}
 			}
 
@@ -145,7 +155,7 @@ func (db *DiffBuilder) diffLoop(old, new reflect.Value, prefixField string) erro
 				continue
 			}
 
-			if f := db.mb.typeHandlers[field.Type]; f != nil {
+			if f := db.typeHandler(field.Type); f != nil {
 				db.diffs = append(db.diffs, f(old.Field(i).Interface(), new.Field(i).Interface(), newPrefixField)...)
 				continue
 			}
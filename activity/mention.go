@@ -0,0 +1,59 @@
+package activity
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"regexp"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/notification"
+)
+
+var mentionRegexp = regexp.MustCompile(`(?:^|\s)@([^\s@]+)`)
+
+func ParseMentions(note string) []string {
+	return lo.Uniq(lo.Map(mentionRegexp.FindAllStringSubmatch(note, -1), func(m []string, _ int) string {
+		return m[1]
+	}))
+}
+
+func (ab *Builder) findMentionedUsers(ctx context.Context, db *gorm.DB, mentions []string) ([]string, error) {
+	if ab.mentionedUsersFunc != nil {
+		return ab.mentionedUsersFunc(ctx, mentions)
+	}
+	var ids []string
+	if err := db.Model(&ActivityUser{}).Where("id IN ? OR name IN ?", mentions, mentions).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (ab *Builder) notifyMentions(ctx context.Context, db *gorm.DB, author *User, alog *ActivityLog, note *Note) {
+	if ab.nb == nil || note == nil {
+		return
+	}
+	mentions := ParseMentions(note.Note)
+	if len(mentions) == 0 {
+		return
+	}
+	userIDs, err := ab.findMentionedUsers(ctx, db, mentions)
+	if err != nil {
+		log.Printf("activity: failed to find mentioned users: %s\n", err)
+		return
+	}
+	userIDs = lo.Without(userIDs, author.ID)
+	if len(userIDs) == 0 {
+		return
+	}
+	label := cmp.Or(alog.ModelLabel, alog.ModelName)
+	msg := notification.NewMessage(I18nActivityKey, Messages_en_US, "NotificationMentioned",
+		"{user}", author.Name, "{model}", label, "{keys}", alog.ModelKeys)
+	msg.Body = note.Note
+	msg.Link = alog.ModelLink
+	if err := ab.nb.SendToUsers(ctx, msg, userIDs...); err != nil {
+		log.Printf("activity: failed to notify mentioned users: %s\n", err)
+	}
+}
@@ -74,6 +74,8 @@ type Messages struct {
 	ActivityLog  string
 
 	FilterTabsHasUnreadNotes string
+
+	NotificationMentioned string
 }
 
 func (msgr *Messages) LastEditedAt(desc string) string {
@@ -167,6 +169,8 @@ var Messages_en_US = &Messages{
 	ActivityLog:  "Activity Log",
 
 	FilterTabsHasUnreadNotes: "Has Unread Notes",
+
+	NotificationMentioned: "{user} mentioned you in a note on {model} {keys}",
 }
 
 var Messages_zh_CN = &Messages{
@@ -238,6 +242,8 @@ var Messages_zh_CN = &Messages{
 	ActivityLog:  "操作日志",
 
 	FilterTabsHasUnreadNotes: "未读备注",
+
+	NotificationMentioned: "{user} 在 {model} {keys} 的备注中提到了你",
 }
 
 var Messages_ja_JP = &Messages{
@@ -309,4 +315,6 @@ var Messages_ja_JP = &Messages{
 	ActivityLog:  "作業履歴",
 
 	FilterTabsHasUnreadNotes: "未読ノート",
+
+	NotificationMentioned: "{user} が {model} {keys} のメモであなたをメンションしました",
 }
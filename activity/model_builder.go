@@ -599,5 +599,9 @@ func (mb *ModelBuilder) create(
 		return nil, errors.Wrap(err, "failed to create log")
 	}
 
+	if note, ok := detail.(*Note); ok && action == ActionNote {
+		mb.ab.notifyMentions(ctx, db, user, log, note)
+	}
+
 	return log, nil
 }
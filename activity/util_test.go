@@ -389,3 +389,9 @@ func TestParsePrimaryKeys(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMentions(t *testing.T) {
+	assert.Empty(t, activity.ParseMentions("no mentions here, mail@example.com"))
+	assert.Equal(t, []string{"alice", "bob"}, activity.ParseMentions("@alice please check with @bob and @alice"))
+	assert.Equal(t, []string{"12"}, activity.ParseMentions("ping\n@12"))
+}
@@ -12,5 +12,4 @@ func main() {
 	config := admin.NewConfig(db, false)
 	storage := admin.PublishStorage
 	publish.RunPublisher(context.Background(), db, storage, config.Publisher)
-	select {}
 }
@@ -0,0 +1,152 @@
+package notification
+
+import (
+	"fmt"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	. "github.com/qor5/x/v3/ui/vuetify"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
+	"golang.org/x/text/language"
+
+	"github.com/qor5/admin/v3/presets"
+)
+
+const (
+	I18nNotificationKey i18n.ModuleKey = "I18nNotificationKey"
+
+	EventMarkRead    = "notification_MarkRead"
+	EventMarkAllRead = "notification_MarkAllRead"
+
+	paramNotificationID = "notification_id"
+)
+
+func (b *Builder) Install(pb *presets.Builder) error {
+	pb.GetI18n().
+		RegisterForModule(language.English, I18nNotificationKey, Messages_en_US).
+		RegisterForModule(language.SimplifiedChinese, I18nNotificationKey, Messages_zh_CN).
+		RegisterForModule(language.Japanese, I18nNotificationKey, Messages_ja_JP)
+
+	pb.NotificationFunc(b.notificationContent, b.notificationCount)
+	pb.GetWebBuilder().RegisterEventFunc(EventMarkRead, b.markRead)
+	pb.GetWebBuilder().RegisterEventFunc(EventMarkAllRead, b.markAllRead)
+	return nil
+}
+
+func (b *Builder) notificationCount(ctx *web.EventContext) int {
+	uid, err := b.currentUserID(ctx.R.Context())
+	if err != nil {
+		return 0
+	}
+	count, err := b.UnreadCount(ctx.R.Context(), uid)
+	if err != nil {
+		return 0
+	}
+	return int(count)
+}
+
+func severityColor(s Severity) string {
+	switch s {
+	case SeveritySuccess:
+		return ColorSuccess
+	case SeverityWarning:
+		return ColorWarning
+	case SeverityError:
+		return ColorError
+	default:
+		return ColorInfo
+	}
+}
+
+func (b *Builder) notificationContent(ctx *web.EventContext) h.HTMLComponent {
+	msgr := i18n.MustGetModuleMessages(ctx.R, I18nNotificationKey, Messages_en_US).(*Messages)
+
+	uid, err := b.currentUserID(ctx.R.Context())
+	if err != nil {
+		return VCardText(h.Text(msgr.NoNotifications))
+	}
+	ns, err := b.List(ctx.R.Context(), uid, b.maxCountInCenter)
+	if err != nil || len(ns) == 0 {
+		return VCardText(h.Text(msgr.NoNotifications))
+	}
+
+	hasUnread := lo.ContainsBy(ns, func(n *Notification) bool { return !n.IsRead() })
+	items := lo.Map(ns, func(n *Notification, _ int) h.HTMLComponent {
+		item := VListItem(
+			VListItemTitle(h.Text(n.TranslatedTitle(ctx.R))).Class(lo.Ternary(n.IsRead(), "", "font-weight-bold")),
+			h.If(n.Body != "", VListItemSubtitle(h.Text(n.Body))),
+			VListItemSubtitle(h.Text(n.CreatedAt.Local().Format("2006-01-02 15:04"))).Class("text-caption"),
+		).Attr("@click", web.Plaid().
+			EventFunc(EventMarkRead).
+			Query(paramNotificationID, fmt.Sprint(n.ID)).
+			Go())
+		return item.Children(
+			web.Slot(
+				VIcon("mdi-circle").Size(SizeXSmall).Color(severityColor(n.Severity)).
+					Class(lo.Ternary(n.IsRead(), "opacity-50", "")),
+			).Name(VSlotPrepend),
+		)
+	})
+
+	return h.Components(
+		VCardTitle(
+			h.Div(
+				h.Text(msgr.Notifications),
+				VSpacer(),
+				h.If(hasUnread,
+					VBtn(msgr.MarkAllAsRead).Size(SizeSmall).Variant(VariantText).Color(ColorPrimary).
+						Attr("@click.stop", web.Plaid().EventFunc(EventMarkAllRead).Go()),
+				),
+			).Class("d-flex align-center"),
+		),
+		VDivider(),
+		VList(items...).MaxHeight(480).Width(360).Class("overflow-y-auto"),
+	)
+}
+
+func (b *Builder) markRead(ctx *web.EventContext) (r web.EventResponse, err error) {
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), ColorError)
+			err = nil
+		}
+	}()
+
+	uid, err := b.currentUserID(ctx.R.Context())
+	if err != nil {
+		return
+	}
+	// the link is the one stored for the user, never one sent by the browser
+	n, err := b.Get(ctx.R.Context(), uid, uint(ctx.ParamAsInt(paramNotificationID)))
+	if err != nil {
+		return
+	}
+	if err = b.MarkRead(ctx.R.Context(), uid, n.ID); err != nil {
+		return
+	}
+	r.ReloadPortals = []string{presets.NotificationCenterPortalName}
+	if n.Link != "" {
+		r.PushState = web.Location(nil).URL(n.Link)
+	}
+	return
+}
+
+func (b *Builder) markAllRead(ctx *web.EventContext) (r web.EventResponse, err error) {
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), ColorError)
+			err = nil
+		}
+	}()
+
+	uid, err := b.currentUserID(ctx.R.Context())
+	if err != nil {
+		return
+	}
+	if err = b.MarkAllRead(ctx.R.Context(), uid); err != nil {
+		return
+	}
+	r.ReloadPortals = []string{presets.NotificationCenterPortalName}
+	return
+}
@@ -0,0 +1,197 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultRetention        = 90 * 24 * time.Hour
+	DefaultUnreadRetention  = 365 * 24 * time.Hour
+	DefaultMaxCountInCenter = 20
+)
+
+type Builder struct {
+	db                *gorm.DB
+	currentUserIDFunc func(ctx context.Context) (string, error)
+	roleUsersFunc     func(ctx context.Context, roles []string) ([]string, error)
+	retention         time.Duration
+	unreadRetention   time.Duration
+	maxCountInCenter  int
+}
+
+func New(db *gorm.DB, currentUserIDFunc func(ctx context.Context) (string, error)) *Builder {
+	return &Builder{
+		db:                db,
+		currentUserIDFunc: currentUserIDFunc,
+		retention:         DefaultRetention,
+		unreadRetention:   DefaultUnreadRetention,
+		maxCountInCenter:  DefaultMaxCountInCenter,
+	}
+}
+
+// RoleUsersFunc resolves role names to user ids, it is required by SendToRoles
+func (b *Builder) RoleUsersFunc(v func(ctx context.Context, roles []string) ([]string, error)) *Builder {
+	b.roleUsersFunc = v
+	return b
+}
+
+// Retention sets how long read notifications are kept before Cleanup removes them
+func (b *Builder) Retention(v time.Duration) *Builder {
+	b.retention = v
+	return b
+}
+
+// UnreadRetention sets how long unread notifications are kept before Cleanup removes them,
+// it should be longer than the retention so that users have time to see them
+func (b *Builder) UnreadRetention(v time.Duration) *Builder {
+	b.unreadRetention = v
+	return b
+}
+
+func (b *Builder) MaxCountInCenter(v int) *Builder {
+	b.maxCountInCenter = v
+	return b
+}
+
+func (b *Builder) GetDB() *gorm.DB {
+	return b.db
+}
+
+func (b *Builder) AutoMigrate() error {
+	return AutoMigrate(b.db)
+}
+
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Notification{})
+}
+
+func (b *Builder) currentUserID(ctx context.Context) (string, error) {
+	if b.currentUserIDFunc == nil {
+		return "", errors.New("notification: current user id func is not set")
+	}
+	uid, err := b.currentUserIDFunc(ctx)
+	if err != nil {
+		return "", err
+	}
+	if uid == "" {
+		return "", errors.New("notification: current user id is empty")
+	}
+	return uid, nil
+}
+
+// SendToUsers delivers one notification per recipient
+func (b *Builder) SendToUsers(ctx context.Context, msg *Message, userIDs ...string) error {
+	if msg == nil || msg.Title == "" {
+		return errors.New("notification: title is required")
+	}
+	userIDs = lo.Uniq(lo.Compact(userIDs))
+	if len(userIDs) == 0 {
+		return nil
+	}
+	ns := lo.Map(userIDs, func(uid string, _ int) *Notification {
+		return msg.toNotification(uid)
+	})
+	return b.db.WithContext(ctx).Create(&ns).Error
+}
+
+// SendToRoles resolves roles to users via RoleUsersFunc and delivers to each of them
+func (b *Builder) SendToRoles(ctx context.Context, msg *Message, roles ...string) error {
+	if b.roleUsersFunc == nil {
+		return errors.New("notification: role users func is not set")
+	}
+	userIDs, err := b.roleUsersFunc(ctx, roles)
+	if err != nil {
+		return err
+	}
+	return b.SendToUsers(ctx, msg, userIDs...)
+}
+
+func (b *Builder) UnreadCount(ctx context.Context, userID string) (count int64, err error) {
+	err = b.db.WithContext(ctx).Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return
+}
+
+// List returns the latest notifications of the user, unread ones first
+func (b *Builder) List(ctx context.Context, userID string, limit int) (ns []*Notification, err error) {
+	g := b.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("read_at IS NOT NULL, created_at DESC")
+	if limit > 0 {
+		g = g.Limit(limit)
+	}
+	err = g.Find(&ns).Error
+	return
+}
+
+// Get returns the notification of the user with id
+func (b *Builder) Get(ctx context.Context, userID string, id uint) (n *Notification, err error) {
+	n = &Notification{}
+	if err = b.db.WithContext(ctx).Where("user_id = ? AND id = ?", userID, id).First(n).Error; err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (b *Builder) MarkRead(ctx context.Context, userID string, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return b.db.WithContext(ctx).Model(&Notification{}).
+		Where("user_id = ? AND id IN ? AND read_at IS NULL", userID, ids).
+		Update("read_at", b.db.NowFunc()).Error
+}
+
+func (b *Builder) MarkAllRead(ctx context.Context, userID string) error {
+	return b.db.WithContext(ctx).Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", b.db.NowFunc()).Error
+}
+
+// Cleanup permanently deletes read notifications older than the retention and unread ones
+// older than the unread retention, a zero duration keeps them forever
+func (b *Builder) Cleanup(ctx context.Context) (deleted int64, err error) {
+	now := b.db.NowFunc()
+	for _, c := range []struct {
+		cond      string
+		retention time.Duration
+	}{
+		{"read_at IS NOT NULL AND created_at < ?", b.retention},
+		{"read_at IS NULL AND created_at < ?", b.unreadRetention},
+	} {
+		if c.retention <= 0 {
+			continue
+		}
+		result := b.db.WithContext(ctx).Unscoped().Where(c.cond, now.Add(-c.retention)).Delete(&Notification{})
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+	return
+}
+
+// RunCleanup calls Cleanup every interval until ctx is done
+func (b *Builder) RunCleanup(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := b.Cleanup(ctx); err != nil {
+				log.Printf("notification: cleanup error: %s\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
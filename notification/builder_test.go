@@ -0,0 +1,157 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/testenv"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var db *gorm.DB
+
+func TestMain(m *testing.M) {
+	env, err := testenv.New().DBEnable(true).SetUp()
+	if err != nil {
+		panic(err)
+	}
+	defer env.TearDown()
+	db = env.DB
+	db.Logger = db.Logger.LogMode(logger.Info)
+
+	if err = AutoMigrate(db); err != nil {
+		panic(err)
+	}
+	m.Run()
+}
+
+func resetNotifications(t *testing.T) {
+	require.NoError(t, db.Exec("DELETE FROM notifications").Error)
+}
+
+func TestSendAndRead(t *testing.T) {
+	resetNotifications(t)
+	ctx := context.Background()
+	b := New(db, nil).RoleUsersFunc(func(ctx context.Context, roles []string) ([]string, error) {
+		return []string{"1", "2"}, nil
+	})
+
+	require.Error(t, b.SendToUsers(ctx, &Message{}, "1"))
+	require.NoError(t, b.SendToUsers(ctx, &Message{Title: "hello"}, "1", "1", ""))
+	require.NoError(t, b.SendToRoles(ctx, &Message{Title: "to roles", Severity: SeverityWarning}, "admin"))
+
+	count, err := b.UnreadCount(ctx, "1")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+
+	ns, err := b.List(ctx, "1", 0)
+	require.NoError(t, err)
+	require.Len(t, ns, 2)
+	require.NoError(t, b.MarkRead(ctx, "1", ns[0].ID))
+	// the notifications of other users are not changed
+	require.NoError(t, b.MarkRead(ctx, "2", ns[1].ID))
+
+	ns, err = b.List(ctx, "1", 0)
+	require.NoError(t, err)
+	require.False(t, ns[0].IsRead(), "unread notifications are listed first")
+	require.True(t, ns[1].IsRead())
+
+	require.NoError(t, b.MarkAllRead(ctx, "2"))
+	count, err = b.UnreadCount(ctx, "2")
+	require.NoError(t, err)
+	require.EqualValues(t, 0, count)
+}
+
+func TestMarkReadLink(t *testing.T) {
+	resetNotifications(t)
+	ctx := context.Background()
+	b := New(db, func(ctx context.Context) (string, error) { return "1", nil })
+	require.NoError(t, b.SendToUsers(ctx, &Message{Title: "mine", Link: "/admin/pages/1"}, "1"))
+	require.NoError(t, b.SendToUsers(ctx, &Message{Title: "other", Link: "/admin/pages/2"}, "2"))
+	var mine, other Notification
+	require.NoError(t, db.First(&mine, "user_id = ?", "1").Error)
+	require.NoError(t, db.First(&other, "user_id = ?", "2").Error)
+
+	markRead := func(id uint) (web.EventResponse, error) {
+		r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/?%s=%d&link=https://evil.example.com", paramNotificationID, id), nil)
+		return b.markRead(&web.EventContext{R: r})
+	}
+	// the stored link is followed, not the one of the request
+	r, err := markRead(mine.ID)
+	require.NoError(t, err)
+	require.NotNil(t, r.PushState)
+	require.Equal(t, "/admin/pages/1", r.PushState.MyURL)
+	n, err := b.Get(ctx, "1", mine.ID)
+	require.NoError(t, err)
+	require.True(t, n.IsRead())
+
+	// the notifications of other users are not found
+	r, err = markRead(other.ID)
+	require.NoError(t, err)
+	require.Nil(t, r.PushState)
+	_, err = b.Get(ctx, "1", other.ID)
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestCleanup(t *testing.T) {
+	resetNotifications(t)
+	ctx := context.Background()
+	now := db.NowFunc()
+	read := now.Add(-time.Hour)
+	for _, n := range []*Notification{
+		{UserID: "1", Title: "old read", ReadAt: &read, Model: gorm.Model{CreatedAt: now.Add(-48 * time.Hour)}},
+		{UserID: "1", Title: "new read", ReadAt: &read, Model: gorm.Model{CreatedAt: now.Add(-time.Hour)}},
+		{UserID: "1", Title: "old unread", Model: gorm.Model{CreatedAt: now.Add(-96 * time.Hour)}},
+		{UserID: "1", Title: "new unread", Model: gorm.Model{CreatedAt: now.Add(-48 * time.Hour)}},
+	} {
+		require.NoError(t, db.Create(n).Error)
+	}
+
+	b := New(db, nil).Retention(24 * time.Hour).UnreadRetention(72 * time.Hour)
+	deleted, err := b.Cleanup(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, deleted)
+
+	var titles []string
+	require.NoError(t, db.Model(&Notification{}).Order("title").Pluck("title", &titles).Error)
+	require.Equal(t, []string{"new read", "new unread"}, titles)
+
+	deleted, err = New(db, nil).Retention(0).UnreadRetention(0).Cleanup(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, deleted)
+}
+
+type testMessages struct {
+	Greeting string
+}
+
+func TestTranslatedTitle(t *testing.T) {
+	const module i18n.ModuleKey = "test"
+	en := &testMessages{Greeting: "Hello {name}"}
+	msg := NewMessage(module, en, "Greeting", "{name}", "Ann")
+	require.Equal(t, "Hello Ann", msg.Title)
+	n := msg.toNotification("1")
+
+	ib := i18n.New().SupportLanguages(language.English, language.Japanese).
+		RegisterForModule(language.English, module, en).
+		RegisterForModule(language.Japanese, module, &testMessages{Greeting: "こんにちは {name}"})
+	title := func(n *Notification, lang string) (r string) {
+		ib.EnsureLanguage(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			r = n.TranslatedTitle(req)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?lang="+lang, nil))
+		return
+	}
+	require.Equal(t, "こんにちは Ann", title(n, "ja"))
+	require.Equal(t, "Hello Ann", title(n, "en"))
+	require.Equal(t, "plain", title(&Notification{Title: "plain"}, "ja"))
+	require.Equal(t, "stored", title(&Notification{Title: "stored", TitleModule: string(module), TitleKey: "Missing"}, "ja"))
+}
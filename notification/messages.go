@@ -0,0 +1,25 @@
+package notification
+
+type Messages struct {
+	Notifications   string
+	NoNotifications string
+	MarkAllAsRead   string
+}
+
+var Messages_en_US = &Messages{
+	Notifications:   "Notifications",
+	NoNotifications: "No notifications",
+	MarkAllAsRead:   "Mark all as read",
+}
+
+var Messages_zh_CN = &Messages{
+	Notifications:   "通知",
+	NoNotifications: "暂无通知",
+	MarkAllAsRead:   "全部标为已读",
+}
+
+var Messages_ja_JP = &Messages{
+	Notifications:   "通知",
+	NoNotifications: "通知はありません",
+	MarkAllAsRead:   "すべて既読にする",
+}
@@ -0,0 +1,114 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/qor5/x/v3/i18n"
+	"github.com/sunfmin/reflectutils"
+	"gorm.io/gorm"
+)
+
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeveritySuccess Severity = "success"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+type Notification struct {
+	gorm.Model
+
+	UserID   string `gorm:"index;not null"`
+	Title    string `gorm:"not null"`
+	Body     string
+	Link     string
+	Severity Severity   `gorm:"default:info"`
+	ReadAt   *time.Time `gorm:"index"`
+	// TitleModule, TitleKey and TitleArgs translate the title to the language of the recipient, see NewMessage
+	TitleModule string
+	TitleKey    string
+	TitleArgs   string `gorm:"type:text"`
+}
+
+func (n *Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
+
+// TranslatedTitle is the title in the language of the request, Title when it has no translation
+func (n *Notification) TranslatedTitle(r *http.Request) string {
+	if n.TitleKey == "" {
+		return n.Title
+	}
+	msgr := i18n.MustGetModuleMessages(r, i18n.ModuleKey(n.TitleModule), nil)
+	if msgr == nil {
+		return n.Title
+	}
+	tmpl, err := reflectutils.Get(msgr, n.TitleKey)
+	if err != nil {
+		return n.Title
+	}
+	s, ok := tmpl.(string)
+	if !ok || s == "" {
+		return n.Title
+	}
+	var args []string
+	if n.TitleArgs != "" {
+		_ = json.Unmarshal([]byte(n.TitleArgs), &args)
+	}
+	return strings.NewReplacer(args...).Replace(s)
+}
+
+// Message is the payload of a notification before it is delivered to recipients.
+type Message struct {
+	Title    string
+	Body     string
+	Link     string
+	Severity Severity
+
+	TitleModule i18n.ModuleKey
+	TitleKey    string
+	TitleArgs   []string
+}
+
+// NewMessage returns a message whose title is the field key of the Messages of module with the placeholders
+// of args, in pairs like strings.NewReplacer, replaced. The title is translated when it is shown to the
+// recipient, en are the English Messages of module giving the stored Title.
+func NewMessage(module i18n.ModuleKey, en any, key string, args ...string) *Message {
+	msg := &Message{
+		TitleModule: module,
+		TitleKey:    key,
+		TitleArgs:   args,
+	}
+	if tmpl, err := reflectutils.Get(en, key); err == nil {
+		if s, ok := tmpl.(string); ok {
+			msg.Title = strings.NewReplacer(args...).Replace(s)
+		}
+	}
+	return msg
+}
+
+func (m *Message) toNotification(userID string) *Notification {
+	severity := m.Severity
+	if severity == "" {
+		severity = SeverityInfo
+	}
+	n := &Notification{
+		UserID:      userID,
+		Title:       m.Title,
+		Body:        m.Body,
+		Link:        m.Link,
+		Severity:    severity,
+		TitleModule: string(m.TitleModule),
+		TitleKey:    m.TitleKey,
+	}
+	if len(m.TitleArgs) > 0 {
+		args, _ := json.Marshal(m.TitleArgs)
+		n.TitleArgs = string(args)
+	}
+	return n
+}
@@ -0,0 +1,500 @@
+package pagebuilder
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	. "github.com/qor5/x/v3/ui/vuetify"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/media/base"
+	"github.com/qor5/admin/v3/media/media_library"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/presets/actions"
+	"github.com/qor5/admin/v3/publish"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	A11yAuditDialogEvent = "page_builder_A11yAuditDialogEvent"
+
+	A11ySeverityError   = "error"
+	A11ySeverityWarning = "warning"
+
+	A11yRuleImageAlt      = "image-alt"
+	A11yRuleHeadingOrder  = "heading-order"
+	A11yRuleEmptyLink     = "empty-link"
+	A11yRuleColorContrast = "color-contrast"
+	A11yRuleHTMLLang      = "html-lang"
+
+	// a11yMinContrast is the WCAG AA ratio of normal text, the large text one is an error below it
+	a11yMinContrast      = 4.5
+	a11yMinLargeContrast = 3.0
+	a11ySnippetMaxLength = 120
+)
+
+// A11yIssue is a WCAG issue found in the rendered HTML of a page, ContainerDataID is empty for the ones
+// of the page layout
+type A11yIssue struct {
+	Rule            string
+	Severity        string
+	ContainerDataID string
+	Detail          string
+	Snippet         string
+}
+
+// A11yError refuses the publishing of a page with accessibility errors
+type A11yError struct {
+	Errors int
+	// lang is the language of the request refused
+	lang language.Tag
+}
+
+func (e *A11yError) Error() string {
+	msgr := Messages_en_US
+	switch e.lang {
+	case language.SimplifiedChinese:
+		msgr = Messages_zh_CN
+	case language.Japanese:
+		msgr = Messages_ja_JP
+	}
+	return fmt.Sprintf(msgr.A11yPublishBlockedTemplate, e.Errors)
+}
+
+// AccessibilityBlocksPublish refuses publishing the pages with accessibility errors, from the admin as well as
+// by the scheduled and the automatic republishing, which keep the published version until the errors are fixed
+func (b *Builder) AccessibilityBlocksPublish(v bool) (r *Builder) {
+	b.a11yBlocksPublish = v
+	return b
+}
+
+var a11yNamedColors = map[string][3]float64{
+	"black":  {0, 0, 0},
+	"white":  {255, 255, 255},
+	"red":    {255, 0, 0},
+	"green":  {0, 128, 0},
+	"blue":   {0, 0, 255},
+	"yellow": {255, 255, 0},
+	"orange": {255, 165, 0},
+	"gray":   {128, 128, 128},
+	"grey":   {128, 128, 128},
+	"silver": {192, 192, 192},
+}
+
+// parseA11yColor parses the opaque colors of CSS, the others are not checked
+func parseA11yColor(v string) (c [3]float64, ok bool) {
+	v = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), "!important")))
+	if c, ok = a11yNamedColors[v]; ok {
+		return
+	}
+	if strings.HasPrefix(v, "#") {
+		hex := v[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return c, false
+		}
+		for i := 0; i < 3; i++ {
+			n, err := strconv.ParseUint(hex[i*2:i*2+2], 16, 8)
+			if err != nil {
+				return c, false
+			}
+			c[i] = float64(n)
+		}
+		return c, true
+	}
+	for _, fn := range []string{"rgb(", "rgba("} {
+		if !strings.HasPrefix(v, fn) || !strings.HasSuffix(v, ")") {
+			continue
+		}
+		parts := strings.FieldsFunc(v[len(fn):len(v)-1], func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) < 3 || (len(parts) == 4 && parts[3] != "1") || len(parts) > 4 {
+			return c, false
+		}
+		for i := 0; i < 3; i++ {
+			n, err := strconv.ParseFloat(parts[i], 64)
+			if err != nil {
+				return c, false
+			}
+			c[i] = n
+		}
+		return c, true
+	}
+	return c, false
+}
+
+func a11yLuminance(c [3]float64) float64 {
+	var l [3]float64
+	for i, v := range c {
+		v /= 255
+		if v <= 0.03928 {
+			l[i] = v / 12.92
+		} else {
+			l[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*l[0] + 0.7152*l[1] + 0.0722*l[2]
+}
+
+// a11yContrast is the WCAG contrast ratio of two colors, from 1 to 21
+func a11yContrast(a, b [3]float64) float64 {
+	la, lb := a11yLuminance(a), a11yLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// a11yStyle returns the text and background colors declared by the style attribute
+func a11yStyle(style string) (fg, bg *[3]float64) {
+	for _, decl := range strings.Split(style, ";") {
+		name, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		c, ok := parseA11yColor(value)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "color":
+			fg = &c
+		case "background-color", "background":
+			bg = &c
+		}
+	}
+	return
+}
+
+func a11yText(n *html.Node) string {
+	var s strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			s.WriteString(n.Data)
+		case html.ElementNode:
+			if htmlAttr(n, "aria-hidden") == "true" {
+				return
+			}
+			if n.Data == "img" {
+				s.WriteString(htmlAttr(n, "alt"))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(s.String())
+}
+
+func hasDirectText(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode && strings.TrimSpace(c.Data) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func a11ySnippet(n *html.Node) string {
+	var s strings.Builder
+	s.WriteString("<" + n.Data)
+	for _, attr := range n.Attr {
+		if attr.Key == containerDataIDAttr {
+			continue
+		}
+		fmt.Fprintf(&s, " %s=%q", attr.Key, attr.Val)
+	}
+	s.WriteString(">")
+	if text := a11yText(n); text != "" {
+		s.WriteString(text)
+	}
+	r := []rune(s.String())
+	if len(r) > a11ySnippetMaxLength {
+		return string(r[:a11ySnippetMaxLength]) + "…"
+	}
+	return string(r)
+}
+
+// auditA11y checks the document for missing lang and alt texts, empty links, skipped heading levels and the
+// contrast of the colors declared in the style attributes
+func auditA11y(doc *html.Node) (issues []*A11yIssue) {
+	var (
+		lastHeading int
+		walk        func(n *html.Node, containerID string, fg, bg *[3]float64)
+	)
+	add := func(rule, severity, containerID, detail string, n *html.Node) {
+		issues = append(issues, &A11yIssue{Rule: rule, Severity: severity, ContainerDataID: containerID, Detail: detail, Snippet: a11ySnippet(n)})
+	}
+	walk = func(n *html.Node, containerID string, fg, bg *[3]float64) {
+		if n.Type == html.ElementNode {
+			if id := htmlAttr(n, containerDataIDAttr); id != "" {
+				containerID = id
+			}
+			declaredFg, declaredBg := a11yStyle(htmlAttr(n, "style"))
+			if declaredFg != nil {
+				fg = declaredFg
+			}
+			if declaredBg != nil {
+				bg = declaredBg
+			}
+			if (declaredFg != nil || declaredBg != nil) && fg != nil && bg != nil && hasDirectText(n) {
+				if ratio := a11yContrast(*fg, *bg); ratio < a11yMinContrast {
+					add(A11yRuleColorContrast, lo.Ternary(ratio < a11yMinLargeContrast, A11ySeverityError, A11ySeverityWarning),
+						containerID, fmt.Sprintf("%.2f:1", ratio), n)
+				}
+			}
+			hidden := htmlAttr(n, "aria-hidden") == "true"
+			switch n.Data {
+			case "html":
+				if strings.TrimSpace(htmlAttr(n, "lang")) == "" {
+					add(A11yRuleHTMLLang, A11ySeverityError, "", "", &html.Node{Type: html.ElementNode, Data: "html"})
+				}
+			case "img":
+				role := htmlAttr(n, "role")
+				if _, ok := htmlAttrOK(n, "alt"); !ok && !hidden && role != "presentation" && role != "none" {
+					add(A11yRuleImageAlt, A11ySeverityError, containerID, htmlAttr(n, "src"), n)
+				}
+			case "a":
+				if _, ok := htmlAttrOK(n, "href"); ok && !hidden && a11yText(n) == "" &&
+					htmlAttr(n, "aria-label") == "" && htmlAttr(n, "aria-labelledby") == "" && htmlAttr(n, "title") == "" {
+					add(A11yRuleEmptyLink, A11ySeverityError, containerID, htmlAttr(n, "href"), n)
+				}
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				level := int(n.Data[1] - '0')
+				if lastHeading > 0 && level > lastHeading+1 {
+					add(A11yRuleHeadingOrder, A11ySeverityWarning, containerID, fmt.Sprintf("h%d → h%d", lastHeading, level), n)
+				}
+				lastHeading = level
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, containerID, fg, bg)
+		}
+	}
+	walk(doc, "", nil, nil)
+	return
+}
+
+func htmlAttrOK(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func a11yErrors(issues []*A11yIssue) (n int) {
+	for _, issue := range issues {
+		if issue.Severity == A11ySeverityError {
+			n++
+		}
+	}
+	return
+}
+
+// auditMediaAlt flags the images of the media boxes of the containers without a description, the description
+// is their alt text and the containers may render it empty, which hides the image from the screen readers.
+// The images already flagged in the rendering are left out
+func (b *ModelBuilder) auditMediaAlt(db *gorm.DB, cons []*Container, rendered []*A11yIssue) (issues []*A11yIssue, err error) {
+	flagged := map[[2]string]bool{}
+	for _, issue := range rendered {
+		if issue.Rule == A11yRuleImageAlt {
+			flagged[[2]string{issue.ContainerDataID, issue.Detail}] = true
+		}
+	}
+	for _, c := range cons {
+		cb := b.builder.containerByName(c.ModelName)
+		if c.Hidden || cb == nil {
+			continue
+		}
+		model := cb.NewModel()
+		if err = db.First(model, "id = ?", c.ModelID).Error; err != nil {
+			return
+		}
+		dataID := cb.getContainerDataID(int(c.ModelID))
+		walkMediaBoxes(reflect.ValueOf(model), func(box *media_library.MediaBox) {
+			if box.Url == "" || strings.TrimSpace(box.Description) != "" || flagged[[2]string{dataID, box.Url}] ||
+				!base.IsImageFormat(lo.Ternary(box.FileName != "", box.FileName, box.Url)) {
+				return
+			}
+			issues = append(issues, &A11yIssue{
+				Rule:            A11yRuleImageAlt,
+				Severity:        A11ySeverityError,
+				ContainerDataID: dataID,
+				Detail:          box.Url,
+				Snippet:         fmt.Sprintf("<img src=%q>", box.Url),
+			})
+		})
+	}
+	return
+}
+
+// auditAccessibility audits the published rendering of the page or of its variant and the descriptions
+// of the images of its containers
+func (b *ModelBuilder) auditAccessibility(slug, variant string) (issues []*A11yIssue, err error) {
+	doc, err := html.Parse(strings.NewReader(b.previewHTML(slug, variant)))
+	if err != nil {
+		return
+	}
+	issues = auditA11y(doc)
+	pageID, pageVersion, locale := b.primaryColumnValuesBySlug(slug)
+	cons, err := b.variantContainers(b.db, pageID, variantPageVersion(pageVersion, variant), locale)
+	if err != nil {
+		return
+	}
+	media, err := b.auditMediaAlt(b.db, cons, issues)
+	if err != nil {
+		return
+	}
+	return append(issues, media...), nil
+}
+
+// checkAccessibility is the publish check refusing the pages with accessibility errors, in the language
+// of the request publishing them, the scheduled and the automatic republishing are refused in English
+func (b *ModelBuilder) checkAccessibility(ctx context.Context, obj any) error {
+	if utils.GetObjectName(obj) != b.name {
+		return nil
+	}
+	slug, ok := obj.(presets.SlugEncoder)
+	if !ok {
+		return nil
+	}
+	issues, err := b.auditAccessibility(slug.PrimarySlug(), "")
+	if err != nil {
+		return err
+	}
+	if n := a11yErrors(issues); n > 0 {
+		return &A11yError{Errors: n, lang: i18n.LanguageTagFromContext(ctx, language.English)}
+	}
+	return nil
+}
+
+func a11yRuleText(rule string, msgr *Messages) string {
+	switch rule {
+	case A11yRuleImageAlt:
+		return msgr.A11yImageAlt
+	case A11yRuleHeadingOrder:
+		return msgr.A11yHeadingOrder
+	case A11yRuleEmptyLink:
+		return msgr.A11yEmptyLink
+	case A11yRuleColorContrast:
+		return msgr.A11yColorContrast
+	}
+	return msgr.A11yHTMLLang
+}
+
+func (b *ModelBuilder) a11yButton(ctx *web.EventContext) h.HTMLComponent {
+	msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+	return VBtn("").Icon("mdi-human").Variant(VariantText).Size(SizeSmall).
+		Attr("title", msgr.A11yAudit).
+		Attr("@click", web.Plaid().EventFunc(A11yAuditDialogEvent).MergeQuery(true).Query(paramStatus, ctx.Param(paramStatus)).Go())
+}
+
+// a11yFocusScript selects the container of the issue in the editor and scrolls to it
+func (b *ModelBuilder) a11yFocusScript(ctx *web.EventContext, c *Container) string {
+	cb := b.builder.ContainerByName(c.ModelName)
+	if cb == nil {
+		return ""
+	}
+	dataID := cb.getContainerDataID(int(c.ModelID))
+	script := fmt.Sprintf("locals.a11yDialog = false;vars.%s=%q;", paramContainerDataID, dataID)
+	if status := ctx.Param(paramStatus); status == "" || status == publish.StatusDraft {
+		script += web.Plaid().
+			EventFunc(EditContainerEvent).
+			Query(paramContainerUri, cb.mb.Info().ListingHref()).
+			Query(paramContainerID, fmt.Sprint(c.ModelID)).
+			Query(presets.ParamOverlay, actions.Content).
+			Query(presets.ParamPortalName, pageBuilderRightContentPortal).
+			Go() + ";"
+	}
+	return script + scrollToContainer(strconv.Quote(dataID))
+}
+
+func (b *ModelBuilder) a11yAuditDialog(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		msgr                        = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		pMsgr                       = presets.MustGetMessages(ctx.R)
+		pageID, pageVersion, locale = b.getPrimaryColumnValuesBySlug(ctx)
+		issues                      []*A11yIssue
+		cons                        []*Container
+		items                       []h.HTMLComponent
+	)
+	if issues, err = b.auditAccessibility(ctx.Param(presets.ParamID), ctx.Param(paramVariant)); err != nil {
+		return
+	}
+	if cons, err = b.variantContainers(b.db, pageID, pageVersion, locale); err != nil {
+		return
+	}
+	containers := map[string]*Container{}
+	for _, c := range cons {
+		if cb := b.builder.ContainerByName(c.ModelName); cb != nil {
+			containers[cb.getContainerDataID(int(c.ModelID))] = c
+		}
+	}
+	for _, issue := range issues {
+		var (
+			where = msgr.A11yPageLayout
+			click string
+		)
+		if c, ok := containers[issue.ContainerDataID]; ok {
+			where = c.DisplayName
+			click = b.a11yFocusScript(ctx, c)
+		}
+		title := a11yRuleText(issue.Rule, msgr)
+		if issue.Detail != "" {
+			title = fmt.Sprintf("%s (%s)", title, issue.Detail)
+		}
+		items = append(items,
+			VListItem(
+				VListItemTitle(h.Text(title)),
+				VListItemSubtitle(h.Text(where)),
+				h.Div(h.Text(issue.Snippet)).Class("text-caption text-grey text-truncate"),
+			).PrependIcon(lo.Ternary(issue.Severity == A11ySeverityError, "mdi-alert-circle", "mdi-alert")).
+				BaseColor(lo.Ternary(issue.Severity == A11ySeverityError, ColorError, ColorWarning)).
+				Attr("@click", click),
+		)
+	}
+	body := h.HTMLComponent(h.Div(h.Text(msgr.A11yNoIssues)).Class("text-body-2 pa-4"))
+	if len(items) > 0 {
+		body = VList(items...).Density(DensityCompact)
+	}
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+		Name: dialogPortalName,
+		Body: web.Scope(
+			VDialog(
+				VCard(
+					VCardTitle(h.Text(msgr.A11yAudit)),
+					VCardText(
+						h.Div(h.Text(fmt.Sprintf(msgr.A11ySummaryTemplate, a11yErrors(issues), len(issues)-a11yErrors(issues)))).Class("text-caption mb-2"),
+						body,
+					).Class("overflow-y-auto").Attr("style", "max-height: 70vh"),
+					VCardActions(
+						VSpacer(),
+						VBtn(pMsgr.OK).
+							Color(ColorPrimary).
+							Variant(VariantFlat).
+							Theme(ThemeDark).
+							Attr("@click", "locals.a11yDialog = false"),
+					),
+				),
+			).MaxWidth("640px").
+				Attr("v-model", "locals.a11yDialog"),
+		).Init("{a11yDialog:true}").VSlot("{locals}"),
+	})
+	return
+}
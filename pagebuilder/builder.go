@@ -1,6 +1,7 @@
 package pagebuilder
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/iancoleman/strcase"
 	"github.com/jinzhu/inflection"
@@ -22,6 +24,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/oss"
 	"github.com/qor5/x/v3/perm"
 	. "github.com/qor5/x/v3/ui/vuetify"
 	vx "github.com/qor5/x/v3/ui/vuetifyx"
@@ -37,6 +40,7 @@ import (
 	"github.com/qor5/admin/v3/seo"
 	"github.com/qor5/admin/v3/tiptap"
 	"github.com/qor5/admin/v3/utils"
+	"github.com/qor5/admin/v3/worker"
 )
 
 type RenderInput struct {
@@ -46,6 +50,7 @@ type RenderInput struct {
 	ContainerId string
 	DisplayName string
 	Obj         interface{}
+	Breadcrumbs []*Breadcrumb
 }
 
 type RenderFunc func(obj interface{}, input *RenderInput, ctx *web.EventContext) h.HTMLComponent
@@ -71,6 +76,7 @@ type (
 		LocaleCode        string
 		EditorCss         []h.HTMLComponent
 		IsPreview         bool
+		Breadcrumbs       []*Breadcrumb
 	}
 )
 
@@ -108,6 +114,15 @@ type Builder struct {
 	categoryInstall               presets.ModelInstallFunc
 	devices                       []Device
 	fields                        []string
+	redirectsEnabled              bool
+	redirectStorage               oss.StorageInterface
+	redirects                     *redirectCache
+	linkAuditWorker               *worker.Builder
+	childPagesWorker              *worker.Builder
+	linkAuditIgnore               []string
+	a11yBlocksPublish             bool
+	deliveryPreviewSecret         []byte
+	bundleMaxSize                 int64
 }
 
 const (
@@ -137,6 +152,7 @@ func newBuilder(prefix string, db *gorm.DB, b *presets.Builder) *Builder {
 		expendContainers:  true,
 		pageEnabled:       true,
 		previewContainer:  true,
+		redirects:         &redirectCache{},
 	}
 	r.templateInstall = r.defaultTemplateInstall
 	r.categoryInstall = r.defaultCategoryInstall
@@ -207,6 +223,10 @@ func AutoMigrate(db *gorm.DB) (err error) {
 		&Container{},
 		&Category{},
 		&DemoContainer{},
+		&EditorOperation{},
+		&PageVariant{},
+		&Redirect{},
+		&BrokenLink{},
 	); err != nil {
 		return
 	}
@@ -322,10 +342,11 @@ func (b *Builder) DisabledNormalContainersGroup(v bool) (r *Builder) {
 
 func (b *Builder) Model(mb *presets.ModelBuilder) (r *ModelBuilder) {
 	r = &ModelBuilder{
-		mb:      mb,
-		editor:  b.ps.Model(mb.NewModel()).URIName(mb.Info().URIName()),
-		builder: b,
-		db:      b.db,
+		mb:       mb,
+		editor:   b.ps.Model(mb.NewModel()).URIName(mb.Info().URIName()),
+		builder:  b,
+		db:       b.db,
+		variants: &variantCache{},
 	}
 	b.models = append(b.models, r)
 	r.setName()
@@ -345,6 +366,7 @@ func (b *Builder) ModelInstall(pb *presets.Builder, mb *presets.ModelBuilder) (e
 	b.configEditor(r)
 	b.configPublish(r)
 	b.configDetail(r)
+	r.configBundle()
 	return nil
 }
 
@@ -368,8 +390,7 @@ func (b *Builder) configPageSaver(pb *presets.Builder) (mb *presets.ModelBuilder
 			if p.Slug != "" {
 				p.Slug = path.Clean(p.Slug)
 			}
-			funcName := ctx.R.FormValue(web.EventFuncIDName)
-			if funcName == publish.EventDuplicateVersion {
+			if publish.IsDuplicatingVersion(ctx) {
 				var fromPage Page
 				eb.Fetcher(&fromPage, ctx.Param(presets.ParamID), ctx)
 				p.SEO = fromPage.SEO
@@ -391,10 +412,17 @@ func (b *Builder) Install(pb *presets.Builder) (err error) {
 		b.configTemplateAndPage(pb, r)
 		b.configSharedContainer(pb, r)
 		b.configDetail(r)
+		r.configBundle()
 		categoryM := pb.Model(&Category{}).URIName("page_categories").Label("Page Categories")
 		if err = b.categoryInstall(pb, categoryM); err != nil {
 			return
 		}
+		if b.redirectsEnabled {
+			b.installRedirects(pb)
+		}
+		if b.linkAuditWorker != nil {
+			b.installLinkAudit(pb)
+		}
 	}
 	b.configDemoContainer(pb)
 	b.preparePlugins()
@@ -434,7 +462,16 @@ func (b *Builder) configPublish(r *ModelBuilder) {
 	if publisher != nil {
 		publisher.ContextValueFuncs(r.ContextValueProvider).Activity(b.ab).AfterInstall(func() {
 			r.mb.Editing().SidePanelFunc(nil).ActionsFunc(nil).TabsPanels()
+		}).WrapVersionDiff(r.wrapVersionDiff).WrapPrune(r.wrapPrune)
+		if _, ok := r.mb.NewModel().(publish.StatusInterface); ok {
+			publisher.ScheduleTask(r.scheduleTaskName(), r.republishScheduledContainers)
+		}
+		publisher.ScheduleTask(editorHistoryTaskName, func(ctx context.Context, _, _ time.Time) error {
+			return b.PurgeEditorHistory(ctx)
 		})
+		if b.a11yBlocksPublish && r.tb == nil {
+			publisher.PublishCheck(r.checkAccessibility)
+		}
 	}
 }
 
@@ -622,7 +659,28 @@ func (b *Builder) defaultCategoryInstall(pb *presets.Builder, pm *presets.ModelB
 		)
 	})
 
-	eb := pm.Editing("Name", "Path", "Description")
+	eb := pm.Editing("Name", "ParentID", "Path", "Description")
+	eb.Field("ParentID").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		var (
+			c          = obj.(*Category)
+			categories []*Category
+			locale, _  = l10n.IsLocalizableFromContext(ctx.R.Context())
+			msgr       = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		)
+		g := db.Model(&Category{}).Where("locale_code = ?", locale).Order("path")
+		if c.ID != 0 {
+			g = g.Where("id <> ? AND path NOT LIKE ?", c.ID, strings.TrimSuffix(c.Path, "/")+"/%")
+		}
+		if err := g.Find(&categories).Error; err != nil {
+			panic(err)
+		}
+		categories = append([]*Category{{Path: msgr.PageTreeRoot}}, categories...)
+		return presets.SelectField(obj, field, ctx).
+			Multiple(false).Chips(false).
+			Label(msgr.ParentCategory).
+			Items(categories).ItemTitle("Path").ItemValue("ID").
+			Attr(presets.VFieldError(field.FormKey, c.ParentID, field.Errors)...)
+	})
 	eb.Field("Path").LazyWrapComponentFunc(func(in presets.FieldComponentFunc) presets.FieldComponentFunc {
 		return func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
 			comp := in(obj, field, ctx)
@@ -668,9 +726,34 @@ func (b *Builder) defaultCategoryInstall(pb *presets.Builder, pm *presets.ModelB
 
 	eb.WrapSaveFunc(func(in presets.SaveFunc) presets.SaveFunc {
 		return func(obj interface{}, id string, ctx *web.EventContext) (err error) {
-			c := obj.(*Category)
-			c.Path = path.Clean(c.Path)
-			return in(obj, id, ctx)
+			var (
+				c   = obj.(*Category)
+				old Category
+			)
+			if c.ID != 0 {
+				if err = db.Where("id = ? AND locale_code = ?", c.ID, c.LocaleCode).First(&old).Error; err != nil {
+					return
+				}
+			}
+			if c.Path, err = categoryPath(db, c); err != nil {
+				return
+			}
+			var ids []uint
+			if err = db.Transaction(func(tx *gorm.DB) (dbErr error) {
+				ctx.WithContextValue(gorm2op.CtxKeyDB{}, tx)
+				defer ctx.WithContextValue(gorm2op.CtxKeyDB{}, nil)
+				if dbErr = in(obj, id, ctx); dbErr != nil {
+					return
+				}
+				ids, dbErr = moveCategoryDescendants(tx, c, old.Path)
+				return
+			}); err != nil {
+				return
+			}
+			if b.publisher != nil && len(ids) > 0 {
+				b.queueCategoryPages(ctx.R.Context(), &CategoryPagesJobArgs{CategoryIDs: ids, LocaleCode: c.LocaleCode})
+			}
+			return
 		}
 	})
 	if b.ab != nil {
@@ -946,6 +1029,9 @@ type ContainerBuilder struct {
 	cover        string
 	group        string
 	onlyPages    bool
+
+	deviceOverrideFields []string
+	deliveryFields       []string
 }
 
 func (b *Builder) RegisterContainer(name string) (r *ContainerBuilder) {
@@ -1084,6 +1170,11 @@ func (b *ContainerBuilder) warpSaver() {
 					return
 				}
 			}
+			if id != "" && ctx.Param(presets.ParamPortalName) == pageBuilderRightContentPortal {
+				return b.builder.recordContainerUpdate(ctx, b, id, func() error {
+					return in(obj, id, ctx)
+				})
+			}
 			return in(obj, id, ctx)
 		}
 	})
@@ -1285,6 +1376,10 @@ func republishRelatedOnlinePages(pageURL string) web.EventFunc {
 
 func (b *Builder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	for _, mb := range b.models {
+		if r.URL.Path == b.prefix+"/"+mb.mb.Info().URIName()+bundleExportPath {
+			mb.serveExport(w, r)
+			return
+		}
 		if strings.Index(r.RequestURI, b.prefix+"/"+mb.mb.Info().URIName()+"/preview") >= 0 {
 			if mb.mb.Info().Verifier().Do(presets.PermGet).WithReq(r).IsAllowed() != nil {
 				_, _ = w.Write([]byte(perm.PermissionDenied.Error()))
@@ -1428,7 +1523,11 @@ func (b *Builder) deviceToggle(ctx *web.EventContext) h.HTMLComponent {
 			device = d.Name
 		}
 		comps = append(comps,
-			VBtn("").Icon(d.Icon).Color(ColorPrimary).Value(d.Name).
+			VBtn("").Icon(true).Color(ColorPrimary).Value(d.Name).
+				Children(
+					VBadge(VIcon(d.Icon)).Dot(true).Color(ColorWarning).
+						Attr(":model-value", fmt.Sprintf("!!vars.__pbDeviceCounts?.[%q]", d.Name)),
+				).
 				Disabled(d.Disabled).
 				BaseColor(ColorPrimary).Variant(VariantText).Class("mr-2"),
 		)
@@ -1448,6 +1547,7 @@ func (b *Builder) deviceToggle(ctx *web.EventContext) h.HTMLComponent {
 						PushState(true).MergeQuery(true).Query(paramsDevice, web.Var("toggleLocals.activeDevice")).RunPushState()).
 					Query(paramContainerDataID, web.Var(fmt.Sprintf("vars.%s", paramContainerDataID))).
 					Query(paramIsUpdate, false).
+					ThenScript(web.Plaid().EventFunc(ShowSortedContainerDrawerEvent).MergeQuery(true).Query(paramStatus, ctx.Param(paramStatus)).Go()).
 					Go(),
 			),
 	).VSlot("{ locals : toggleLocals}").Init(fmt.Sprintf(`{activeDevice: %q,devices:%v}`, device, h.JSONString(devices)))
@@ -0,0 +1,629 @@
+package pagebuilder
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	. "github.com/qor5/x/v3/ui/vuetify"
+	"github.com/sunfmin/reflectutils"
+	h "github.com/theplant/htmlgo"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/l10n"
+	"github.com/qor5/admin/v3/media/base"
+	"github.com/qor5/admin/v3/media/media_library"
+	mediaoss "github.com/qor5/admin/v3/media/oss"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/publish"
+	"github.com/qor5/admin/v3/utils"
+)
+
+// BundleFormatVersion is increased when a bundle written by an older version can no longer be imported
+const BundleFormatVersion = 1
+
+// DefaultBundleMaxSize is the size a bundle upload and the files it unzips may not exceed, see Builder.BundleMaxSize
+const DefaultBundleMaxSize = 100 << 20
+
+var ErrBundleTooLarge = errors.New("pagebuilder: the bundle is too large")
+
+const (
+	bundleFileName    = "bundle.json"
+	bundleMediaDir    = "media"
+	bundleImportField = "Bundle"
+	bundleExportPath  = "/export"
+	bundleImportName  = "Import"
+)
+
+// Bundle is a portable copy of a page or template with its containers and the media they use,
+// the IDs in it are the ones of the exporting database and are remapped on import
+type Bundle struct {
+	FormatVersion int
+	ModelName     string
+	ExportedAt    time.Time
+	Record        json.RawMessage
+	Category      *BundleCategory `json:",omitempty"`
+	Containers    []*BundleContainer
+	Media         []*BundleMedia `json:",omitempty"`
+}
+
+type BundleCategory struct {
+	Name string
+	Path string
+}
+
+type BundleContainer struct {
+	ModelName    string
+	DisplayName  string
+	DisplayOrder float64
+	Shared       bool
+	Hidden       bool
+	Devices      string     `json:",omitempty"`
+	Overrides    string     `json:",omitempty"`
+	StartAt      *time.Time `json:",omitempty"`
+	EndAt        *time.Time `json:",omitempty"`
+	Model        json.RawMessage
+}
+
+// BundleMedia is a media library record, File is its database value
+type BundleMedia struct {
+	ID           uint
+	SelectedType string
+	File         string
+
+	content []byte
+}
+
+// ImportResult reports the draft created by Import and what could not be imported as it is
+type ImportResult struct {
+	Record            any
+	UnknownContainers []string
+	Warnings          []string
+}
+
+func (b *ModelBuilder) exportURL(slug string) string {
+	return b.builder.prefix + "/" + b.mb.Info().URIName() + bundleExportPath + "?" + url.Values{presets.ParamID: {slug}}.Encode()
+}
+
+// BundleMaxSize is the size a bundle upload and the files it unzips may not exceed, DefaultBundleMaxSize by default
+func (b *Builder) BundleMaxSize(v int64) (r *Builder) {
+	b.bundleMaxSize = v
+	return b
+}
+
+// collectMediaIDs adds the media library IDs of the media boxes of obj to ids
+func collectMediaIDs(obj any, ids map[uint]bool) {
+	walkMediaBoxes(reflect.ValueOf(obj), func(box *media_library.MediaBox) {
+		if id, err := strconv.ParseUint(box.ID.String(), 10, 64); err == nil && id > 0 {
+			ids[uint(id)] = true
+		}
+	})
+}
+
+// remapMediaIDs replaces the exported media library IDs of the media boxes of obj with the imported ones
+func remapMediaIDs(obj any, ids map[uint]uint) {
+	walkMediaBoxes(reflect.ValueOf(obj), func(box *media_library.MediaBox) {
+		old, err := strconv.ParseUint(box.ID.String(), 10, 64)
+		if err != nil || old == 0 {
+			return
+		}
+		if id, ok := ids[uint(old)]; ok {
+			box.ID = json.Number(strconv.FormatUint(uint64(id), 10))
+		}
+	})
+}
+
+// Export returns the bundle of the record of slug with the media of the record, like its SEO image, and of its containers
+func (b *ModelBuilder) Export(ctx context.Context, slug string) (bundle *Bundle, err error) {
+	db := b.db.WithContext(ctx)
+	obj := b.mb.NewModel()
+	if err = utils.PrimarySluggerWhere(db, obj, slug).First(obj).Error; err != nil {
+		return
+	}
+	bundle = &Bundle{
+		FormatVersion: BundleFormatVersion,
+		ModelName:     b.name,
+		ExportedAt:    db.NowFunc(),
+	}
+	if bundle.Record, err = json.Marshal(obj); err != nil {
+		return
+	}
+
+	pageID, pageVersion, locale := b.primaryColumnValuesBySlug(slug)
+	if categoryID, _ := reflectutils.Get(obj, "CategoryID"); categoryID != nil && categoryID != uint(0) {
+		var category Category
+		if err = db.Where("id = ? AND locale_code = ?", categoryID, locale).First(&category).Error; err == nil {
+			bundle.Category = &BundleCategory{Name: category.Name, Path: category.Path}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+	}
+
+	var cons []*Container
+	if err = db.Order("display_order ASC").
+		Find(&cons, "page_id = ? AND page_version = ? AND locale_code = ? and page_model_name = ? ", pageID, pageVersion, locale, b.name).Error; err != nil {
+		return
+	}
+	mediaIDs := map[uint]bool{}
+	collectMediaIDs(obj, mediaIDs)
+	for _, c := range cons {
+		bc := &BundleContainer{
+			ModelName:    c.ModelName,
+			DisplayName:  c.DisplayName,
+			DisplayOrder: c.DisplayOrder,
+			Shared:       c.Shared,
+			Hidden:       c.Hidden,
+			Devices:      c.Devices,
+			Overrides:    c.DeviceOverrides,
+			StartAt:      c.ScheduledStartAt,
+			EndAt:        c.ScheduledEndAt,
+		}
+		bundle.Containers = append(bundle.Containers, bc)
+		cb := b.builder.containerByName(c.ModelName)
+		if cb == nil {
+			continue
+		}
+		model := cb.NewModel()
+		if err = db.First(model, "id = ?", c.ModelID).Error; err != nil {
+			return
+		}
+		collectMediaIDs(model, mediaIDs)
+		if bc.Model, err = json.Marshal(model); err != nil {
+			return
+		}
+	}
+
+	if len(mediaIDs) > 0 {
+		ids := make([]uint, 0, len(mediaIDs))
+		for id := range mediaIDs {
+			ids = append(ids, id)
+		}
+		var medias []*media_library.MediaLibrary
+		if err = db.Order("id").Find(&medias, ids).Error; err != nil {
+			return
+		}
+		for _, m := range medias {
+			file, err := m.File.Value()
+			if err != nil {
+				return nil, err
+			}
+			bundle.Media = append(bundle.Media, &BundleMedia{ID: m.ID, SelectedType: m.SelectedType, File: file.(string)})
+		}
+	}
+	return
+}
+
+func (b *Builder) containerByName(name string) *ContainerBuilder {
+	for _, cb := range b.containerBuilders {
+		if cb.name == name {
+			return cb
+		}
+	}
+	return nil
+}
+
+// walkMediaBoxes calls f with the media boxes in the fields of v, including the ones of nested structs and slices
+func walkMediaBoxes(v reflect.Value, f func(box *media_library.MediaBox)) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			walkMediaBoxes(v.Elem(), f)
+		}
+	case reflect.Struct:
+		if box, ok := v.Addr().Interface().(*media_library.MediaBox); ok {
+			f(box)
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkMediaBoxes(v.Field(i), f)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkMediaBoxes(v.Index(i), f)
+		}
+	}
+}
+
+// mediaPath is the path of the file in the media storage, the urls may be prefixed with its endpoint
+func mediaPath(fileURL string) string {
+	if strings.HasPrefix(fileURL, "//") {
+		fileURL = "http:" + fileURL
+	}
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// WriteZip writes the bundle as a zip with the original files of its media
+func (bundle *Bundle) WriteZip(ctx context.Context, w io.Writer) (err error) {
+	zw := zip.NewWriter(w)
+	fw, err := zw.Create(bundleFileName)
+	if err != nil {
+		return
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(bundle); err != nil {
+		return
+	}
+	for _, m := range bundle.Media {
+		var file media_library.MediaLibraryStorage
+		if err = file.Scan([]byte(m.File)); err != nil {
+			return
+		}
+		p := mediaPath(file.Url)
+		if p == "" {
+			continue
+		}
+		r, err := mediaoss.Storage.GetStream(ctx, p)
+		if err != nil {
+			// the media is still imported with its url, only the file is not copied
+			continue
+		}
+		fw, err := zw.Create(path.Join(bundleMediaDir, strconv.FormatUint(uint64(m.ID), 10), path.Base(p)))
+		if err == nil {
+			_, err = io.Copy(fw, r)
+		}
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// readLimited reads r, which may not exceed maxSize
+func readLimited(r io.Reader, maxSize int64) (data []byte, err error) {
+	if data, err = io.ReadAll(io.LimitReader(r, maxSize+1)); err != nil {
+		return
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrBundleTooLarge
+	}
+	return
+}
+
+// ReadBundle reads a bundle written by WriteZip, or a plain bundle.json. The upload and the files it unzips
+// may not exceed maxSize in total, DefaultBundleMaxSize when it is not positive
+func ReadBundle(r io.Reader, maxSize int64) (bundle *Bundle, err error) {
+	if maxSize <= 0 {
+		maxSize = DefaultBundleMaxSize
+	}
+	data, err := readLimited(r, maxSize)
+	if err != nil {
+		return
+	}
+	bundle = &Bundle{}
+	zr, zerr := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if zerr != nil {
+		return bundle, json.Unmarshal(data, bundle)
+	}
+	contents := map[string][]byte{}
+	left := maxSize
+	for _, f := range zr.File {
+		// the sizes of the headers are checked first, the reads are limited as the headers may be wrong
+		if f.UncompressedSize64 > uint64(left) {
+			return nil, ErrBundleTooLarge
+		}
+		var rc io.ReadCloser
+		if rc, err = f.Open(); err != nil {
+			return
+		}
+		contents[f.Name], err = readLimited(rc, left)
+		rc.Close()
+		if err != nil {
+			return
+		}
+		left -= int64(len(contents[f.Name]))
+	}
+	content, ok := contents[bundleFileName]
+	if !ok {
+		return nil, fmt.Errorf("pagebuilder: %s not found in the bundle", bundleFileName)
+	}
+	if err = json.Unmarshal(content, bundle); err != nil {
+		return
+	}
+	for _, m := range bundle.Media {
+		prefix := path.Join(bundleMediaDir, strconv.FormatUint(uint64(m.ID), 10)) + "/"
+		for name, content := range contents {
+			if strings.HasPrefix(name, prefix) {
+				m.content = content
+			}
+		}
+	}
+	return
+}
+
+// Import creates the record of bundle as a new draft, a new version of the record with the same slug when there is one,
+// the containers whose type is not registered are skipped and reported
+func (b *ModelBuilder) Import(ctx context.Context, bundle *Bundle) (result *ImportResult, err error) {
+	if bundle.FormatVersion != BundleFormatVersion {
+		return nil, fmt.Errorf("pagebuilder: unsupported bundle format %d", bundle.FormatVersion)
+	}
+	if bundle.ModelName != b.name {
+		return nil, fmt.Errorf("pagebuilder: the bundle is a %s, not a %s", bundle.ModelName, b.name)
+	}
+	obj := b.mb.NewModel()
+	if err = json.Unmarshal(bundle.Record, obj); err != nil {
+		return
+	}
+	result = &ImportResult{Record: obj}
+	err = b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var locale string
+		if l, ok := obj.(l10n.LocaleInterface); ok {
+			locale = l.EmbedLocale().LocaleCode
+		}
+		if err = b.resetImported(tx, obj, locale); err != nil {
+			return
+		}
+		if bundle.Category != nil {
+			var category Category
+			err = tx.Where("path = ? AND locale_code = ?", bundle.Category.Path, locale).First(&category).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return
+			}
+			if err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("category %s not found", bundle.Category.Path))
+			}
+			_ = reflectutils.Set(obj, "CategoryID", category.ID)
+		}
+		mediaIDs, err := importMedia(tx, bundle.Media)
+		if err != nil {
+			return
+		}
+		remapMediaIDs(obj, mediaIDs)
+		if err = tx.Create(obj).Error; err != nil {
+			return
+		}
+		pageID, pageVersion, _ := b.primaryColumnValuesBySlug(obj.(presets.SlugEncoder).PrimarySlug())
+		for _, bc := range bundle.Containers {
+			cb := b.builder.containerByName(bc.ModelName)
+			if cb == nil || bc.Model == nil {
+				result.UnknownContainers = append(result.UnknownContainers, bc.ModelName)
+				continue
+			}
+			var modelID uint
+			if modelID, err = b.importContainerModel(tx, cb, bc, locale, mediaIDs); err != nil {
+				return
+			}
+			if err = tx.Create(&Container{
+				PageID:           uint(pageID),
+				PageVersion:      pageVersion,
+				PageModelName:    b.name,
+				ModelName:        bc.ModelName,
+				ModelID:          modelID,
+				DisplayOrder:     bc.DisplayOrder,
+				Shared:           bc.Shared,
+				Hidden:           bc.Hidden,
+				Devices:          bc.Devices,
+				DeviceOverrides:  bc.Overrides,
+				ScheduledStartAt: bc.StartAt,
+				ScheduledEndAt:   bc.EndAt,
+				DisplayName:      bc.DisplayName,
+				Locale:           l10n.Locale{LocaleCode: locale},
+			}).Error; err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// resetImported makes obj a new draft, of the record with the same slug if there is one
+func (b *ModelBuilder) resetImported(tx *gorm.DB, obj any, locale string) (err error) {
+	_ = reflectutils.Set(obj, "ID", uint(0))
+	_ = reflectutils.Set(obj, "CreatedAt", time.Time{})
+	_ = reflectutils.Set(obj, "UpdatedAt", time.Time{})
+	_ = reflectutils.Set(obj, "DeletedAt", gorm.DeletedAt{})
+	// the parent is a page of the exporting database
+	_ = reflectutils.Set(obj, "ParentID", uint(0))
+	if p, ok := obj.(publish.StatusInterface); ok {
+		*p.EmbedStatus() = publish.Status{Status: publish.StatusDraft}
+	}
+	if p, ok := obj.(publish.ScheduleInterface); ok {
+		*p.EmbedSchedule() = publish.Schedule{}
+	}
+	v, ok := obj.(publish.VersionInterface)
+	if !ok {
+		return
+	}
+	version := v.EmbedVersion()
+	*version = publish.Version{}
+	if slug, err := reflectutils.Get(obj, "Slug"); err == nil {
+		existing := b.mb.NewModel()
+		err = tx.Where("slug = ? AND locale_code = ?", slug, locale).Order("version DESC").First(existing).Error
+		if err == nil {
+			id := reflectutils.MustGet(existing, "ID")
+			_ = reflectutils.Set(obj, "ID", id)
+			if parentID, err := reflectutils.Get(existing, "ParentID"); err == nil {
+				_ = reflectutils.Set(obj, "ParentID", parentID)
+			}
+			_, err = version.CreateVersion(tx, existing.(presets.SlugEncoder).PrimarySlug(), obj)
+			return err
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	now := tx.NowFunc()
+	version.Version = version.GetNextVersion(&now)
+	version.VersionName = version.Version
+	return
+}
+
+// importMedia reuses the media with the same file or creates them, it returns the new IDs by the exported ones.
+// The files in the bundle are uploaded like new ones, to the path of the new media, the paths in the bundle
+// are never written
+func importMedia(tx *gorm.DB, medias []*BundleMedia) (ids map[uint]uint, err error) {
+	ids = map[uint]uint{}
+	for _, bm := range medias {
+		m := &media_library.MediaLibrary{}
+		err = tx.Where("file = ?", bm.File).First(m).Error
+		if err == nil {
+			ids[bm.ID] = m.ID
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		var exported media_library.MediaLibraryStorage
+		if err = exported.Scan([]byte(bm.File)); err != nil {
+			return
+		}
+		m = &media_library.MediaLibrary{SelectedType: bm.SelectedType}
+		if len(bm.content) == 0 {
+			// without its file the media keeps the url it was exported with
+			m.File = exported
+			if err = tx.Create(m).Error; err != nil {
+				return
+			}
+			ids[bm.ID] = m.ID
+			continue
+		}
+		name := path.Base(exported.FileName)
+		if name == "." || name == "/" {
+			name = path.Base(mediaPath(exported.Url))
+		}
+		if err = m.File.Scan(base.NewMemoryFile(name, bm.content)); err != nil {
+			return
+		}
+		m.File.Description = exported.Description
+		m.File.Video = exported.Video
+		m.File.SelectedType = exported.SelectedType
+		if err = base.SaveUploadAndCropImage(tx, m, "", nil); err != nil {
+			return
+		}
+		ids[bm.ID] = m.ID
+	}
+	return ids, nil
+}
+
+// importContainerModel creates the model of a container, shared containers are matched by name instead
+func (b *ModelBuilder) importContainerModel(tx *gorm.DB, cb *ContainerBuilder, bc *BundleContainer, locale string, mediaIDs map[uint]uint) (id uint, err error) {
+	if bc.Shared {
+		var c Container
+		err = tx.Where("shared = true AND model_name = ? AND display_name = ? AND locale_code = ? AND page_model_name = ?",
+			bc.ModelName, bc.DisplayName, locale, b.name).First(&c).Error
+		if err == nil {
+			return c.ModelID, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+	}
+	model := cb.NewModel()
+	if err = json.Unmarshal(bc.Model, model); err != nil {
+		return
+	}
+	if err = reflectutils.Set(model, "ID", uint(0)); err != nil {
+		return
+	}
+	_ = reflectutils.Set(model, "LocaleCode", locale)
+	remapMediaIDs(model, mediaIDs)
+	if err = tx.Create(model).Error; err != nil {
+		return
+	}
+	return reflectutils.MustGet(model, "ID").(uint), nil
+}
+
+func (b *ModelBuilder) serveExport(w http.ResponseWriter, r *http.Request) {
+	if b.mb.Info().Verifier().Do(presets.PermGet).WithReq(r).IsAllowed() != nil {
+		http.Error(w, perm.PermissionDenied.Error(), http.StatusForbidden)
+		return
+	}
+	slug := r.URL.Query().Get(presets.ParamID)
+	bundle, err := b.Export(r.Context(), slug)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		} else {
+			log.Printf("error: %s\n", err)
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	var buf bytes.Buffer
+	if err = bundle.WriteZip(r.Context(), &buf); err != nil {
+		log.Printf("error: %s\n", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.zip"`, b.mb.Info().URIName(), slug))
+	_, _ = w.Write(buf.Bytes())
+}
+
+// configBundle adds the export row menu item and the import action to the listing
+func (b *ModelBuilder) configBundle() {
+	lb := b.mb.Listing()
+	lb.RowMenu().RowMenuItem("Export").ComponentFunc(func(obj interface{}, id string, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		return VListItem().PrependIcon("mdi-export").Title(msgr.Export).
+			Attr("@click", fmt.Sprintf("$event.view.window.open(%q)", b.exportURL(id)))
+	})
+	lb.Action(bundleImportName).
+		ComponentFunc(func(id string, ctx *web.EventContext) h.HTMLComponent {
+			msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+			return VFileInput().Attr(web.VField(bundleImportField, nil)...).
+				Attr("accept", ".zip,.json").
+				Label(msgr.ImportBundle).
+				Hint(msgr.ImportBundleHint).PersistentHint(true)
+		}).
+		UpdateFunc(func(id string, ctx *web.EventContext, r *web.EventResponse) (err error) {
+			if b.mb.Info().Verifier().Do(presets.PermCreate).WithReq(ctx.R).IsAllowed() != nil {
+				return perm.PermissionDenied
+			}
+			msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+			if ctx.R.MultipartForm == nil || len(ctx.R.MultipartForm.File[bundleImportField]) == 0 {
+				return errors.New(msgr.ImportBundleHint)
+			}
+			f, err := ctx.R.MultipartForm.File[bundleImportField][0].Open()
+			if err != nil {
+				return
+			}
+			defer f.Close()
+			bundle, err := ReadBundle(f, b.builder.bundleMaxSize)
+			if err != nil {
+				return
+			}
+			result, err := b.Import(ctx.R.Context(), bundle)
+			if err != nil {
+				return
+			}
+			r.Emit(b.mb.NotifModelsCreated(), presets.PayloadModelsCreated{Models: []any{result.Record}})
+			notes := result.Warnings
+			if len(result.UnknownContainers) > 0 {
+				notes = append([]string{msgr.UnknownContainerTypes + ": " + strings.Join(result.UnknownContainers, ", ")}, notes...)
+			}
+			if len(notes) > 0 {
+				presets.ShowMessage(r, msgr.ImportedWithWarnings+" "+strings.Join(notes, "; "), "warning")
+				return
+			}
+			presets.ShowMessage(r, msgr.SuccessfullyImported, "")
+			return
+		})
+}
@@ -0,0 +1,192 @@
+package pagebuilder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	. "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	h "github.com/theplant/htmlgo"
+
+	"github.com/qor5/admin/v3/l10n"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/publish"
+)
+
+const (
+	ContainerScheduleDialogEvent = "page_builder_ContainerScheduleDialogEvent"
+	UpdateContainerScheduleEvent = "page_builder_UpdateContainerScheduleEvent"
+
+	paramScheduledStartAt = "ScheduledStartAt"
+	paramScheduledEndAt   = "ScheduledEndAt"
+
+	containerScheduleTimeFormat = "2006-01-02 15:04"
+)
+
+// VisibleAt reports whether t is within the schedule of the container, an empty time leaves that side open
+func (c *Container) VisibleAt(t time.Time) bool {
+	if c.ScheduledStartAt != nil && t.Before(*c.ScheduledStartAt) {
+		return false
+	}
+	if c.ScheduledEndAt != nil && !t.Before(*c.ScheduledEndAt) {
+		return false
+	}
+	return true
+}
+
+func (c *Container) scheduled() bool {
+	return c.ScheduledStartAt != nil || c.ScheduledEndAt != nil
+}
+
+func parseContainerScheduleTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(containerScheduleTimeFormat, v, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (b *ModelBuilder) scheduleTaskName() string {
+	return fmt.Sprintf("pagebuilder-%s-container-schedule", b.name)
+}
+
+// republishScheduledContainers republishes the online pages with a container shown or hidden between since and now,
+// their static content was rendered before
+func (b *ModelBuilder) republishScheduledContainers(ctx context.Context, since, now time.Time) (err error) {
+	var cons []*Container
+	if err = b.db.WithContext(ctx).
+		Select("DISTINCT page_id, page_version, locale_code").
+		Where("page_model_name = ?", b.name).
+		Where("(scheduled_start_at > ? AND scheduled_start_at <= ?) OR (scheduled_end_at > ? AND scheduled_end_at <= ?)", since, now, since, now).
+		Find(&cons).Error; err != nil {
+		return
+	}
+	for _, c := range cons {
+		// the containers of the variants are in the page version
+		version, _, _ := strings.Cut(c.PageVersion, variantVersionSeparator)
+		obj := b.mb.NewModel()
+		g := b.db.WithContext(ctx).Where("id = ? AND version = ? AND status = ?", c.PageID, version, publish.StatusOnline)
+		if b.builder.l10n != nil {
+			g = g.Where("locale_code = ?", c.LocaleCode)
+		}
+		result := g.Limit(1).Find(obj)
+		if err = result.Error; err != nil {
+			return
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		// a page refused by the checks of the publisher keeps its published content until it is fixed,
+		// the other pages are still republished
+		err2 := b.builder.publisher.Publish(b.builder.publisher.WithContextValues(ctx), obj)
+		var checkErr *publish.PublishCheckError
+		if errors.As(err2, &checkErr) {
+			log.Printf("error: %s\n", err2)
+			continue
+		}
+		if err2 != nil {
+			err = multierror.Append(err, err2).ErrorOrNil()
+		}
+	}
+	return
+}
+
+func (b *ModelBuilder) containerScheduleDialog(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		container Container
+		paramID   = ctx.R.FormValue(paramContainerID)
+		cs        = container.PrimaryColumnValuesBySlug(paramID)
+		msgr      = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		pMsgr     = presets.MustGetMessages(ctx.R)
+	)
+	if err = b.db.First(&container, "id = ? AND locale_code = ?", cs[presets.ParamID], cs[l10n.SlugLocaleCode]).Error; err != nil {
+		return
+	}
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+		Name: dialogPortalName,
+		Body: web.Scope(
+			vx.VXDialog(
+				h.Div(h.Text(msgr.ContainerScheduleHint)).Class("text-caption mb-4"),
+				VRow(
+					VCol(
+						vx.VXDatepicker().Type("datetimepicker").
+							Format("YYYY-MM-DD HH:mm").
+							Clearable(true).
+							Attr(web.VField(paramScheduledStartAt, publish.ScheduleTimeString(container.ScheduledStartAt))...).
+							Label(msgr.ShowFrom),
+					),
+					VCol(
+						vx.VXDatepicker().Type("datetimepicker").
+							Format("YYYY-MM-DD HH:mm").
+							Clearable(true).
+							Attr(web.VField(paramScheduledEndAt, publish.ScheduleTimeString(container.ScheduledEndAt))...).
+							Label(msgr.ShowUntil),
+					),
+				).Class("justify-center"),
+			).Attr("v-model", "locals.scheduleDialog").
+				Title(msgr.ContainerSchedule).
+				CancelText(pMsgr.Cancel).
+				OkText(pMsgr.Update).
+				Attr("@click:ok", web.Plaid().
+					EventFunc(UpdateContainerScheduleEvent).
+					Query(paramContainerID, paramID).
+					Query(paramStatus, ctx.Param(paramStatus)).
+					ThenScript("locals.scheduleDialog = false").
+					Go()).
+				MaxWidth(480),
+		).Init("{scheduleDialog:true}").VSlot("{locals}"),
+	})
+	return
+}
+
+func (b *ModelBuilder) updateContainerSchedule(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		container      Container
+		cs             = container.PrimaryColumnValuesBySlug(ctx.R.FormValue(paramContainerID))
+		msgr           = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		startAt, endAt *time.Time
+	)
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), ColorError)
+			err = nil
+		}
+	}()
+	if startAt, err = parseContainerScheduleTime(ctx.R.FormValue(paramScheduledStartAt)); err != nil {
+		return
+	}
+	if endAt, err = parseContainerScheduleTime(ctx.R.FormValue(paramScheduledEndAt)); err != nil {
+		return
+	}
+	if startAt != nil && endAt != nil && !endAt.After(*startAt) {
+		presets.ShowMessage(&r, msgr.ShowUntilShouldLaterThanShowFrom, ColorWarning)
+		return
+	}
+	if err = b.editorDB(ctx).Model(&Container{}).Where("id = ? AND locale_code = ?", cs[presets.ParamID], cs[l10n.SlugLocaleCode]).
+		Updates(map[string]interface{}{"scheduled_start_at": startAt, "scheduled_end_at": endAt}).Error; err != nil {
+		return
+	}
+	web.AppendRunScripts(&r,
+		web.Plaid().EventFunc(ShowSortedContainerDrawerEvent).MergeQuery(true).Query(paramStatus, ctx.Param(paramStatus)).Go(),
+		web.Plaid().EventFunc(ReloadRenderPageOrTemplateBodyEvent).MergeQuery(true).Go(),
+	)
+	return
+}
+
+// containerScheduleText describes the schedule of the container in the sidebar
+func containerScheduleText(c *Container) string {
+	if !c.scheduled() {
+		return ""
+	}
+	return fmt.Sprintf("%s ~ %s", publish.ScheduleTimeString(c.ScheduledStartAt), publish.ScheduleTimeString(c.ScheduledEndAt))
+}
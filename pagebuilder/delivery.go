@@ -0,0 +1,330 @@
+package pagebuilder
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunfmin/reflectutils"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/publish"
+	"github.com/qor5/admin/v3/seo"
+)
+
+const (
+	// DeliveryParamPath, or DeliveryParamID with DeliveryParamLocale, select the page served by DeliveryHandler,
+	// DeliveryParamPreview is a token of DeliveryPreviewToken serving the version DeliveryParamVersion of the page,
+	// or its latest one, whatever its status
+	DeliveryParamPath    = "path"
+	DeliveryParamID      = "id"
+	DeliveryParamLocale  = "locale"
+	DeliveryParamVersion = "version"
+	DeliveryParamPreview = "preview"
+)
+
+var (
+	ErrDeliveryPreviewInvalid = errors.New("pagebuilder: invalid preview token")
+	ErrDeliveryPreviewExpired = errors.New("pagebuilder: preview token expired")
+)
+
+// DeliveryPage is the JSON of a page served by DeliveryHandler, Fields of the containers are their models
+type DeliveryPage struct {
+	ID          uint
+	Version     string
+	Locale      string `json:",omitempty"`
+	Status      string
+	Title       string
+	Slug        string
+	URL         string `json:",omitempty"`
+	CategoryID  uint   `json:",omitempty"`
+	ParentID    uint   `json:",omitempty"`
+	UpdatedAt   time.Time
+	SEO         *seo.Setting      `json:",omitempty"`
+	SEOMeta     map[string]string `json:",omitempty"`
+	Breadcrumbs []*Breadcrumb     `json:",omitempty"`
+	Containers  []*DeliveryContainer
+}
+
+// DeliveryContainer is a container of a DeliveryPage, Type is the name of its ContainerBuilder
+// and Fields are the DeliveryFields of its model
+type DeliveryContainer struct {
+	ID              string
+	Type            string
+	DisplayName     string
+	Devices         []string        `json:",omitempty"`
+	DeviceOverrides DeviceOverrides `json:",omitempty"`
+	Fields          json.RawMessage
+}
+
+// deliveryBookkeeping are the fields of the models maintained by gorm, which are left out of the delivery api
+var deliveryBookkeeping = map[string]bool{"ID": true, "CreatedAt": true, "UpdatedAt": true, "DeletedAt": true}
+
+// DeliveryFields are the fields of the model served by the delivery api, by default its exported fields
+// but the ones maintained by gorm and the ones without json
+func (b *ContainerBuilder) DeliveryFields(v ...string) *ContainerBuilder {
+	b.deliveryFields = v
+	return b
+}
+
+// defaultDeliveryFields are the exported fields of t and of its embedded structs, but the bookkeeping ones
+func defaultDeliveryFields(t reflect.Type) (fields []string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || deliveryBookkeeping[f.Name] || f.Tag.Get("json") == "-" {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, defaultDeliveryFields(f.Type)...)
+			continue
+		}
+		fields = append(fields, f.Name)
+	}
+	return
+}
+
+// deliveryFieldsJSON is the JSON of the DeliveryFields of obj, keyed by their names
+func (b *ContainerBuilder) deliveryFieldsJSON(obj interface{}) (json.RawMessage, error) {
+	fields := b.deliveryFields
+	if len(fields) == 0 {
+		fields = defaultDeliveryFields(reflect.TypeOf(obj))
+	}
+	m := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		v, err := reflectutils.Get(obj, f)
+		if err != nil {
+			return nil, err
+		}
+		m[f] = v
+	}
+	return json.Marshal(m)
+}
+
+// DeliveryPreviewSecret enables the preview tokens of the delivery api signed with secret
+func (b *Builder) DeliveryPreviewSecret(secret []byte) (r *Builder) {
+	b.deliveryPreviewSecret = secret
+	return b
+}
+
+func (b *Builder) signDeliveryPreview(pageID uint, locale string, expires int64) string {
+	mac := hmac.New(sha256.New, b.deliveryPreviewSecret)
+	fmt.Fprintf(mac, "%d:%s:%d", pageID, locale, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeliveryPreviewToken returns a token valid for ttl to get the draft versions of the page from DeliveryHandler
+func (b *Builder) DeliveryPreviewToken(pageID uint, locale string, ttl time.Duration) (string, error) {
+	if len(b.deliveryPreviewSecret) == 0 {
+		return "", errors.New("pagebuilder: delivery preview is not enabled")
+	}
+	expires := time.Now().Add(ttl).Unix()
+	return fmt.Sprintf("%d.%s", expires, b.signDeliveryPreview(pageID, locale, expires)), nil
+}
+
+func (b *Builder) verifyDeliveryPreview(token string, pageID uint, locale string) error {
+	if len(b.deliveryPreviewSecret) == 0 {
+		return ErrDeliveryPreviewInvalid
+	}
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrDeliveryPreviewInvalid
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !hmac.Equal([]byte(sig), []byte(b.signDeliveryPreview(pageID, locale, expires))) {
+		return ErrDeliveryPreviewInvalid
+	}
+	if time.Now().Unix() > expires {
+		return ErrDeliveryPreviewExpired
+	}
+	return nil
+}
+
+// onlineURL is the online url of the page served on the path p
+func onlineURL(p string) string {
+	if strings.HasSuffix(p, ".html") {
+		return p
+	}
+	return path.Join("/", p, "index.html")
+}
+
+// etagMatch reports whether the If-None-Match header matches etag
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// deliveryPageRecord finds the online page of the request, or the previewed version with a preview token
+func (b *Builder) deliveryPageRecord(r *http.Request) (p *Page, status int, err error) {
+	var (
+		q     = r.URL.Query()
+		db    = b.db.WithContext(r.Context())
+		token = q.Get(DeliveryParamPreview)
+	)
+	p = &Page{}
+	if q.Get(DeliveryParamPath) != "" && token == "" {
+		err = db.Where("online_url = ? AND status = ?", onlineURL(q.Get(DeliveryParamPath)), publish.StatusOnline).First(p).Error
+	} else {
+		var id uint64
+		if id, err = strconv.ParseUint(q.Get(DeliveryParamID), 10, 64); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("pagebuilder: invalid page id %q", q.Get(DeliveryParamID))
+		}
+		locale := q.Get(DeliveryParamLocale)
+		wh := withLocale(b, db.Where("id = ?", id), locale)
+		if token == "" {
+			err = wh.Where("status = ?", publish.StatusOnline).First(p).Error
+		} else {
+			if err = b.verifyDeliveryPreview(token, uint(id), locale); err != nil {
+				return nil, http.StatusUnauthorized, err
+			}
+			if v := q.Get(DeliveryParamVersion); v != "" {
+				wh = wh.Where("version = ?", v)
+			}
+			err = wh.Order("version DESC").First(p).Error
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, http.StatusNotFound, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return p, http.StatusOK, nil
+}
+
+// deliveryPage is the page with its visible containers in the display order
+func (b *ModelBuilder) deliveryPage(ctx context.Context, r *http.Request, p *Page) (dp *DeliveryPage, err error) {
+	db := b.db.WithContext(ctx)
+	dp = &DeliveryPage{
+		ID:          p.ID,
+		Version:     p.Version.Version,
+		Locale:      p.LocaleCode,
+		Status:      p.Status.Status,
+		Title:       p.Title,
+		Slug:        p.Slug,
+		CategoryID:  p.CategoryID,
+		ParentID:    p.ParentID,
+		UpdatedAt:   p.UpdatedAt,
+		Breadcrumbs: b.breadcrumbs(p, p.LocaleCode),
+		Containers:  []*DeliveryContainer{},
+	}
+	if p.OnlineUrl != "" {
+		dp.URL = p.getAccessUrl(p.OnlineUrl)
+	}
+	if b.builder.seoBuilder != nil {
+		if setting, meta, ok := b.builder.seoBuilder.Resolve(p, r); ok {
+			dp.SEO, dp.SEOMeta = &setting, meta
+		}
+	}
+
+	var cons []*Container
+	if err = withLocale(b.builder, db.Order("display_order ASC").
+		Where("page_id = ? AND page_version = ? AND page_model_name = ?", p.ID, p.Version.Version, b.name), p.LocaleCode).
+		Find(&cons).Error; err != nil {
+		return
+	}
+	now := db.NowFunc()
+	for _, c := range cons {
+		cb := b.builder.ContainerByName(c.ModelName)
+		if cb == nil || c.Hidden || !c.VisibleAt(now) {
+			continue
+		}
+		obj := cb.NewModel()
+		if err = db.First(obj, "id = ?", c.ModelID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = nil
+				continue
+			}
+			return
+		}
+		dc := &DeliveryContainer{
+			ID:              cb.getContainerDataID(int(c.ModelID)),
+			Type:            cb.name,
+			DisplayName:     c.DisplayName,
+			Devices:         c.DeviceNames(),
+			DeviceOverrides: c.GetDeviceOverrides(),
+		}
+		if dc.Fields, err = cb.deliveryFieldsJSON(obj); err != nil {
+			return
+		}
+		dp.Containers = append(dp.Containers, dc)
+	}
+	return
+}
+
+// DeliveryHandler serves the online pages as JSON to render them in other applications,
+// a page is selected by its url with ?path=, or by ?id= and ?locale=. The drafts are served
+// with a token of DeliveryPreviewToken in ?preview=, the responses are revalidated with their ETag.
+func (b *Builder) DeliveryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		pm := b.GetPageModelBuilder()
+		if pm == nil {
+			http.NotFound(w, r)
+			return
+		}
+		p, status, err := b.deliveryPageRecord(r)
+		if err != nil {
+			if status == http.StatusNotFound {
+				http.NotFound(w, r)
+				return
+			}
+			if status == http.StatusInternalServerError {
+				log.Printf("error: %s\n", err)
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		dp, err := pm.deliveryPage(r.Context(), r, p)
+		if err != nil {
+			log.Printf("error: %s\n", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		body, err := json.Marshal(dp)
+		if err != nil {
+			log.Printf("error: %s\n", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+		w.Header().Set("ETag", etag)
+		if r.URL.Query().Get(DeliveryParamPreview) != "" {
+			w.Header().Set("Cache-Control", "private, no-store")
+		} else {
+			w.Header().Set("Cache-Control", "public, no-cache")
+		}
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write(body)
+	})
+}
@@ -0,0 +1,361 @@
+package pagebuilder
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	. "github.com/qor5/x/v3/ui/vuetify"
+	h "github.com/theplant/htmlgo"
+
+	"github.com/qor5/admin/v3/l10n"
+	"github.com/qor5/admin/v3/presets"
+)
+
+const (
+	ContainerDevicesDialogEvent = "page_builder_ContainerDevicesDialogEvent"
+	UpdateContainerDevicesEvent = "page_builder_UpdateContainerDevicesEvent"
+
+	paramDevices         = "Devices"
+	paramDeviceOverrides = "Override"
+)
+
+// DeviceOverrides are the values of the model fields of a container by device and field name,
+// the values are set as is to string fields and decoded as JSON for the others
+type DeviceOverrides map[string]map[string]string
+
+// deviceVariant is a rendering of a container for the devices showing the same fields
+type deviceVariant struct {
+	device  string
+	devices []string
+	hidden  []string
+	fields  map[string]string
+}
+
+func (c *Container) DeviceNames() []string {
+	if c.Devices == "" {
+		return nil
+	}
+	return strings.Split(c.Devices, ",")
+}
+
+func (c *Container) VisibleOn(device string) bool {
+	return device == "" || c.Devices == "" || slices.Contains(c.DeviceNames(), device)
+}
+
+func (c *Container) GetDeviceOverrides() (r DeviceOverrides) {
+	r = DeviceOverrides{}
+	if c.DeviceOverrides != "" {
+		_ = json.Unmarshal([]byte(c.DeviceOverrides), &r)
+	}
+	return
+}
+
+func (c *Container) SetDeviceOverrides(v DeviceOverrides) {
+	for device, fields := range v {
+		if len(fields) == 0 {
+			delete(v, device)
+		}
+	}
+	if len(v) == 0 {
+		c.DeviceOverrides = ""
+		return
+	}
+	data, _ := json.Marshal(v)
+	c.DeviceOverrides = string(data)
+}
+
+// DeviceOverrideFields are the fields of the model editors can override for each device
+func (b *ContainerBuilder) DeviceOverrideFields(v ...string) *ContainerBuilder {
+	b.deviceOverrideFields = v
+	return b
+}
+
+// applyDeviceOverrides returns a copy of obj with the fields overridden
+func applyDeviceOverrides(obj interface{}, fields map[string]string) (r interface{}, err error) {
+	if len(fields) == 0 {
+		return obj, nil
+	}
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr {
+		return obj, nil
+	}
+	cp := reflect.New(val.Elem().Type())
+	cp.Elem().Set(val.Elem())
+	for name, v := range fields {
+		f := cp.Elem().FieldByName(name)
+		if !f.IsValid() || !f.CanSet() {
+			continue
+		}
+		if f.Kind() == reflect.String {
+			f.SetString(v)
+			continue
+		}
+		if err = json.Unmarshal([]byte(v), f.Addr().Interface()); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return cp.Interface(), nil
+}
+
+// deviceVariants groups the devices showing c by their overrides, a single variant without hidden devices
+// is rendered as before
+func (b *Builder) deviceVariants(c *Container) (r []*deviceVariant) {
+	var (
+		devices   = b.getDevices()
+		overrides = c.GetDeviceOverrides()
+		variants  = map[string]*deviceVariant{}
+	)
+	for _, d := range devices {
+		if !c.VisibleOn(d.Name) {
+			continue
+		}
+		var key string
+		fields := overrides[d.Name]
+		if len(fields) > 0 {
+			data, _ := json.Marshal(fields)
+			key = string(data)
+		}
+		v, ok := variants[key]
+		if !ok {
+			v = &deviceVariant{fields: fields}
+			if key != "" {
+				v.device = d.Name
+			}
+			variants[key] = v
+			r = append(r, v)
+		}
+		v.devices = append(v.devices, d.Name)
+	}
+	for _, v := range r {
+		for _, d := range devices {
+			if !slices.Contains(v.devices, d.Name) {
+				v.hidden = append(v.hidden, d.Name)
+			}
+		}
+	}
+	return
+}
+
+func deviceHiddenClass(device string) string {
+	return "pb-device-hidden-" + strcase.ToKebab(device)
+}
+
+// deviceStyle hides the variants of the containers on the other devices, a device covers the widths
+// above the narrower one up to its own width, the widest covers all wider screens
+func (b *Builder) deviceStyle() string {
+	type deviceWidth struct {
+		name  string
+		width int
+	}
+	var widths []deviceWidth
+	for _, d := range b.getDevices() {
+		w, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(d.Width), "px"))
+		if err != nil {
+			w = math.MaxInt
+		}
+		widths = append(widths, deviceWidth{name: d.Name, width: w})
+	}
+	sort.SliceStable(widths, func(i, j int) bool {
+		return widths[i].width < widths[j].width
+	})
+	var (
+		css  strings.Builder
+		prev int
+	)
+	for i, d := range widths {
+		var conds []string
+		if i > 0 {
+			conds = append(conds, fmt.Sprintf("(min-width: %dpx)", prev+1))
+		}
+		if i < len(widths)-1 && d.width != math.MaxInt {
+			conds = append(conds, fmt.Sprintf("(max-width: %dpx)", d.width))
+		}
+		rule := fmt.Sprintf(".%s{display:none !important}", deviceHiddenClass(d.name))
+		if len(conds) > 0 {
+			rule = fmt.Sprintf("@media %s{%s}", strings.Join(conds, " and "), rule)
+		}
+		css.WriteString(rule)
+		css.WriteString("\n")
+		prev = d.width
+	}
+	return css.String()
+}
+
+// deviceCounts are the numbers of containers hidden or overridden by device
+func (b *Builder) deviceCounts(cons []*Container) map[string]int {
+	counts := map[string]int{}
+	for _, c := range cons {
+		if c.Hidden {
+			continue
+		}
+		overrides := c.GetDeviceOverrides()
+		for _, d := range b.getDevices() {
+			if !c.VisibleOn(d.Name) || len(overrides[d.Name]) > 0 {
+				counts[d.Name]++
+			}
+		}
+	}
+	return counts
+}
+
+func (b *Builder) deviceIcons(c *Container) (icons []string) {
+	if c.Devices == "" {
+		return
+	}
+	for _, d := range b.getDevices() {
+		if c.VisibleOn(d.Name) {
+			icons = append(icons, d.Icon)
+		}
+	}
+	return
+}
+
+func deviceOverrideKey(device, field string) string {
+	return fmt.Sprintf("%s.%s.%s", paramDeviceOverrides, device, field)
+}
+
+func (b *ModelBuilder) containerDevicesDialog(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		container Container
+		paramID   = ctx.R.FormValue(paramContainerID)
+		cs        = container.PrimaryColumnValuesBySlug(paramID)
+		msgr      = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		pMsgr     = presets.MustGetMessages(ctx.R)
+	)
+	if err = b.db.First(&container, "id = ? AND locale_code = ?", cs[presets.ParamID], cs[l10n.SlugLocaleCode]).Error; err != nil {
+		return
+	}
+	var (
+		devices   = b.builder.getDevices()
+		selected  = container.DeviceNames()
+		overrides = container.GetDeviceOverrides()
+		fields    []string
+		form      = map[string]interface{}{}
+		rows      []h.HTMLComponent
+	)
+	if cb := b.builder.containerByName(container.ModelName); cb != nil {
+		fields = cb.deviceOverrideFields
+	}
+	if len(selected) == 0 {
+		for _, d := range devices {
+			selected = append(selected, d.Name)
+		}
+	}
+	form[paramDevices] = selected
+	for _, d := range devices {
+		var overrideFields []h.HTMLComponent
+		for _, f := range fields {
+			key := deviceOverrideKey(d.Name, f)
+			form[key] = overrides[d.Name][f]
+			overrideFields = append(overrideFields,
+				VTextField().Label(f).Attr("v-model", fmt.Sprintf("form[%q]", key)).
+					Placeholder(msgr.DeviceOverrideHint).
+					Variant(FieldVariantUnderlined).Density(DensityCompact).HideDetails(true).Class("mb-2"),
+			)
+		}
+		rows = append(rows,
+			VCheckbox().Label(d.Name).Value(d.Name).Attr("v-model", fmt.Sprintf("form.%s", paramDevices)).
+				HideDetails(true).Density(DensityCompact),
+			h.If(len(overrideFields) > 0,
+				h.Div(overrideFields...).Class("pl-10").Attr("v-if", fmt.Sprintf("form.%s.includes(%q)", paramDevices, d.Name)),
+			),
+		)
+	}
+	okAction := web.Plaid().
+		EventFunc(UpdateContainerDevicesEvent).
+		Query(paramContainerID, paramID).
+		Query(paramStatus, ctx.Param(paramStatus)).
+		ThenScript("locals.devicesDialog = false").
+		Go()
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+		Name: dialogPortalName,
+		Body: web.Scope(
+			VDialog(
+				VCard(
+					VCardTitle(h.Text(msgr.Devices)),
+					VCardText(
+						h.Div(h.Text(msgr.DevicesHint)).Class("text-caption mb-2"),
+						h.Div(rows...).Attr(web.VAssign("form", form)...),
+					),
+					VCardActions(
+						VSpacer(),
+						VBtn(pMsgr.Cancel).
+							Variant(VariantFlat).
+							Class("ml-2").
+							On("click", "locals.devicesDialog = false"),
+						VBtn(pMsgr.OK).
+							Color(ColorPrimary).
+							Variant(VariantFlat).
+							Theme(ThemeDark).
+							Attr("@click", okAction),
+					),
+				),
+			).MaxWidth("480px").
+				Attr("v-model", "locals.devicesDialog"),
+		).Init("{devicesDialog:true}").VSlot("{locals}"),
+	})
+	return
+}
+
+func (b *ModelBuilder) updateContainerDevices(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		container Container
+		cs        = container.PrimaryColumnValuesBySlug(ctx.R.FormValue(paramContainerID))
+		msgr      = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		devices   = b.builder.getDevices()
+		selected  []string
+		overrides = DeviceOverrides{}
+	)
+	if err = b.editorDB(ctx).First(&container, "id = ? AND locale_code = ?", cs[presets.ParamID], cs[l10n.SlugLocaleCode]).Error; err != nil {
+		return
+	}
+	cb := b.builder.containerByName(container.ModelName)
+	for _, d := range devices {
+		if !slices.Contains(ctx.R.Form[paramDevices], d.Name) {
+			continue
+		}
+		selected = append(selected, d.Name)
+		if cb == nil {
+			continue
+		}
+		for _, f := range cb.deviceOverrideFields {
+			if v := ctx.R.FormValue(deviceOverrideKey(d.Name, f)); v != "" {
+				if overrides[d.Name] == nil {
+					overrides[d.Name] = map[string]string{}
+				}
+				overrides[d.Name][f] = v
+			}
+		}
+		if _, err = applyDeviceOverrides(cb.NewModel(), overrides[d.Name]); err != nil {
+			presets.ShowMessage(&r, fmt.Sprintf("%s %s", msgr.InvalidDeviceOverride, err), ColorError)
+			return r, nil
+		}
+	}
+	if len(selected) == 0 {
+		presets.ShowMessage(&r, msgr.SelectAtLeastOneDevice, ColorWarning)
+		return
+	}
+	container.Devices = strings.Join(selected, ",")
+	if len(selected) == len(devices) {
+		container.Devices = ""
+	}
+	container.SetDeviceOverrides(overrides)
+	if err = b.editorDB(ctx).Model(&Container{}).Where("id = ? AND locale_code = ?", container.ID, container.LocaleCode).
+		Updates(map[string]interface{}{"devices": container.Devices, "device_overrides": container.DeviceOverrides}).Error; err != nil {
+		return
+	}
+	web.AppendRunScripts(&r,
+		web.Plaid().EventFunc(ShowSortedContainerDrawerEvent).MergeQuery(true).Query(paramStatus, ctx.Param(paramStatus)).Go(),
+		web.Plaid().EventFunc(ReloadRenderPageOrTemplateBodyEvent).MergeQuery(true).Go(),
+	)
+	return
+}
@@ -135,7 +135,7 @@ func (b *Builder) Editor(m *ModelBuilder) web.PageFunc {
 		} else {
 			editContainerDrawer = b.emptyEdit(ctx)
 		}
-		deviceToggle = b.deviceToggle(ctx)
+		ensureEditorSession(ctx)
 		if tabContent, err = m.pageContent(ctx); err != nil {
 			return
 		}
@@ -146,6 +146,7 @@ func (b *Builder) Editor(m *ModelBuilder) web.PageFunc {
 		if !isStag && m.mb.Info().Verifier().Do(presets.PermUpdate).WithReq(ctx.R).IsAllowed() != nil {
 			isStag = true
 		}
+		deviceToggle = b.deviceToggle(ctx)
 		afterLeaveEvent := removeVirtualElement() + scrollToContainer(fmt.Sprintf("vars.%s", paramContainerDataID))
 		addOverlay := vx.VXOverlay(m.newContainerContent(ctx)).
 			MaxWidth(665).
@@ -168,6 +169,9 @@ func (b *Builder) Editor(m *ModelBuilder) web.PageFunc {
 				VAppBarTitle().Text(title),
 			).Class("d-inline-flex align-center"),
 			h.Div(deviceToggle).Class("text-center d-flex justify-space-between mx-6"),
+			h.If(!isStag, m.historyButtons(ctx)),
+			h.If(m.tb == nil, web.Portal(m.variantSwitcher(ctx)).Name(variantSwitcherPortal)),
+			h.If(m.tb == nil, m.a11yButton(ctx)),
 			versionComponent,
 			publish.NewListenerModelsDeleted(m.mb, ctx.Param(presets.ParamID)),
 			publish.NewListenerVersionSelected(ctx, m.editor, ctx.Param(presets.ParamID)),
@@ -397,19 +401,24 @@ func (b *Builder) getDevice(ctx *web.EventContext) (device string, style string)
 type CtxKeyContainerToPageLayout struct{}
 
 type ContainerSorterItem struct {
-	Index           int    `json:"index"`
-	Label           string `json:"label"`
-	ModelName       string `json:"model_name"`
-	ModelID         string `json:"model_id"`
-	DisplayName     string `json:"display_name"`
-	ContainerID     string `json:"container_id"`
-	ContainerDataID string `json:"container_data_id"`
-	URL             string `json:"url"`
-	Shared          bool   `json:"shared"`
-	Hidden          bool   `json:"hidden"`
-	VisibilityIcon  string `json:"visibility_icon"`
-	ParamID         string `json:"param_id"`
-	Locale          string `json:"locale"`
+	Index           int      `json:"index"`
+	Label           string   `json:"label"`
+	ModelName       string   `json:"model_name"`
+	ModelID         string   `json:"model_id"`
+	DisplayName     string   `json:"display_name"`
+	ContainerID     string   `json:"container_id"`
+	ContainerDataID string   `json:"container_data_id"`
+	URL             string   `json:"url"`
+	Shared          bool     `json:"shared"`
+	Hidden          bool     `json:"hidden"`
+	VisibilityIcon  string   `json:"visibility_icon"`
+	ParamID         string   `json:"param_id"`
+	Locale          string   `json:"locale"`
+	DeviceIcons     []string `json:"device_icons"`
+	Overridden      bool     `json:"overridden"`
+	HiddenOnDevice  bool     `json:"hidden_on_device"`
+	ScheduleText    string   `json:"schedule_text"`
+	OutOfSchedule   bool     `json:"out_of_schedule"`
 }
 
 type ContainerSorter struct {
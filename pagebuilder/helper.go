@@ -3,6 +3,7 @@ package pagebuilder
 import (
 	"path"
 	"regexp"
+	"strings"
 
 	"github.com/qor5/x/v3/i18n"
 
@@ -20,7 +21,9 @@ const (
 	       pages.version AS version,
 	       pages.locale_code AS locale_code,
 	       categories.path AS category_path,
-	       pages.slug AS slug
+	       pages.slug AS slug,
+	       pages.parent_id AS parent_id,
+	       pages.status AS status
 FROM page_builder_pages pages
 LEFT JOIN page_builder_categories categories ON category_id = categories.id AND pages.locale_code = categories.locale_code
 WHERE pages.deleted_at IS NULL AND categories.deleted_at IS NULL
@@ -33,6 +36,8 @@ type pagePathInfo struct {
 	LocaleCode   string
 	CategoryPath string
 	Slug         string
+	ParentID     uint
+	Status       string
 }
 
 func pageValidator(ctx *web.EventContext, p *Page, db *gorm.DB, l10nB *l10n.Builder) (err web.ValidationErrors) {
@@ -56,16 +61,25 @@ func pageValidator(ctx *web.EventContext, p *Page, db *gorm.DB, l10nB *l10n.Buil
 		localePath = l10nB.GetLocalePath(p.LocaleCode)
 	}
 
-	currentPageCategory, inErr := p.GetCategory(db)
-	if inErr != nil {
-		panic(err)
-	}
-	currentPagePublishUrl := p.getPublishUrl(localePath, currentPageCategory.Path)
-
-	var pagePathInfos []pagePathInfo
-	if dbErr := db.Raw(queryLocaleCodeCategoryPathSlugSQL).Scan(&pagePathInfos).Error; dbErr != nil {
+	resolver, pagePathInfos, dbErr := loadPagePathResolver(db)
+	if dbErr != nil {
 		panic(dbErr)
 	}
+	if p.ParentID != 0 && p.ID != 0 && resolver.isDescendant(p.ParentID, p.ID, p.LocaleCode) {
+		err.FieldError("ParentID", msgr.InvalidParentPage)
+		return
+	}
+	category, inErr := p.GetCategory(db)
+	if inErr != nil {
+		panic(inErr)
+	}
+	currentPagePublishUrl := resolver.publishUrl(localePath, pagePathInfo{
+		ID:           p.ID,
+		LocaleCode:   p.LocaleCode,
+		CategoryPath: category.Path,
+		Slug:         p.Slug,
+		ParentID:     p.ParentID,
+	})
 
 	for _, info := range pagePathInfos {
 		if info.ID == p.ID && info.LocaleCode == p.LocaleCode {
@@ -76,7 +90,7 @@ func pageValidator(ctx *web.EventContext, p *Page, db *gorm.DB, l10nB *l10n.Buil
 			innerLocalePath = l10nB.GetLocalePath(info.LocaleCode)
 		}
 
-		if generatePublishUrl(innerLocalePath, info.CategoryPath, info.Slug) == currentPagePublishUrl {
+		if resolver.publishUrl(innerLocalePath, info) == currentPagePublishUrl {
 			err.FieldError("Slug", msgr.ConflictSlugMsg)
 			return
 		}
@@ -91,11 +105,22 @@ func categoryValidator(ctx *web.EventContext, category *Category, db *gorm.DB, l
 		err.FieldError("Name", msgr.InvalidNameMsg)
 	}
 
-	categoryPath := path.Clean(category.Path)
-	if !directoryRe.MatchString(categoryPath) {
+	if !directoryRe.MatchString(path.Clean(category.Path)) {
 		err.FieldError("Path", msgr.InvalidPathMsg)
 		return
 	}
+	categoryPath, dbErr := categoryPath(db, category)
+	if dbErr != nil {
+		panic(dbErr)
+	}
+	if category.ParentID != 0 && category.ID != 0 {
+		var parent Category
+		if dbErr = db.Where("id = ? AND locale_code = ?", category.ParentID, category.LocaleCode).First(&parent).Error; dbErr == nil &&
+			(parent.ID == category.ID || strings.HasPrefix(parent.Path, categoryPath+"/")) {
+			err.FieldError("ParentID", msgr.InvalidParentCategory)
+			return
+		}
+	}
 
 	var localePath string
 	if l10nB != nil {
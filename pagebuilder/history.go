@@ -0,0 +1,525 @@
+package pagebuilder
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	. "github.com/qor5/x/v3/ui/vuetify"
+	h "github.com/theplant/htmlgo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/qor5/admin/v3/l10n"
+	"github.com/qor5/admin/v3/presets"
+)
+
+const (
+	UndoEvent = "page_builder_UndoEvent"
+	RedoEvent = "page_builder_RedoEvent"
+
+	editorSessionCookie = "page_builder_editor_session"
+	editorHistoryLimit  = 50
+	editorHistoryTTL    = 24 * time.Hour
+
+	editorHistoryTaskName = "pagebuilder-editor-history"
+)
+
+// errEditorConflict refuses to undo or redo an operation whose containers were changed since
+var errEditorConflict = errors.New("pagebuilder: the containers were changed since the operation")
+
+// ctxKeyEditorTx is the transaction of the event recorded in the history
+type ctxKeyEditorTx struct{}
+
+// EditorOperation is an operation of an editing session on the containers of a page version,
+// Before and After are the states of the containers it changed, which undo and redo restore
+type EditorOperation struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	SessionID     string `gorm:"index"`
+	PageModelName string
+	PageID        uint
+	PageVersion   string
+	LocaleCode    string
+	Event         string
+	Keys          string
+	Before        string `gorm:"type:text"`
+	After         string `gorm:"type:text"`
+	Undone        bool
+}
+
+func (*EditorOperation) TableName() string {
+	return "page_builder_editor_operations"
+}
+
+// containerSnapshot is a container row with the record of its model, a missing snapshot means the container did not exist
+type containerSnapshot struct {
+	Container *Container
+	Model     json.RawMessage
+}
+
+type containerSnapshots map[string]*containerSnapshot
+
+func editorSessionID(r *http.Request) string {
+	c, err := r.Cookie(editorSessionCookie)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// ensureEditorSession starts an editing session for the browser, it lasts until the browser is closed
+func ensureEditorSession(ctx *web.EventContext) string {
+	if id := editorSessionID(ctx.R); id != "" {
+		return id
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return ""
+	}
+	id := hex.EncodeToString(token)
+	if ctx.W != nil {
+		http.SetCookie(ctx.W, &http.Cookie{
+			Name:     editorSessionCookie,
+			Value:    id,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   ctx.R.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return id
+}
+
+// editorDB is the transaction of the event recorded in the history, the events change the containers with it
+func (b *ModelBuilder) editorDB(ctx *web.EventContext) *gorm.DB {
+	if tx, ok := ctx.ContextValue(ctxKeyEditorTx{}).(*gorm.DB); ok && tx != nil {
+		return tx
+	}
+	return b.db
+}
+
+// forUpdate locks the rows read with tx until it ends, SQLite locks the whole database on writes instead
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	if tx.Dialector.Name() == "sqlite" {
+		return tx
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Session(&gorm.Session{})
+}
+
+func (b *Builder) snapshotContainer(tx *gorm.DB, c *Container) (s *containerSnapshot, err error) {
+	s = &containerSnapshot{Container: c}
+	cb := b.containerByName(c.ModelName)
+	if cb == nil {
+		return
+	}
+	model := cb.NewModel()
+	if err = tx.First(model, "id = ?", c.ModelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		return
+	}
+	s.Model, err = json.Marshal(model)
+	return
+}
+
+func (b *ModelBuilder) snapshotContainers(tx *gorm.DB, pageID int, pageVersion, locale string) (r containerSnapshots, err error) {
+	var cons []*Container
+	if err = withLocale(
+		b.builder,
+		tx.Where("page_id = ? AND page_version = ? and page_model_name = ? ", pageID, pageVersion, b.name),
+		locale,
+	).Find(&cons).Error; err != nil {
+		return
+	}
+	r = containerSnapshots{}
+	for _, c := range cons {
+		if r[c.PrimarySlug()], err = b.builder.snapshotContainer(tx, c); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *Builder) snapshotKeys(tx *gorm.DB, keys []string) (r containerSnapshots, err error) {
+	r = containerSnapshots{}
+	for _, key := range keys {
+		var c Container
+		cs := c.PrimaryColumnValuesBySlug(key)
+		err = tx.Where("id = ? AND locale_code = ?", cs[presets.ParamID], cs[l10n.SlugLocaleCode]).First(&c).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if r[key], err = b.snapshotContainer(tx, &c); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *containerSnapshot) equal(o *containerSnapshot) bool {
+	if s == nil || o == nil {
+		return s == o
+	}
+	c1, c2 := *s.Container, *o.Container
+	c1.UpdatedAt, c2.UpdatedAt = time.Time{}, time.Time{}
+	v1, _ := json.Marshal(c1)
+	v2, _ := json.Marshal(c2)
+	return bytes.Equal(v1, v2) && bytes.Equal(s.Model, o.Model)
+}
+
+func changedKeys(before, after containerSnapshots) (keys []string) {
+	for key, s := range before {
+		if !s.equal(after[key]) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
+
+func encodeSnapshots(s containerSnapshots) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func decodeSnapshots(v string) (s containerSnapshots, err error) {
+	s = containerSnapshots{}
+	if v == "" {
+		return
+	}
+	err = json.Unmarshal([]byte(v), &s)
+	return
+}
+
+func historyQuery(tx *gorm.DB, op *EditorOperation) *gorm.DB {
+	return tx.Model(&EditorOperation{}).Where("session_id = ? AND page_model_name = ? AND page_id = ? AND page_version = ? AND locale_code = ?",
+		op.SessionID, op.PageModelName, op.PageID, op.PageVersion, op.LocaleCode)
+}
+
+// recordOperation saves op with the containers changed between before and after, which drops the operations
+// that could be redone, nothing is saved when no container changed. The expired operations are deleted
+// by PurgeEditorHistory
+func (b *Builder) recordOperation(db *gorm.DB, op *EditorOperation, before, after containerSnapshots) error {
+	keys := changedKeys(before, after)
+	if len(keys) == 0 {
+		return nil
+	}
+	op.Keys = strings.Join(keys, ",")
+	op.Before = encodeSnapshots(before.pick(keys))
+	op.After = encodeSnapshots(after.pick(keys))
+	return db.Transaction(func(tx *gorm.DB) (dbErr error) {
+		if dbErr = historyQuery(tx, op).Where("undone = true").Delete(&EditorOperation{}).Error; dbErr != nil {
+			return
+		}
+		if dbErr = tx.Create(op).Error; dbErr != nil {
+			return
+		}
+		var ids []uint
+		if dbErr = historyQuery(tx, op).Order("id DESC").Offset(editorHistoryLimit).Pluck("id", &ids).Error; dbErr != nil {
+			return
+		}
+		if len(ids) > 0 {
+			return tx.Delete(&EditorOperation{}, ids).Error
+		}
+		return
+	})
+}
+
+// PurgeEditorHistory deletes the operations of the editing sessions older than a day, it is a task of the
+// schedulers of the publisher, the applications without it call it from time to time
+func (b *Builder) PurgeEditorHistory(ctx context.Context) error {
+	return b.db.WithContext(ctx).Where("created_at < ?", b.db.NowFunc().Add(-editorHistoryTTL)).Delete(&EditorOperation{}).Error
+}
+
+func (s containerSnapshots) pick(keys []string) containerSnapshots {
+	r := containerSnapshots{}
+	for _, key := range keys {
+		if v, ok := s[key]; ok {
+			r[key] = v
+		}
+	}
+	return r
+}
+
+// unchanged reports whether the containers of keys are still in the state expected
+func (s containerSnapshots) unchanged(keys []string, expected containerSnapshots) bool {
+	for _, key := range keys {
+		if !s[key].equal(expected[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreContainers sets the containers of keys to target, current is their state before
+func (b *Builder) restoreContainers(tx *gorm.DB, keys []string, target, current containerSnapshots) (err error) {
+	for _, key := range keys {
+		s := target[key]
+		if s == nil {
+			var c Container
+			cs := c.PrimaryColumnValuesBySlug(key)
+			if err = tx.Delete(&Container{}, "id = ? AND locale_code = ?", cs[presets.ParamID], cs[l10n.SlugLocaleCode]).Error; err != nil {
+				return
+			}
+			continue
+		}
+		if err = tx.Unscoped().Save(s.Container).Error; err != nil {
+			return
+		}
+		cb := b.containerByName(s.Container.ModelName)
+		if cb == nil || s.Model == nil || current[key] != nil && bytes.Equal(current[key].Model, s.Model) {
+			continue
+		}
+		model := cb.NewModel()
+		if err = json.Unmarshal(s.Model, model); err != nil {
+			return
+		}
+		if err = tx.Unscoped().Save(model).Error; err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *ModelBuilder) newEditorOperation(ctx *web.EventContext, event string) *EditorOperation {
+	pageID, pageVersion, locale := b.getPrimaryColumnValuesBySlug(ctx)
+	return &EditorOperation{
+		SessionID:     editorSessionID(ctx.R),
+		PageModelName: b.name,
+		PageID:        uint(pageID),
+		PageVersion:   pageVersion,
+		LocaleCode:    locale,
+		Event:         event,
+	}
+}
+
+// recordEvent records the containers of the page changed by the event in the history of the editing session,
+// the event runs in the transaction of the snapshots and of the operation, which locks the containers of the page
+// so the edits of other sessions are not recorded with it
+func (b *ModelBuilder) recordEvent(event string, in web.EventFunc) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		op := b.newEditorOperation(ctx, event)
+		if op.SessionID == "" || op.PageID == 0 {
+			return in(ctx)
+		}
+		err = b.db.Transaction(func(tx *gorm.DB) (dbErr error) {
+			var before, after containerSnapshots
+			if before, dbErr = b.snapshotContainers(forUpdate(tx), int(op.PageID), op.PageVersion, op.LocaleCode); dbErr != nil {
+				return
+			}
+			ctx.WithContextValue(ctxKeyEditorTx{}, tx)
+			defer ctx.WithContextValue(ctxKeyEditorTx{}, nil)
+			if r, dbErr = in(ctx); dbErr != nil {
+				return
+			}
+			if after, dbErr = b.snapshotContainers(tx, int(op.PageID), op.PageVersion, op.LocaleCode); dbErr != nil {
+				return
+			}
+			return b.builder.recordOperation(tx, op, before, after)
+		})
+		return
+	}
+}
+
+// recordContainerUpdate records the update of the model of a container made in the editor,
+// for every page version using the container
+func (b *Builder) recordContainerUpdate(ctx *web.EventContext, cb *ContainerBuilder, id string, save func() error) (err error) {
+	session := editorSessionID(ctx.R)
+	if session == "" {
+		return save()
+	}
+	var cons []*Container
+	if err = b.db.Where("model_name = ? AND model_id = ?", cb.name, id).Find(&cons).Error; err != nil {
+		return
+	}
+	befores := make([]*containerSnapshot, len(cons))
+	for i, c := range cons {
+		if befores[i], err = b.snapshotContainer(b.db, c); err != nil {
+			return
+		}
+	}
+	if err = save(); err != nil {
+		return
+	}
+	for i, c := range cons {
+		var after *containerSnapshot
+		if after, err = b.snapshotContainer(b.db, c); err != nil {
+			return
+		}
+		op := &EditorOperation{
+			SessionID:     session,
+			PageModelName: c.PageModelName,
+			PageID:        c.PageID,
+			PageVersion:   c.PageVersion,
+			LocaleCode:    c.LocaleCode,
+			Event:         UpdateContainerEvent,
+		}
+		key := c.PrimarySlug()
+		if err = b.recordOperation(b.db, op, containerSnapshots{key: befores[i]}, containerSnapshots{key: after}); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *ModelBuilder) undo(ctx *web.EventContext) (r web.EventResponse, err error) {
+	return b.replayOperation(ctx, true)
+}
+
+func (b *ModelBuilder) redo(ctx *web.EventContext) (r web.EventResponse, err error) {
+	return b.replayOperation(ctx, false)
+}
+
+// replayOperation undoes the last operation of the editing session on the current page version, or redoes the last undone one,
+// the containers must still be in the state the operation left them in, so the edits of other sessions are not overwritten
+func (b *ModelBuilder) replayOperation(ctx *web.EventContext, undo bool) (r web.EventResponse, err error) {
+	var (
+		msgr  = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		cur   = b.newEditorOperation(ctx, "")
+		op    EditorOperation
+		count int64
+	)
+	err = b.db.Transaction(func(tx *gorm.DB) (dbErr error) {
+		g := historyQuery(tx, cur).Where("undone = ?", !undo)
+		if undo {
+			g = g.Order("id DESC")
+		} else {
+			g = g.Order("id ASC")
+		}
+		if dbErr = g.First(&op).Error; dbErr != nil {
+			return
+		}
+		var (
+			keys                             = strings.Split(op.Keys, ",")
+			before, after, current, restored containerSnapshots
+			target, expected                 containerSnapshots
+		)
+		if before, dbErr = decodeSnapshots(op.Before); dbErr != nil {
+			return
+		}
+		if after, dbErr = decodeSnapshots(op.After); dbErr != nil {
+			return
+		}
+		target, expected = after, before
+		if undo {
+			target, expected = before, after
+		}
+		if current, dbErr = b.builder.snapshotKeys(forUpdate(tx), keys); dbErr != nil {
+			return
+		}
+		if !current.unchanged(keys, expected) {
+			return errEditorConflict
+		}
+		if dbErr = b.builder.restoreContainers(tx, keys, target, current); dbErr != nil {
+			return
+		}
+		// the restored state is read back as saving the models updates their timestamps
+		if restored, dbErr = b.builder.snapshotKeys(tx, keys); dbErr != nil {
+			return
+		}
+		if undo {
+			op.Before, op.After = encodeSnapshots(restored), encodeSnapshots(current)
+		} else {
+			op.Before, op.After = encodeSnapshots(current), encodeSnapshots(restored)
+		}
+		op.Undone = undo
+		if dbErr = tx.Save(&op).Error; dbErr != nil {
+			return
+		}
+		return withLocale(b.builder, tx.Model(&Container{}).Where("page_id = ? and page_version = ? and page_model_name = ?", cur.PageID, cur.PageVersion, b.name), cur.LocaleCode).
+			Count(&count).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		msg := msgr.NothingToRedo
+		if undo {
+			msg = msgr.NothingToUndo
+		}
+		presets.ShowMessage(&r, msg, ColorWarning)
+		return r, nil
+	}
+	if errors.Is(err, errEditorConflict) {
+		presets.ShowMessage(&r, msgr.HistoryConflict, ColorWarning)
+		return r, nil
+	}
+	if err != nil {
+		return
+	}
+	web.AppendRunScripts(&r,
+		web.Plaid().PushState(true).ClearMergeQuery([]string{paramContainerID, paramContainerDataID}).RunPushState(),
+		web.Plaid().EventFunc(ReloadRenderPageOrTemplateBodyEvent).Go(),
+		web.Plaid().EventFunc(ShowSortedContainerDrawerEvent).Query(paramStatus, ctx.Param(paramStatus)).Go(),
+		web.Plaid().EventFunc(EditContainerEvent).Go(),
+		fmt.Sprintf("vars.emptyIframe=%v", count == 0),
+	)
+	return
+}
+
+// historyScript updates the state of the undo and redo buttons
+func (b *ModelBuilder) historyScript(ctx *web.EventContext) string {
+	var (
+		cur          = b.newEditorOperation(ctx, "")
+		undos, redos int64
+	)
+	if cur.SessionID != "" && cur.PageID != 0 {
+		historyQuery(b.db, cur).Where("undone = false").Count(&undos)
+		historyQuery(b.db, cur).Where("undone = true").Count(&redos)
+	}
+	return fmt.Sprintf("vars.__pbCanUndo=%v;vars.__pbCanRedo=%v;", undos > 0, redos > 0)
+}
+
+func (b *ModelBuilder) historyButtons(ctx *web.EventContext) h.HTMLComponent {
+	var (
+		msgr = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		undo = web.Plaid().EventFunc(UndoEvent).Query(paramStatus, ctx.Param(paramStatus)).Go()
+		redo = web.Plaid().EventFunc(RedoEvent).Query(paramStatus, ctx.Param(paramStatus)).Go()
+	)
+	return h.Div(
+		VBtn("").Icon("mdi-undo").Variant(VariantText).Size(SizeSmall).Attr("title", msgr.Undo).
+			Attr(":disabled", "!vars.__pbCanUndo").Attr("@click", undo),
+		VBtn("").Icon("mdi-redo").Variant(VariantText).Size(SizeSmall).Attr("title", msgr.Redo).
+			Attr(":disabled", "!vars.__pbCanRedo").Attr("@click", redo),
+		h.Div().Style("display:none").
+			Attr("v-on-mounted", fmt.Sprintf(`({window}) => {
+				%s
+				vars.$pbHistoryKeydown = (event) => {
+					if (!(event.ctrlKey || event.metaKey) || event.altKey) return
+					const target = event.target
+					if (target && (target.isContentEditable || ["INPUT", "TEXTAREA", "SELECT"].includes(target.tagName))) return
+					const key = event.key.toLowerCase()
+					if (key === "z" && !event.shiftKey) {
+						event.preventDefault()
+						if (vars.__pbCanUndo) {%s}
+					} else if (key === "y" || (key === "z" && event.shiftKey)) {
+						event.preventDefault()
+						if (vars.__pbCanRedo) {%s}
+					}
+				}
+				window.addEventListener("keydown", vars.$pbHistoryKeydown)
+			}`, b.historyScript(ctx), undo, redo)).
+			Attr("v-on-unmounted", `({window}) => {
+				window.removeEventListener("keydown", vars.$pbHistoryKeydown)
+				vars.$pbHistoryKeydown = null
+			}`),
+	).Class("d-inline-flex align-center")
+}
@@ -0,0 +1,476 @@
+package pagebuilder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/oss"
+	. "github.com/qor5/x/v3/ui/vuetify"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
+	"golang.org/x/net/html"
+	"gorm.io/gorm"
+
+	mediaoss "github.com/qor5/admin/v3/media/oss"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/publish"
+	"github.com/qor5/admin/v3/utils"
+	"github.com/qor5/admin/v3/worker"
+)
+
+const (
+	LinkAuditJobName = "page-builder-link-audit"
+
+	RunLinkAuditEvent = "page_builder_RunLinkAuditEvent"
+
+	BrokenLinkKindLink  = "link"
+	BrokenLinkKindAsset = "asset"
+
+	// BrokenLinkNotFound is a link to a path no online page, redirect or ignored prefix matches
+	BrokenLinkNotFound = "not_found"
+	// BrokenLinkUnpublished is a link to the old URL of a page that is offline now
+	BrokenLinkUnpublished = "unpublished"
+	// BrokenLinkBrokenRedirect is a link redirected to a path that is not found
+	BrokenLinkBrokenRedirect = "broken_redirect"
+	// BrokenLinkMissingAsset is an asset of the media storage that is not in it
+	BrokenLinkMissingAsset = "missing_asset"
+
+	containerDataIDAttr = "data-container-id"
+)
+
+// BrokenLink is a link or an asset of an online page found broken by the last link audit
+type BrokenLink struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	PageModelName string `gorm:"index"`
+	PageID        uint
+	PageVersion   string
+	LocaleCode    string
+	PageTitle     string
+	PageUrl       string
+	// ContainerID is the container the link is in, 0 for the links of the page layout
+	ContainerID   uint
+	ContainerName string
+	Kind          string
+	Url           string
+	Reason        string
+}
+
+func (*BrokenLink) TableName() string {
+	return "page_builder_broken_links"
+}
+
+type LinkAuditJobArgs struct {
+	worker.Schedule
+}
+
+type LinkAuditReport struct {
+	Pages  int
+	Links  int
+	Broken []*BrokenLink
+}
+
+func (r *LinkAuditReport) String() string {
+	return fmt.Sprintf("%d pages and %d links checked, %d broken", r.Pages, r.Links, len(r.Broken))
+}
+
+// LinkAuditWorker runs the link audit as jobs of w, which can be started or scheduled from the worker.
+// The job only shows up in the worker when w is installed afterwards
+func (b *Builder) LinkAuditWorker(w *worker.Builder) (r *Builder) {
+	b.linkAuditWorker = w
+	w.NewJob(LinkAuditJobName).
+		Resource(&LinkAuditJobArgs{}).
+		Handler(func(ctx context.Context, job worker.QorJobInterface) error {
+			report, err := b.AuditLinks(ctx)
+			if report != nil {
+				for _, l := range report.Broken {
+					_ = job.AddLog(fmt.Sprintf("%s %s: %s %s", l.PageUrl, l.ContainerName, l.Reason, l.Url))
+				}
+				_ = job.AddLog(report.String())
+			}
+			return err
+		})
+	return b
+}
+
+// LinkAuditIgnore are the path prefixes served by other handlers than the page builder, the links to them
+// are not checked
+func (b *Builder) LinkAuditIgnore(prefixes ...string) (r *Builder) {
+	b.linkAuditIgnore = prefixes
+	return b
+}
+
+type linkAuditor struct {
+	ctx         context.Context
+	pages       map[string]bool
+	unpublished map[string]bool
+	redirects   []*Redirect
+	ignore      []string
+	media       oss.StorageInterface
+	mediaPrefix string
+	mediaHost   string
+	assets      map[string]bool
+}
+
+// mediaURLPrefix is the static beginning of the URLs of the media
+func mediaURLPrefix() string {
+	prefix, _, _ := strings.Cut(mediaoss.URLTemplate, "{")
+	return prefix
+}
+
+func (a *linkAuditor) ignored(p string) bool {
+	for _, prefix := range a.ignore {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLink returns why the link is broken, an empty reason for the ones working or not checked
+func (a *linkAuditor) checkLink(base *url.URL, raw string) (reason string) {
+	u, err := base.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return BrokenLinkNotFound
+	}
+	if (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") || u.Host != "" {
+		return
+	}
+	if a.mediaPrefix != "" && strings.HasPrefix(u.Path, a.mediaPrefix) {
+		return a.checkAsset(base, raw)
+	}
+	p := normalizeRedirectPath(u.Path)
+	if a.pages[p] || a.ignored(p) {
+		return
+	}
+	if rule, to := matchRedirect(a.redirects, p); rule != nil {
+		if !strings.HasPrefix(to, "/") {
+			return
+		}
+		if t := normalizeRedirectPath(strings.SplitN(to, "?", 2)[0]); a.pages[t] || a.ignored(t) {
+			return
+		}
+		return BrokenLinkBrokenRedirect
+	}
+	if a.unpublished[p] {
+		return BrokenLinkUnpublished
+	}
+	return BrokenLinkNotFound
+}
+
+// checkAsset returns why the asset is broken, only the assets of the media storage are checked
+func (a *linkAuditor) checkAsset(base *url.URL, raw string) (reason string) {
+	u, err := base.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	if u.Host != "" && u.Host != a.mediaHost {
+		return
+	}
+	if a.media == nil || a.mediaPrefix == "" || !strings.HasPrefix(u.Path, a.mediaPrefix) {
+		return
+	}
+	exists, ok := a.assets[u.Path]
+	if !ok {
+		r, getErr := a.media.GetStream(a.ctx, u.Path)
+		if exists = getErr == nil; exists {
+			_ = r.Close()
+		}
+		a.assets[u.Path] = exists
+	}
+	if !exists {
+		return BrokenLinkMissingAsset
+	}
+	return
+}
+
+type linkAuditRef struct {
+	kind        string
+	url         string
+	containerID string
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// linkAuditRefs returns the links and assets of the document with the data ID of the containers they are in
+func linkAuditRefs(doc *html.Node) (refs []linkAuditRef) {
+	var walk func(n *html.Node, containerID string)
+	walk = func(n *html.Node, containerID string) {
+		if n.Type == html.ElementNode {
+			if id := htmlAttr(n, containerDataIDAttr); id != "" {
+				containerID = id
+			}
+			add := func(kind, v string) {
+				if v = strings.TrimSpace(v); v != "" {
+					refs = append(refs, linkAuditRef{kind: kind, url: v, containerID: containerID})
+				}
+			}
+			switch n.Data {
+			case "a", "area", "iframe":
+				add(BrokenLinkKindLink, htmlAttr(n, lo.Ternary(n.Data == "iframe", "src", "href")))
+			case "link":
+				rel := strings.ToLower(htmlAttr(n, "rel"))
+				if strings.Contains(rel, "canonical") || strings.Contains(rel, "alternate") {
+					add(BrokenLinkKindLink, htmlAttr(n, "href"))
+				} else {
+					add(BrokenLinkKindAsset, htmlAttr(n, "href"))
+				}
+			case "img", "source", "video", "audio", "script", "track", "embed":
+				add(BrokenLinkKindAsset, htmlAttr(n, "src"))
+				add(BrokenLinkKindAsset, htmlAttr(n, "poster"))
+				for _, candidate := range strings.Split(htmlAttr(n, "srcset"), ",") {
+					if fields := strings.Fields(candidate); len(fields) > 0 {
+						add(BrokenLinkKindAsset, fields[0])
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, containerID)
+		}
+	}
+	walk(doc, "")
+	return
+}
+
+func (b *Builder) newLinkAuditor(ctx context.Context, db *gorm.DB) (a *linkAuditor, pages []*Page, err error) {
+	a = &linkAuditor{
+		ctx:         ctx,
+		pages:       map[string]bool{},
+		unpublished: map[string]bool{},
+		ignore:      b.linkAuditIgnore,
+		media:       mediaoss.Storage,
+		mediaPrefix: mediaURLPrefix(),
+		assets:      map[string]bool{},
+	}
+	if a.media != nil {
+		if endpoint, parseErr := url.Parse(a.media.GetEndpoint(ctx)); parseErr == nil {
+			a.mediaHost = endpoint.Host
+		}
+	}
+	var all []*Page
+	if err = db.Select("id", "version", "locale_code", "title", "status", "online_url").
+		Where("online_url <> ''").Find(&all).Error; err != nil {
+		return
+	}
+	for _, p := range all {
+		u := normalizeRedirectPath(p.getAccessUrl(p.OnlineUrl))
+		if p.Status.Status == publish.StatusOnline {
+			a.pages[u] = true
+			pages = append(pages, p)
+			continue
+		}
+		a.unpublished[u] = true
+	}
+	if b.redirectsEnabled {
+		if a.redirects, err = loadRedirects(db); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// AuditLinks renders the online pages and records their broken links and assets, the ones found by
+// the previous audit are replaced
+func (b *Builder) AuditLinks(ctx context.Context) (report *LinkAuditReport, err error) {
+	pm := b.GetPageModelBuilder()
+	if pm == nil {
+		return nil, errors.New("page builder: pages are not enabled")
+	}
+	var (
+		db    = b.db.WithContext(ctx)
+		a     *linkAuditor
+		pages []*Page
+	)
+	report = &LinkAuditReport{}
+	if a, pages, err = b.newLinkAuditor(ctx, db); err != nil {
+		return
+	}
+	for _, p := range pages {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		var broken []*BrokenLink
+		if broken, err = b.auditPage(ctx, db, pm, a, p, report); err != nil {
+			return
+		}
+		report.Pages++
+		report.Broken = append(report.Broken, broken...)
+	}
+	err = utils.Transact(db, func(tx *gorm.DB) error {
+		if err := tx.Where("page_model_name = ?", pm.name).Delete(&BrokenLink{}).Error; err != nil {
+			return err
+		}
+		if len(report.Broken) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(report.Broken, 100).Error
+	})
+	return
+}
+
+func (b *Builder) auditPage(ctx context.Context, db *gorm.DB, pm *ModelBuilder, a *linkAuditor, p *Page, report *LinkAuditReport) (broken []*BrokenLink, err error) {
+	var (
+		pageUrl = normalizeRedirectPath(p.getAccessUrl(p.OnlineUrl))
+		base    = &url.URL{Path: pageUrl + "/"}
+		doc     *html.Node
+		cons    []*Container
+	)
+	if doc, err = html.Parse(strings.NewReader(pm.previewHTML(p.PrimarySlug(), ""))); err != nil {
+		return
+	}
+	if err = db.Where("page_id = ? AND page_version = ? AND locale_code = ? AND page_model_name = ?",
+		p.ID, p.Version.Version, p.LocaleCode, pm.name).Find(&cons).Error; err != nil {
+		return
+	}
+	containers := map[string]*Container{}
+	for _, c := range cons {
+		if cb := b.ContainerByName(c.ModelName); cb != nil {
+			containers[cb.getContainerDataID(int(c.ModelID))] = c
+		}
+	}
+	seen := map[linkAuditRef]bool{}
+	for _, ref := range linkAuditRefs(doc) {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		report.Links++
+		check := a.checkLink
+		if ref.kind == BrokenLinkKindAsset {
+			check = a.checkAsset
+		}
+		reason := check(base, ref.url)
+		if reason == "" {
+			continue
+		}
+		l := &BrokenLink{
+			PageModelName: pm.name,
+			PageID:        p.ID,
+			PageVersion:   p.Version.Version,
+			LocaleCode:    p.LocaleCode,
+			PageTitle:     p.Title,
+			PageUrl:       pageUrl,
+			Kind:          ref.kind,
+			Url:           ref.url,
+			Reason:        reason,
+		}
+		if c, ok := containers[ref.containerID]; ok {
+			l.ContainerID = c.ID
+			l.ContainerName = c.DisplayName
+		}
+		broken = append(broken, l)
+	}
+	return
+}
+
+func brokenLinkReasonText(reason string, msgr *Messages) string {
+	switch reason {
+	case BrokenLinkUnpublished:
+		return msgr.BrokenLinkUnpublished
+	case BrokenLinkBrokenRedirect:
+		return msgr.BrokenLinkBrokenRedirect
+	case BrokenLinkMissingAsset:
+		return msgr.BrokenLinkMissingAsset
+	}
+	return msgr.BrokenLinkNotFound
+}
+
+func (b *Builder) installLinkAudit(pb *presets.Builder) {
+	pageModel := b.GetPageModelBuilder()
+	mb := pb.Model(&BrokenLink{}).URIName("page_broken_links").MenuIcon("mdi-link-variant-off")
+	mb.LabelName(func(evCtx *web.EventContext, singular bool) string {
+		msgr := i18n.MustGetModuleMessages(evCtx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		return msgr.ModelLabelBrokenLinks
+	})
+	eb := mb.Editing()
+	eb.SaveFunc(func(obj any, id string, ctx *web.EventContext) error {
+		return errors.New("should not be used")
+	})
+	eb.DeleteFunc(func(obj any, id string, ctx *web.EventContext) error {
+		return errors.New("should not be used")
+	})
+
+	lb := mb.Listing("PageTitle", "ContainerName", "Kind", "Url", "Reason", "CreatedAt").SearchColumns("page_title", "url")
+	lb.NewButtonFunc(func(ctx *web.EventContext) h.HTMLComponent { return nil })
+	lb.RowMenu().Empty()
+	lb.WrapColumns(presets.CustomizeColumnLabel(func(evCtx *web.EventContext) (map[string]string, error) {
+		msgr := i18n.MustGetModuleMessages(evCtx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		return map[string]string{
+			"PageTitle":     msgr.BrokenLinkPage,
+			"ContainerName": msgr.BrokenLinkContainer,
+			"Kind":          msgr.BrokenLinkKind,
+			"Url":           msgr.BrokenLinkUrl,
+			"Reason":        msgr.BrokenLinkReason,
+			"CreatedAt":     msgr.BrokenLinkCheckedAt,
+		}, nil
+	}))
+	lb.Field("PageTitle").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		l := obj.(*BrokenLink)
+		page := &Page{Model: gorm.Model{ID: l.PageID}}
+		page.Version.Version = l.PageVersion
+		page.LocaleCode = l.LocaleCode
+		return h.Td(
+			h.A(h.Text(l.PageTitle)).Href(pageModel.mb.Info().DetailingHref(page.PrimarySlug())).Attr("@click.stop", ""),
+			h.Div(h.Text(l.PageUrl)).Class("text-caption text-grey"),
+		)
+	})
+	lb.Field("Kind").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		if obj.(*BrokenLink).Kind == BrokenLinkKindAsset {
+			return h.Td(h.Text(msgr.BrokenLinkKindAsset))
+		}
+		return h.Td(h.Text(msgr.BrokenLinkKindLink))
+	})
+	lb.Field("Reason").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		return h.Td(VChip(h.Text(brokenLinkReasonText(obj.(*BrokenLink).Reason, msgr))).
+			Color(ColorError).Size(SizeSmall).Variant(VariantTonal))
+	})
+	lb.Field("CreatedAt").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return h.Td(h.Text(obj.(*BrokenLink).CreatedAt.Local().Format("2006-01-02 15:04")))
+	})
+	lb.Action("RunLinkAudit").ButtonCompFunc(func(ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		return VBtn(msgr.RunLinkAudit).
+			PrependIcon("mdi-play").
+			Variant(VariantTonal).
+			Color(ColorPrimary).
+			Class("ml-2").
+			Attr("@click", web.Plaid().EventFunc(RunLinkAuditEvent).Go())
+	})
+	mb.RegisterEventFunc(RunLinkAuditEvent, b.runLinkAudit)
+}
+
+func (b *Builder) runLinkAudit(ctx *web.EventContext) (r web.EventResponse, err error) {
+	msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), ColorError)
+			err = nil
+		}
+	}()
+	if pm := b.GetPageModelBuilder(); pm != nil {
+		if err = pm.mb.Info().Verifier().Do(presets.PermUpdate).WithReq(ctx.R).IsAllowed(); err != nil {
+			return
+		}
+	}
+	if _, err = b.linkAuditWorker.AddJob(ctx.R.Context(), LinkAuditJobName, &LinkAuditJobArgs{}); err != nil {
+		return
+	}
+	presets.ShowMessage(&r, msgr.LinkAuditStarted, ColorSuccess)
+	return
+}
@@ -95,6 +95,103 @@ type Messages struct {
 
 	CategoryDeleteConfirmationText string
 	TheResourceCanNotBeModified    string
+
+	Export                string
+	ImportBundle          string
+	ImportBundleHint      string
+	SuccessfullyImported  string
+	ImportedWithWarnings  string
+	UnknownContainerTypes string
+
+	Undo            string
+	Redo            string
+	NothingToUndo   string
+	NothingToRedo   string
+	HistoryConflict string
+
+	Devices                string
+	DevicesHint            string
+	DeviceOverrideHint     string
+	SelectAtLeastOneDevice string
+	InvalidDeviceOverride  string
+
+	ContainerSchedule                string
+	ContainerScheduleHint            string
+	ShowFrom                         string
+	ShowUntil                        string
+	ShowUntilShouldLaterThanShowFrom string
+
+	Variants              string
+	VariantsHint          string
+	OriginalVariant       string
+	VariantName           string
+	NewVariantName        string
+	VariantWeight         string
+	VariantTraffic        string
+	VariantImpressions    string
+	VariantConversions    string
+	VariantConversionRate string
+	AddVariant            string
+	EditVariant           string
+	SaveVariantWeights    string
+	PromoteVariant        string
+	PromoteVariantConfirm string
+	VariantPromoted       string
+	InvalidVariantName    string
+	VariantNameExists     string
+	InvalidVariantWeight  string
+
+	PageTree              string
+	PageTreeHint          string
+	PageTreeRootDropZone  string
+	ParentPage            string
+	ParentCategory        string
+	InvalidParentPage     string
+	InvalidParentCategory string
+	PageMovedInDraft      string
+
+	PageTreeRoot string
+
+	ModelLabelRedirect  string
+	ModelLabelRedirects string
+	RedirectFrom        string
+	RedirectTo          string
+	RedirectStatusCode  string
+	RedirectAutomatic   string
+	RedirectPermanent   string
+	RedirectTemporary   string
+	RedirectFromHint    string
+	InvalidRedirectFrom string
+	InvalidRedirectTo   string
+	RedirectToItself    string
+	RedirectFromExists  string
+
+	ModelLabelBrokenLinks    string
+	BrokenLinkPage           string
+	BrokenLinkContainer      string
+	BrokenLinkKind           string
+	BrokenLinkKindLink       string
+	BrokenLinkKindAsset      string
+	BrokenLinkUrl            string
+	BrokenLinkReason         string
+	BrokenLinkCheckedAt      string
+	BrokenLinkNotFound       string
+	BrokenLinkUnpublished    string
+	BrokenLinkBrokenRedirect string
+	BrokenLinkMissingAsset   string
+	RunLinkAudit             string
+	LinkAuditStarted         string
+
+	A11yAudit                  string
+	A11ySummaryTemplate        string
+	A11yNoIssues               string
+	A11yPageLayout             string
+	A11yImageAlt               string
+	A11yHeadingOrder           string
+	A11yEmptyLink              string
+	A11yColorContrast          string
+	A11yHTMLLang               string
+	A11yPublishBlockedTemplate string
 }
 
 var Messages_en_US = &Messages{
@@ -185,6 +282,103 @@ var Messages_en_US = &Messages{
 	Description:                    "Description",
 	CategoryDeleteConfirmationText: "this will remove all the records in all localized languages",
 	TheResourceCanNotBeModified:    "The resource can not be modified",
+
+	Export:                "Export",
+	ImportBundle:          "Bundle",
+	ImportBundleHint:      "A .zip or .json file exported from a page builder",
+	SuccessfullyImported:  "Successfully Imported",
+	ImportedWithWarnings:  "Imported with warnings:",
+	UnknownContainerTypes: "unknown container types",
+
+	Undo:            "Undo",
+	Redo:            "Redo",
+	NothingToUndo:   "Nothing to undo",
+	NothingToRedo:   "Nothing to redo",
+	HistoryConflict: "The containers were changed by someone else since, reload the page to see their changes",
+
+	Devices:                "Devices",
+	DevicesHint:            "Show the container on the selected devices, the fields filled in override the container on that device",
+	DeviceOverrideHint:     "Same as the container",
+	SelectAtLeastOneDevice: "Select at least one device, or hide the container",
+	InvalidDeviceOverride:  "Invalid override:",
+
+	ContainerSchedule:                "Schedule",
+	ContainerScheduleHint:            "The container is only shown between these times, published pages are republished when it appears or disappears",
+	ShowFrom:                         "Show from",
+	ShowUntil:                        "Show until",
+	ShowUntilShouldLaterThanShowFrom: "The end time should be later than the start time",
+
+	Variants:              "A/B Variants",
+	VariantsHint:          "Visitors of the online page are split between the variants by their weights, promote the winner to end the test",
+	OriginalVariant:       "Original",
+	VariantName:           "Variant",
+	NewVariantName:        "New variant name",
+	VariantWeight:         "Weight",
+	VariantTraffic:        "Traffic",
+	VariantImpressions:    "Impressions",
+	VariantConversions:    "Conversions",
+	VariantConversionRate: "Conversion rate",
+	AddVariant:            "Add Variant",
+	EditVariant:           "Edit Variant",
+	SaveVariantWeights:    "Save Weights",
+	PromoteVariant:        "Promote",
+	PromoteVariantConfirm: "A new draft is created with the containers of this variant, the test goes on until the draft is published, are you sure?",
+	VariantPromoted:       "A new draft is created from the variant",
+	InvalidVariantName:    "The variant name should be letters, digits or dashes",
+	VariantNameExists:     "The variant name already exists",
+	InvalidVariantWeight:  "The weights should be numbers not less than 0",
+
+	PageTree:              "Page Tree",
+	PageTreeHint:          "Drag a page onto another one to move it under it, the URLs of the page and its children follow its parent",
+	PageTreeRootDropZone:  "Drop here to make it a top level page",
+	ParentPage:            "Parent Page",
+	ParentCategory:        "Parent Category",
+	InvalidParentPage:     "The page can not be under itself or one of its children",
+	InvalidParentCategory: "The category can not be under itself or one of its children",
+	PageMovedInDraft:      "The page is moved in its draft, the move goes online when the draft is published",
+
+	PageTreeRoot: "None (top level)",
+
+	ModelLabelRedirect:  "Redirect",
+	ModelLabelRedirects: "Redirects",
+	RedirectFrom:        "From",
+	RedirectTo:          "To",
+	RedirectStatusCode:  "Status Code",
+	RedirectAutomatic:   "Automatic",
+	RedirectPermanent:   "301 Moved Permanently",
+	RedirectTemporary:   "302 Found",
+	RedirectFromHint:    "End the path with /* to redirect the paths under it, the rest of the path replaces the * of the target",
+	InvalidRedirectFrom: "The path should start with / and can only end with /*",
+	InvalidRedirectTo:   "The target should be a path starting with / or a URL with at most one *",
+	RedirectToItself:    "The path can not be redirected to itself",
+	RedirectFromExists:  "A redirect from the path already exists",
+
+	ModelLabelBrokenLinks:    "Broken Links",
+	BrokenLinkPage:           "Page",
+	BrokenLinkContainer:      "Container",
+	BrokenLinkKind:           "Kind",
+	BrokenLinkKindLink:       "Link",
+	BrokenLinkKindAsset:      "Asset",
+	BrokenLinkUrl:            "URL",
+	BrokenLinkReason:         "Reason",
+	BrokenLinkCheckedAt:      "Checked At",
+	BrokenLinkNotFound:       "Not found",
+	BrokenLinkUnpublished:    "Page unpublished",
+	BrokenLinkBrokenRedirect: "Redirected to a missing page",
+	BrokenLinkMissingAsset:   "Missing asset",
+	RunLinkAudit:             "Check Links",
+	LinkAuditStarted:         "The link check is started, its progress is in the jobs",
+
+	A11yAudit:                  "Accessibility",
+	A11ySummaryTemplate:        "%d errors, %d warnings",
+	A11yNoIssues:               "No accessibility issues found",
+	A11yPageLayout:             "Page layout",
+	A11yImageAlt:               "Image without alternative text, fill in the description of the media",
+	A11yHeadingOrder:           "Heading levels are skipped",
+	A11yEmptyLink:              "Link without text",
+	A11yColorContrast:          "Insufficient color contrast",
+	A11yHTMLLang:               "Page language is not declared",
+	A11yPublishBlockedTemplate: "%d accessibility errors should be fixed before publishing, see the accessibility audit of the editor",
 }
 
 var Messages_zh_CN = &Messages{
@@ -276,6 +470,103 @@ var Messages_zh_CN = &Messages{
 
 	CategoryDeleteConfirmationText: "这将删除所有本地化语言中的所有记录",
 	TheResourceCanNotBeModified:    "该资源无法被修改",
+
+	Export:                "导出",
+	ImportBundle:          "导出包",
+	ImportBundleHint:      "从页面构建器导出的 .zip 或 .json 文件",
+	SuccessfullyImported:  "导入成功",
+	ImportedWithWarnings:  "导入完成，但有警告：",
+	UnknownContainerTypes: "未知的容器类型",
+
+	Undo:            "撤销",
+	Redo:            "重做",
+	NothingToUndo:   "没有可撤销的操作",
+	NothingToRedo:   "没有可重做的操作",
+	HistoryConflict: "这些容器已被他人修改，请重新加载页面查看修改",
+
+	Devices:                "设备",
+	DevicesHint:            "在选中的设备上显示该容器，填写的字段将覆盖该设备上的容器内容",
+	DeviceOverrideHint:     "与容器相同",
+	SelectAtLeastOneDevice: "请至少选择一个设备，或隐藏该容器",
+	InvalidDeviceOverride:  "无效的覆盖值：",
+
+	ContainerSchedule:                "定时显示",
+	ContainerScheduleHint:            "容器仅在此时间段内显示，显示或隐藏时已发布的页面会自动重新发布",
+	ShowFrom:                         "开始显示",
+	ShowUntil:                        "结束显示",
+	ShowUntilShouldLaterThanShowFrom: "结束时间应晚于开始时间",
+
+	Variants:              "A/B 变体",
+	VariantsHint:          "在线页面的访问者按权重分配到各变体，推广胜出的变体以结束测试",
+	OriginalVariant:       "原始版本",
+	VariantName:           "变体",
+	NewVariantName:        "新变体名称",
+	VariantWeight:         "权重",
+	VariantTraffic:        "流量",
+	VariantImpressions:    "展示次数",
+	VariantConversions:    "转化次数",
+	VariantConversionRate: "转化率",
+	AddVariant:            "添加变体",
+	EditVariant:           "编辑变体",
+	SaveVariantWeights:    "保存权重",
+	PromoteVariant:        "推广",
+	PromoteVariantConfirm: "将使用该变体的容器创建新的草稿，测试将持续到草稿发布为止，确定吗？",
+	VariantPromoted:       "已从变体创建新的草稿",
+	InvalidVariantName:    "变体名称只能包含字母、数字或短横线",
+	VariantNameExists:     "变体名称已存在",
+	InvalidVariantWeight:  "权重必须是不小于 0 的数字",
+
+	PageTree:              "页面树",
+	PageTreeHint:          "将页面拖到另一个页面上即可移到其下，页面及其子页面的 URL 将随父页面变化",
+	PageTreeRootDropZone:  "拖到这里成为顶级页面",
+	ParentPage:            "父页面",
+	ParentCategory:        "父分类",
+	InvalidParentPage:     "页面不能位于其自身或其子页面之下",
+	InvalidParentCategory: "分类不能位于其自身或其子分类之下",
+	PageMovedInDraft:      "页面已在草稿中移动，草稿发布后生效",
+
+	PageTreeRoot: "无（顶级）",
+
+	ModelLabelRedirect:  "重定向",
+	ModelLabelRedirects: "重定向",
+	RedirectFrom:        "来源",
+	RedirectTo:          "目标",
+	RedirectStatusCode:  "状态码",
+	RedirectAutomatic:   "自动",
+	RedirectPermanent:   "301 永久重定向",
+	RedirectTemporary:   "302 临时重定向",
+	RedirectFromHint:    "以 /* 结尾可重定向其下的路径，剩余路径将替换目标中的 *",
+	InvalidRedirectFrom: "路径应以 / 开头，且只能以 /* 结尾",
+	InvalidRedirectTo:   "目标应为以 / 开头的路径或 URL，且最多包含一个 *",
+	RedirectToItself:    "路径不能重定向到自身",
+	RedirectFromExists:  "该路径的重定向已存在",
+
+	ModelLabelBrokenLinks:    "失效链接",
+	BrokenLinkPage:           "页面",
+	BrokenLinkContainer:      "容器",
+	BrokenLinkKind:           "类型",
+	BrokenLinkKindLink:       "链接",
+	BrokenLinkKindAsset:      "资源",
+	BrokenLinkUrl:            "URL",
+	BrokenLinkReason:         "原因",
+	BrokenLinkCheckedAt:      "检查时间",
+	BrokenLinkNotFound:       "未找到",
+	BrokenLinkUnpublished:    "页面已下线",
+	BrokenLinkBrokenRedirect: "重定向到不存在的页面",
+	BrokenLinkMissingAsset:   "资源缺失",
+	RunLinkAudit:             "检查链接",
+	LinkAuditStarted:         "链接检查已开始，可在任务中查看进度",
+
+	A11yAudit:                  "无障碍检查",
+	A11ySummaryTemplate:        "%d 个错误，%d 个警告",
+	A11yNoIssues:               "未发现无障碍问题",
+	A11yPageLayout:             "页面布局",
+	A11yImageAlt:               "图片缺少替代文本，请填写媒体的描述",
+	A11yHeadingOrder:           "标题层级跳跃",
+	A11yEmptyLink:              "链接没有文本",
+	A11yColorContrast:          "颜色对比度不足",
+	A11yHTMLLang:               "未声明页面语言",
+	A11yPublishBlockedTemplate: "发布前需要修复 %d 个无障碍错误，请查看编辑器中的无障碍检查",
 }
 
 var Messages_ja_JP = &Messages{
@@ -366,6 +657,103 @@ var Messages_ja_JP = &Messages{
 	Description:                    "説明",
 	CategoryDeleteConfirmationText: "これは、すべてのローカライズされた言語のすべてのレコードを削除します",
 	TheResourceCanNotBeModified:    "このリソースは変更できません",
+
+	Export:                "エクスポート",
+	ImportBundle:          "バンドル",
+	ImportBundleHint:      "ページビルダーからエクスポートした .zip または .json ファイル",
+	SuccessfullyImported:  "インポートしました",
+	ImportedWithWarnings:  "警告付きでインポートしました：",
+	UnknownContainerTypes: "不明なコンテナタイプ",
+
+	Undo:            "元に戻す",
+	Redo:            "やり直し",
+	NothingToUndo:   "元に戻す操作はありません",
+	NothingToRedo:   "やり直す操作はありません",
+	HistoryConflict: "コンテナは他のユーザーによって変更されました。ページを再読み込みして変更を確認してください",
+
+	Devices:                "デバイス",
+	DevicesHint:            "選択したデバイスにコンテナを表示します。入力したフィールドはそのデバイスでのコンテナの値を上書きします",
+	DeviceOverrideHint:     "コンテナと同じ",
+	SelectAtLeastOneDevice: "少なくとも1つのデバイスを選択するか、コンテナを非表示にしてください",
+	InvalidDeviceOverride:  "無効な上書き値：",
+
+	ContainerSchedule:                "表示スケジュール",
+	ContainerScheduleHint:            "コンテナはこの期間のみ表示されます。表示・非表示が切り替わると公開済みのページは自動的に再公開されます",
+	ShowFrom:                         "表示開始",
+	ShowUntil:                        "表示終了",
+	ShowUntilShouldLaterThanShowFrom: "終了時刻は開始時刻より後にしてください",
+
+	Variants:              "A/B バリアント",
+	VariantsHint:          "公開中のページの訪問者は重みに応じて各バリアントに振り分けられます。勝者を採用するとテストが終了します",
+	OriginalVariant:       "オリジナル",
+	VariantName:           "バリアント",
+	NewVariantName:        "新しいバリアント名",
+	VariantWeight:         "重み",
+	VariantTraffic:        "トラフィック",
+	VariantImpressions:    "表示回数",
+	VariantConversions:    "コンバージョン数",
+	VariantConversionRate: "コンバージョン率",
+	AddVariant:            "バリアントを追加",
+	EditVariant:           "バリアントを編集",
+	SaveVariantWeights:    "重みを保存",
+	PromoteVariant:        "採用",
+	PromoteVariantConfirm: "このバリアントのコンテナで新しい下書きを作成します。テストは下書きが公開されるまで続きます。よろしいですか？",
+	VariantPromoted:       "バリアントから新しい下書きを作成しました",
+	InvalidVariantName:    "バリアント名は英数字またはハイフンで入力してください",
+	VariantNameExists:     "バリアント名はすでに存在します",
+	InvalidVariantWeight:  "重みは 0 以上の数値にしてください",
+
+	PageTree:              "ページツリー",
+	PageTreeHint:          "ページを別のページにドラッグするとその下に移動します。ページと子ページの URL は親ページに従います",
+	PageTreeRootDropZone:  "ここにドロップするとトップレベルのページになります",
+	ParentPage:            "親ページ",
+	ParentCategory:        "親カテゴリー",
+	InvalidParentPage:     "ページを自身またはその子ページの下に置くことはできません",
+	InvalidParentCategory: "カテゴリーを自身またはその子カテゴリーの下に置くことはできません",
+	PageMovedInDraft:      "ページは下書きで移動されました。下書きの公開後に反映されます",
+
+	PageTreeRoot: "なし（トップレベル）",
+
+	ModelLabelRedirect:  "リダイレクト",
+	ModelLabelRedirects: "リダイレクト",
+	RedirectFrom:        "リダイレクト元",
+	RedirectTo:          "リダイレクト先",
+	RedirectStatusCode:  "ステータスコード",
+	RedirectAutomatic:   "自動",
+	RedirectPermanent:   "301 恒久的な移動",
+	RedirectTemporary:   "302 一時的な移動",
+	RedirectFromHint:    "/* で終わるとその下のパスをリダイレクトします。残りのパスはリダイレクト先の * を置き換えます",
+	InvalidRedirectFrom: "パスは / で始まり、末尾にのみ /* を使用できます",
+	InvalidRedirectTo:   "リダイレクト先は / で始まるパスまたは URL で、* は 1 つまでです",
+	RedirectToItself:    "パスを自身にリダイレクトすることはできません",
+	RedirectFromExists:  "このパスからのリダイレクトは既に存在します",
+
+	ModelLabelBrokenLinks:    "リンク切れ",
+	BrokenLinkPage:           "ページ",
+	BrokenLinkContainer:      "コンテナ",
+	BrokenLinkKind:           "種類",
+	BrokenLinkKindLink:       "リンク",
+	BrokenLinkKindAsset:      "アセット",
+	BrokenLinkUrl:            "URL",
+	BrokenLinkReason:         "理由",
+	BrokenLinkCheckedAt:      "チェック日時",
+	BrokenLinkNotFound:       "見つかりません",
+	BrokenLinkUnpublished:    "ページが非公開です",
+	BrokenLinkBrokenRedirect: "存在しないページにリダイレクトされます",
+	BrokenLinkMissingAsset:   "アセットがありません",
+	RunLinkAudit:             "リンクをチェック",
+	LinkAuditStarted:         "リンクチェックを開始しました。進捗はジョブで確認できます",
+
+	A11yAudit:                  "アクセシビリティ",
+	A11ySummaryTemplate:        "エラー %d 件、警告 %d 件",
+	A11yNoIssues:               "アクセシビリティの問題は見つかりませんでした",
+	A11yPageLayout:             "ページレイアウト",
+	A11yImageAlt:               "画像に代替テキストがありません。メディアの説明を入力してください",
+	A11yHeadingOrder:           "見出しレベルが飛んでいます",
+	A11yEmptyLink:              "テキストのないリンク",
+	A11yColorContrast:          "色のコントラストが不足しています",
+	A11yHTMLLang:               "ページの言語が宣言されていません",
+	A11yPublishBlockedTemplate: "公開する前に %d 件のアクセシビリティエラーを修正してください。エディタのアクセシビリティ監査を確認してください",
 }
 
 type ModelsI18nModulePage struct {
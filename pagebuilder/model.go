@@ -12,6 +12,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/qor5/web/v3"
 	"github.com/qor5/x/v3/i18n"
@@ -40,6 +41,7 @@ type (
 		preview         http.Handler
 		tb              *TemplateBuilder
 		eventMiddleware eventMiddlewareFunc
+		variants        *variantCache
 	}
 )
 
@@ -71,10 +73,10 @@ func (b *ModelBuilder) setName() {
 	b.name = utils.GetObjectName(b.mb.NewModel())
 }
 
-func (b *ModelBuilder) addSharedContainerToPage(pageID int, containerID, pageVersion, locale, modelName string, modelID uint) (newContainerID string, err error) {
+func (b *ModelBuilder) addSharedContainerToPage(db *gorm.DB, pageID int, containerID, pageVersion, locale, modelName string, modelID uint) (newContainerID string, err error) {
 	var c Container
 
-	err = b.db.Transaction(func(tx *gorm.DB) (dbErr error) {
+	err = db.Transaction(func(tx *gorm.DB) (dbErr error) {
 		if dbErr = tx.First(&c, "model_name = ? AND model_id = ? AND shared = true and page_model_name = ? ", modelName, modelID, b.name).Error; dbErr != nil {
 			return
 		}
@@ -144,7 +146,7 @@ func (b *ModelBuilder) addContainerToPage(ctx *web.EventContext, pageID int, con
 		model       = containerMb.NewModel()
 	)
 
-	err = b.db.Transaction(func(tx *gorm.DB) (dbErr error) {
+	err = b.editorDB(ctx).Transaction(func(tx *gorm.DB) (dbErr error) {
 		tx.Where("model_name = ? AND locale_code = ?", modelName, locale).First(&dc)
 		if dc.ID != 0 && dc.ModelID != 0 {
 			tx.Where("id = ?", dc.ModelID).First(model)
@@ -223,8 +225,11 @@ func (b *ModelBuilder) pageContent(ctx *web.EventContext) (r web.PageResponse, e
 	return
 }
 
+// getPrimaryColumnValuesBySlug returns the page version of the containers of the variant edited
 func (b *ModelBuilder) getPrimaryColumnValuesBySlug(ctx *web.EventContext) (pageID int, pageVersion string, locale string) {
-	return b.primaryColumnValuesBySlug(ctx.Param(presets.ParamID))
+	pageID, pageVersion, locale = b.primaryColumnValuesBySlug(ctx.Param(presets.ParamID))
+	pageVersion = variantPageVersion(pageVersion, ctx.Param(paramVariant))
+	return
 }
 
 func (b *ModelBuilder) primaryColumnValuesBySlug(slug string) (pageID int, pageVersion string, locale string) {
@@ -288,10 +293,11 @@ func (b *ModelBuilder) renderScrollIframe(comps []h.HTMLComponent, ctx *web.Even
 		seoTags = b.builder.seoBuilder.Render(obj, ctx.R)
 	}
 	input := &PageLayoutInput{
-		LocaleCode: locale,
-		IsEditor:   isEditor,
-		IsPreview:  !isEditor,
-		SeoTags:    seoTags,
+		LocaleCode:  locale,
+		IsEditor:    isEditor,
+		IsPreview:   !isEditor,
+		SeoTags:     seoTags,
+		Breadcrumbs: b.breadcrumbs(obj, locale),
 	}
 
 	if isEditor {
@@ -523,31 +529,64 @@ func (b *ModelBuilder) renderContainers(ctx *web.EventContext, obj interface{},
 	if err != nil {
 		return
 	}
-	device, _ := b.builder.getDevice(ctx)
+	var (
+		device, _ = b.builder.getDevice(ctx)
+		// the published pages are rendered for all devices, the variants for other devices are hidden by css
+		responsive   = !isEditor && ctx.Param(paramsDevice) == ""
+		deviceHidden bool
+		now          = b.db.NowFunc()
+		breadcrumbs  = b.breadcrumbs(obj, locale)
+	)
 	cbs := b.builder.getContainerBuilders(cons)
 	for i, ec := range cbs {
 		if ec.container.Hidden {
 			continue
 		}
+		if !responsive && !ec.container.VisibleOn(device) {
+			continue
+		}
+		if !isEditor && !ec.container.VisibleAt(now) {
+			continue
+		}
 		containerObj := ec.builder.NewModel()
 		err = b.db.FirstOrCreate(containerObj, "id = ?", ec.container.ModelID).Error
 		if err != nil {
 			return
 		}
-		input := RenderInput{
-			IsEditor:    isEditor,
-			IsReadonly:  isReadonly,
-			Device:      device,
-			ContainerId: ec.container.PrimarySlug(),
-			DisplayName: ec.container.DisplayName,
-			Obj:         obj,
+		variants := []*deviceVariant{{fields: ec.container.GetDeviceOverrides()[device]}}
+		if responsive {
+			variants = b.builder.deviceVariants(ec.container)
 		}
-		pure := ec.builder.renderFunc(containerObj, &input, ctx)
+		for _, v := range variants {
+			var variantObj interface{}
+			if variantObj, err = applyDeviceOverrides(containerObj, v.fields); err != nil {
+				return
+			}
+			input := RenderInput{
+				IsEditor:    isEditor,
+				IsReadonly:  isReadonly,
+				Device:      device,
+				ContainerId: ec.container.PrimarySlug(),
+				DisplayName: ec.container.DisplayName,
+				Obj:         obj,
+				Breadcrumbs: breadcrumbs,
+			}
+			if v.device != "" {
+				input.Device = v.device
+			}
+			pure := ec.builder.renderFunc(variantObj, &input, ctx).(*h.HTMLTagBuilder)
+			for _, d := range v.hidden {
+				pure.Class(deviceHiddenClass(d))
+				deviceHidden = true
+			}
 
-		r = append(r, b.builder.containerWrapper(pure.(*h.HTMLTagBuilder), ctx, isEditor, isReadonly, i == 0, i == len(cbs)-1,
-			ec.builder.getContainerDataID(int(ec.container.ModelID)), ec.container.ModelName, &input))
+			r = append(r, b.builder.containerWrapper(pure, ctx, isEditor, isReadonly, i == 0, i == len(cbs)-1,
+				ec.builder.getContainerDataID(int(ec.container.ModelID)), ec.container.ModelName, &input))
+		}
+	}
+	if deviceHidden {
+		r = append(r, h.Style(b.builder.deviceStyle()))
 	}
-
 	return
 }
 
@@ -593,6 +632,7 @@ func (b *ModelBuilder) renderPreviewContainer(ctx *web.EventContext, obj interfa
 		ContainerId: "",
 		DisplayName: modelName,
 		Obj:         obj,
+		Breadcrumbs: b.breadcrumbs(obj, locale),
 	}
 	containerObj := containerBuilder.NewModel()
 	err = b.db.FirstOrCreate(containerObj, "id = ?", modelID).Error
@@ -639,6 +679,40 @@ func (b *ModelBuilder) markAsSharedContainer(ctx *web.EventContext) (r web.Event
 	return
 }
 
+// newDraftVersion saves obj, a version of the page, as a new draft version with the containers of containersVersion
+func (b *ModelBuilder) newDraftVersion(tx *gorm.DB, obj interface{}, containersVersion string) (err error) {
+	v, ok := obj.(publish.VersionInterface)
+	if !ok {
+		return errors.New("pagebuilder: the page has no versions")
+	}
+	var (
+		pageID, oldVersion, locale = b.primaryColumnValuesBySlug(obj.(presets.SlugEncoder).PrimarySlug())
+		version                    = v.EmbedVersion()
+		newVersion                 string
+	)
+	if newVersion, err = version.CreateVersion(tx, obj.(presets.SlugEncoder).PrimarySlug(), b.mb.NewModel()); err != nil {
+		return
+	}
+	*version = publish.Version{Version: newVersion, VersionName: newVersion, ParentVersion: oldVersion}
+	if p, ok := obj.(publish.StatusInterface); ok {
+		*p.EmbedStatus() = publish.Status{Status: publish.StatusDraft}
+	}
+	if p, ok := obj.(publish.ScheduleInterface); ok {
+		*p.EmbedSchedule() = publish.Schedule{}
+	}
+	for _, field := range []string{"CreatedAt", "UpdatedAt"} {
+		if _, getErr := reflectutils.Get(obj, field); getErr == nil {
+			if err = reflectutils.Set(obj, field, time.Time{}); err != nil {
+				return
+			}
+		}
+	}
+	if err = tx.Create(obj).Error; err != nil {
+		return
+	}
+	return b.copyContainersToAnotherPage(tx, pageID, containersVersion, locale, pageID, newVersion, locale, b.name, b.name)
+}
+
 func (b *ModelBuilder) copyContainersToNewPageVersion(db *gorm.DB, pageID int, locale, oldPageVersion, newPageVersion, fromModelName, toModelName string) (err error) {
 	return b.copyContainersToAnotherPage(db, pageID, oldPageVersion, locale, pageID, newPageVersion, locale, fromModelName, toModelName)
 }
@@ -672,14 +746,18 @@ func (b *ModelBuilder) copyContainersToAnotherPage(db *gorm.DB, pageID int, page
 		}
 
 		if err = db.Create(&Container{
-			PageID:        uint(toPageID),
-			PageVersion:   toPageVersion,
-			PageModelName: toModelName,
-			ModelName:     c.ModelName,
-			DisplayName:   c.DisplayName,
-			ModelID:       newModelID,
-			DisplayOrder:  c.DisplayOrder,
-			Shared:        c.Shared,
+			PageID:           uint(toPageID),
+			PageVersion:      toPageVersion,
+			PageModelName:    toModelName,
+			ModelName:        c.ModelName,
+			DisplayName:      c.DisplayName,
+			ModelID:          newModelID,
+			DisplayOrder:     c.DisplayOrder,
+			Shared:           c.Shared,
+			Devices:          c.Devices,
+			DeviceOverrides:  c.DeviceOverrides,
+			ScheduledStartAt: c.ScheduledStartAt,
+			ScheduledEndAt:   c.ScheduledEndAt,
 			Locale: l10n.Locale{
 				LocaleCode: toPageLocale,
 			},
@@ -754,6 +832,10 @@ func (b *ModelBuilder) localizeContainersToAnotherPage(db *gorm.DB, pageID int,
 		newCon.ModelID = newModelID
 		newCon.DisplayOrder = c.DisplayOrder
 		newCon.Shared = c.Shared
+		newCon.Devices = c.Devices
+		newCon.DeviceOverrides = c.DeviceOverrides
+		newCon.ScheduledStartAt = c.ScheduledStartAt
+		newCon.ScheduledEndAt = c.ScheduledEndAt
 		newCon.LocaleCode = toPageLocale
 		newCon.LocalizeFromModelID = c.ModelID
 		newCon.PageModelName = b.name
@@ -784,8 +866,7 @@ func (b *ModelBuilder) configDuplicate(mb *presets.ModelBuilder) {
 				if p.Slug != "" {
 					p.Slug = path.Clean(p.Slug)
 				}
-				funcName := ctx.R.FormValue(web.EventFuncIDName)
-				if funcName == publish.EventDuplicateVersion {
+				if publish.IsDuplicatingVersion(ctx) {
 					var fromPage Page
 					eb.Fetcher(&fromPage, ctx.Param(presets.ParamID), ctx)
 					p.SEO = fromPage.SEO
@@ -808,7 +889,7 @@ func (b *ModelBuilder) configDuplicate(mb *presets.ModelBuilder) {
 				version = p.EmbedVersion().Version
 			}
 			err = b.db.Transaction(func(tx *gorm.DB) (inerr error) {
-				if strings.Contains(ctx.R.RequestURI, publish.EventDuplicateVersion) {
+				if publish.IsDuplicatingVersion(ctx) {
 					if inerr = b.copyContainersToNewPageVersion(tx, pageID, localeCode, parentVersion, version, b.name, b.name); inerr != nil {
 						return
 					}
@@ -848,11 +929,24 @@ func (b *ModelBuilder) PreviewHTML(obj interface{}) (r string) {
 	if !ok {
 		return
 	}
+	return b.previewHTML(p.PrimarySlug(), "")
+}
+
+func (b *ModelBuilder) previewHTML(slug, variant string) string {
+	body, _ := b.renderPreview(slug, variant)
+	return body
+}
+
+// renderPreview renders the preview of the record of slug, or of its variant, with the status of the response
+func (b *ModelBuilder) renderPreview(slug, variant string) (body string, status int) {
+	query := url.Values{presets.ParamID: {slug}}
+	if variant != "" {
+		query.Set(paramVariant, variant)
+	}
 	w := httptest.NewRecorder()
-	req := httptest.NewRequest("GET", fmt.Sprintf("/?id=%s", p.PrimarySlug()), nil)
+	req := httptest.NewRequest("GET", "/?"+query.Encode(), nil)
 	b.preview.ServeHTTP(w, req)
-	r = w.Body.String()
-	return
+	return w.Body.String(), w.Code
 }
 
 func (b *ModelBuilder) ContextValueProvider(in context.Context) context.Context {
@@ -30,15 +30,16 @@ type pageBuilderModelKey struct{}
 
 func (b *ModelBuilder) registerFuncs() {
 	b.eventMiddleware = b.defaultWrapEvent
+	b.editor.RegisterEventFunc(A11yAuditDialogEvent, b.a11yAuditDialog)
 	b.editor.RegisterEventFunc(ShowSortedContainerDrawerEvent, b.eventMiddleware(b.showSortedContainerDrawer))
-	b.editor.RegisterEventFunc(AddContainerEvent, b.eventMiddleware(b.addContainer))
+	b.editor.RegisterEventFunc(AddContainerEvent, b.eventMiddleware(b.recordEvent(AddContainerEvent, b.addContainer)))
 	b.editor.RegisterEventFunc(DeleteContainerConfirmationEvent, b.eventMiddleware(b.deleteContainerConfirmation))
-	b.editor.RegisterEventFunc(DeleteContainerEvent, b.eventMiddleware(b.deleteContainer))
-	b.editor.RegisterEventFunc(MoveContainerEvent, b.eventMiddleware(b.moveContainer))
-	b.editor.RegisterEventFunc(MoveUpDownContainerEvent, b.eventMiddleware(b.moveUpDownContainer))
-	b.editor.RegisterEventFunc(ToggleContainerVisibilityEvent, b.eventMiddleware(b.toggleContainerVisibility))
+	b.editor.RegisterEventFunc(DeleteContainerEvent, b.eventMiddleware(b.recordEvent(DeleteContainerEvent, b.deleteContainer)))
+	b.editor.RegisterEventFunc(MoveContainerEvent, b.eventMiddleware(b.recordEvent(MoveContainerEvent, b.moveContainer)))
+	b.editor.RegisterEventFunc(MoveUpDownContainerEvent, b.eventMiddleware(b.recordEvent(MoveUpDownContainerEvent, b.moveUpDownContainer)))
+	b.editor.RegisterEventFunc(ToggleContainerVisibilityEvent, b.eventMiddleware(b.recordEvent(ToggleContainerVisibilityEvent, b.toggleContainerVisibility)))
 	b.editor.RegisterEventFunc(RenameContainerDialogEvent, b.eventMiddleware(b.renameContainerDialog))
-	b.editor.RegisterEventFunc(RenameContainerEvent, b.eventMiddleware(b.renameContainer))
+	b.editor.RegisterEventFunc(RenameContainerEvent, b.eventMiddleware(b.recordEvent(RenameContainerEvent, b.renameContainer)))
 	b.editor.RegisterEventFunc(ReloadRenderPageOrTemplateEvent, b.reloadRenderPageOrTemplate)
 	b.editor.RegisterEventFunc(ReloadRenderPageOrTemplateBodyEvent, b.reloadRenderPageOrTemplateBody)
 	b.editor.RegisterEventFunc(MarkAsSharedContainerEvent, b.eventMiddleware(b.markAsSharedContainer))
@@ -46,6 +47,17 @@ func (b *ModelBuilder) registerFuncs() {
 	b.editor.RegisterEventFunc(ReplicateContainerEvent, b.eventMiddleware(b.replicateContainer))
 	b.editor.RegisterEventFunc(EditContainerEvent, b.eventMiddleware(b.editContainer))
 	b.editor.RegisterEventFunc(UpdateContainerEvent, b.eventMiddleware(b.updateContainer))
+	b.editor.RegisterEventFunc(ContainerDevicesDialogEvent, b.eventMiddleware(b.containerDevicesDialog))
+	b.editor.RegisterEventFunc(UpdateContainerDevicesEvent, b.eventMiddleware(b.recordEvent(UpdateContainerDevicesEvent, b.updateContainerDevices)))
+	b.editor.RegisterEventFunc(ContainerScheduleDialogEvent, b.eventMiddleware(b.containerScheduleDialog))
+	b.editor.RegisterEventFunc(UpdateContainerScheduleEvent, b.eventMiddleware(b.recordEvent(UpdateContainerScheduleEvent, b.updateContainerSchedule)))
+	b.editor.RegisterEventFunc(VariantsDialogEvent, b.variantsDialog)
+	b.editor.RegisterEventFunc(AddVariantEvent, b.eventMiddleware(b.addVariant))
+	b.editor.RegisterEventFunc(UpdateVariantWeightsEvent, b.updateVariantWeights)
+	b.editor.RegisterEventFunc(DeleteVariantEvent, b.eventMiddleware(b.deleteVariantEvent))
+	b.editor.RegisterEventFunc(PromoteVariantEvent, b.promoteVariantEvent)
+	b.editor.RegisterEventFunc(UndoEvent, b.eventMiddleware(b.undo))
+	b.editor.RegisterEventFunc(RedoEvent, b.eventMiddleware(b.redo))
 	b.preview = web.Page(b.previewContent)
 }
 
@@ -57,7 +69,7 @@ func (b *ModelBuilder) pageBuilderModel(ctx *web.EventContext) (obj interface{},
 	obj = ctx.ContextValue(pageBuilderModelKey{})
 	if obj == nil {
 		obj = b.mb.NewModel()
-		pageID, pageVersion, locale := b.getPrimaryColumnValuesBySlug(ctx)
+		pageID, pageVersion, locale := b.variantPage(ctx)
 		if pageID == 0 {
 			return
 		}
@@ -129,6 +141,8 @@ func (b *ModelBuilder) renderContainersSortedList(ctx *web.EventContext) (r h.HT
 
 	var sorterData ContainerSorter
 	sorterData.Items = []ContainerSorterItem{}
+	device, _ := b.builder.getDevice(ctx)
+	now := b.db.NowFunc()
 
 	for i, c := range cons {
 		vicon := "mdi-eye"
@@ -151,6 +165,11 @@ func (b *ModelBuilder) renderContainersSortedList(ctx *web.EventContext) (r h.HT
 				Locale:          locale,
 				Hidden:          c.Hidden,
 				ContainerDataID: fmt.Sprintf(`%s_%s`, inflection.Plural(strcase.ToKebab(c.ModelName)), strconv.Itoa(int(c.ModelID))),
+				DeviceIcons:     b.builder.deviceIcons(c),
+				Overridden:      c.DeviceOverrides != "",
+				HiddenOnDevice:  !c.VisibleOn(device),
+				ScheduleText:    containerScheduleText(c),
+				OutOfSchedule:   !c.VisibleAt(now),
 			},
 		)
 	}
@@ -198,6 +217,20 @@ func (b *ModelBuilder) renderContainersSortedList(ctx *web.EventContext) (r h.HT
 						Query(paramStatus, status).
 						Go(),
 				),
+				VListItem(h.Text(msgr.Devices)).PrependIcon("mdi-devices").Attr("@click",
+					web.Plaid().
+						EventFunc(ContainerDevicesDialogEvent).
+						Query(paramContainerID, web.Var("element.param_id")).
+						Query(paramStatus, status).
+						Go(),
+				),
+				VListItem(h.Text(msgr.ContainerSchedule)).PrependIcon("mdi-clock-outline").Attr("@click",
+					web.Plaid().
+						EventFunc(ContainerScheduleDialogEvent).
+						Query(paramContainerID, web.Var("element.param_id")).
+						Query(paramStatus, status).
+						Go(),
+				),
 				VListItem(h.Text(pMsgr.Delete)).PrependIcon("mdi-delete").Attr("@click",
 					web.Plaid().
 						URL(ctx.R.URL.Path).
@@ -238,7 +271,14 @@ func (b *ModelBuilder) renderContainersSortedList(ctx *web.EventContext) (r h.HT
 														Attr("v-if", "element.editShow").
 														Attr("@blur", "element.editShow=false;"+renameEvent).
 														Attr("@keyup.enter", renameEvent),
-													VListItemTitle(h.Text("{{element.display_name}}")).Attr(":style", "[element.shared ? {'color':'green'}:{}]").Attr("v-if", "!element.editShow"),
+													VListItemTitle(
+														h.Text("{{element.display_name}}"),
+														VIcon("").Attr("v-for", "icon in element.device_icons", ":key", "icon", ":icon", "icon").Size(SizeXSmall).Class("ml-1"),
+														VIcon("mdi-tune-variant").Attr("v-if", "element.overridden").Size(SizeXSmall).Class("ml-1"),
+														VIcon("mdi-clock-outline").Attr("v-if", "element.schedule_text", ":title", "element.schedule_text").Size(SizeXSmall).Class("ml-1"),
+													).Attr(":style", "[element.shared ? {'color':'green'}:{}]").
+														Attr(":class", "element.hidden_on_device || element.out_of_schedule ? 'text-disabled' : ''").
+														Attr("v-if", "!element.editShow"),
 												).VSlot("{form}").FormInit("{ DisplayName:element.display_name }"),
 											),
 										),
@@ -269,6 +309,7 @@ func (b *ModelBuilder) renderContainersSortedList(ctx *web.EventContext) (r h.HT
 			Height(50).
 			Attr(":disabled", "vars.__pageBuilderAddContainerBtnDisabled").
 			Attr("@click", appendVirtualElement()+web.Plaid().PushState(true).ClearMergeQuery([]string{paramContainerID}).RunPushState()+";vars.containerPreview=false;vars.overlay=true;vars.overlayEl.refs.overlay.showByElement($event)"),
+		h.Div().Style("display:none").Attr("v-on-mounted", fmt.Sprintf(`() => { vars.__pbDeviceCounts = %s }`, h.JSONString(b.builder.deviceCounts(cons)))),
 	).Init(h.JSONString(sorterData)).VSlot("{ locals:sortLocals,form }")
 	return
 }
@@ -284,7 +325,7 @@ func (b *ModelBuilder) addContainer(ctx *web.EventContext) (r web.EventResponse,
 	)
 
 	if sharedContainer == "true" {
-		newContainerID, err = b.addSharedContainerToPage(pageID, containerID, pageVersion, locale, modelName, uint(modelID))
+		newContainerID, err = b.addSharedContainerToPage(b.editorDB(ctx), pageID, containerID, pageVersion, locale, modelName, uint(modelID))
 	} else {
 		var newModelId uint
 		newModelId, newContainerID, err = b.addContainerToPage(ctx, pageID, containerID, pageVersion, locale, modelName)
@@ -313,7 +354,7 @@ func (b *ModelBuilder) moveContainer(ctx *web.EventContext) (r web.EventResponse
 	if err != nil {
 		return
 	}
-	err = b.db.Transaction(func(tx *gorm.DB) (inerr error) {
+	err = b.editorDB(ctx).Transaction(func(tx *gorm.DB) (inerr error) {
 		for i, r := range result {
 			if inerr = tx.Model(&Container{}).Where("id = ? AND locale_code = ?", r.ContainerID, r.Locale).Update("display_order", i+1).Error; inerr != nil {
 				return
@@ -348,7 +389,7 @@ func (b *ModelBuilder) moveUpDownContainer(ctx *web.EventContext) (r web.EventRe
 		locale       = cs[l10n.SlugLocaleCode]
 	)
 
-	err = b.db.Transaction(func(tx *gorm.DB) (inerr error) {
+	err = b.editorDB(ctx).Transaction(func(tx *gorm.DB) (inerr error) {
 		if inerr = tx.Where("id = ? AND locale_code = ?", containerID, locale).First(&container).Error; inerr != nil {
 			return
 		}
@@ -386,7 +427,7 @@ func (b *ModelBuilder) toggleContainerVisibility(ctx *web.EventContext) (r web.E
 		locale      = cs[l10n.SlugLocaleCode]
 	)
 
-	err = b.db.Exec("UPDATE page_builder_containers SET hidden = NOT(coalesce(hidden,FALSE)) WHERE id = ? AND locale_code = ?", containerID, locale).Error
+	err = b.editorDB(ctx).Exec("UPDATE page_builder_containers SET hidden = NOT(coalesce(hidden,FALSE)) WHERE id = ? AND locale_code = ?", containerID, locale).Error
 
 	web.AppendRunScripts(&r,
 		web.Plaid().
@@ -439,7 +480,7 @@ func (b *ModelBuilder) deleteContainer(ctx *web.EventContext) (r web.EventRespon
 		locale                 = cs[l10n.SlugLocaleCode]
 		count                  int64
 	)
-	if err = b.db.Transaction(func(tx *gorm.DB) (dbErr error) {
+	if err = b.editorDB(ctx).Transaction(func(tx *gorm.DB) (dbErr error) {
 		if dbErr = tx.Delete(&Container{}, "id = ? AND locale_code = ?", containerID, locale).Error; err != nil {
 			return
 		}
@@ -668,18 +709,19 @@ func (b *ModelBuilder) renameContainer(ctx *web.EventContext) (r web.EventRespon
 		containerID = cs[presets.ParamID]
 		locale      = cs[l10n.SlugLocaleCode]
 		name        = ctx.R.FormValue(paramsDisplayName)
+		db          = b.editorDB(ctx)
 	)
-	err = b.db.First(&container, "id = ? AND locale_code = ?  ", containerID, locale).Error
+	err = db.First(&container, "id = ? AND locale_code = ?  ", containerID, locale).Error
 	if err != nil {
 		return
 	}
 	if container.Shared {
-		err = b.db.Model(&Container{}).Where("model_name = ? AND model_id = ? AND locale_code = ?", container.ModelName, container.ModelID, locale).Update("display_name", name).Error
+		err = db.Model(&Container{}).Where("model_name = ? AND model_id = ? AND locale_code = ?", container.ModelName, container.ModelID, locale).Update("display_name", name).Error
 		if err != nil {
 			return
 		}
 	} else {
-		err = b.db.Model(&Container{}).Where("id = ? AND locale_code = ?", containerID, locale).Update("display_name", name).Error
+		err = db.Model(&Container{}).Where("id = ? AND locale_code = ?", containerID, locale).Update("display_name", name).Error
 		if err != nil {
 			return
 		}
@@ -865,6 +907,7 @@ func (b *ModelBuilder) reloadRenderPageOrTemplateBody(ctx *web.EventContext) (r
 				IsUpdate:        ctx.Param(paramIsUpdate) != "false",
 			},
 		),
+		b.historyScript(ctx),
 	)
 	return
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/qor5/web/v3"
 	"github.com/sunfmin/reflectutils"
@@ -20,12 +21,19 @@ type Page struct {
 	Title      string
 	Slug       string
 	CategoryID uint
+	// ParentID is the page the page is under, its URL is the one of the parent followed by its slug
+	// and the category of the root page is used
+	ParentID uint
 
 	SEO seo.Setting
 	publish.Status
 	publish.Schedule
 	publish.Version
 	l10n.Locale
+
+	// movedFrom and movedTo are the access URLs of a move found by the publish actions, AfterPublish records
+	// the redirect between them
+	movedFrom, movedTo string
 }
 
 type PageTitleInterface interface {
@@ -132,6 +140,8 @@ type Category struct {
 	Name        string
 	Path        string
 	Description string
+	// ParentID is the category the category is under, its path starts with the one of the parent
+	ParentID uint
 
 	IndentLevel int `gorm:"-"`
 
@@ -161,6 +171,13 @@ type Container struct {
 	Shared        bool
 	Hidden        bool
 	DisplayName   string
+	// Devices are the names of the devices the container is shown on, it is shown on all devices when empty
+	Devices string
+	// DeviceOverrides are the values of the model fields overridden by device, as JSON
+	DeviceOverrides string `gorm:"type:text"`
+	// ScheduledStartAt and ScheduledEndAt limit the time the container is shown
+	ScheduledStartAt *time.Time
+	ScheduledEndAt   *time.Time
 
 	l10n.Locale
 	LocalizeFromModelID uint
@@ -43,11 +43,11 @@ func (b *Builder) defaultPageInstall(pb *presets.Builder, pm *presets.ModelBuild
 	})
 	lb.Field("Path").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
 		page := obj.(*Page)
-		category, err := page.GetCategory(db)
+		publishUrl, err := page.publishUrl(db, b.l10n.GetLocalePath(page.LocaleCode))
 		if err != nil {
 			panic(err)
 		}
-		return h.Td(h.Text(page.getAccessUrl(page.getPublishUrl(b.l10n.GetLocalePath(page.LocaleCode), category.Path))))
+		return h.Td(h.Text(page.getAccessUrl(publishUrl)))
 	})
 
 	detailList := []interface{}{"Title", PageBuilderPreviewCard, "Page"}
@@ -88,7 +88,7 @@ func (b *Builder) defaultPageInstall(pb *presets.Builder, pm *presets.ModelBuild
 		).Class("d-inline-flex align-center")
 	})
 	// register modelBuilder
-	names := b.filterFields([]interface{}{"Title", "CategoryID", "Slug"})
+	names := b.filterFields([]interface{}{"Title", "CategoryID", "ParentID", "Slug"})
 	if b.templateEnabled {
 		names = append([]interface{}{PageTemplateSelectionFiled}, names...)
 	}
@@ -175,6 +175,21 @@ func (b *Builder) defaultPageInstall(pb *presets.Builder, pm *presets.ModelBuild
 		})
 	}
 
+	if parentIDField := eb.GetField("ParentID"); parentIDField != nil {
+		parentIDField.ComponentFunc(b.parentPageComponent)
+	}
+	lb.Action(pageTreeActionName).ButtonCompFunc(func(ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		return VBtn(msgr.PageTree).
+			PrependIcon("mdi-file-tree").
+			Variant(VariantTonal).
+			Color(ColorPrimary).
+			Class("ml-2").
+			Attr("@click", web.Plaid().EventFunc(PageTreeDialogEvent).Go())
+	})
+	pm.RegisterEventFunc(PageTreeDialogEvent, b.pageTreeDialog)
+	pm.RegisterEventFunc(ReparentPageEvent, b.reparentPage)
+
 	detailPageEditor(dp, pm, b)
 
 	b.configDetailLayoutFunc(pb, pm, db)
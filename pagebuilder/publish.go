@@ -4,8 +4,11 @@ import (
 	"context"
 	"path"
 	"path/filepath"
+	"slices"
 
 	"github.com/qor5/admin/v3/l10n"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/publish"
 	"github.com/qor5/admin/v3/utils"
 	"github.com/qor5/x/v3/oss"
 	"gorm.io/gorm"
@@ -26,22 +29,60 @@ func (p *Page) PublishUrl(db *gorm.DB, ctx context.Context, storage oss.StorageI
 		localePath = l10n.LocalePathFromContext(p, ctx)
 	}
 
-	var category Category
-	if category, err = p.GetCategory(db); err != nil {
+	if p.OnlineUrl, err = p.publishUrl(db, localePath); err != nil {
 		return
 	}
-	p.OnlineUrl = p.getPublishUrl(localePath, category.Path)
 	return p.OnlineUrl
 }
 
-func generatePublishUrl(localePath, categoryPath, slug string) string {
-	return path.Join("/", localePath, categoryPath, slug, "/index.html")
-}
-
-func (p *Page) getPublishUrl(localePath, categoryPath string) string {
-	return generatePublishUrl(localePath, categoryPath, p.Slug)
+// generatePublishUrl joins the slugs of the ancestors of the page and its own slug under the category path
+func generatePublishUrl(localePath, categoryPath string, slugs ...string) string {
+	return path.Join(append(append([]string{"/", localePath, categoryPath}, slugs...), "/index.html")...)
 }
 
 func (p *Page) getAccessUrl(publishUrl string) string {
 	return filepath.Dir(publishUrl)
 }
+
+// wrapPrune deletes the containers of the versions pruned by the retention policy,
+// the models of shared containers are used by other pages so only their containers are deleted
+func (b *ModelBuilder) wrapPrune(in publish.PruneFunc) publish.PruneFunc {
+	return func(ctx context.Context, tx *gorm.DB, record any) (err error) {
+		if utils.GetObjectName(record) != b.name {
+			return in(ctx, tx, record)
+		}
+		cs := primaryColumnValuesBySlug(record.(presets.SlugEncoder).PrimarySlug())
+		var cons []*Container
+		// the containers of the variants are pruned with the version
+		if err = tx.Find(&cons, "page_id = ? AND (page_version = ? OR page_version LIKE ?) AND locale_code = ? and page_model_name = ? ",
+			cs["id"], cs[publish.SlugVersion], variantPageVersion(cs[publish.SlugVersion], "%"), cs[l10n.SlugLocaleCode], b.name).Error; err != nil {
+			return
+		}
+		if err = b.deleteContainers(tx, cons); err != nil {
+			return
+		}
+		if err = tx.Where("page_model_name = ? AND page_id = ? AND page_version = ? AND locale_code = ?",
+			b.name, cs["id"], cs[publish.SlugVersion], cs[l10n.SlugLocaleCode]).Delete(&PageVariant{}).Error; err != nil {
+			return
+		}
+		return in(ctx, tx, record)
+	}
+}
+
+// deleteContainers deletes the containers with their models, the models of shared containers are used by other pages
+func (b *ModelBuilder) deleteContainers(tx *gorm.DB, cons []*Container) (err error) {
+	builders := b.getContainerBuilders()
+	for _, c := range cons {
+		if !c.Shared && slices.ContainsFunc(builders, func(builder *ContainerBuilder) bool {
+			return c.ModelName == builder.name
+		}) {
+			if err = tx.Delete(b.builder.ContainerByName(c.ModelName).NewModel(), "id = ?", c.ModelID).Error; err != nil {
+				return
+			}
+		}
+		if err = tx.Delete(&Container{}, "id = ? AND locale_code = ?", c.ID, c.LocaleCode).Error; err != nil {
+			return
+		}
+	}
+	return
+}
@@ -1,15 +1,33 @@
 package pagebuilder
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/oss/filesystem"
 	"github.com/theplant/gofixtures"
 	"github.com/theplant/testenv"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
+	"github.com/qor5/admin/v3/l10n"
+	"github.com/qor5/admin/v3/media/media_library"
+	mediaoss "github.com/qor5/admin/v3/media/oss"
 	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/publish"
 )
 
 var TestDB *gorm.DB
@@ -46,3 +64,448 @@ func TestPage_PublishUrl(t *testing.T) {
 		t.Log("Error Publish Url")
 	}
 }
+
+type bundleHeading struct {
+	ID    uint
+	Text  string
+	Image media_library.MediaBox `sql:"type:text;"`
+}
+
+func TestBundleExportImport(t *testing.T) {
+	dbr, _ := TestDB.DB()
+	TestDB.AutoMigrate(&Page{}, &Category{}, &Container{}, &bundleHeading{}, &media_library.MediaLibrary{})
+	pageBuilderData.TruncatePut(dbr)
+	TestDB.Exec("DELETE FROM page_builder_containers")
+	ctx := context.Background()
+
+	b := New("/page_builder", TestDB, presets.New())
+	b.RegisterContainer("Heading").Model(&bundleHeading{})
+	r := b.Model(b.ps.Model(&Page{}))
+
+	media := &media_library.MediaLibrary{SelectedType: media_library.ALLOW_TYPE_IMAGE}
+	media.File.Url = "/system/media_libraries/1/file.png"
+	if err := TestDB.Create(media).Error; err != nil {
+		t.Fatal(err)
+	}
+	mediaID := json.Number(fmt.Sprint(media.ID))
+	seoMedia := &media_library.MediaLibrary{SelectedType: media_library.ALLOW_TYPE_IMAGE}
+	seoMedia.File.Url = "/system/media_libraries/2/og.png"
+	if err := TestDB.Create(seoMedia).Error; err != nil {
+		t.Fatal(err)
+	}
+	TestDB.Model(&Page{}).Where("id = 1").Update("seo", fmt.Sprintf(`{"OpenGraphImageFromMediaLibrary":{"ID":%d,"Url":%q}}`, seoMedia.ID, seoMedia.File.Url))
+	heading := &bundleHeading{Text: "hello", Image: media_library.MediaBox{ID: mediaID, Url: media.File.Url}}
+	TestDB.Create(heading)
+	TestDB.Create(&Container{PageID: 1, PageVersion: "2024-05-18-v01", PageModelName: r.name, ModelName: "Heading", ModelID: heading.ID, DisplayOrder: 1})
+	TestDB.Create(&Container{PageID: 1, PageVersion: "2024-05-18-v01", PageModelName: r.name, ModelName: "Removed", ModelID: 1, DisplayOrder: 2})
+
+	bundle, err := r.Export(ctx, "1_2024-05-18-v01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Category == nil || bundle.Category.Path != "/12" || len(bundle.Containers) != 2 || len(bundle.Media) != 2 {
+		t.Fatalf("unexpected bundle: %+v", bundle)
+	}
+	var buf bytes.Buffer
+	if err = bundle.WriteZip(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadBundle(bytes.NewReader(buf.Bytes()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadBundle(bytes.NewReader(buf.Bytes()), 10); !errors.Is(err, ErrBundleTooLarge) {
+		t.Fatalf("expected the bundle to be too large, got %v", err)
+	}
+	// the SEO media is missing in the importing database
+	TestDB.Delete(seoMedia)
+
+	result, err := r.Import(ctx, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := result.Record.(*Page)
+	if page.ID != 1 || page.Version.Version == "2024-05-18-v01" || page.Status.Status != publish.StatusDraft || page.CategoryID != 1 {
+		t.Fatalf("the import should be a new draft version of the page: %+v", page)
+	}
+	if id := page.SEO.OpenGraphImageFromMediaLibrary.ID; id == "" || id == json.Number(fmt.Sprint(seoMedia.ID)) {
+		t.Fatalf("the SEO media should be remapped to the imported one, got %s", id)
+	}
+	if len(result.UnknownContainers) != 1 || result.UnknownContainers[0] != "Removed" {
+		t.Fatalf("unexpected unknown containers: %v", result.UnknownContainers)
+	}
+	var cons []*Container
+	TestDB.Find(&cons, "page_id = ? AND page_version = ?", page.ID, page.Version.Version)
+	if len(cons) != 1 || cons[0].ModelID == heading.ID {
+		t.Fatalf("the container model should be copied: %+v", cons)
+	}
+	var imported bundleHeading
+	TestDB.First(&imported, cons[0].ModelID)
+	if imported.Text != "hello" || imported.Image.ID != mediaID {
+		t.Fatalf("unexpected container model: %+v", imported)
+	}
+}
+
+func TestBundleImportMediaPath(t *testing.T) {
+	TestDB.AutoMigrate(&media_library.MediaLibrary{})
+	dir := t.TempDir()
+	storage := mediaoss.Storage
+	mediaoss.Storage = filesystem.New(filepath.Join(dir, "public"))
+	defer func() { mediaoss.Storage = storage }()
+
+	// the bundle points the media at a path outside of the media library
+	ids, err := importMedia(TestDB, []*BundleMedia{{
+		ID:           7,
+		SelectedType: media_library.ALLOW_TYPE_FILE,
+		File:         `{"FileName":"../../notes.txt","Url":"/../notes.txt"}`,
+		content:      []byte("imported"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m media_library.MediaLibrary
+	if err = TestDB.First(&m, ids[7]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if prefix := fmt.Sprintf("/system/media_libraries/%d/", m.ID); !strings.HasPrefix(m.File.Url, prefix) {
+		t.Fatalf("the file should be uploaded to the path of the new media, got %s", m.File.Url)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "notes.txt")); !os.IsNotExist(err) {
+		t.Fatalf("the path of the bundle should not be written: %v", err)
+	}
+}
+
+func TestEditorUndoRedo(t *testing.T) {
+	dbr, _ := TestDB.DB()
+	TestDB.AutoMigrate(&Page{}, &Category{}, &Container{}, &EditorOperation{}, &bundleHeading{})
+	pageBuilderData.TruncatePut(dbr)
+	TestDB.Exec("DELETE FROM page_builder_containers")
+	TestDB.Exec("DELETE FROM page_builder_editor_operations")
+
+	b := New("/page_builder", TestDB, presets.New())
+	b.RegisterContainer("Heading").Model(&bundleHeading{})
+	r := b.Model(b.ps.Model(&Page{}))
+
+	heading := &bundleHeading{Text: "hello"}
+	TestDB.Create(heading)
+	con := &Container{PageID: 1, PageVersion: "2024-05-18-v01", PageModelName: r.name, ModelName: "Heading", ModelID: heading.ID, DisplayOrder: 1}
+	TestDB.Create(con)
+
+	newCtx := func() *web.EventContext {
+		req := httptest.NewRequest(http.MethodPost, "/?id=1_2024-05-18-v01", nil)
+		req.AddCookie(&http.Cookie{Name: editorSessionCookie, Value: "session"})
+		return &web.EventContext{R: req}
+	}
+	deleteContainer := r.recordEvent(DeleteContainerEvent, func(ctx *web.EventContext) (er web.EventResponse, err error) {
+		err = r.editorDB(ctx).Delete(&Container{}, con.ID).Error
+		return
+	})
+	if _, err := deleteContainer(newCtx()); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	TestDB.Model(&Container{}).Where("page_id = 1").Count(&count)
+	if count != 0 {
+		t.Fatalf("the container should be deleted")
+	}
+
+	if _, err := r.undo(newCtx()); err != nil {
+		t.Fatal(err)
+	}
+	var restored Container
+	if err := TestDB.First(&restored, con.ID).Error; err != nil || restored.ModelID != heading.ID {
+		t.Fatalf("undo should restore the container: %v %+v", err, restored)
+	}
+
+	if _, err := r.redo(newCtx()); err != nil {
+		t.Fatal(err)
+	}
+	TestDB.Model(&Container{}).Where("page_id = 1").Count(&count)
+	if count != 0 {
+		t.Fatalf("redo should delete the container again")
+	}
+	if s := r.historyScript(newCtx()); s != "vars.__pbCanUndo=true;vars.__pbCanRedo=false;" {
+		t.Fatalf("unexpected history state: %s", s)
+	}
+
+	// the container is renamed by another session once restored, redoing the deletion would lose the change
+	if _, err := r.undo(newCtx()); err != nil {
+		t.Fatal(err)
+	}
+	TestDB.Model(&Container{}).Where("id = ?", con.ID).Update("display_name", "renamed")
+	if _, err := r.redo(newCtx()); err != nil {
+		t.Fatal(err)
+	}
+	TestDB.Model(&Container{}).Where("page_id = 1").Count(&count)
+	if count != 1 {
+		t.Fatalf("redo should not overwrite the changes of another session")
+	}
+	if s := r.historyScript(newCtx()); s != "vars.__pbCanUndo=false;vars.__pbCanRedo=true;" {
+		t.Fatalf("unexpected history state after the conflict: %s", s)
+	}
+
+	TestDB.Model(&EditorOperation{}).Where("1 = 1").Update("created_at", time.Now().Add(-2*editorHistoryTTL))
+	if err := b.PurgeEditorHistory(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	TestDB.Model(&EditorOperation{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("the expired operations should be deleted, got %d", count)
+	}
+}
+
+func TestPageVariantPromote(t *testing.T) {
+	dbr, _ := TestDB.DB()
+	TestDB.AutoMigrate(&Page{}, &Category{}, &Container{}, &PageVariant{}, &EditorOperation{}, &bundleHeading{})
+	pageBuilderData.TruncatePut(dbr)
+	TestDB.Exec("DELETE FROM page_builder_containers")
+	TestDB.Exec("DELETE FROM page_builder_page_variants")
+
+	b := New("/page_builder", TestDB, presets.New())
+	b.RegisterContainer("Heading").Model(&bundleHeading{})
+	r := b.Model(b.ps.Model(&Page{}))
+
+	const version = "2024-05-18-v01"
+	heading := &bundleHeading{Text: "original"}
+	TestDB.Create(heading)
+	TestDB.Create(&Container{PageID: 1, PageVersion: version, PageModelName: r.name, ModelName: "Heading", ModelID: heading.ID, DisplayOrder: 1})
+
+	variants := []*PageVariant{
+		{PageModelName: r.name, PageID: 1, PageVersion: version, Weight: 50},
+		{PageModelName: r.name, PageID: 1, PageVersion: version, Name: "b", Weight: 50},
+	}
+	TestDB.Create(&variants)
+	if err := r.copyContainersToAnotherPage(TestDB, 1, version, "", 1, variantPageVersion(version, "b"), "", r.name, r.name); err != nil {
+		t.Fatal(err)
+	}
+	var variantCon Container
+	TestDB.First(&variantCon, "page_version = ?", variantPageVersion(version, "b"))
+	TestDB.Model(&bundleHeading{}).Where("id = ?", variantCon.ModelID).Update("text", "variant")
+
+	page := &Page{}
+	TestDB.First(page, "id = ? AND version = ?", 1, version)
+	if err := r.promoteVariant(TestDB, page, variants[1]); err != nil {
+		t.Fatal(err)
+	}
+	if page.Version.Version == version || page.Status.Status != publish.StatusDraft || page.ParentVersion != version {
+		t.Fatalf("the variant should be promoted to a new draft: %+v", page.Version)
+	}
+	var cons []*Container
+	TestDB.Find(&cons, "page_id = 1 AND page_version = ?", page.Version.Version)
+	if len(cons) != 1 || cons[0].ID == variantCon.ID {
+		t.Fatalf("the containers of the variant should be copied to the draft: %+v", cons)
+	}
+	var promoted bundleHeading
+	TestDB.First(&promoted, cons[0].ModelID)
+	if promoted.Text != "variant" {
+		t.Errorf("the draft should have the content of the variant, got %s", promoted.Text)
+	}
+	var count int64
+	TestDB.Model(&Container{}).Where("page_id = 1 AND page_version IN ?", []string{version, variantPageVersion(version, "b")}).Count(&count)
+	if count != 2 {
+		t.Errorf("the containers of the test should be kept, got %d", count)
+	}
+	TestDB.Model(&PageVariant{}).Count(&count)
+	if count != 2 {
+		t.Errorf("the test should go on until the draft is published")
+	}
+}
+
+func TestDiffContainers(t *testing.T) {
+	TestDB.AutoMigrate(&Container{}, &bundleHeading{})
+	TestDB.Exec("DELETE FROM page_builder_containers")
+
+	b := New("/page_builder", TestDB, presets.New())
+	b.RegisterContainer("Heading").Model(&bundleHeading{})
+	r := b.Model(b.ps.Model(&Page{}))
+
+	oldPage := &Page{Model: gorm.Model{ID: 1}, Version: publish.Version{Version: "2024-05-18-v01"}}
+	newPage := &Page{Model: gorm.Model{ID: 1}, Version: publish.Version{Version: "2024-05-18-v02"}}
+	headings := []*bundleHeading{{Text: "kept"}, {Text: "kept changed"}, {Text: "removed"}, {Text: "lost"}}
+	TestDB.Create(&headings)
+	// the model of the second heading is missing in the new version
+	missingID := headings[3].ID + 100
+	TestDB.Create(&[]*Container{
+		{PageID: 1, PageVersion: oldPage.Version.Version, PageModelName: r.name, ModelName: "Heading", ModelID: headings[0].ID, DisplayOrder: 1},
+		{PageID: 1, PageVersion: oldPage.Version.Version, PageModelName: r.name, ModelName: "Heading", ModelID: headings[3].ID, DisplayOrder: 2, DisplayName: "Lost"},
+		{PageID: 1, PageVersion: oldPage.Version.Version, PageModelName: r.name, ModelName: "Heading", ModelID: headings[2].ID, DisplayOrder: 3},
+		{PageID: 1, PageVersion: newPage.Version.Version, PageModelName: r.name, ModelName: "Heading", ModelID: headings[1].ID, DisplayOrder: 1},
+		{PageID: 1, PageVersion: newPage.Version.Version, PageModelName: r.name, ModelName: "Heading", ModelID: missingID, DisplayOrder: 2, DisplayName: "Lost"},
+	})
+
+	diffs, err := r.diffContainers(TestDB, oldPage, newPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string][2]string{}
+	for _, d := range diffs {
+		fields[d.Field] = [2]string{d.Old, d.New}
+	}
+	expected := map[string][2]string{
+		"Containers.Heading[2]":      {"Heading", ""},
+		"Containers.Heading[0].Text": {"kept", "kept changed"},
+		"Containers.Heading[1]":      {"Lost", ""},
+	}
+	if !reflect.DeepEqual(expected, fields) {
+		t.Errorf("unexpected diffs: %+v", diffs)
+	}
+}
+
+func TestRecordRedirect(t *testing.T) {
+	TestDB.AutoMigrate(&Redirect{})
+	TestDB.Exec("DELETE FROM page_builder_redirects")
+
+	for _, move := range [][2]string{{"/a", "/b"}, {"/b", "/c"}, {"/c", "/a"}} {
+		if err := recordRedirect(TestDB, move[0], move[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rules, err := loadRedirects(TestDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range rules {
+		got = append(got, r.FromPath+">"+r.ToPath)
+	}
+	if expect := []string{"/b>/a", "/c>/a"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected %v, got %v", expect, got)
+	}
+}
+
+func TestDeliveryHandler(t *testing.T) {
+	TestDB.AutoMigrate(&Page{}, &Category{}, &Container{}, &bundleHeading{})
+	TestDB.Exec("DELETE FROM page_builder_pages")
+	TestDB.Exec("DELETE FROM page_builder_containers")
+
+	b := New("/page_builder", TestDB, presets.New()).L10n(l10n.New(TestDB))
+	b.RegisterContainer("Heading").Model(&bundleHeading{})
+	r := b.Model(b.ps.Model(&Page{}))
+	b.DeliveryPreviewSecret([]byte("secret"))
+
+	pages := []*Page{
+		{Model: gorm.Model{ID: 1}, Title: "online", Slug: "/123", Status: publish.Status{Status: publish.StatusOnline, OnlineUrl: "/123/index.html"}, Version: publish.Version{Version: "2024-05-18-v01"}, Locale: l10n.Locale{LocaleCode: "en"}},
+		{Model: gorm.Model{ID: 1}, Title: "draft", Slug: "/123", Status: publish.Status{Status: publish.StatusDraft}, Version: publish.Version{Version: "2024-05-18-v02"}, Locale: l10n.Locale{LocaleCode: "en"}},
+		{Model: gorm.Model{ID: 1}, Title: "online zh", Slug: "/123", Status: publish.Status{Status: publish.StatusOnline, OnlineUrl: "/zh/123/index.html"}, Version: publish.Version{Version: "2024-05-18-v01"}, Locale: l10n.Locale{LocaleCode: "zh"}},
+		{Model: gorm.Model{ID: 2}, Title: "offline", Slug: "/456", Status: publish.Status{Status: publish.StatusDraft}, Version: publish.Version{Version: "2024-05-18-v01"}, Locale: l10n.Locale{LocaleCode: "en"}},
+	}
+	for _, p := range pages {
+		if err := TestDB.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, v := range []struct {
+		locale, text string
+		hidden       bool
+	}{{"en", "hello", false}, {"en", "hidden", true}, {"zh", "nihao", false}} {
+		heading := &bundleHeading{Text: v.text}
+		TestDB.Create(heading)
+		TestDB.Create(&Container{PageID: 1, PageVersion: "2024-05-18-v01", PageModelName: r.name, ModelName: "Heading", ModelID: heading.ID, DisplayOrder: float64(i + 1), Hidden: v.hidden, Locale: l10n.Locale{LocaleCode: v.locale}})
+	}
+
+	serve := func(query string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		b.DeliveryHandler().ServeHTTP(w, req)
+		return w
+	}
+	page := func(w *httptest.ResponseRecorder) (dp DeliveryPage, texts []string) {
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &dp); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range dp.Containers {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(c.Fields, &fields); err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range []string{"ID", "CreatedAt", "UpdatedAt", "DeletedAt"} {
+				if _, ok := fields[f]; ok {
+					t.Errorf("the bookkeeping field %s should not be served", f)
+				}
+			}
+			var text string
+			_ = json.Unmarshal(fields["Text"], &text)
+			texts = append(texts, text)
+		}
+		return
+	}
+
+	// the hidden containers and the draft versions are left out
+	w := serve("path=/123", nil)
+	dp, texts := page(w)
+	if dp.Title != "online" || !reflect.DeepEqual(texts, []string{"hello"}) {
+		t.Errorf("unexpected page %s with containers %v", dp.Title, texts)
+	}
+	if got := serve("path=/123", http.Header{"If-None-Match": {w.Header().Get("ETag")}}); got.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for the matching ETag, got %d", got.Code)
+	}
+
+	dp, texts = page(serve("id=1&locale=zh", nil))
+	if dp.Title != "online zh" || dp.Locale != "zh" || !reflect.DeepEqual(texts, []string{"nihao"}) {
+		t.Errorf("unexpected page %s of locale %s with containers %v", dp.Title, dp.Locale, texts)
+	}
+
+	if got := serve("id=2&locale=en", nil); got.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a page not online, got %d", got.Code)
+	}
+
+	expired, _ := b.DeliveryPreviewToken(1, "en", -time.Minute)
+	for _, token := range []string{"123.abc", expired} {
+		if got := serve("id=1&locale=en&preview="+token, nil); got.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for the token %s, got %d", token, got.Code)
+		}
+	}
+	token, _ := b.DeliveryPreviewToken(1, "en", time.Minute)
+	if dp, _ = page(serve("id=1&locale=en&preview="+token, nil)); dp.Title != "draft" {
+		t.Errorf("the preview should serve the latest version, got %s", dp.Title)
+	}
+}
+
+func TestMoveCategoryDescendants(t *testing.T) {
+	TestDB.AutoMigrate(&Category{})
+	TestDB.Exec("DELETE FROM page_builder_categories")
+	cats := []*Category{{Name: "a", Path: "/a"}, {Name: "b", Path: "/a/b"}, {Name: "c", Path: "/a/b/c"}, {Name: "ab", Path: "/ab"}}
+	if err := TestDB.Create(&cats).Error; err != nil {
+		t.Fatal(err)
+	}
+	paths := func() (r []string) {
+		TestDB.Model(&Category{}).Order("id").Pluck("path", &r)
+		return
+	}
+	moved := &Category{Model: cats[0].Model, Path: "/x"}
+
+	errRollback := errors.New("rollback")
+	err := TestDB.Transaction(func(tx *gorm.DB) error {
+		if _, err := moveCategoryDescendants(tx, moved, "/a"); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("expected the rollback, got %v", err)
+	}
+	if got, expect := paths(), []string{"/a", "/a/b", "/a/b/c", "/ab"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v, expected %v", got, expect)
+	}
+
+	var ids []uint
+	if err = TestDB.Transaction(func(tx *gorm.DB) (err error) {
+		ids, err = moveCategoryDescendants(tx, moved, "/a")
+		return
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, expect := paths(), []string{"/a", "/x/b", "/x/b/c", "/ab"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v, expected %v", got, expect)
+	}
+	slices.Sort(ids)
+	if got, expect := ids, []uint{cats[0].ID, cats[1].ID, cats[2].ID}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("got %v, expected %v", got, expect)
+	}
+}
@@ -0,0 +1,460 @@
+package pagebuilder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/oss"
+	h "github.com/theplant/htmlgo"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/publish"
+)
+
+const (
+	// RedirectsJSONPath and RedirectsNginxPath are the rules files written to the storage when the redirects change,
+	// the nginx one is included in the http block and used with
+	//
+	//	if ($pb_redirect_permanent) { return 301 $pb_redirect_permanent$is_args$args; }
+	//	if ($pb_redirect_temporary) { return 302 $pb_redirect_temporary$is_args$args; }
+	RedirectsJSONPath  = "/redirects.json"
+	RedirectsNginxPath = "/redirects.conf"
+
+	redirectWildcard = "*"
+	// redirectCacheTTL is how long RedirectHandler keeps the rules, the changes made by other instances
+	// are served after it
+	redirectCacheTTL = time.Minute
+)
+
+// Redirect sends the visitors of FromPath to ToPath, a FromPath ending with "/*" matches the paths under it
+// and the rest of the path replaces the "*" of ToPath
+type Redirect struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FromPath   string `gorm:"uniqueIndex"`
+	ToPath     string
+	StatusCode int
+	// Automatic is set for the redirects recorded when the URL of an online page changes
+	Automatic bool
+}
+
+func (*Redirect) TableName() string {
+	return "page_builder_redirects"
+}
+
+func (r *Redirect) wildcard() bool {
+	return strings.HasSuffix(r.FromPath, "/"+redirectWildcard)
+}
+
+// target returns where p is redirected to, p is a normalized path
+func (r *Redirect) target(p string) (string, bool) {
+	if !r.wildcard() {
+		return r.ToPath, p == r.FromPath
+	}
+	prefix := strings.TrimSuffix(r.FromPath, redirectWildcard)
+	if p+"/" == prefix {
+		return strings.Replace(r.ToPath, redirectWildcard, "", 1), true
+	}
+	if !strings.HasPrefix(p, prefix) {
+		return "", false
+	}
+	return strings.Replace(r.ToPath, redirectWildcard, strings.TrimPrefix(p, prefix), 1), true
+}
+
+type redirectRuleJSON struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+}
+
+type redirectCache struct {
+	mu       sync.RWMutex
+	rules    []*Redirect
+	loadedAt time.Time
+}
+
+func (c *redirectCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadedAt = time.Time{}
+}
+
+// Redirects enables the redirect manager, the redirects from the old URLs of the online pages are recorded
+// when they are published to a new one. The rules files are written to storage after every change, by the
+// publishing and by the admin, storage should be the one of the publisher
+func (b *Builder) Redirects(storage oss.StorageInterface) (r *Builder) {
+	b.redirectsEnabled = true
+	b.redirectStorage = storage
+	return b
+}
+
+// normalizeRedirectPath removes the trailing slash and index.html of the published pages
+func normalizeRedirectPath(p string) string {
+	p = path.Clean("/" + p)
+	return path.Clean(strings.TrimSuffix(p, "/index.html"))
+}
+
+// sortRedirects orders the exact redirects first and the wildcard ones from the longest prefix
+func sortRedirects(rules []*Redirect) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		wi, wj := rules[i].wildcard(), rules[j].wildcard()
+		if wi != wj {
+			return !wi
+		}
+		if wi && len(rules[i].FromPath) != len(rules[j].FromPath) {
+			return len(rules[i].FromPath) > len(rules[j].FromPath)
+		}
+		return rules[i].FromPath < rules[j].FromPath
+	})
+}
+
+// matchRedirect returns the first rule of the sorted rules matching p and its target
+func matchRedirect(rules []*Redirect, p string) (*Redirect, string) {
+	p = normalizeRedirectPath(p)
+	for _, r := range rules {
+		if to, ok := r.target(p); ok && to != p {
+			return r, to
+		}
+	}
+	return nil, ""
+}
+
+func loadRedirects(db *gorm.DB) (rules []*Redirect, err error) {
+	if err = db.Order("from_path").Find(&rules).Error; err != nil {
+		return
+	}
+	sortRedirects(rules)
+	return
+}
+
+func redirectsJSON(rules []*Redirect) string {
+	items := []redirectRuleJSON{}
+	for _, r := range rules {
+		items = append(items, redirectRuleJSON{From: r.FromPath, To: r.ToPath, Status: r.StatusCode})
+	}
+	data, _ := json.MarshalIndent(items, "", "  ")
+	return string(data)
+}
+
+// nginxQuote quotes s as a string of the nginx configuration, where only the quotes and the backslashes
+// are escaped, the other characters are kept as they are
+func nginxQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// nginxTarget is the target of a redirect as a value of the map, where $ starts a variable and the control
+// characters end the line, so they are percent-encoded
+func nginxTarget(to string) string {
+	var s strings.Builder
+	for _, c := range []byte(to) {
+		if c == '$' || c < 0x20 || c == 0x7f {
+			fmt.Fprintf(&s, "%%%02X", c)
+			continue
+		}
+		s.WriteByte(c)
+	}
+	return s.String()
+}
+
+// nginxEntry is an entry of a map of the nginx rules
+func nginxEntry(from, to string) string {
+	return fmt.Sprintf("\t%s %s;", nginxQuote(from), nginxQuote(to))
+}
+
+func redirectsNginx(rules []*Redirect) string {
+	var permanent, temporary []string
+	for _, r := range rules {
+		var entries []string
+		to := nginxTarget(r.ToPath)
+		if r.wildcard() {
+			from := "~^" + regexp.QuoteMeta(strings.TrimSuffix(r.FromPath, redirectWildcard)) + "(.*)$"
+			entries = append(entries, nginxEntry(from, strings.Replace(to, redirectWildcard, "$1", 1)))
+		} else {
+			entries = append(entries, nginxEntry(r.FromPath, to))
+			if r.FromPath != "/" {
+				entries = append(entries, nginxEntry(r.FromPath+"/", to))
+			}
+		}
+		if r.StatusCode == http.StatusFound {
+			temporary = append(temporary, entries...)
+		} else {
+			permanent = append(permanent, entries...)
+		}
+	}
+	var s strings.Builder
+	s.WriteString("# generated by the page builder\n")
+	for _, m := range []struct {
+		name    string
+		entries []string
+	}{{"pb_redirect_permanent", permanent}, {"pb_redirect_temporary", temporary}} {
+		fmt.Fprintf(&s, "map $uri $%s {\n", m.name)
+		for _, e := range m.entries {
+			s.WriteString(e)
+			s.WriteString("\n")
+		}
+		s.WriteString("}\n")
+	}
+	return s.String()
+}
+
+func redirectActions(db *gorm.DB) (actions []*publish.PublishAction, err error) {
+	var rules []*Redirect
+	if rules, err = loadRedirects(db); err != nil {
+		return
+	}
+	return []*publish.PublishAction{
+		{Url: RedirectsJSONPath, Content: redirectsJSON(rules)},
+		{Url: RedirectsNginxPath, Content: redirectsNginx(rules)},
+	}, nil
+}
+
+// recordRedirect redirects from to to, the redirects to from follow it and the one from to is removed
+// as the page is back on it
+func recordRedirect(db *gorm.DB, from, to string) (err error) {
+	if from == to {
+		return
+	}
+	if err = db.Where("from_path = ?", to).Delete(&Redirect{}).Error; err != nil {
+		return
+	}
+	if err = db.Model(&Redirect{}).Where("to_path = ?", from).UpdateColumn("to_path", to).Error; err != nil {
+		return
+	}
+	var r Redirect
+	if err = db.Where("from_path = ?", from).Limit(1).Find(&r).Error; err != nil {
+		return
+	}
+	r.FromPath = from
+	r.ToPath = to
+	r.StatusCode = http.StatusMovedPermanently
+	r.Automatic = true
+	return db.Save(&r).Error
+}
+
+// WrapPublishActions keeps the move of the page when it is published to a new URL, nothing is written
+// as the actions are computed for the dry runs and the checks as well
+func (p *Page) WrapPublishActions(in publish.PublishActionsFunc) publish.PublishActionsFunc {
+	return func(ctx context.Context, db *gorm.DB, storage oss.StorageInterface, obj interface{}) (actions []*publish.PublishAction, err error) {
+		if actions, err = in(ctx, db, storage, obj); err != nil {
+			return
+		}
+		p.movedFrom, p.movedTo = "", ""
+		var from, to string
+		for _, a := range actions {
+			if a.IsDelete {
+				from = a.Url
+			} else {
+				to = a.Url
+			}
+		}
+		if from != "" && to != "" {
+			p.movedFrom, p.movedTo = p.getAccessUrl(from), p.getAccessUrl(to)
+		}
+		return
+	}
+}
+
+// recordPageMove records the redirect from the old URL of the published page in the publishing transaction,
+// the rules files are written once it is committed, from the committed redirects
+func (b *Builder) recordPageMove(ctx context.Context, db *gorm.DB, p *Page) (err error) {
+	from, to := p.movedFrom, p.movedTo
+	p.movedFrom, p.movedTo = "", ""
+	if !b.redirectsEnabled || from == "" || publish.TargetFromContext(ctx) != publish.DefaultTarget {
+		return
+	}
+	if err = recordRedirect(db, from, to); err != nil {
+		return
+	}
+	publish.AfterCommit(ctx, func(ctx context.Context) {
+		if err := b.writeRedirects(ctx); err != nil {
+			log.Printf("error: %s\n", err)
+		}
+	})
+	return
+}
+
+// writeRedirects writes the rules files after a change of the redirects in the admin
+func (b *Builder) writeRedirects(ctx context.Context) (err error) {
+	b.redirects.invalidate()
+	return b.writeRedirectRules(ctx, b.db.WithContext(ctx))
+}
+
+func (b *Builder) writeRedirectRules(ctx context.Context, db *gorm.DB) (err error) {
+	if b.redirectStorage == nil {
+		return
+	}
+	var actions []*publish.PublishAction
+	if actions, err = redirectActions(db); err != nil {
+		return
+	}
+	return publish.UploadOrDelete(ctx, actions, b.redirectStorage)
+}
+
+func (b *Builder) redirectRules(ctx context.Context) (rules []*Redirect, err error) {
+	c := b.redirects
+	c.mu.RLock()
+	if time.Since(c.loadedAt) < redirectCacheTTL {
+		rules = c.rules
+		c.mu.RUnlock()
+		return
+	}
+	c.mu.RUnlock()
+	if rules, err = loadRedirects(b.db.WithContext(ctx)); err != nil {
+		return
+	}
+	c.mu.Lock()
+	c.rules, c.loadedAt = rules, time.Now()
+	c.mu.Unlock()
+	return
+}
+
+// RedirectHandler serves the redirects for the deployments serving the pages dynamically, the other requests
+// are served by next
+func (b *Builder) RedirectHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rules, err := b.redirectRules(r.Context())
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rule, to := matchRedirect(rules, r.URL.Path)
+		if rule == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.RawQuery != "" && !strings.Contains(to, "?") {
+			to += "?" + r.URL.RawQuery
+		}
+		code := rule.StatusCode
+		if code != http.StatusFound {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, to, code)
+	})
+}
+
+func redirectValidator(db *gorm.DB, r *Redirect, msgr *Messages) (err web.ValidationErrors) {
+	from := strings.TrimSuffix(r.FromPath, "/"+redirectWildcard)
+	if !strings.HasPrefix(r.FromPath, "/") || strings.Contains(from, redirectWildcard) {
+		err.FieldError("FromPath", msgr.InvalidRedirectFrom)
+	}
+	if r.ToPath == "" || strings.Count(r.ToPath, redirectWildcard) > 1 ||
+		(!strings.HasPrefix(r.ToPath, "/") && !strings.HasPrefix(r.ToPath, "http://") && !strings.HasPrefix(r.ToPath, "https://")) {
+		err.FieldError("ToPath", msgr.InvalidRedirectTo)
+	}
+	if err.HaveErrors() {
+		return
+	}
+	if r.FromPath == r.ToPath {
+		err.FieldError("ToPath", msgr.RedirectToItself)
+		return
+	}
+	var count int64
+	if dbErr := db.Model(&Redirect{}).Where("from_path = ? AND id <> ?", r.FromPath, r.ID).Count(&count).Error; dbErr != nil {
+		err.GlobalError(dbErr.Error())
+		return
+	}
+	if count > 0 {
+		err.FieldError("FromPath", msgr.RedirectFromExists)
+	}
+	return
+}
+
+func (b *Builder) installRedirects(pb *presets.Builder) {
+	if b.publisher != nil {
+		// the rules files are not orphans of the published pages
+		b.publisher.StoragePaths(func(context.Context, *gorm.DB) ([]string, error) {
+			return []string{RedirectsJSONPath, RedirectsNginxPath}, nil
+		})
+	}
+	pm := pb.Model(&Redirect{}).URIName("page_redirects").Label("Page Redirects")
+	pm.LabelName(func(evCtx *web.EventContext, singular bool) string {
+		msgr := i18n.MustGetModuleMessages(evCtx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		if singular {
+			return msgr.ModelLabelRedirect
+		}
+		return msgr.ModelLabelRedirects
+	})
+	lb := pm.Listing("FromPath", "ToPath", "StatusCode", "Automatic").SearchColumns("from_path", "to_path")
+	lb.WrapColumns(presets.CustomizeColumnLabel(func(evCtx *web.EventContext) (map[string]string, error) {
+		msgr := i18n.MustGetModuleMessages(evCtx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		return map[string]string{
+			"FromPath":   msgr.RedirectFrom,
+			"ToPath":     msgr.RedirectTo,
+			"StatusCode": msgr.RedirectStatusCode,
+			"Automatic":  msgr.RedirectAutomatic,
+		}, nil
+	}))
+	lb.Field("Automatic").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		if obj.(*Redirect).Automatic {
+			return h.Td(h.Text(msgr.RedirectAutomatic))
+		}
+		return h.Td()
+	})
+
+	eb := pm.Editing("FromPath", "ToPath", "StatusCode")
+	eb.Field("FromPath").LazyWrapComponentFunc(func(in presets.FieldComponentFunc) presets.FieldComponentFunc {
+		return func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+			msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+			return h.Components(in(obj, field, ctx), h.Div(h.Text(msgr.RedirectFromHint)).Class("text-caption mb-4"))
+		}
+	})
+	eb.Field("StatusCode").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		r := obj.(*Redirect)
+		if r.StatusCode == 0 {
+			r.StatusCode = http.StatusMovedPermanently
+		}
+		return presets.SelectField(obj, field, ctx).
+			Multiple(false).Chips(false).
+			Label(msgr.RedirectStatusCode).
+			Items([]map[string]interface{}{
+				{"Value": http.StatusMovedPermanently, "Title": msgr.RedirectPermanent},
+				{"Value": http.StatusFound, "Title": msgr.RedirectTemporary},
+			}).ItemTitle("Title").ItemValue("Value").
+			Attr(presets.VFieldError(field.FormKey, r.StatusCode, field.Errors)...)
+	})
+	eb.ValidateFunc(func(obj interface{}, ctx *web.EventContext) (err web.ValidationErrors) {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		return redirectValidator(b.db, obj.(*Redirect), msgr)
+	})
+	eb.WrapSaveFunc(func(in presets.SaveFunc) presets.SaveFunc {
+		return func(obj interface{}, id string, ctx *web.EventContext) (err error) {
+			r := obj.(*Redirect)
+			if r.StatusCode != http.StatusFound {
+				r.StatusCode = http.StatusMovedPermanently
+			}
+			r.Automatic = false
+			if err = in(obj, id, ctx); err != nil {
+				return
+			}
+			return b.writeRedirects(ctx.R.Context())
+		}
+	})
+	eb.WrapDeleteFunc(func(in presets.DeleteFunc) presets.DeleteFunc {
+		return func(obj interface{}, id string, ctx *web.EventContext) (err error) {
+			if err = in(obj, id, ctx); err != nil {
+				return
+			}
+			return b.writeRedirects(ctx.R.Context())
+		}
+	})
+}
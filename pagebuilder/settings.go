@@ -128,7 +128,7 @@ transform-origin: 0 0; transform:scale(0.5);width:200%;height:200%`),
 
 func detailPageEditor(dp *presets.DetailingBuilder, mb *presets.ModelBuilder, b *Builder) {
 	db := b.db
-	fields := b.filterFields([]interface{}{"Title", "CategoryID", "Slug"})
+	fields := b.filterFields([]interface{}{"Title", "CategoryID", "ParentID", "Slug"})
 	section := presets.NewSectionBuilder(mb, "Page").
 		Editing(fields...).WrapValidator(func(in presets.ValidateFunc) presets.ValidateFunc {
 		return func(obj interface{}, ctx *web.EventContext) (err web.ValidationErrors) {
@@ -215,6 +215,24 @@ func detailPageEditor(dp *presets.DetailingBuilder, mb *presets.ModelBuilder, b
 			return complete
 		})
 	}
+	if b.expectField("ParentID") {
+		section.ViewingField("ParentID").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+			p := obj.(*Page)
+			parent, err := p.GetParent(db)
+			if err != nil {
+				panic(err)
+			}
+			msgr := i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+			value := msgr.PageTreeRoot
+			if parent != nil {
+				value = parent.Title
+			}
+			return presets.ReadonlyText(obj, field, ctx).
+				Label(msgr.ParentPage).
+				Value(value)
+		})
+		section.EditingField("ParentID").ComponentFunc(b.parentPageComponent)
+	}
 	dp.Section(section)
 	return
 }
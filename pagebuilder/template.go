@@ -486,6 +486,7 @@ func (b *TemplateBuilder) Install() {
 			panic("error template model")
 		}
 		builder.configEditor(model)
+		model.configBundle()
 	}
 	b.model = model
 	model.tb = b
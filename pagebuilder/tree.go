@@ -0,0 +1,655 @@
+package pagebuilder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/oss"
+	. "github.com/qor5/x/v3/ui/vuetify"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/l10n"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/publish"
+	"github.com/qor5/admin/v3/utils"
+	"github.com/qor5/admin/v3/worker"
+)
+
+const (
+	PageTreeDialogEvent = "page_builder_PageTreeDialogEvent"
+	ReparentPageEvent   = "page_builder_ReparentPageEvent"
+
+	paramParentID = "parentID"
+
+	pageTreeActionName = "PageTree"
+	pageTreePortal     = "pageBuilderPageTreePortal"
+
+	// maxTreeDepth stops walking up the parents of a tree with a cycle
+	maxTreeDepth = 32
+
+	ChildPagesJobName    = "page-builder-republish-child-pages"
+	CategoryPagesJobName = "page-builder-republish-category-pages"
+)
+
+// ChildPagesJobArgs is a published page whose online children are republished
+type ChildPagesJobArgs struct {
+	PageID     uint
+	LocaleCode string
+	Target     string
+}
+
+// CategoryPagesJobArgs are the moved categories whose online root pages are republished
+type CategoryPagesJobArgs struct {
+	CategoryIDs []uint
+	LocaleCode  string
+}
+
+// Breadcrumb is a page from the root of the tree to the current page, it is given to the PageLayoutFunc
+// and the containers to render the navigation
+type Breadcrumb struct {
+	ID    uint
+	Title string
+	Url   string
+}
+
+// GetParent returns the online version of the parent page, or its latest version when it is not online
+func (p *Page) GetParent(db *gorm.DB) (parent *Page, err error) {
+	if p.ParentID == 0 {
+		return
+	}
+	parent = &Page{}
+	err = db.Where("id = ? AND locale_code = ?", p.ParentID, p.LocaleCode).
+		Order(gorm.Expr("CASE WHEN status = ? THEN 0 ELSE 1 END", publish.StatusOnline)).
+		Order("version DESC").
+		First(parent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return
+}
+
+// GetAncestors returns the pages above the page from the root
+func (p *Page) GetAncestors(db *gorm.DB) (r []*Page, err error) {
+	cur := p
+	for i := 0; i < maxTreeDepth; i++ {
+		var parent *Page
+		if parent, err = cur.GetParent(db); err != nil || parent == nil || parent.ID == p.ID {
+			return
+		}
+		r = append([]*Page{parent}, r...)
+		cur = parent
+	}
+	return
+}
+
+// publishUrl is the URL of the page under the category of the root page and the slugs of its ancestors
+func (p *Page) publishUrl(db *gorm.DB, localePath string) (r string, err error) {
+	var (
+		ancestors []*Page
+		category  Category
+		root      = p
+		slugs     []string
+	)
+	if ancestors, err = p.GetAncestors(db); err != nil {
+		return
+	}
+	if len(ancestors) > 0 {
+		root = ancestors[0]
+	}
+	if category, err = root.GetCategory(db); err != nil {
+		return
+	}
+	for _, a := range ancestors {
+		slugs = append(slugs, a.Slug)
+	}
+	return generatePublishUrl(localePath, category.Path, append(slugs, p.Slug)...), nil
+}
+
+// GetBreadcrumbs returns the ancestors of the page and the page itself with their URLs
+func (p *Page) GetBreadcrumbs(db *gorm.DB, localePath string) (r []*Breadcrumb, err error) {
+	var ancestors []*Page
+	if ancestors, err = p.GetAncestors(db); err != nil {
+		return
+	}
+	for _, page := range append(ancestors, p) {
+		var u string
+		if u, err = page.publishUrl(db, localePath); err != nil {
+			return
+		}
+		r = append(r, &Breadcrumb{ID: page.ID, Title: page.Title, Url: page.getAccessUrl(u)})
+	}
+	return
+}
+
+func (b *ModelBuilder) breadcrumbs(obj interface{}, locale string) []*Breadcrumb {
+	p, ok := obj.(*Page)
+	if !ok || p.ID == 0 {
+		return nil
+	}
+	r, err := p.GetBreadcrumbs(b.db, b.builder.l10n.GetLocalePath(locale))
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// AfterPublish records the redirect from the old URL of the page and republishes the online child pages
+// whose URL has changed with the one of the page, once the page is committed, in a job of the ChildPagesWorker
+// or right after the commit without it. The children republish their own children the same way
+func (p *Page) AfterPublish(ctx context.Context, db *gorm.DB, storage oss.StorageInterface) (err error) {
+	b, ok := ctx.Value(utils.GetObjectName(p)).(*ModelBuilder)
+	if !ok || b.builder.publisher == nil {
+		return
+	}
+	if err = b.builder.recordPageMove(ctx, db, p); err != nil {
+		return
+	}
+	args := &ChildPagesJobArgs{PageID: p.ID, LocaleCode: p.LocaleCode, Target: publish.TargetFromContext(ctx)}
+	publish.AfterCommit(ctx, func(ctx context.Context) {
+		b.builder.queueChildPages(ctx, args)
+	})
+	return
+}
+
+// ChildPagesWorker republishes the child pages of the published pages and the pages of the moved categories
+// as jobs of w, so that the publishing of a page or the saving of a category doesn't wait for them
+func (b *Builder) ChildPagesWorker(w *worker.Builder) (r *Builder) {
+	b.childPagesWorker = w
+	w.NewJob(ChildPagesJobName).
+		Resource(&ChildPagesJobArgs{}).
+		Handler(func(ctx context.Context, job worker.QorJobInterface) error {
+			info, err := job.GetJobInfo()
+			if err != nil {
+				return err
+			}
+			return b.republishChildPages(ctx, info.Argument.(*ChildPagesJobArgs))
+		})
+	w.NewJob(CategoryPagesJobName).
+		Resource(&CategoryPagesJobArgs{}).
+		Handler(func(ctx context.Context, job worker.QorJobInterface) error {
+			info, err := job.GetJobInfo()
+			if err != nil {
+				return err
+			}
+			return b.republishCategoryPages(ctx, info.Argument.(*CategoryPagesJobArgs))
+		})
+	return b
+}
+
+func (b *Builder) queueChildPages(ctx context.Context, args *ChildPagesJobArgs) {
+	b.queueRepublish(ctx, ChildPagesJobName, args, func(ctx context.Context) error {
+		return b.republishChildPages(ctx, args)
+	})
+}
+
+func (b *Builder) queueCategoryPages(ctx context.Context, args *CategoryPagesJobArgs) {
+	b.queueRepublish(ctx, CategoryPagesJobName, args, func(ctx context.Context) error {
+		return b.republishCategoryPages(ctx, args)
+	})
+}
+
+// queueRepublish adds the job to the ChildPagesWorker, without it the pages are republished at once,
+// the errors of the pages are logged as the committed change is kept
+func (b *Builder) queueRepublish(ctx context.Context, job string, args any, republish func(ctx context.Context) error) {
+	var err error
+	if b.childPagesWorker != nil {
+		_, err = b.childPagesWorker.AddJob(ctx, job, args)
+	} else {
+		err = republish(ctx)
+	}
+	if err != nil {
+		log.Printf("error: %s\n", err)
+	}
+}
+
+// republishChildPages republishes the online children of the page whose URL has changed
+func (b *Builder) republishChildPages(ctx context.Context, args *ChildPagesJobArgs) (err error) {
+	var (
+		db         = b.db.WithContext(ctx)
+		localePath = b.l10n.GetLocalePath(args.LocaleCode)
+		children   []*Page
+	)
+	ctx = b.publisher.WithContextValues(ctx)
+	if args.Target != "" && args.Target != publish.DefaultTarget {
+		ctx = publish.WithTarget(ctx, args.Target)
+	}
+	if err = db.Where("parent_id = ? AND locale_code = ? AND status = ?", args.PageID, args.LocaleCode, publish.StatusOnline).
+		Find(&children).Error; err != nil {
+		return
+	}
+	for _, child := range children {
+		if child.ID == args.PageID {
+			continue
+		}
+		u, err2 := child.publishUrl(db, localePath)
+		if err2 == nil && u == child.OnlineUrl {
+			continue
+		}
+		if err2 == nil {
+			err2 = b.publisher.Publish(ctx, child)
+		}
+		if err2 != nil {
+			err = multierror.Append(err, fmt.Errorf("page %d: %w", child.ID, err2)).ErrorOrNil()
+		}
+	}
+	return
+}
+
+type pageKey struct {
+	id     uint
+	locale string
+}
+
+// pagePathResolver resolves the URLs of the pages in memory, the parents are their online versions
+// or their latest ones
+type pagePathResolver struct {
+	pages map[pageKey]pagePathInfo
+}
+
+func newPagePathResolver(infos []pagePathInfo) *pagePathResolver {
+	r := &pagePathResolver{pages: map[pageKey]pagePathInfo{}}
+	for _, info := range infos {
+		k := pageKey{info.ID, info.LocaleCode}
+		if cur, ok := r.pages[k]; !ok || preferredPagePathInfo(info, cur) {
+			r.pages[k] = info
+		}
+	}
+	return r
+}
+
+func preferredPagePathInfo(a, b pagePathInfo) bool {
+	aOnline, bOnline := a.Status == publish.StatusOnline, b.Status == publish.StatusOnline
+	if aOnline != bOnline {
+		return aOnline
+	}
+	return a.Version > b.Version
+}
+
+func (r *pagePathResolver) publishUrl(localePath string, info pagePathInfo) string {
+	var (
+		slugs = []string{info.Slug}
+		root  = info
+	)
+	for i := 0; i < maxTreeDepth && root.ParentID != 0; i++ {
+		parent, ok := r.pages[pageKey{root.ParentID, root.LocaleCode}]
+		if !ok || parent.ID == info.ID {
+			break
+		}
+		slugs = append([]string{parent.Slug}, slugs...)
+		root = parent
+	}
+	return generatePublishUrl(localePath, root.CategoryPath, slugs...)
+}
+
+// isDescendant reports whether the page id is parentID or under it
+func (r *pagePathResolver) isDescendant(parentID, id uint, locale string) bool {
+	cur := parentID
+	for i := 0; i < maxTreeDepth && cur != 0; i++ {
+		if cur == id {
+			return true
+		}
+		cur = r.pages[pageKey{cur, locale}].ParentID
+	}
+	return false
+}
+
+func loadPagePathResolver(db *gorm.DB) (r *pagePathResolver, infos []pagePathInfo, err error) {
+	if err = db.Raw(queryLocaleCodeCategoryPathSlugSQL).Scan(&infos).Error; err != nil {
+		return
+	}
+	return newPagePathResolver(infos), infos, nil
+}
+
+type pageTreeNode struct {
+	info     pagePathInfo
+	title    string
+	online   bool
+	children []*pageTreeNode
+}
+
+// latestPagePathInfos keeps the latest version of each page, the tree is edited on them
+func latestPagePathInfos(infos []pagePathInfo) (r []pagePathInfo) {
+	latest := map[pageKey]int{}
+	for _, info := range infos {
+		k := pageKey{info.ID, info.LocaleCode}
+		if i, ok := latest[k]; ok {
+			if info.Version > r[i].Version {
+				r[i] = info
+			}
+			continue
+		}
+		latest[k] = len(r)
+		r = append(r, info)
+	}
+	return
+}
+
+// pageTree returns the root pages of the locale with their children ordered by title, as their latest versions
+// place them
+func pageTree(db *gorm.DB, locale string) (roots []*pageTreeNode, err error) {
+	var pages []*Page
+	if err = db.Select("id", "version", "locale_code", "title", "slug", "parent_id", "status").
+		Where("locale_code = ?", locale).
+		Find(&pages).Error; err != nil {
+		return
+	}
+	var infos []pagePathInfo
+	titles := map[pageKey]string{}
+	online := map[pageKey]bool{}
+	for _, p := range pages {
+		infos = append(infos, pagePathInfo{ID: p.ID, Version: p.Version.Version, LocaleCode: p.LocaleCode, Slug: p.Slug, ParentID: p.ParentID, Status: p.Status.Status})
+		if p.Status.Status == publish.StatusOnline {
+			online[pageKey{p.ID, p.LocaleCode}] = true
+		}
+	}
+	resolver := newPagePathResolver(latestPagePathInfos(infos))
+	for _, p := range pages {
+		if info := resolver.pages[pageKey{p.ID, p.LocaleCode}]; info.Version == p.Version.Version {
+			titles[pageKey{p.ID, p.LocaleCode}] = p.Title
+		}
+	}
+	nodes := map[uint]*pageTreeNode{}
+	for k, info := range resolver.pages {
+		nodes[info.ID] = &pageTreeNode{info: info, title: titles[k], online: online[k]}
+	}
+	for _, n := range nodes {
+		parent, ok := nodes[n.info.ParentID]
+		if ok && !resolver.isDescendant(n.info.ParentID, n.info.ID, locale) {
+			parent.children = append(parent.children, n)
+			continue
+		}
+		roots = append(roots, n)
+	}
+	var sortNodes func(ns []*pageTreeNode)
+	sortNodes = func(ns []*pageTreeNode) {
+		sort.Slice(ns, func(i, j int) bool {
+			if ns[i].title != ns[j].title {
+				return ns[i].title < ns[j].title
+			}
+			return ns[i].info.ID < ns[j].info.ID
+		})
+		for _, n := range ns {
+			sortNodes(n.children)
+		}
+	}
+	sortNodes(roots)
+	return
+}
+
+func (b *Builder) pageTreeContent(ctx *web.EventContext) (r h.HTMLComponent, err error) {
+	var (
+		msgr      = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		locale, _ = l10n.IsLocalizableFromContext(ctx.R.Context())
+		roots     []*pageTreeNode
+		rows      []h.HTMLComponent
+	)
+	if roots, err = pageTree(b.db, locale); err != nil {
+		return
+	}
+	reparent := func(parentID string) string {
+		return web.Plaid().EventFunc(ReparentPageEvent).
+			Query(presets.ParamID, web.Var("treeLocals.dragging")).
+			Query(paramParentID, parentID).
+			Go()
+	}
+	var walk func(ns []*pageTreeNode, level int)
+	walk = func(ns []*pageTreeNode, level int) {
+		for _, n := range ns {
+			id := fmt.Sprint(n.info.ID)
+			rows = append(rows,
+				h.Div(
+					VIcon("mdi-drag").Size(SizeSmall).Class("mr-2"),
+					VIcon(lo.Ternary(len(n.children) > 0, "mdi-file-tree", "mdi-file-outline")).Size(SizeSmall).Class("mr-2"),
+					h.Span(n.title),
+					h.Span(n.info.Slug).Class("text-caption text-grey ml-2"),
+					h.If(n.online,
+						VIcon("mdi-earth").Size(SizeXSmall).Color(ColorSuccess).Class("ml-2"),
+					),
+				).Class("d-flex align-center py-2 px-2 rounded").
+					Style(fmt.Sprintf("padding-left: %dpx !important; cursor: grab;", 8+level*32)).
+					Attr("draggable", "true").
+					Attr(":class", fmt.Sprintf(`treeLocals.over === %q ? "bg-primary-lighten-2" : ""`, id)).
+					Attr("@dragstart", fmt.Sprintf("treeLocals.dragging = %q", id)).
+					Attr("@dragover.prevent", fmt.Sprintf("treeLocals.over = %q", id)).
+					Attr("@dragleave", "treeLocals.over = null").
+					Attr("@drop.prevent", fmt.Sprintf("treeLocals.over = null; if (treeLocals.dragging && treeLocals.dragging !== %q) {%s}", id, reparent(id))),
+			)
+			walk(n.children, level+1)
+		}
+	}
+	walk(roots, 0)
+	return web.Portal(
+		web.Scope(
+			h.Div(h.Text(msgr.PageTreeHint)).Class("text-caption mb-2"),
+			h.Div(rows...),
+			h.Div(h.Text(msgr.PageTreeRootDropZone)).
+				Class("text-caption text-center pa-4 mt-2 rounded border-dashed border").
+				Attr(":class", `treeLocals.over === "0" ? "bg-primary-lighten-2" : ""`).
+				Attr("@dragover.prevent", `treeLocals.over = "0"`).
+				Attr("@dragleave", "treeLocals.over = null").
+				Attr("@drop.prevent", fmt.Sprintf("treeLocals.over = null; if (treeLocals.dragging) {%s}", reparent("0"))),
+		).VSlot("{ locals : treeLocals }").Init("{dragging: null, over: null}"),
+	).Name(pageTreePortal), nil
+}
+
+func (b *Builder) pageTreeDialog(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		msgr    = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		pMsgr   = presets.MustGetMessages(ctx.R)
+		content h.HTMLComponent
+	)
+	if content, err = b.pageTreeContent(ctx); err != nil {
+		return
+	}
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+		Name: presets.DialogPortalName,
+		Body: web.Scope(
+			VDialog(
+				VCard(
+					VCardTitle(h.Text(msgr.PageTree)),
+					VCardText(content).Class("overflow-y-auto").Attr("style", "max-height: 70vh"),
+					VCardActions(
+						VSpacer(),
+						VBtn(pMsgr.OK).
+							Color(ColorPrimary).
+							Variant(VariantFlat).
+							Theme(ThemeDark).
+							Attr("@click", "locals.pageTreeDialog = false;"+web.Plaid().Reload().Go()),
+					),
+				),
+			).MaxWidth("640px").
+				Attr("v-model", "locals.pageTreeDialog"),
+		).Init("{pageTreeDialog:true}").VSlot("{locals}"),
+	})
+	return
+}
+
+// reparentPage moves the latest version of the page under another one, a draft is created from it when it is
+// not a draft. The online version is not changed, the page is moved online when the draft is published
+func (b *Builder) reparentPage(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		msgr      = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		locale, _ = l10n.IsLocalizableFromContext(ctx.R.Context())
+		pm        = b.GetPageModelBuilder()
+	)
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), ColorError)
+			err = nil
+		}
+	}()
+	if pm == nil {
+		return
+	}
+	if err = pm.mb.Info().Verifier().Do(presets.PermUpdate).WithReq(ctx.R).IsAllowed(); err != nil {
+		return
+	}
+	id, _ := strconv.ParseUint(ctx.R.FormValue(presets.ParamID), 10, 64)
+	parentID, _ := strconv.ParseUint(ctx.R.FormValue(paramParentID), 10, 64)
+	if id == 0 {
+		return
+	}
+	_, infos, err := loadPagePathResolver(b.db)
+	if err != nil {
+		return
+	}
+	infos = latestPagePathInfos(infos)
+	resolver := newPagePathResolver(infos)
+	page, ok := resolver.pages[pageKey{uint(id), locale}]
+	if !ok {
+		return
+	}
+	if parentID != 0 && resolver.isDescendant(uint(parentID), page.ID, locale) {
+		presets.ShowMessage(&r, msgr.InvalidParentPage, ColorWarning)
+		return
+	}
+	page.ParentID = uint(parentID)
+	resolver.pages[pageKey{page.ID, locale}] = page
+	localePath := b.l10n.GetLocalePath(locale)
+	newUrl := resolver.publishUrl(localePath, page)
+	for _, info := range infos {
+		if info.ID == page.ID && info.LocaleCode == locale {
+			continue
+		}
+		if resolver.publishUrl(b.l10n.GetLocalePath(info.LocaleCode), info) == newUrl {
+			presets.ShowMessage(&r, msgr.ConflictSlugMsg, ColorWarning)
+			return
+		}
+	}
+	if err = b.db.Transaction(func(tx *gorm.DB) (dbErr error) {
+		latest := &Page{}
+		if dbErr = tx.Where("id = ? AND version = ? AND locale_code = ?", page.ID, page.Version, locale).First(latest).Error; dbErr != nil {
+			return
+		}
+		if latest.Status.Status == publish.StatusDraft {
+			return tx.Model(&Page{}).Where("id = ? AND version = ? AND locale_code = ?", page.ID, page.Version, locale).
+				UpdateColumn("parent_id", page.ParentID).Error
+		}
+		latest.ParentID = page.ParentID
+		return pm.newDraftVersion(tx, latest, page.Version)
+	}); err != nil {
+		return
+	}
+	var content h.HTMLComponent
+	if content, err = b.pageTreeContent(ctx); err != nil {
+		return
+	}
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{Name: pageTreePortal, Body: content})
+	presets.ShowMessage(&r, msgr.PageMovedInDraft, "")
+	return
+}
+
+// categoryPath is the path of the category under its parent, the path is kept when it is already under it
+func categoryPath(db *gorm.DB, c *Category) (r string, err error) {
+	r = path.Clean(path.Join("/", c.Path))
+	if c.ParentID == 0 {
+		return
+	}
+	var parent Category
+	if err = db.Where("id = ? AND locale_code = ?", c.ParentID, c.LocaleCode).First(&parent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		return
+	}
+	if r == parent.Path || strings.HasPrefix(r, strings.TrimSuffix(parent.Path, "/")+"/") {
+		return
+	}
+	return path.Join(parent.Path, r), nil
+}
+
+// moveCategoryDescendants moves the categories under the old path of the category to its new path in tx,
+// ids are the category and its descendants, whose online root pages are to be republished
+func moveCategoryDescendants(tx *gorm.DB, c *Category, oldPath string) (ids []uint, err error) {
+	if oldPath == "" || oldPath == c.Path {
+		return
+	}
+	var cats []*Category
+	if err = tx.Where("locale_code = ? AND (id = ? OR path LIKE ?)", c.LocaleCode, c.ID, strings.TrimSuffix(oldPath, "/")+"/%").
+		Find(&cats).Error; err != nil {
+		return
+	}
+	for _, cat := range cats {
+		ids = append(ids, cat.ID)
+		if cat.ID == c.ID {
+			continue
+		}
+		newPath := path.Join(c.Path, strings.TrimPrefix(cat.Path, strings.TrimSuffix(oldPath, "/")))
+		if err = tx.Model(&Category{}).Where("id = ? AND locale_code = ?", cat.ID, cat.LocaleCode).
+			UpdateColumn("path", newPath).Error; err != nil {
+			return
+		}
+	}
+	return
+}
+
+// republishCategoryPages republishes the online root pages of the moved categories, their child pages
+// are republished with them
+func (b *Builder) republishCategoryPages(ctx context.Context, args *CategoryPagesJobArgs) (err error) {
+	var pages []*Page
+	if err = b.db.WithContext(ctx).Where("category_id IN ? AND locale_code = ? AND parent_id = 0 AND status = ?", args.CategoryIDs, args.LocaleCode, publish.StatusOnline).
+		Find(&pages).Error; err != nil {
+		return
+	}
+	ctx = b.publisher.WithContextValues(ctx)
+	for _, p := range pages {
+		if err2 := b.publisher.Publish(ctx, p); err2 != nil {
+			err = multierror.Append(err, fmt.Errorf("page %d: %w", p.ID, err2)).ErrorOrNil()
+		}
+	}
+	return
+}
+
+type parentPageItem struct {
+	ID    uint
+	Title string
+}
+
+// parentPageItems are the pages of the locale the page can be moved under, the page and its children are left out
+func parentPageItems(db *gorm.DB, locale string, id uint, none string) (r []parentPageItem, err error) {
+	var roots []*pageTreeNode
+	if roots, err = pageTree(db, locale); err != nil {
+		return
+	}
+	r = append(r, parentPageItem{Title: none})
+	var walk func(ns []*pageTreeNode, level int)
+	walk = func(ns []*pageTreeNode, level int) {
+		for _, n := range ns {
+			if n.info.ID == id {
+				continue
+			}
+			r = append(r, parentPageItem{ID: n.info.ID, Title: strings.Repeat("— ", level) + n.title})
+			walk(n.children, level+1)
+		}
+	}
+	walk(roots, 0)
+	return
+}
+
+func (b *Builder) parentPageComponent(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+	var (
+		p         = obj.(*Page)
+		locale, _ = l10n.IsLocalizableFromContext(ctx.R.Context())
+		msgr      = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+	)
+	items, err := parentPageItems(b.db, locale, p.ID, msgr.PageTreeRoot)
+	if err != nil {
+		panic(err)
+	}
+	return presets.SelectField(obj, field, ctx).
+		Multiple(false).Chips(false).
+		Label(msgr.ParentPage).
+		Items(items).ItemTitle("Title").ItemValue("ID").
+		Attr(presets.VFieldError(field.FormKey, p.ParentID, field.Errors)...)
+}
@@ -1,9 +1,25 @@
 package pagebuilder
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/qor5/x/v3/oss"
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/publish"
 )
 
 func TestFillCategoryIndentLevels(t *testing.T) {
@@ -68,3 +84,379 @@ func TestFillCategoryIndentLevels(t *testing.T) {
 		}
 	}
 }
+
+func TestContainerDiffKeys(t *testing.T) {
+	cons := []*Container{
+		{ModelName: "Header"},
+		{ModelName: "Text"},
+		{ModelName: "Text"},
+		{ModelName: "Footer"},
+	}
+	expect := []containerDiffKey{
+		{modelName: "Header", occurrence: 0},
+		{modelName: "Text", occurrence: 0},
+		{modelName: "Text", occurrence: 1},
+		{modelName: "Footer", occurrence: 0},
+	}
+	if diff := cmp.Diff(expect, containerDiffKeys(cons), cmp.AllowUnexported(containerDiffKey{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDeviceVariants(t *testing.T) {
+	b := &Builder{}
+	c := &Container{Devices: "computer,phone"}
+	c.SetDeviceOverrides(DeviceOverrides{"phone": {"Title": "short"}})
+
+	variants := b.deviceVariants(c)
+	if len(variants) != 2 {
+		t.Fatalf("expected 2 variants, got %d", len(variants))
+	}
+	if diff := cmp.Diff([]string{"phone", "tablet"}, variants[0].hidden); diff != "" {
+		t.Error(diff)
+	}
+	if variants[1].device != "phone" || variants[1].fields["Title"] != "short" {
+		t.Errorf("unexpected phone variant: %+v", variants[1])
+	}
+	if diff := cmp.Diff([]string{"computer", "tablet"}, variants[1].hidden); diff != "" {
+		t.Error(diff)
+	}
+
+	expected := "@media (max-width: 414px){.pb-device-hidden-phone{display:none !important}}\n" +
+		"@media (min-width: 415px) and (max-width: 768px){.pb-device-hidden-tablet{display:none !important}}\n" +
+		"@media (min-width: 769px){.pb-device-hidden-computer{display:none !important}}\n"
+	if diff := cmp.Diff(expected, b.deviceStyle()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestApplyDeviceOverrides(t *testing.T) {
+	type heading struct {
+		Title string
+		Size  int
+	}
+	obj := &heading{Title: "long", Size: 3}
+	r, err := applyDeviceOverrides(obj, map[string]string{"Title": "short", "Size": "2", "Unknown": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&heading{Title: "short", Size: 2}, r); diff != "" {
+		t.Error(diff)
+	}
+	if obj.Title != "long" {
+		t.Errorf("the original object should not be changed")
+	}
+	if _, err = applyDeviceOverrides(obj, map[string]string{"Size": "big"}); err == nil {
+		t.Errorf("expected an error for an invalid value")
+	}
+}
+
+func TestContainerVisibleAt(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	for _, c := range []struct {
+		name   string
+		c      *Container
+		at     time.Time
+		expect bool
+	}{
+		{name: "not scheduled", c: &Container{}, at: start, expect: true},
+		{name: "before start", c: &Container{ScheduledStartAt: &start}, at: start.Add(-time.Minute), expect: false},
+		{name: "at start", c: &Container{ScheduledStartAt: &start, ScheduledEndAt: &end}, at: start, expect: true},
+		{name: "at end", c: &Container{ScheduledStartAt: &start, ScheduledEndAt: &end}, at: end, expect: false},
+		{name: "open start", c: &Container{ScheduledEndAt: &end}, at: start.Add(-time.Hour), expect: true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.c.VisibleAt(c.at); got != c.expect {
+				t.Errorf("expected %v, got %v", c.expect, got)
+			}
+		})
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []*PageVariant{{Name: "", Weight: 1}, {Name: "b", Weight: 0}, {Name: "c", Weight: 3}}
+	for roll, expect := range []string{"", "c", "c", "c"} {
+		if v := pickVariant(variants, func(n int) int { return roll }); v.Name != expect {
+			t.Errorf("roll %d: expected %q, got %q", roll, expect, v.Name)
+		}
+	}
+	if v := pickVariant([]*PageVariant{{Name: ""}, {Name: "b"}}, func(n int) int { return 0 }); v.Name != "" {
+		t.Errorf("the original should be picked without weights, got %q", v.Name)
+	}
+	if s := variantTrafficShare(variants, variants[2]); s != 0.75 {
+		t.Errorf("expected the share 0.75, got %v", s)
+	}
+}
+
+func TestVariantBeacon(t *testing.T) {
+	b := &ModelBuilder{variants: &variantCache{}}
+	b.variants.put("/p", &variantPage{variants: []*PageVariant{{ID: 2}}, loadedAt: time.Now()})
+	for _, c := range []struct {
+		name   string
+		method string
+		query  string
+		cookie string
+		expect int
+	}{
+		{name: "get", method: http.MethodGet, query: "variantID=1&event=impression", expect: http.StatusMethodNotAllowed},
+		{name: "unknown event", method: http.MethodPost, query: "variantID=1&event=click", expect: http.StatusBadRequest},
+		{name: "invalid id", method: http.MethodPost, query: "variantID=x&event=impression", expect: http.StatusBadRequest},
+		{name: "counted", method: http.MethodPost, query: "variantID=1&event=impression", cookie: "pb_variant_event_1_impression", expect: http.StatusNoContent},
+		{name: "not in the test", method: http.MethodPost, query: "variantID=1&event=impression&path=/p", expect: http.StatusBadRequest},
+	} {
+		r := httptest.NewRequest(c.method, VariantBeaconPath+"?"+c.query, nil)
+		if c.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: c.cookie, Value: "1"})
+		}
+		w := httptest.NewRecorder()
+		b.serveVariantBeacon(w, r)
+		if w.Code != c.expect {
+			t.Errorf("%s: expected %d, got %d", c.name, c.expect, w.Code)
+		}
+		if w.Code != http.StatusNoContent && strings.TrimSpace(w.Body.String()) != http.StatusText(w.Code) {
+			t.Errorf("%s: the error should be generic, got %q", c.name, w.Body.String())
+		}
+	}
+}
+
+func TestVariantPageBody(t *testing.T) {
+	status := http.StatusInternalServerError
+	b := &ModelBuilder{preview: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, "variant "+r.URL.Query().Get(paramVariant))
+	})}
+	page := &variantPage{slug: "1_v1", bodies: map[string]string{}}
+	if _, ok := page.body(b, "b"); ok {
+		t.Fatal("a failed rendering should not be served")
+	}
+	status = http.StatusOK
+	if body, ok := page.body(b, "b"); !ok || body != "variant b" {
+		t.Fatalf("the rendering should be served once it succeeds, got %q", body)
+	}
+	status = http.StatusInternalServerError
+	if body, ok := page.body(b, "b"); !ok || body != "variant b" {
+		t.Fatalf("the rendering should be cached, got %q", body)
+	}
+}
+
+func TestPagePathResolver(t *testing.T) {
+	r := newPagePathResolver([]pagePathInfo{
+		{ID: 1, Version: "v1", LocaleCode: "en", CategoryPath: "/docs", Slug: "/guide", Status: "online"},
+		{ID: 1, Version: "v2", LocaleCode: "en", CategoryPath: "/docs", Slug: "/draft", Status: "draft"},
+		{ID: 2, Version: "v1", LocaleCode: "en", Slug: "/install", ParentID: 1},
+		{ID: 3, Version: "v1", LocaleCode: "en", CategoryPath: "/ignored", Slug: "/linux", ParentID: 2},
+	})
+	if u := r.publishUrl("", r.pages[pageKey{3, "en"}]); u != "/docs/guide/install/linux/index.html" {
+		t.Errorf("unexpected url %q", u)
+	}
+	if u := r.publishUrl("/en", r.pages[pageKey{1, "en"}]); u != "/en/docs/guide/index.html" {
+		t.Errorf("unexpected url %q", u)
+	}
+	if !r.isDescendant(3, 1, "en") || !r.isDescendant(1, 1, "en") {
+		t.Errorf("page 3 should be under page 1")
+	}
+	if r.isDescendant(1, 3, "en") {
+		t.Errorf("page 1 should not be under page 3")
+	}
+
+	// the tree is edited on the drafts
+	latest := newPagePathResolver(latestPagePathInfos([]pagePathInfo{
+		{ID: 1, Version: "v1", LocaleCode: "en", Slug: "/guide", Status: "online"},
+		{ID: 1, Version: "v2", LocaleCode: "en", Slug: "/draft", Status: "draft"},
+		{ID: 2, Version: "v1", LocaleCode: "en", Slug: "/install", Status: "online"},
+		{ID: 2, Version: "v2", LocaleCode: "en", Slug: "/install", ParentID: 1, Status: "draft"},
+	}))
+	if u := latest.publishUrl("", latest.pages[pageKey{2, "en"}]); u != "/draft/install/index.html" {
+		t.Errorf("unexpected url of the drafts %q", u)
+	}
+}
+
+func TestPageMoveActions(t *testing.T) {
+	p := &Page{}
+	in := func(actions ...*publish.PublishAction) publish.PublishActionsFunc {
+		return func(context.Context, *gorm.DB, oss.StorageInterface, interface{}) ([]*publish.PublishAction, error) {
+			return actions, nil
+		}
+	}
+	// the db is nil as nothing is written
+	actions, err := p.WrapPublishActions(in(
+		&publish.PublishAction{Url: "/new/index.html", Content: "page"},
+		&publish.PublishAction{Url: "/old/index.html", IsDelete: true},
+	))(context.Background(), nil, nil, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 2 {
+		t.Errorf("the actions should be kept, got %d", len(actions))
+	}
+	if p.movedFrom != "/old" || p.movedTo != "/new" {
+		t.Errorf("unexpected move %q > %q", p.movedFrom, p.movedTo)
+	}
+	if _, err = p.WrapPublishActions(in(&publish.PublishAction{Url: "/new/index.html"}))(context.Background(), nil, nil, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.movedFrom != "" || p.movedTo != "" {
+		t.Errorf("the move of a previous computation should be cleared")
+	}
+}
+
+func TestMatchRedirect(t *testing.T) {
+	rules := []*Redirect{
+		{FromPath: "/blog/*", ToPath: "/news/*", StatusCode: 301},
+		{FromPath: "/blog/old/*", ToPath: "/archive", StatusCode: 302},
+		{FromPath: "/about", ToPath: "/company/about", StatusCode: 301},
+	}
+	sortRedirects(rules)
+	cases := []struct {
+		path   string
+		expect string
+	}{
+		{"/about/", "/company/about"},
+		{"/about/index.html", "/company/about"},
+		{"/blog/2024/hello", "/news/2024/hello"},
+		{"/blog", "/news/"},
+		{"/blog/old/post", "/archive"},
+		{"/contact", ""},
+	}
+	for _, c := range cases {
+		if _, to := matchRedirect(rules, c.path); to != c.expect {
+			t.Errorf("%s: expected %q, got %q", c.path, c.expect, to)
+		}
+	}
+	nginx := redirectsNginx(rules)
+	for _, expect := range []string{`"/about/" "/company/about";`, `"~^/blog/(.*)$" "/news/$1";`} {
+		if !strings.Contains(nginx, expect) {
+			t.Errorf("expected %s in\n%s", expect, nginx)
+		}
+	}
+	nginx = redirectsNginx([]*Redirect{
+		{FromPath: `/a"b\c`, ToPath: "/x$y\n", StatusCode: 301},
+		{FromPath: "/c.d/*", ToPath: "/e/*", StatusCode: 301},
+	})
+	for _, expect := range []string{`"/a\"b\\c" "/x%24y%0A";`, `"~^/c\\.d/(.*)$" "/e/$1";`} {
+		if !strings.Contains(nginx, expect) {
+			t.Errorf("expected %s in\n%s", expect, nginx)
+		}
+	}
+}
+
+func TestLinkAudit(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><head><link rel="stylesheet" href="/assets/app.css"></head><body>
+<div data-container-id="headers_1"><a href="/docs">Docs</a><a href="old">Old</a><img srcset="/system/a.png 1x, /system/b.png 2x"></div>
+<a href="mailto:a@b.c">Mail</a><a href="https://example.com/x">External</a><a href="#top">Top</a><a href="/moved">Moved</a><a href="/gone">Gone</a>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &linkAuditor{
+		pages:       map[string]bool{"/docs": true, "/blog": true},
+		unpublished: map[string]bool{"/blog/old": true},
+		redirects:   []*Redirect{{FromPath: "/moved", ToPath: "/missing"}},
+		mediaPrefix: "/system/",
+	}
+	base := &url.URL{Path: "/blog/"}
+	var got []string
+	for _, ref := range linkAuditRefs(doc) {
+		check := a.checkLink
+		if ref.kind == BrokenLinkKindAsset {
+			check = a.checkAsset
+		}
+		got = append(got, fmt.Sprintf("%s %s %s %s", ref.containerID, ref.kind, ref.url, check(base, ref.url)))
+	}
+	expect := []string{
+		" asset /assets/app.css ",
+		"headers_1 link /docs ",
+		"headers_1 link old unpublished",
+		"headers_1 asset /system/a.png ",
+		"headers_1 asset /system/b.png ",
+		" link mailto:a@b.c ",
+		" link https://example.com/x ",
+		" link #top ",
+		" link /moved broken_redirect",
+		" link /gone not_found",
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestAuditA11y(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><body>
+<div data-container-id="headers_1"><h1>Title</h1><img src="/a.png"><img src="/b.png" alt=""><img src="/c.png" aria-hidden="true"></div>
+<div data-container-id="texts_2" style="background-color: #fff"><h3>Skipped</h3><p style="color: #777">Grey</p><p style="color: rgb(200, 200, 200)">Light</p><p style="color: black">Dark</p></div>
+<a href="/x"></a><a href="/y"><img src="/y.png" alt="Y"></a><a href="/z" aria-label="Z"></a><a href="/w"><img src="/w.png" alt="W" aria-hidden="true"></a>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, issue := range auditA11y(doc) {
+		got = append(got, fmt.Sprintf("%s %s %s %s", issue.ContainerDataID, issue.Rule, issue.Severity, issue.Detail))
+	}
+	expect := []string{
+		" html-lang error ",
+		"headers_1 image-alt error /a.png",
+		"texts_2 heading-order warning h1 → h3",
+		"texts_2 color-contrast warning 4.48:1",
+		"texts_2 color-contrast error 1.67:1",
+		" empty-link error /x",
+		" empty-link error /w",
+	}
+	if diff := cmp.Diff(expect, got); diff != "" {
+		t.Error(diff)
+	}
+
+	if got, expect := (&A11yError{Errors: 2}).Error(), fmt.Sprintf(Messages_en_US.A11yPublishBlockedTemplate, 2); got != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+	if got, expect := (&A11yError{Errors: 2, lang: language.SimplifiedChinese}).Error(), fmt.Sprintf(Messages_zh_CN.A11yPublishBlockedTemplate, 2); got != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+
+	img := &html.Node{Type: html.ElementNode, Data: "img", Attr: []html.Attribute{{Key: "alt", Val: strings.Repeat("無", 200)}}}
+	if got := a11ySnippet(img); !utf8.ValidString(got) || utf8.RuneCountInString(got) != a11ySnippetMaxLength+1 {
+		t.Errorf("expected a snippet of %d runes, got %q", a11ySnippetMaxLength, got)
+	}
+}
+
+func TestDeliveryPreviewToken(t *testing.T) {
+	b := &Builder{}
+	if _, err := b.DeliveryPreviewToken(1, "en", time.Minute); err == nil {
+		t.Error("expected an error without secret")
+	}
+	b.DeliveryPreviewSecret([]byte("secret"))
+	token, err := b.DeliveryPreviewToken(1, "en", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.verifyDeliveryPreview(token, 1, "en"); err != nil {
+		t.Error(err)
+	}
+	if err = b.verifyDeliveryPreview(token, 2, "en"); !errors.Is(err, ErrDeliveryPreviewInvalid) {
+		t.Errorf("expected invalid token for another page, got %v", err)
+	}
+	if err = b.verifyDeliveryPreview(token, 1, "zh"); !errors.Is(err, ErrDeliveryPreviewInvalid) {
+		t.Errorf("expected invalid token for another locale, got %v", err)
+	}
+	expired, _ := b.DeliveryPreviewToken(1, "en", -time.Minute)
+	if err = b.verifyDeliveryPreview(expired, 1, "en"); !errors.Is(err, ErrDeliveryPreviewExpired) {
+		t.Errorf("expected expired token, got %v", err)
+	}
+	if err = b.verifyDeliveryPreview("123.abc", 1, "en"); !errors.Is(err, ErrDeliveryPreviewInvalid) {
+		t.Errorf("expected invalid token, got %v", err)
+	}
+
+	for header, expect := range map[string]bool{
+		`"a"`:      true,
+		`W/"a"`:    true,
+		`"b", "a"`: true,
+		`*`:        true,
+		`"b"`:      false,
+		``:         false,
+	} {
+		if got := etagMatch(header, `"a"`); got != expect {
+			t.Errorf("etagMatch(%q) = %v, expected %v", header, got, expect)
+		}
+	}
+}
@@ -0,0 +1,754 @@
+package pagebuilder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	. "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	h "github.com/theplant/htmlgo"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/publish"
+)
+
+const (
+	VariantsDialogEvent       = "page_builder_VariantsDialogEvent"
+	AddVariantEvent           = "page_builder_AddVariantEvent"
+	UpdateVariantWeightsEvent = "page_builder_UpdateVariantWeightsEvent"
+	DeleteVariantEvent        = "page_builder_DeleteVariantEvent"
+	PromoteVariantEvent       = "page_builder_PromoteVariantEvent"
+
+	VariantEventImpression = "impression"
+	VariantEventConversion = "conversion"
+
+	// VariantBeaconPath is the path VariantHandler receives the impressions and conversions of the variants on
+	VariantBeaconPath = "/page-builder/variant-events"
+
+	paramVariant       = "variant"
+	paramVariantID     = "variantID"
+	paramVariantName   = "VariantName"
+	paramVariantWeight = "Weight"
+	paramVariantEvent  = "event"
+	paramVariantPath   = "path"
+
+	variantSwitcherPortal   = "pageBuilderVariantSwitcherPortal"
+	variantVersionSeparator = "#"
+	variantCookiePrefix     = "pb_variant_"
+	variantCookieMaxAge     = 30 * 24 * time.Hour
+	// variantEventCookiePrefix marks the events of a variant already counted for the visitor
+	variantEventCookiePrefix = "pb_variant_event_"
+	defaultVariantWeight     = 50
+	// variantCacheTTL is how long VariantHandler keeps the variants and the rendered pages of a path,
+	// the changes made by other instances are served after it
+	variantCacheTTL  = time.Minute
+	variantCacheSize = 1000
+)
+
+var variantNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9-]{1,32}$`)
+
+// PageVariant is an alternate set of containers of a page version shown to a part of the visitors,
+// the containers of the page itself are the variant without name
+type PageVariant struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	PageModelName string `gorm:"index:idx_page_builder_page_variants_page"`
+	PageID        uint   `gorm:"index:idx_page_builder_page_variants_page"`
+	PageVersion   string `gorm:"index:idx_page_builder_page_variants_page"`
+	LocaleCode    string `gorm:"index:idx_page_builder_page_variants_page"`
+	Name          string
+	// Weight is the share of the traffic of the variant relative to the others
+	Weight      int
+	Impressions int64
+	Conversions int64
+}
+
+func (*PageVariant) TableName() string {
+	return "page_builder_page_variants"
+}
+
+func (v *PageVariant) ConversionRate() float64 {
+	if v.Impressions == 0 {
+		return 0
+	}
+	return float64(v.Conversions) / float64(v.Impressions)
+}
+
+// variantPageVersion is the page version the containers of the variant are stored with
+func variantPageVersion(pageVersion, variant string) string {
+	if variant == "" {
+		return pageVersion
+	}
+	return pageVersion + variantVersionSeparator + variant
+}
+
+// pickVariant picks a variant by the weights, roll returns a number in [0, n)
+func pickVariant(variants []*PageVariant, roll func(n int) int) *PageVariant {
+	var total int
+	for _, v := range variants {
+		total += max(v.Weight, 0)
+	}
+	if total == 0 {
+		return variants[0]
+	}
+	n := roll(total)
+	for _, v := range variants {
+		if v.Weight <= 0 {
+			continue
+		}
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+	return variants[len(variants)-1]
+}
+
+func variantTrafficShare(variants []*PageVariant, v *PageVariant) float64 {
+	var total int
+	for _, o := range variants {
+		total += max(o.Weight, 0)
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(max(v.Weight, 0)) / float64(total)
+}
+
+func (b *ModelBuilder) pageVariants(db *gorm.DB, pageID int, pageVersion, locale string) (r []*PageVariant, err error) {
+	err = db.Where("page_model_name = ? AND page_id = ? AND page_version = ? AND locale_code = ?", b.name, pageID, pageVersion, locale).
+		Order("name ASC").
+		Find(&r).Error
+	return
+}
+
+// variantPage is the page opened in the editor without the variant
+func (b *ModelBuilder) variantPage(ctx *web.EventContext) (pageID int, pageVersion string, locale string) {
+	return b.primaryColumnValuesBySlug(ctx.Param(presets.ParamID))
+}
+
+func (b *ModelBuilder) variantContainers(db *gorm.DB, pageID int, pageVersion, locale string) (cons []*Container, err error) {
+	err = db.Find(&cons, "page_id = ? AND page_version = ? AND locale_code = ? and page_model_name = ? ", pageID, pageVersion, locale, b.name).Error
+	return
+}
+
+// deleteVariant deletes the containers of the variant with their models and its editing history
+func (b *ModelBuilder) deleteVariant(tx *gorm.DB, v *PageVariant) (err error) {
+	var (
+		version = variantPageVersion(v.PageVersion, v.Name)
+		cons    []*Container
+	)
+	if cons, err = b.variantContainers(tx, int(v.PageID), version, v.LocaleCode); err != nil {
+		return
+	}
+	if err = b.deleteContainers(tx, cons); err != nil {
+		return
+	}
+	if err = tx.Where("page_model_name = ? AND page_id = ? AND page_version = ? AND locale_code = ?", b.name, v.PageID, version, v.LocaleCode).
+		Delete(&EditorOperation{}).Error; err != nil {
+		return
+	}
+	return tx.Delete(&PageVariant{}, v.ID).Error
+}
+
+// promoteVariant saves obj as a new draft version with the containers of the winner, the version of the test
+// is not changed and keeps serving the variants until the draft is published
+func (b *ModelBuilder) promoteVariant(tx *gorm.DB, obj interface{}, winner *PageVariant) (err error) {
+	return b.newDraftVersion(tx, obj, variantPageVersion(winner.PageVersion, winner.Name))
+}
+
+func (b *ModelBuilder) variantSwitchScript(ctx *web.EventContext, variant interface{}) string {
+	return web.Plaid().EventFunc(ReloadRenderPageOrTemplateBodyEvent).
+		BeforeScript(web.Plaid().PushState(true).MergeQuery(true).Query(paramVariant, variant).RunPushState()).
+		Query(paramVariant, variant).
+		Query(paramIsUpdate, false).
+		ThenScript(web.Plaid().EventFunc(ShowSortedContainerDrawerEvent).MergeQuery(true).
+			Query(paramVariant, variant).
+			Query(paramStatus, ctx.Param(paramStatus)).Go()).
+		Go()
+}
+
+// variantSwitcher selects the variant edited in the editor
+func (b *ModelBuilder) variantSwitcher(ctx *web.EventContext) h.HTMLComponent {
+	var (
+		msgr                        = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		pageID, pageVersion, locale = b.variantPage(ctx)
+		dialog                      = web.Plaid().EventFunc(VariantsDialogEvent).Query(paramStatus, ctx.Param(paramStatus)).Go()
+		items                       []map[string]string
+	)
+	variants, err := b.pageVariants(b.db, pageID, pageVersion, locale)
+	if err != nil {
+		return nil
+	}
+	for _, v := range variants {
+		items = append(items, map[string]string{"title": variantTitle(msgr, v), "value": v.Name})
+	}
+	return h.Div(
+		VBtn("").Icon("mdi-ab-testing").Variant(VariantText).Size(SizeSmall).
+			Attr("title", msgr.Variants).Attr("@click", dialog),
+		h.If(len(variants) > 0,
+			web.Scope(
+				VSelect().Items(items).
+					Density(DensityCompact).
+					Variant(VariantOutlined).
+					HideDetails(true).
+					Attr("v-model", "variantLocals.active").
+					Attr("@update:model-value", b.variantSwitchScript(ctx, web.Var("variantLocals.active"))).
+					Attr("style", "min-width:140px"),
+			).VSlot("{ locals : variantLocals }").Init(fmt.Sprintf(`{active: %q}`, ctx.Param(paramVariant))),
+		),
+	).Class("d-inline-flex align-center")
+}
+
+func variantTitle(msgr *Messages, v *PageVariant) string {
+	if v.Name == "" {
+		return msgr.OriginalVariant
+	}
+	return v.Name
+}
+
+// variantsDialog shows the variants of the page with their results
+func (b *ModelBuilder) variantsDialog(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		msgr                        = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		pMsgr                       = presets.MustGetMessages(ctx.R)
+		pageID, pageVersion, locale = b.variantPage(ctx)
+		isDraft                     = ctx.Param(paramStatus) == "" || ctx.Param(paramStatus) == publish.StatusDraft
+		variants                    []*PageVariant
+		rows                        []h.HTMLComponent
+	)
+	if variants, err = b.pageVariants(b.db, pageID, pageVersion, locale); err != nil {
+		return
+	}
+	for _, v := range variants {
+		rows = append(rows, h.Tr(
+			h.Td(h.Text(variantTitle(msgr, v))),
+			h.Td(
+				VTextField().Type("number").Attr(web.VField(fmt.Sprintf("%s.%d", paramVariantWeight, v.ID), v.Weight)...).
+					Density(DensityCompact).Variant(FieldVariantUnderlined).HideDetails(true).Attr("min", 0).Width(80),
+			),
+			h.Td(h.Text(fmt.Sprintf("%.0f%%", variantTrafficShare(variants, v)*100))),
+			h.Td(h.Text(fmt.Sprint(v.Impressions))),
+			h.Td(h.Text(fmt.Sprint(v.Conversions))),
+			h.Td(h.Text(fmt.Sprintf("%.2f%%", v.ConversionRate()*100))),
+			h.Td(
+				VBtn("").Icon("mdi-pencil").Variant(VariantText).Size(SizeSmall).Attr("title", msgr.EditVariant).
+					Attr("@click", "locals.variantsDialog = false;"+b.variantSwitchScript(ctx, v.Name)),
+				VBtn("").Icon("mdi-trophy-outline").Variant(VariantText).Size(SizeSmall).Attr("title", msgr.PromoteVariant).
+					Attr("@click", fmt.Sprintf("locals.promoteID = %d; locals.promoteDialog = true", v.ID)),
+				h.If(v.Name != "" && isDraft,
+					VBtn("").Icon("mdi-delete").Variant(VariantText).Size(SizeSmall).Attr("title", pMsgr.Delete).
+						Attr("@click", web.Plaid().EventFunc(DeleteVariantEvent).
+							Query(paramVariantID, v.ID).
+							Query(paramStatus, ctx.Param(paramStatus)).
+							Go()),
+				),
+			).Class("text-no-wrap"),
+		))
+	}
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+		Name: dialogPortalName,
+		Body: web.Scope(
+			VDialog(
+				VCard(
+					VCardTitle(h.Text(msgr.Variants)),
+					VCardText(
+						h.Div(h.Text(msgr.VariantsHint)).Class("text-caption mb-2"),
+						h.If(len(variants) > 0,
+							VTable(
+								h.Thead(h.Tr(
+									h.Th(msgr.VariantName),
+									h.Th(msgr.VariantWeight),
+									h.Th(msgr.VariantTraffic),
+									h.Th(msgr.VariantImpressions),
+									h.Th(msgr.VariantConversions),
+									h.Th(msgr.VariantConversionRate),
+									h.Th(""),
+								)),
+								h.Tbody(rows...),
+							).Density(DensityCompact),
+						),
+						h.If(isDraft,
+							h.Div(
+								VTextField().Attr(web.VField(paramVariantName, "")...).
+									Label(msgr.NewVariantName).
+									Density(DensityCompact).Variant(FieldVariantUnderlined).HideDetails(true),
+								VBtn(msgr.AddVariant).Variant(VariantTonal).Color(ColorPrimary).Class("ml-2").
+									Attr("@click", web.Plaid().EventFunc(AddVariantEvent).Query(paramStatus, ctx.Param(paramStatus)).Go()),
+							).Class("d-flex align-center mt-4"),
+						),
+					),
+					VCardActions(
+						VSpacer(),
+						VBtn(pMsgr.Cancel).
+							Variant(VariantFlat).
+							Class("ml-2").
+							On("click", "locals.variantsDialog = false"),
+						h.If(len(variants) > 0,
+							VBtn(msgr.SaveVariantWeights).
+								Color(ColorPrimary).
+								Variant(VariantFlat).
+								Theme(ThemeDark).
+								Attr("@click", web.Plaid().EventFunc(UpdateVariantWeightsEvent).Query(paramStatus, ctx.Param(paramStatus)).Go()),
+						),
+					),
+				),
+			).MaxWidth("760px").
+				Attr("v-model", "locals.variantsDialog"),
+			vx.VXDialog(h.Text(msgr.PromoteVariantConfirm)).
+				Title(msgr.PromoteVariant).
+				CancelText(pMsgr.Cancel).
+				OkText(msgr.PromoteVariant).
+				Attr("@click:ok", web.Plaid().
+					EventFunc(PromoteVariantEvent).
+					Query(paramVariantID, web.Var("locals.promoteID")).
+					Query(paramStatus, ctx.Param(paramStatus)).
+					ThenScript("locals.promoteDialog = false; locals.variantsDialog = false").
+					Go()).
+				Attr("v-model", "locals.promoteDialog"),
+		).Init("{variantsDialog:true, promoteDialog:false, promoteID:0}").VSlot("{locals}"),
+	})
+	return
+}
+
+// refreshVariants reopens the dialog and updates the switcher after a change of the variants
+func (b *ModelBuilder) refreshVariants(ctx *web.EventContext, r *web.EventResponse) (err error) {
+	var dialog web.EventResponse
+	if dialog, err = b.variantsDialog(ctx); err != nil {
+		return
+	}
+	r.UpdatePortals = append(r.UpdatePortals, dialog.UpdatePortals...)
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+		Name: variantSwitcherPortal,
+		Body: b.variantSwitcher(ctx),
+	})
+	return
+}
+
+func (b *ModelBuilder) addVariant(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		msgr                        = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		pageID, pageVersion, locale = b.variantPage(ctx)
+		name                        = strings.TrimSpace(ctx.R.FormValue(paramVariantName))
+	)
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), ColorError)
+			err = nil
+		}
+	}()
+	if err = b.mb.Info().Verifier().Do(presets.PermUpdate).WithReq(ctx.R).IsAllowed(); err != nil {
+		return
+	}
+	if !variantNameRegexp.MatchString(name) {
+		presets.ShowMessage(&r, msgr.InvalidVariantName, ColorWarning)
+		return
+	}
+	if err = b.db.Transaction(func(tx *gorm.DB) (dbErr error) {
+		var variants []*PageVariant
+		if variants, dbErr = b.pageVariants(tx, pageID, pageVersion, locale); dbErr != nil {
+			return
+		}
+		if slices.ContainsFunc(variants, func(v *PageVariant) bool { return v.Name == name }) {
+			return errors.New(msgr.VariantNameExists)
+		}
+		newVariant := func(name string) *PageVariant {
+			return &PageVariant{
+				PageModelName: b.name,
+				PageID:        uint(pageID),
+				PageVersion:   pageVersion,
+				LocaleCode:    locale,
+				Name:          name,
+				Weight:        defaultVariantWeight,
+			}
+		}
+		if len(variants) == 0 {
+			if dbErr = tx.Create(newVariant("")).Error; dbErr != nil {
+				return
+			}
+		}
+		if dbErr = tx.Create(newVariant(name)).Error; dbErr != nil {
+			return
+		}
+		return b.copyContainersToAnotherPage(tx, pageID, pageVersion, locale, pageID, variantPageVersion(pageVersion, name), locale, b.name, b.name)
+	}); err != nil {
+		return
+	}
+	b.variants.invalidate()
+	err = b.refreshVariants(ctx, &r)
+	return
+}
+
+func (b *ModelBuilder) updateVariantWeights(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		msgr                        = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		pMsgr                       = presets.MustGetMessages(ctx.R)
+		pageID, pageVersion, locale = b.variantPage(ctx)
+		variants                    []*PageVariant
+	)
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), ColorError)
+			err = nil
+		}
+	}()
+	if err = b.mb.Info().Verifier().Do(presets.PermUpdate).WithReq(ctx.R).IsAllowed(); err != nil {
+		return
+	}
+	if variants, err = b.pageVariants(b.db, pageID, pageVersion, locale); err != nil {
+		return
+	}
+	for _, v := range variants {
+		weight, parseErr := strconv.Atoi(ctx.R.FormValue(fmt.Sprintf("%s.%d", paramVariantWeight, v.ID)))
+		if parseErr != nil || weight < 0 {
+			presets.ShowMessage(&r, msgr.InvalidVariantWeight, ColorWarning)
+			return
+		}
+		v.Weight = weight
+	}
+	if err = b.db.Transaction(func(tx *gorm.DB) (dbErr error) {
+		for _, v := range variants {
+			if dbErr = tx.Model(&PageVariant{}).Where("id = ?", v.ID).Update("weight", v.Weight).Error; dbErr != nil {
+				return
+			}
+		}
+		return
+	}); err != nil {
+		return
+	}
+	b.variants.invalidate()
+	if err = b.refreshVariants(ctx, &r); err != nil {
+		return
+	}
+	presets.ShowMessage(&r, pMsgr.SuccessfullyUpdated, "")
+	return
+}
+
+func (b *ModelBuilder) deleteVariantEvent(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		pageID, pageVersion, locale = b.variantPage(ctx)
+		variants                    []*PageVariant
+		current                     = ctx.Param(paramVariant)
+	)
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), ColorError)
+			err = nil
+		}
+	}()
+	if err = b.mb.Info().Verifier().Do(presets.PermUpdate).WithReq(ctx.R).IsAllowed(); err != nil {
+		return
+	}
+	if variants, err = b.pageVariants(b.db, pageID, pageVersion, locale); err != nil {
+		return
+	}
+	i := slices.IndexFunc(variants, func(v *PageVariant) bool { return fmt.Sprint(v.ID) == ctx.R.FormValue(paramVariantID) })
+	if i < 0 || variants[i].Name == "" {
+		return
+	}
+	deleted := variants[i]
+	if err = b.db.Transaction(func(tx *gorm.DB) (dbErr error) {
+		if dbErr = b.deleteVariant(tx, deleted); dbErr != nil {
+			return
+		}
+		// the test is over without other variants than the original
+		if len(variants) == 2 {
+			dbErr = tx.Delete(&PageVariant{}, variants[0].ID).Error
+		}
+		return
+	}); err != nil {
+		return
+	}
+	b.variants.invalidate()
+	if current == deleted.Name {
+		ctx.R.Form.Set(paramVariant, "")
+		web.AppendRunScripts(&r, b.variantSwitchScript(ctx, ""))
+	}
+	err = b.refreshVariants(ctx, &r)
+	return
+}
+
+// promoteVariantEvent creates a new draft from the winner and opens it, the draft is published like the others.
+// It is allowed on the online pages, where the variants are served, as no version is changed
+func (b *ModelBuilder) promoteVariantEvent(ctx *web.EventContext) (r web.EventResponse, err error) {
+	var (
+		msgr                        = i18n.MustGetModuleMessages(ctx.R, I18nPageBuilderKey, Messages_en_US).(*Messages)
+		pageID, pageVersion, locale = b.variantPage(ctx)
+		variants                    []*PageVariant
+		obj                         interface{}
+	)
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), ColorError)
+			err = nil
+		}
+	}()
+	if obj, err = b.pageBuilderModel(ctx); err != nil {
+		return
+	}
+	if publish.DeniedDo(b.mb.Info().Verifier(), obj, ctx.R, presets.PermUpdate, publish.PermDuplicate) {
+		err = perm.PermissionDenied
+		return
+	}
+	if variants, err = b.pageVariants(b.db, pageID, pageVersion, locale); err != nil {
+		return
+	}
+	i := slices.IndexFunc(variants, func(v *PageVariant) bool { return fmt.Sprint(v.ID) == ctx.R.FormValue(paramVariantID) })
+	if i < 0 {
+		return
+	}
+	if err = b.db.Transaction(func(tx *gorm.DB) error {
+		return b.promoteVariant(tx, obj, variants[i])
+	}); err != nil {
+		return
+	}
+	web.AppendRunScripts(&r, web.Plaid().URL(b.editorURLWithSlug(obj.(presets.SlugEncoder).PrimarySlug())).PushState(true).Go())
+	presets.ShowMessage(&r, msgr.VariantPromoted, "")
+	return
+}
+
+// variantPage is an online page served on a path with its variants, the variants are empty for the pages without a test
+type variantPage struct {
+	slug     string
+	variants []*PageVariant
+	loadedAt time.Time
+
+	mu     sync.Mutex
+	bodies map[string]string
+}
+
+// variant is the variant of the page with id
+func (page *variantPage) variant(id string) *PageVariant {
+	if i := slices.IndexFunc(page.variants, func(v *PageVariant) bool { return fmt.Sprint(v.ID) == id }); i >= 0 {
+		return page.variants[i]
+	}
+	return nil
+}
+
+type variantCache struct {
+	mu    sync.Mutex
+	pages map[string]*variantPage
+}
+
+func (c *variantCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages = nil
+}
+
+func (c *variantCache) get(p string) *variantPage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if page := c.pages[p]; page != nil && time.Since(page.loadedAt) < variantCacheTTL {
+		return page
+	}
+	return nil
+}
+
+func (c *variantCache) put(p string, page *variantPage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pages) >= variantCacheSize {
+		for k, v := range c.pages {
+			if time.Since(v.loadedAt) >= variantCacheTTL {
+				delete(c.pages, k)
+			}
+		}
+	}
+	if c.pages == nil || len(c.pages) >= variantCacheSize {
+		c.pages = map[string]*variantPage{}
+	}
+	c.pages[p] = page
+}
+
+// body renders the variant once for the lifetime of the page in the cache, the failed renderings are
+// neither cached nor served
+func (page *variantPage) body(b *ModelBuilder, variant string) (body string, ok bool) {
+	page.mu.Lock()
+	defer page.mu.Unlock()
+	if body, ok = page.bodies[variant]; ok {
+		return
+	}
+	body, status := b.renderPreview(page.slug, variant)
+	if status != http.StatusOK {
+		return "", false
+	}
+	page.bodies[variant] = body
+	return body, true
+}
+
+func variantCookieName(variants []*PageVariant) string {
+	return fmt.Sprintf("%s%d", variantCookiePrefix, variants[0].ID)
+}
+
+// onlineVariants are the online page served on the path and its variants
+func (b *ModelBuilder) onlineVariants(ctx context.Context, p string) (obj interface{}, variants []*PageVariant, err error) {
+	obj = b.mb.NewModel()
+	if _, ok := obj.(publish.StatusInterface); !ok {
+		return
+	}
+	result := b.db.WithContext(ctx).Where("online_url = ? AND status = ?", onlineURL(p), publish.StatusOnline).Limit(1).Find(obj)
+	if err = result.Error; err != nil || result.RowsAffected == 0 {
+		return
+	}
+	pageID, pageVersion, locale := b.primaryColumnValuesBySlug(obj.(presets.SlugEncoder).PrimarySlug())
+	variants, err = b.pageVariants(b.db.WithContext(ctx), pageID, pageVersion, locale)
+	return
+}
+
+func (b *ModelBuilder) cachedVariantPage(ctx context.Context, p string) (page *variantPage, err error) {
+	if page = b.variants.get(p); page != nil {
+		return
+	}
+	obj, variants, err := b.onlineVariants(ctx, p)
+	if err != nil {
+		return
+	}
+	page = &variantPage{variants: variants, loadedAt: time.Now(), bodies: map[string]string{}}
+	if len(variants) > 0 {
+		page.slug = obj.(presets.SlugEncoder).PrimarySlug()
+	}
+	b.variants.put(p, page)
+	return
+}
+
+// VariantHandler serves the online pages with variants, a visitor is assigned to a variant by the weights
+// and kept on it by a cookie. The pages send an impression when shown and a conversion when
+// window.pbVariantConversion() is called to VariantBeaconPath, the other requests are served by next.
+func (b *ModelBuilder) VariantHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == VariantBeaconPath {
+			b.serveVariantBeacon(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		page, err := b.cachedVariantPage(r.Context(), r.URL.Path)
+		if err != nil || len(page.variants) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		variants := page.variants
+		var v *PageVariant
+		cookieName := variantCookieName(variants)
+		if c, cookieErr := r.Cookie(cookieName); cookieErr == nil {
+			v = page.variant(c.Value)
+		}
+		if v == nil {
+			v = pickVariant(variants, rand.IntN)
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookieName,
+				Value:    fmt.Sprint(v.ID),
+				Path:     "/",
+				MaxAge:   int(variantCookieMaxAge.Seconds()),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		body, ok := page.body(b, v.Name)
+		if !ok {
+			// the online page is served when the variant fails to render
+			next.ServeHTTP(w, r)
+			return
+		}
+		script := variantBeaconScript(v)
+		if i := strings.LastIndex(body, "</body>"); i >= 0 {
+			body = body[:i] + script + body[i:]
+		} else {
+			body += script
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "private, no-cache")
+		_, _ = io.WriteString(w, body)
+	})
+}
+
+// serveVariantBeacon counts an event of a variant once for each visitor, the variant must be one of the test
+// of the page served on the path of the beacon
+func (b *ModelBuilder) serveVariantBeacon(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var (
+		event  = r.FormValue(paramVariantEvent)
+		column string
+	)
+	switch event {
+	case VariantEventImpression:
+		column = "impressions"
+	case VariantEventConversion:
+		column = "conversions"
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseUint(r.FormValue(paramVariantID), 10, 64)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	cookieName := fmt.Sprintf("%s%d_%s", variantEventCookiePrefix, id, event)
+	if _, cookieErr := r.Cookie(cookieName); cookieErr == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	page, err := b.cachedVariantPage(r.Context(), r.FormValue(paramVariantPath))
+	if err != nil {
+		log.Printf("error: %s\n", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if page.variant(fmt.Sprint(id)) == nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err = b.db.WithContext(r.Context()).Model(&PageVariant{}).Where("id = ?", id).
+		UpdateColumn(column, gorm.Expr(column+" + 1")).Error; err != nil {
+		log.Printf("error: %s\n", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    "1",
+		Path:     "/",
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func variantBeaconScript(v *PageVariant) string {
+	u := VariantBeaconPath + "?" + url.Values{paramVariantID: {fmt.Sprint(v.ID)}}.Encode()
+	return fmt.Sprintf(`<script>(function(){
+var u = %q + "&%s=" + encodeURIComponent(window.location.pathname);
+function send(e) {
+	var s = u + "&%s=" + e;
+	if (navigator.sendBeacon) { navigator.sendBeacon(s) } else { fetch(s, {method: "POST", keepalive: true}) }
+}
+send(%q);
+window.pbVariantConversion = function() { send(%q) };
+})()</script>`, u, paramVariantPath, paramVariantEvent, VariantEventImpression, VariantEventConversion)
+}
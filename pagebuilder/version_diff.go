@@ -0,0 +1,183 @@
+package pagebuilder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/activity"
+	"github.com/qor5/admin/v3/l10n"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/publish"
+	"github.com/qor5/admin/v3/utils"
+)
+
+// containerDiffKey identifies a container across versions, copying a version creates new container rows,
+// so containers are matched by model name and their occurrence among containers of the same model
+type containerDiffKey struct {
+	modelName  string
+	occurrence int
+}
+
+func (k containerDiffKey) String() string {
+	return fmt.Sprintf("Containers.%s[%d]", k.modelName, k.occurrence)
+}
+
+func (b *ModelBuilder) wrapVersionDiff(in publish.VersionDiffFunc) publish.VersionDiffFunc {
+	return func(ctx context.Context, old, new any) (diffs []activity.Diff, err error) {
+		if diffs, err = in(ctx, old, new); err != nil {
+			return
+		}
+		if utils.GetObjectName(new) != b.name {
+			return
+		}
+		containerDiffs, err := b.diffContainers(b.db, old, new)
+		if err != nil {
+			return nil, err
+		}
+		return append(diffs, containerDiffs...), nil
+	}
+}
+
+func (b *ModelBuilder) versionContainers(db *gorm.DB, obj any) (cons []*Container, err error) {
+	cs := primaryColumnValuesBySlug(obj.(presets.SlugEncoder).PrimarySlug())
+	builders := b.getContainerBuilders()
+	err = db.Order("display_order ASC").Find(&cons, "page_id = ? AND page_version = ? AND locale_code = ? and page_model_name = ? ",
+		cs["id"], cs[publish.SlugVersion], cs[l10n.SlugLocaleCode], b.name).Error
+	if err != nil {
+		return
+	}
+	return slices.DeleteFunc(cons, func(c *Container) bool {
+		return !slices.ContainsFunc(builders, func(builder *ContainerBuilder) bool {
+			return c.ModelName == builder.name
+		})
+	}), nil
+}
+
+func containerDiffKeys(cons []*Container) []containerDiffKey {
+	occurrences := map[string]int{}
+	keys := make([]containerDiffKey, len(cons))
+	for i, c := range cons {
+		keys[i] = containerDiffKey{modelName: c.ModelName, occurrence: occurrences[c.ModelName]}
+		occurrences[c.ModelName]++
+	}
+	return keys
+}
+
+// diffContainers reports added, removed and reordered containers and the field changes of the kept ones
+func (b *ModelBuilder) diffContainers(db *gorm.DB, old, new any) (diffs []activity.Diff, err error) {
+	oldCons, err := b.versionContainers(db, old)
+	if err != nil {
+		return
+	}
+	newCons, err := b.versionContainers(db, new)
+	if err != nil {
+		return
+	}
+	oldKeys := containerDiffKeys(oldCons)
+	newKeys := containerDiffKeys(newCons)
+
+	// positions among the containers that exist in both versions, so an insertion alone is not a reorder
+	var oldKept, newKept []containerDiffKey
+	for _, k := range oldKeys {
+		if slices.Contains(newKeys, k) {
+			oldKept = append(oldKept, k)
+		}
+	}
+	for _, k := range newKeys {
+		if slices.Contains(oldKeys, k) {
+			newKept = append(newKept, k)
+		}
+	}
+
+	for i, k := range oldKeys {
+		if !slices.Contains(newKeys, k) {
+			diffs = append(diffs, activity.Diff{Field: k.String(), Old: containerLabel(oldCons[i])})
+		}
+	}
+	for i, k := range newKeys {
+		c := newCons[i]
+		j := slices.Index(oldKeys, k)
+		if j < 0 {
+			diffs = append(diffs, activity.Diff{Field: k.String(), New: containerLabel(c)})
+			continue
+		}
+		oc := oldCons[j]
+		if oldPos, newPos := slices.Index(oldKept, k), slices.Index(newKept, k); oldPos != newPos {
+			diffs = append(diffs, activity.Diff{Field: k.String() + ".Position", Old: strconv.Itoa(oldPos + 1), New: strconv.Itoa(newPos + 1)})
+		}
+		if oc.DisplayName != c.DisplayName {
+			diffs = append(diffs, activity.Diff{Field: k.String() + ".DisplayName", Old: oc.DisplayName, New: c.DisplayName})
+		}
+		if oc.Hidden != c.Hidden {
+			diffs = append(diffs, activity.Diff{Field: k.String() + ".Hidden", Old: strconv.FormatBool(oc.Hidden), New: strconv.FormatBool(c.Hidden)})
+		}
+		if oc.ModelID == c.ModelID {
+			// shared containers point to the same record
+			continue
+		}
+		var modelDiffs []activity.Diff
+		if modelDiffs, err = b.diffContainerModels(db, k, oc, c); err != nil {
+			return
+		}
+		diffs = append(diffs, modelDiffs...)
+	}
+	return
+}
+
+// diffContainerModels reports the field changes of the models of a kept container,
+// a container whose model row is missing in a version is reported as added or removed
+func (b *ModelBuilder) diffContainerModels(db *gorm.DB, k containerDiffKey, old, new *Container) ([]activity.Diff, error) {
+	cb := b.builder.ContainerByName(new.ModelName)
+	oldModel, err := containerModel(db, cb, old.ModelID)
+	if err != nil {
+		return nil, err
+	}
+	newModel, err := containerModel(db, cb, new.ModelID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case oldModel == nil && newModel == nil:
+		return nil, nil
+	case oldModel == nil:
+		return []activity.Diff{{Field: k.String(), New: containerLabel(new)}}, nil
+	case newModel == nil:
+		return []activity.Diff{{Field: k.String(), Old: containerLabel(old)}}, nil
+	}
+	var amb *activity.ModelBuilder
+	if b.builder.ab != nil {
+		amb, _ = b.builder.ab.GetModelBuilder(cb.mb)
+	}
+	diffs, err := activity.NewDiffBuilder(amb).Diff(oldModel, newModel)
+	if err != nil {
+		return nil, err
+	}
+	for i := range diffs {
+		diffs[i].Field = k.String() + "." + diffs[i].Field
+	}
+	return diffs, nil
+}
+
+// containerModel is the model of a container, or nil when its row is missing
+func containerModel(db *gorm.DB, cb *ContainerBuilder, modelID uint) (any, error) {
+	obj := cb.NewModel()
+	if err := db.First(obj, "id = ?", modelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+func containerLabel(c *Container) string {
+	if c.DisplayName != "" {
+		return c.DisplayName
+	}
+	return c.ModelName
+}
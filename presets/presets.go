@@ -897,12 +897,11 @@ func (b *Builder) defaultLayout(in web.PageFunc, cfg *LayoutConfig) (out web.Pag
 			).Location("bottom").Class("border-t-sm border-b-0").Elevation(0)
 		}
 
-		// showNotificationCenter := cfg == nil || !cfg.NotificationCenterInvisible
-		// var notifier h.HTMLComponent
-		// if b.notificationCountFunc != nil && b.notificationContentFunc != nil {
-		//	notifier = web.Portal().Name(NotificationCenterPortalName).Loader(web.GET().EventFunc(actions.NotificationCenter))
-		// }
-		// ctx.R = ctx.R.WithContext(context.WithValue(ctx.R.Context(), ctxNotifyCenter, notifier))
+		showNotificationCenter := cfg == nil || !cfg.NotificationCenterInvisible
+		var notifier h.HTMLComponent
+		if showNotificationCenter && b.notificationCountFunc != nil && b.notificationContentFunc != nil {
+			notifier = web.Portal().Name(NotificationCenterPortalName).Loader(web.GET().EventFunc(actions.NotificationCenter))
+		}
 
 		// showSearchBox := cfg == nil || !cfg.SearchBoxInvisible
 
@@ -932,6 +931,7 @@ func (b *Builder) defaultLayout(in web.PageFunc, cfg *LayoutConfig) (out web.Pag
 					h.Div().Id(actionsComponentTeleportToID),
 				)
 			}),
+			h.If(notifier != nil, h.Div(notifier).Class("ml-auto")),
 		).Class("d-flex align-center mx-6 border-b w-100").Style("padding-bottom:24px")
 		pr.Body = VCard(
 			VProgressLinear().
@@ -8,13 +8,16 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/iancoleman/strcase"
 	"github.com/qor5/admin/v3/activity"
+	"github.com/qor5/admin/v3/notification"
 	"github.com/qor5/admin/v3/presets"
 	"github.com/qor5/admin/v3/utils"
+	"github.com/qor5/admin/v3/worker"
 	"github.com/qor5/web/v3"
 	"github.com/qor5/x/v3/i18n"
 	"github.com/qor5/x/v3/oss"
@@ -35,23 +38,105 @@ type Builder struct {
 	db                *gorm.DB
 	storage           oss.StorageInterface
 	ab                *activity.Builder
+	nb                *notification.Builder
+	notifyRoles       []string
+	currentUserIDFunc func(ctx context.Context) (string, error)
+	reviews           map[string]*ReviewBuilder
+	releases          bool
+	releaseModels     map[string]*presets.ModelBuilder
 	ctxValueProviders []ContextValueFunc
+	i18nBuilder       *i18n.Builder
 	afterInstallFuncs []func()
 	autoSchedule      bool
 
-	publish   PublishFunc
-	unpublish UnPublishFunc
+	publish     PublishFunc
+	unpublish   UnPublishFunc
+	versionDiff VersionDiffFunc
+
+	dependencyMode        DependencyMode
+	dependencyMigrateOnce sync.Once
+	dependencyMigrateErr  error
+	dependencyTableMu     sync.Mutex
+	dependencyTable       bool
+	dependencyTableAt     time.Time
+
+	publishRuns           bool
+	publishRunModels      map[string]*presets.ModelBuilder
+	publishRunMigrateOnce sync.Once
+	publishRunMigrateErr  error
+
+	targets           []*Target
+	targetModels      map[string]*presets.ModelBuilder
+	targetMigrateOnce sync.Once
+	targetMigrateErr  error
+
+	shareLinkSecret      []byte
+	shareLinkURL         string
+	shareLinkModels      map[string]*presets.ModelBuilder
+	shareLinkMigrateOnce sync.Once
+	shareLinkMigrateErr  error
+
+	sitemap       *SitemapBuilder
+	sitemapModels map[string]*presets.ModelBuilder
+
+	purger         Purger
+	purgeWorker    *worker.Builder
+	purgeModels    map[string]*presets.ModelBuilder
+	purgeBatchSize int
+	purgeRetries   int
+
+	prune           PruneFunc
+	pruneWorker     *worker.Builder
+	retentionModels map[string]*RetentionBuilder
+
+	consistencyPrefixes    []string
+	consistencyModels      map[string]*presets.ModelBuilder
+	consistencyWorker      *worker.Builder
+	consistencyMigrateOnce sync.Once
+	consistencyMigrateErr  error
+	storagePaths           []StoragePathsFunc
+
+	scheduleTasks map[string]ScheduleTaskFunc
+	publishChecks []PublishCheckFunc
 }
 
 type ContextValueFunc func(ctx context.Context) context.Context
 
+// installedBuilders are the publishers by the names of the models they installed, they find the publisher
+// of a record in the components built without it
+var installedBuilders sync.Map
+
+func builderOf(obj any) *Builder {
+	if b, ok := installedBuilders.Load(utils.GetObjectName(obj)); ok {
+		return b.(*Builder)
+	}
+	return nil
+}
+
 func New(db *gorm.DB, storage oss.StorageInterface) *Builder {
 	b := &Builder{
 		db:      db,
 		storage: storage,
+		reviews: map[string]*ReviewBuilder{},
+
+		releaseModels:     map[string]*presets.ModelBuilder{},
+		publishRunModels:  map[string]*presets.ModelBuilder{},
+		targetModels:      map[string]*presets.ModelBuilder{},
+		shareLinkModels:   map[string]*presets.ModelBuilder{},
+		sitemapModels:     map[string]*presets.ModelBuilder{},
+		purgeModels:       map[string]*presets.ModelBuilder{},
+		retentionModels:   map[string]*RetentionBuilder{},
+		consistencyModels: map[string]*presets.ModelBuilder{},
+
+		purgeBatchSize: defaultPurgeBatchSize,
+		purgeRetries:   defaultPurgeRetries,
+
+		dependencyMode: DependencyModeWarn,
 	}
 	b.publish = b.defaultPublish
 	b.unpublish = b.defaultUnPublish
+	b.versionDiff = b.defaultVersionDiff
+	b.prune = b.defaultPrune
 	return b
 }
 
@@ -60,6 +145,19 @@ func (b *Builder) Activity(v *activity.Builder) (r *Builder) {
 	return b
 }
 
+// Notification sets Notification Builder to notify the given roles when a scheduled publish or unpublish fails
+func (b *Builder) Notification(v *notification.Builder, roles ...string) (r *Builder) {
+	b.nb = v
+	b.notifyRoles = roles
+	return b
+}
+
+// CurrentUserIDFunc is used to record submitters and reviewers of review requests
+func (b *Builder) CurrentUserIDFunc(v func(ctx context.Context) (string, error)) (r *Builder) {
+	b.currentUserIDFunc = v
+	return b
+}
+
 func (b *Builder) AutoSchedule(v bool) (r *Builder) {
 	b.autoSchedule = v
 	return b
@@ -76,6 +174,7 @@ func (b *Builder) ModelInstall(pb *presets.Builder, m *presets.ModelBuilder) err
 	obj := m.NewModel()
 	_ = obj.(presets.SlugEncoder)
 	_ = obj.(presets.SlugDecoder)
+	installedBuilders.Store(utils.GetObjectName(obj), b)
 
 	if model, ok := obj.(VersionInterface); ok {
 		if schedulePublishModel, ok := model.(ScheduleInterface); ok {
@@ -124,6 +223,40 @@ func (b *Builder) ModelInstall(pb *presets.Builder, m *presets.ModelBuilder) err
 		}
 	}
 
+	if b.publishRuns {
+		b.publishRunModels[utils.GetObjectName(obj)] = m
+	}
+
+	if _, ok := obj.(StatusInterface); ok {
+		b.consistencyModels[utils.GetObjectName(obj)] = m
+	}
+
+	if len(b.targets) > 0 {
+		b.targetModels[utils.GetObjectName(obj)] = m
+	}
+
+	if _, ok := obj.(StatusInterface); ok {
+		b.sitemapModels[utils.GetObjectName(obj)] = m
+	}
+
+	if _, ok := obj.(StatusInterface); ok && b.purger != nil {
+		b.purgeModels[utils.GetObjectName(obj)] = m
+		m.RegisterEventFunc(eventPurgeCDN, purgeCDN(m, b))
+	}
+
+	if len(b.shareLinkSecret) > 0 {
+		b.shareLinkModels[utils.GetObjectName(obj)] = m
+		m.RegisterEventFunc(eventShareLinkDialog, shareLinkDialog(m, b))
+		m.RegisterEventFunc(eventCreateShareLink, createShareLink(m, b))
+		m.RegisterEventFunc(eventRevokeShareLink, revokeShareLink(m, b))
+	}
+
+	if _, ok := obj.(StatusInterface); ok && b.releases {
+		b.releaseModels[utils.GetObjectName(obj)] = m
+		m.RegisterEventFunc(eventAddToReleaseDialog, addToReleaseDialog(m, b))
+		m.RegisterEventFunc(eventAddToRelease, addToRelease(m, b))
+	}
+
 	registerEventFuncsForResource(db, m, b)
 	return nil
 }
@@ -187,15 +320,7 @@ func (b *Builder) configVersionAndPublish(pb *presets.Builder, mb *presets.Model
 
 	slugDecoder := mb.NewModel().(presets.SlugDecoder)
 	uniqueWithoutVersion := func(id string) string {
-		var kvsWithoutVersion []string
-		for k, v := range slugDecoder.PrimaryColumnValuesBySlug(id) {
-			if k == SlugVersion {
-				continue
-			}
-			kvsWithoutVersion = append(kvsWithoutVersion, fmt.Sprintf(`%s:%s`, k, v))
-		}
-		sort.Strings(kvsWithoutVersion)
-		return strings.Join(kvsWithoutVersion, ",")
+		return slugWithoutVersion(slugDecoder, id)
 	}
 	wrapIdCurrentActive := func(in presets.IdCurrentActiveProcessor) presets.IdCurrentActiveProcessor {
 		return func(ctx *web.EventContext, current string) (string, error) {
@@ -227,6 +352,19 @@ func (b *Builder) configVersionAndPublish(pb *presets.Builder, mb *presets.Model
 	configureVersionListDialog(db, b, pb, mb)
 }
 
+// slugWithoutVersion identifies the record of the version slug id, it is the same for all its versions
+func slugWithoutVersion(slugDecoder presets.SlugDecoder, id string) string {
+	var kvsWithoutVersion []string
+	for k, v := range slugDecoder.PrimaryColumnValuesBySlug(id) {
+		if k == SlugVersion {
+			continue
+		}
+		kvsWithoutVersion = append(kvsWithoutVersion, fmt.Sprintf(`%s:%s`, k, v))
+	}
+	sort.Strings(kvsWithoutVersion)
+	return strings.Join(kvsWithoutVersion, ",")
+}
+
 const ListSubqueryConditionQueryPrefix = "__PUBLISH_LIST_SUBQUERY_CONDITION__: "
 
 func makeSearchFunc(mb *presets.ModelBuilder, db *gorm.DB) func(searcher presets.SearchFunc) presets.SearchFunc {
@@ -341,12 +479,33 @@ func (b *Builder) Install(pb *presets.Builder) error {
 		FieldType(Status{}).
 		ComponentFunc(StatusListFunc())
 
-	pb.GetI18n().
+	b.i18nBuilder = pb.GetI18n()
+	b.i18nBuilder.
 		RegisterForModule(language.English, I18nPublishKey, Messages_en_US).
 		RegisterForModule(language.SimplifiedChinese, I18nPublishKey, Messages_zh_CN).
 		RegisterForModule(language.Japanese, I18nPublishKey, Messages_ja_JP)
 
 	utils.Install(pb)
+	if len(b.reviews) > 0 {
+		if err := b.configureReviewQueue(pb); err != nil {
+			return err
+		}
+	}
+	if b.releases {
+		if err := b.configureReleases(pb); err != nil {
+			return err
+		}
+	}
+	if b.consistencyWorker != nil {
+		if err := b.configureConsistency(pb); err != nil {
+			return err
+		}
+	}
+	if b.publishRuns {
+		if err := b.configurePublishRuns(pb); err != nil {
+			return err
+		}
+	}
 	for _, f := range b.afterInstallFuncs {
 		f()
 	}
@@ -380,6 +539,12 @@ func (b *Builder) getPublishContent(ctx context.Context, obj interface{}) (r str
 }
 
 func (b *Builder) getObjectLiveUrl(ctx context.Context, db *gorm.DB, obj interface{}) (url string) {
+	if !isDefaultTarget(ctx) {
+		if ts, err := b.GetTargetStatus(ctx, TargetFromContext(ctx), obj); err == nil && ts != nil && ts.Status == StatusOnline {
+			url = ts.OnlineUrl
+		}
+		return
+	}
 	builder := ctx.Value(utils.GetObjectName(obj))
 	mb, ok := builder.(PreviewBuilderInterface)
 	if !ok {
@@ -410,13 +575,13 @@ func (b *Builder) defaultPublishActions(ctx context.Context, _ *gorm.DB, _ oss.S
 	if !ok {
 		return nil, errors.New("wrong PublishModelInterface")
 	}
-	if publishUrl := p.PublishUrl(b.db, ctx, b.storage); publishUrl != "" {
+	if publishUrl := p.PublishUrl(b.dbFromContext(ctx), ctx, b.storageFor(ctx)); publishUrl != "" {
 		actions = append(actions, &PublishAction{
 			Url:      publishUrl,
 			Content:  content,
 			IsDelete: false,
 		})
-		if liveUrl := b.getObjectLiveUrl(ctx, b.db, obj); liveUrl != "" && liveUrl != publishUrl {
+		if liveUrl := b.getObjectLiveUrl(ctx, b.dbFromContext(ctx), obj); liveUrl != "" && liveUrl != publishUrl {
 			actions = append(actions, &PublishAction{
 				Url:      liveUrl,
 				IsDelete: true,
@@ -434,16 +599,16 @@ func (b *Builder) getPublishActions(ctx context.Context, obj interface{}) (actio
 	)
 	p, ok = obj.(PublishInterface)
 	if ok {
-		return p.GetPublishActions(ctx, b.db, b.storage)
+		return p.GetPublishActions(ctx, b.dbFromContext(ctx), b.storageFor(ctx))
 	}
 	if m, ok = obj.(WrapPublishInterface); ok {
-		return m.WrapPublishActions(b.defaultPublishActions)(ctx, b.db, b.storage, obj)
+		return m.WrapPublishActions(b.defaultPublishActions)(ctx, b.dbFromContext(ctx), b.storageFor(ctx), obj)
 	}
-	return b.defaultPublishActions(ctx, b.db, b.storage, obj)
+	return b.defaultPublishActions(ctx, b.dbFromContext(ctx), b.storageFor(ctx), obj)
 }
 
 func (b *Builder) defaultUnPublishActions(ctx context.Context, _ *gorm.DB, _ oss.StorageInterface, obj interface{}) (actions []*PublishAction, err error) {
-	if liveUrl := b.getObjectLiveUrl(ctx, b.db, obj); liveUrl != "" {
+	if liveUrl := b.getObjectLiveUrl(ctx, b.dbFromContext(ctx), obj); liveUrl != "" {
 		actions = append(actions, &PublishAction{
 			Url:      liveUrl,
 			IsDelete: true,
@@ -461,12 +626,12 @@ func (b *Builder) getUnPublishActions(ctx context.Context, obj interface{}) (act
 
 	p, ok = obj.(UnPublishInterface)
 	if ok {
-		return p.GetUnPublishActions(ctx, b.db, b.storage)
+		return p.GetUnPublishActions(ctx, b.dbFromContext(ctx), b.storageFor(ctx))
 	}
 	if m, ok = obj.(WrapUnPublishInterface); ok {
-		return m.WrapUnPublishActions(b.defaultUnPublishActions)(ctx, b.db, b.storage, obj)
+		return m.WrapUnPublishActions(b.defaultUnPublishActions)(ctx, b.dbFromContext(ctx), b.storageFor(ctx), obj)
 	}
-	return b.defaultUnPublishActions(ctx, b.db, b.storage, obj)
+	return b.defaultUnPublishActions(ctx, b.dbFromContext(ctx), b.storageFor(ctx), obj)
 }
 
 func (b *Builder) WrapPublish(w func(in PublishFunc) PublishFunc) *Builder {
@@ -474,60 +639,99 @@ func (b *Builder) WrapPublish(w func(in PublishFunc) PublishFunc) *Builder {
 	return b
 }
 
+// Publish publishes record to the target of ctx and, depending on the dependency mode, its dependencies not online
+// on that target in one transaction, the records published to the default target should pass CheckPublish
 func (b *Builder) Publish(ctx context.Context, record any) (err error) {
-	return b.publish(ctx, record)
+	if err = b.checkTarget(ctx); err != nil {
+		return
+	}
+	if isDefaultTarget(ctx) {
+		if err = b.CheckPublish(ctx, record); err != nil {
+			return &PublishCheckError{Err: err}
+		}
+	}
+	if _, ok := record.(DependencyInterface); ok {
+		if err = b.migrateDependencies(); err != nil {
+			return
+		}
+	}
+	err = b.track(ctx, PublishRunActionPublish, record, func(ctx context.Context) error {
+		return b.transact(ctx, func(ctx context.Context, tx *gorm.DB) (err error) {
+			if err = b.publishDependencies(ctx, record); err != nil {
+				return
+			}
+			if err = b.publish(ctx, record); err != nil {
+				return
+			}
+			return b.saveDependencies(ctx, record)
+		})
+	})
+	if err == nil && b.sitemap != nil && isDefaultTarget(ctx) {
+		b.sitemap.schedule(record)
+	}
+	return
+}
+
+type (
+	ctxKeyTx          struct{}
+	ctxKeyAfterCommit struct{}
+)
+
+// transact runs f in the transaction carried by ctx, or in a new one, so nested publishing commits or rolls back together,
+// the functions added by AfterCommit run once the new one is committed
+func (b *Builder) transact(ctx context.Context, f func(ctx context.Context, tx *gorm.DB) error) error {
+	if tx, ok := ctx.Value(ctxKeyTx{}).(*gorm.DB); ok {
+		return f(ctx, tx)
+	}
+	var after []func(ctx context.Context)
+	err := utils.Transact(b.db, func(tx *gorm.DB) error {
+		return f(context.WithValue(context.WithValue(ctx, ctxKeyTx{}, tx), ctxKeyAfterCommit{}, &after), tx)
+	})
+	if err != nil {
+		return err
+	}
+	for _, f := range after {
+		f(ctx)
+	}
+	return nil
+}
+
+// AfterCommit runs f once the publishing transaction of ctx is committed, so that the hooks like AfterPublish
+// can start the work that reads the published records. f is dropped when the transaction rolls back
+// and runs right away without a transaction
+func AfterCommit(ctx context.Context, f func(ctx context.Context)) {
+	if after, ok := ctx.Value(ctxKeyAfterCommit{}).(*[]func(ctx context.Context)); ok {
+		*after = append(*after, f)
+		return
+	}
+	// the transactions of the dry runs are always rolled back
+	if _, ok := ctx.Value(ctxKeyTx{}).(*gorm.DB); ok {
+		return
+	}
+	f(ctx)
+}
+
+func (b *Builder) dbFromContext(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(ctxKeyTx{}).(*gorm.DB); ok {
+		return tx
+	}
+	return b.db
 }
 
 // 幂等
 func (b *Builder) defaultPublish(ctx context.Context, record any) (err error) {
-	err = utils.Transact(b.db, func(tx *gorm.DB) (err error) {
+	if !isDefaultTarget(ctx) {
+		return b.publishToTarget(ctx, record)
+	}
+	err = b.transact(ctx, func(ctx context.Context, tx *gorm.DB) (err error) {
 		// publish content
 		var objs []*PublishAction
 		if objs, err = b.getPublishActions(ctx, record); err != nil {
 			return
 		}
 		// update status
-		if r, ok := record.(StatusInterface); ok {
-			now := tx.NowFunc()
-			if version, ok := record.(VersionInterface); ok {
-				var modelSchema *schema.Schema
-				modelSchema, err = schema.Parse(record, &sync.Map{}, tx.NamingStrategy)
-				if err != nil {
-					return
-				}
-				scope := setPrimaryKeysConditionWithoutVersion(tx.Model(reflect.New(modelSchema.ModelType).Interface()), record, modelSchema).Where("version <> ? AND status = ?", version.EmbedVersion().Version, StatusOnline)
-
-				oldVersionUpdateMap := make(map[string]interface{})
-				if _, ok := record.(ScheduleInterface); ok {
-					oldVersionUpdateMap["scheduled_end_at"] = nil
-					oldVersionUpdateMap["actual_end_at"] = &now
-				}
-				if _, ok := record.(ListInterface); ok {
-					oldVersionUpdateMap["list_deleted"] = true
-				}
-				oldVersionUpdateMap["status"] = StatusOffline
-				if err = scope.Updates(oldVersionUpdateMap).Error; err != nil {
-					return
-				}
-			}
-			updateMap := make(map[string]interface{})
-
-			if r, ok := record.(ScheduleInterface); ok {
-				r.EmbedSchedule().ActualStartAt = &now
-				r.EmbedSchedule().ScheduledStartAt = nil
-				updateMap["scheduled_start_at"] = r.EmbedSchedule().ScheduledStartAt
-				updateMap["actual_start_at"] = r.EmbedSchedule().ActualStartAt
-			}
-			if r, ok := record.(ListInterface); ok {
-				r.EmbedList().ListUpdated = true
-				updateMap["list_updated"] = true
-			}
-			r.EmbedStatus().Status = StatusOnline
-			updateMap["status"] = StatusOnline
-			updateMap["online_url"] = r.EmbedStatus().OnlineUrl
-			if err = tx.Model(record).Updates(updateMap).Error; err != nil {
-				return
-			}
+		if err = updatePublishStatus(tx, record); err != nil {
+			return
 		}
 
 		if err = UploadOrDelete(ctx, objs, b.storage); err != nil {
@@ -551,13 +755,34 @@ func (b *Builder) WrapUnPublish(w func(in UnPublishFunc) UnPublishFunc) *Builder
 	return b
 }
 
+// UnPublish refuses to unpublish a record that online records depend on unless the dependency mode is DependencyModeIgnore
 func (b *Builder) UnPublish(ctx context.Context, record any) (err error) {
-	return b.unpublish(ctx, record)
+	if err = b.checkTarget(ctx); err != nil {
+		return
+	}
+	err = b.track(ctx, PublishRunActionUnPublish, record, func(ctx context.Context) error {
+		return b.transact(ctx, func(ctx context.Context, tx *gorm.DB) (err error) {
+			if err = b.checkLiveDependents(ctx, record); err != nil {
+				return
+			}
+			if err = b.unpublish(ctx, record); err != nil {
+				return
+			}
+			return b.deleteDependencies(ctx, record)
+		})
+	})
+	if err == nil && b.sitemap != nil && isDefaultTarget(ctx) {
+		b.sitemap.schedule(record)
+	}
+	return
 }
 
 // 幂等
 func (b *Builder) defaultUnPublish(ctx context.Context, record any) (err error) {
-	err = utils.Transact(b.db, func(tx *gorm.DB) (err error) {
+	if !isDefaultTarget(ctx) {
+		return b.unpublishFromTarget(ctx, record)
+	}
+	err = b.transact(ctx, func(ctx context.Context, tx *gorm.DB) (err error) {
 		// unpublish content
 		var objs []*PublishAction
 		objs, err = b.getUnPublishActions(ctx, record)
@@ -565,24 +790,8 @@ func (b *Builder) defaultUnPublish(ctx context.Context, record any) (err error)
 			return
 		}
 		// update status
-		if r, ok := record.(StatusInterface); ok {
-			updateMap := make(map[string]interface{})
-			if r, ok := record.(ScheduleInterface); ok {
-				now := tx.NowFunc()
-				r.EmbedSchedule().ActualEndAt = &now
-				r.EmbedSchedule().ScheduledEndAt = nil
-				updateMap["scheduled_end_at"] = r.EmbedSchedule().ScheduledEndAt
-				updateMap["actual_end_at"] = r.EmbedSchedule().ActualEndAt
-			}
-			if r, ok := record.(ListInterface); ok {
-				r.EmbedList().ListDeleted = true
-				updateMap["list_deleted"] = true
-			}
-			r.EmbedStatus().Status = StatusOffline
-			updateMap["status"] = StatusOffline
-			if err = tx.Model(record).Updates(updateMap).Error; err != nil {
-				return
-			}
+		if err = updateUnPublishStatus(tx, record); err != nil {
+			return
 		}
 
 		if err = UploadOrDelete(ctx, objs, b.storage); err != nil {
@@ -601,8 +810,90 @@ func (b *Builder) defaultUnPublish(ctx context.Context, record any) (err error)
 	return
 }
 
+// updatePublishStatus marks record online and takes its other online versions offline
+func updatePublishStatus(tx *gorm.DB, record any) (err error) {
+	if r, ok := record.(StatusInterface); ok {
+		now := tx.NowFunc()
+		if version, ok := record.(VersionInterface); ok {
+			var modelSchema *schema.Schema
+			modelSchema, err = schema.Parse(record, &sync.Map{}, tx.NamingStrategy)
+			if err != nil {
+				return
+			}
+			scope := setPrimaryKeysConditionWithoutVersion(tx.Model(reflect.New(modelSchema.ModelType).Interface()), record, modelSchema).Where("version <> ? AND status = ?", version.EmbedVersion().Version, StatusOnline)
+
+			oldVersionUpdateMap := make(map[string]interface{})
+			if _, ok := record.(ScheduleInterface); ok {
+				oldVersionUpdateMap["scheduled_end_at"] = nil
+				oldVersionUpdateMap["actual_end_at"] = &now
+			}
+			if _, ok := record.(ListInterface); ok {
+				oldVersionUpdateMap["list_deleted"] = true
+			}
+			oldVersionUpdateMap["status"] = StatusOffline
+			if err = scope.Updates(oldVersionUpdateMap).Error; err != nil {
+				return
+			}
+		}
+		updateMap := make(map[string]interface{})
+
+		if r, ok := record.(ScheduleInterface); ok {
+			r.EmbedSchedule().ActualStartAt = &now
+			r.EmbedSchedule().ScheduledStartAt = nil
+			updateMap["scheduled_start_at"] = r.EmbedSchedule().ScheduledStartAt
+			updateMap["actual_start_at"] = r.EmbedSchedule().ActualStartAt
+		}
+		if r, ok := record.(ListInterface); ok {
+			r.EmbedList().ListUpdated = true
+			updateMap["list_updated"] = true
+		}
+		r.EmbedStatus().Status = StatusOnline
+		updateMap["status"] = StatusOnline
+		updateMap["online_url"] = r.EmbedStatus().OnlineUrl
+		if err = tx.Model(record).Updates(updateMap).Error; err != nil {
+			return
+		}
+	}
+	return
+}
+
+func updateUnPublishStatus(tx *gorm.DB, record any) (err error) {
+	if r, ok := record.(StatusInterface); ok {
+		updateMap := make(map[string]interface{})
+		if r, ok := record.(ScheduleInterface); ok {
+			now := tx.NowFunc()
+			r.EmbedSchedule().ActualEndAt = &now
+			r.EmbedSchedule().ScheduledEndAt = nil
+			updateMap["scheduled_end_at"] = r.EmbedSchedule().ScheduledEndAt
+			updateMap["actual_end_at"] = r.EmbedSchedule().ActualEndAt
+		}
+		if r, ok := record.(ListInterface); ok {
+			r.EmbedList().ListDeleted = true
+			updateMap["list_deleted"] = true
+		}
+		r.EmbedStatus().Status = StatusOffline
+		updateMap["status"] = StatusOffline
+		if err = tx.Model(record).Updates(updateMap).Error; err != nil {
+			return
+		}
+	}
+	return
+}
+
 func UploadOrDelete(ctx context.Context, objs []*PublishAction, storage oss.StorageInterface) (err error) {
+	journal := storageJournalFromContext(ctx)
+	done := 0
+	if run := publishRunFromContext(ctx); run != nil {
+		defer func() {
+			run.addActions(objs, done, err)
+		}()
+	}
 	for _, obj := range objs {
+		if journal != nil {
+			if err = journal.record(ctx, storage, obj.Url); err != nil {
+				return
+			}
+		}
 		if obj.IsDelete {
 			fmt.Printf("deleting %s \n", obj.Url)
 			err = storage.Delete(ctx, obj.Url)
@@ -613,6 +904,7 @@ func UploadOrDelete(ctx context.Context, objs []*PublishAction, storage oss.Stor
 		if err != nil {
 			return
 		}
+		done++
 	}
 	return nil
 }
@@ -645,10 +937,16 @@ func setPrimaryKeysConditionWithoutFields(db *gorm.DB, record interface{}, s *sc
 	return db.Where(strings.Join(querys, " AND "), args...)
 }
 
+// FullUrl returns the url of uri on the target of ctx
 func (b *Builder) FullUrl(ctx context.Context, uri string) (string, error) {
-	s, err := b.storage.GetURL(ctx, uri)
+	storage := b.storageFor(ctx)
+	s, err := storage.GetURL(ctx, uri)
 	if err != nil {
 		return "", errors.Wrap(err, "get url")
 	}
-	return strings.TrimSuffix(b.storage.GetEndpoint(ctx), "/") + "/" + strings.Trim(s, "/"), nil
+	endpoint := storage.GetEndpoint(ctx)
+	if t, err := b.getTarget(TargetFromContext(ctx)); err == nil && t.BaseURL != "" {
+		endpoint = t.BaseURL
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/" + strings.Trim(s, "/"), nil
 }
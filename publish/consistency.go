@@ -0,0 +1,358 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+	"github.com/qor5/admin/v3/worker"
+)
+
+const (
+	ConsistencyJobName        = "publish-consistency-check"
+	ConsistencyOrphansJobName = "publish-consistency-delete-orphans"
+
+	ConsistencyMissing = "missing"
+	ConsistencyStale   = "stale"
+	ConsistencyOrphan  = "orphan"
+)
+
+// ConsistencyIssue is an object of the storage that does not match the online records,
+// the model is empty for orphans, which no online record writes. The issues of the last check
+// run by the worker are kept for the admin to review
+type ConsistencyIssue struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Kind      string
+	Url       string
+	ModelName string
+	ModelKeys string
+}
+
+func (*ConsistencyIssue) TableName() string {
+	return "publish_consistency_issues"
+}
+
+type ConsistencyReport struct {
+	Records int
+	Issues  []*ConsistencyIssue
+}
+
+type ConsistencyJobArgs struct {
+	// Repair republishes the records of the missing and stale objects, orphans are only reported
+	Repair bool
+}
+
+// ConsistencyOrphansJobArgs are the orphans of a report confirmed to be deleted
+type ConsistencyOrphansJobArgs struct {
+	Urls []string
+}
+
+// StoragePathsFunc returns the paths of the default storage written besides the publish actions of the online records
+type StoragePathsFunc func(ctx context.Context, db *gorm.DB) ([]string, error)
+
+// ConsistencyPrefixes are the directories of the storage searched for orphans, other objects like media may share
+// the storage so no orphans are reported when it is not set
+func (b *Builder) ConsistencyPrefixes(v ...string) (r *Builder) {
+	b.consistencyPrefixes = v
+	return b
+}
+
+// StoragePaths registers a writer of the default storage other than the publishing of the records, like the sitemap
+// or the rules of the redirects, the paths it returns are not orphans
+func (b *Builder) StoragePaths(v StoragePathsFunc) (r *Builder) {
+	b.storagePaths = append(b.storagePaths, v)
+	return b
+}
+
+// ConsistencyWorker adds the jobs checking the storage and deleting the confirmed orphans to w,
+// the issues found are listed in the admin, where the checks, repairs and deletions are started
+func (b *Builder) ConsistencyWorker(w *worker.Builder) (r *Builder) {
+	b.consistencyWorker = w
+	w.NewJob(ConsistencyJobName).
+		Resource(&ConsistencyJobArgs{}).
+		Handler(func(ctx context.Context, job worker.QorJobInterface) error {
+			info, err := job.GetJobInfo()
+			if err != nil {
+				return err
+			}
+			report, err := b.CheckConsistency(ctx)
+			if err != nil {
+				return err
+			}
+			for _, issue := range report.Issues {
+				_ = job.AddLog(issue.String())
+			}
+			_ = job.AddLog(fmt.Sprintf("%d online records checked, %d issues found", report.Records, len(report.Issues)))
+			if err = b.saveConsistencyIssues(ctx, report); err != nil {
+				return err
+			}
+			if !info.Argument.(*ConsistencyJobArgs).Repair || len(report.Issues) == 0 {
+				return nil
+			}
+			if err = b.RepairConsistency(ctx, report.Issues); err != nil {
+				return err
+			}
+			_ = job.AddLog("missing and stale objects republished")
+			return nil
+		})
+	w.NewJob(ConsistencyOrphansJobName).
+		Resource(&ConsistencyOrphansJobArgs{}).
+		Handler(func(ctx context.Context, job worker.QorJobInterface) error {
+			info, err := job.GetJobInfo()
+			if err != nil {
+				return err
+			}
+			deleted, err := b.DeleteOrphans(ctx, info.Argument.(*ConsistencyOrphansJobArgs).Urls)
+			for _, url := range deleted {
+				_ = job.AddLog("deleted " + url)
+			}
+			_ = job.AddLog(fmt.Sprintf("%d orphans deleted", len(deleted)))
+			return err
+		})
+	return b
+}
+
+func (issue *ConsistencyIssue) String() string {
+	if issue.ModelName == "" {
+		return fmt.Sprintf("%s %s", issue.Kind, issue.Url)
+	}
+	return fmt.Sprintf("%s %s (%s %s)", issue.Kind, issue.Url, issue.ModelName, issue.ModelKeys)
+}
+
+func storageKey(path string) string {
+	return strings.TrimPrefix(path, "/")
+}
+
+func (b *Builder) migrateConsistency() error {
+	b.consistencyMigrateOnce.Do(func() {
+		b.consistencyMigrateErr = b.db.AutoMigrate(&ConsistencyIssue{})
+	})
+	return b.consistencyMigrateErr
+}
+
+// saveConsistencyIssues replaces the issues of the previous check with the ones of report
+func (b *Builder) saveConsistencyIssues(ctx context.Context, report *ConsistencyReport) error {
+	if err := b.migrateConsistency(); err != nil {
+		return err
+	}
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&ConsistencyIssue{}).Error; err != nil {
+			return err
+		}
+		if len(report.Issues) == 0 {
+			return nil
+		}
+		issues := make([]*ConsistencyIssue, 0, len(report.Issues))
+		for _, issue := range report.Issues {
+			c := *issue
+			c.ID = 0
+			issues = append(issues, &c)
+		}
+		return tx.CreateInBatches(issues, 100).Error
+	})
+}
+
+// CheckConsistency compares the objects the online records publish with the default storage, nothing is written.
+// The publish actions of each record are computed in a transaction rolled back right away, the storage is read
+// outside of it
+func (b *Builder) CheckConsistency(ctx context.Context) (report *ConsistencyReport, err error) {
+	return b.checkConsistency(ctx, true)
+}
+
+// checkConsistency only looks for orphans when compare is false
+func (b *Builder) checkConsistency(ctx context.Context, compare bool) (report *ConsistencyReport, err error) {
+	ctx = b.WithContextValues(ctx)
+	db := b.db.WithContext(ctx)
+	report = &ConsistencyReport{}
+	expected := map[string]bool{}
+	for _, name := range sortedKeys(b.consistencyModels) {
+		records, err := onlineRecords(db, b.consistencyModels[name])
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			report.Records++
+			objs, err := b.expectedActions(ctx, record)
+			if err != nil {
+				return nil, err
+			}
+			for _, obj := range objs {
+				if obj.IsDelete {
+					continue
+				}
+				expected[storageKey(obj.Url)] = true
+				if !compare {
+					continue
+				}
+				action, err := b.compareAction(ctx, obj, false)
+				if err != nil {
+					return nil, err
+				}
+				if !action.Changed {
+					continue
+				}
+				issue := &ConsistencyIssue{
+					Kind:      ConsistencyStale,
+					Url:       obj.Url,
+					ModelName: name,
+					ModelKeys: record.(presets.SlugEncoder).PrimarySlug(),
+				}
+				if !action.Exists {
+					issue.Kind = ConsistencyMissing
+				}
+				report.Issues = append(report.Issues, issue)
+			}
+		}
+	}
+	if err = listPageUrls(db, expected); err != nil {
+		return nil, err
+	}
+	for _, f := range b.storagePaths {
+		paths, err := f(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			expected[storageKey(path)] = true
+		}
+	}
+
+	for _, prefix := range b.consistencyPrefixes {
+		objects, err := b.storage.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			if expected[storageKey(obj.Path)] {
+				continue
+			}
+			report.Issues = append(report.Issues, &ConsistencyIssue{Kind: ConsistencyOrphan, Url: obj.Path})
+		}
+	}
+	return report, nil
+}
+
+// expectedActions returns the publish actions of record, which may write to the database, so they run
+// in a transaction that is rolled back
+func (b *Builder) expectedActions(ctx context.Context, record any) (objs []*PublishAction, err error) {
+	err = b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if objs, err = b.getPublishActions(context.WithValue(ctx, ctxKeyTx{}, tx), record); err != nil {
+			return
+		}
+		return errDryRun
+	})
+	if !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return objs, nil
+}
+
+func onlineRecords(tx *gorm.DB, mb *presets.ModelBuilder) ([]any, error) {
+	obj := mb.NewModel()
+	records := reflect.New(reflect.SliceOf(reflect.TypeOf(obj)))
+	if err := tx.Where("status = ?", StatusOnline).Find(records.Interface()).Error; err != nil {
+		return nil, err
+	}
+	var r []any
+	for i := 0; i < records.Elem().Len(); i++ {
+		r = append(r, records.Elem().Index(i).Interface())
+	}
+	return r, nil
+}
+
+// listPageUrls adds the pages written by the list publisher, which are not compared as their content
+// depends on the next run of the list publisher
+func listPageUrls(tx *gorm.DB, expected map[string]bool) error {
+	for _, name := range sortedKeys(ListPublishModels) {
+		model := ListPublishModels[name]
+		lp, ok := model.(ListPublisher)
+		if !ok {
+			continue
+		}
+		var maxPage int
+		if err := tx.Model(reflect.New(reflect.TypeOf(model)).Interface()).
+			Select("COALESCE(MAX(page_number), 0)").Scan(&maxPage).Error; err != nil {
+			return err
+		}
+		expected[storageKey(lp.GetListUrl("index"))] = true
+		for i := 1; i <= maxPage; i++ {
+			expected[storageKey(lp.GetListUrl(strconv.Itoa(i)))] = true
+		}
+	}
+	return nil
+}
+
+// RepairConsistency republishes the records of the missing and stale objects, the orphans are deleted
+// separately by DeleteOrphans once they are reviewed
+func (b *Builder) RepairConsistency(ctx context.Context, issues []*ConsistencyIssue) (err error) {
+	republished := map[string]bool{}
+	for _, issue := range issues {
+		if issue.Kind == ConsistencyOrphan {
+			continue
+		}
+		key := issue.ModelName + ":" + issue.ModelKeys
+		if republished[key] {
+			continue
+		}
+		republished[key] = true
+		if err2 := b.republish(ctx, issue.ModelName, issue.ModelKeys); err2 != nil {
+			err = multierror.Append(err, fmt.Errorf("%s: %w", issue, err2)).ErrorOrNil()
+		}
+	}
+	return
+}
+
+// DeleteOrphans deletes the confirmed urls from the default storage and purges them, the ones written
+// by a record or another writer since they were reported are kept
+func (b *Builder) DeleteOrphans(ctx context.Context, urls []string) (deleted []string, err error) {
+	report, err := b.checkConsistency(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	orphans := map[string]bool{}
+	for _, issue := range report.Issues {
+		orphans[storageKey(issue.Url)] = true
+	}
+	for _, url := range urls {
+		if !orphans[storageKey(url)] {
+			continue
+		}
+		if err2 := b.storage.Delete(ctx, url); err2 != nil {
+			err = multierror.Append(err, fmt.Errorf("%s: %w", url, err2)).ErrorOrNil()
+			continue
+		}
+		deleted = append(deleted, url)
+	}
+	if len(deleted) > 0 && b.db.Migrator().HasTable(&ConsistencyIssue{}) {
+		if err2 := b.db.WithContext(ctx).Where("kind = ? AND url IN ?", ConsistencyOrphan, deleted).
+			Delete(&ConsistencyIssue{}).Error; err2 != nil {
+			err = multierror.Append(err, err2).ErrorOrNil()
+		}
+	}
+	if err2 := b.Purge(ctx, deleted); err2 != nil {
+		err = multierror.Append(err, err2).ErrorOrNil()
+	}
+	return
+}
+
+func (b *Builder) republish(ctx context.Context, modelName, keys string) error {
+	mb, ok := b.consistencyModels[modelName]
+	if !ok {
+		return fmt.Errorf("publish: unknown model %s", modelName)
+	}
+	obj := mb.NewModel()
+	if err := utils.PrimarySluggerWhere(b.db, obj, keys).First(obj).Error; err != nil {
+		return err
+	}
+	return b.Publish(b.WithContextValues(ctx), obj)
+}
@@ -0,0 +1,150 @@
+package publish
+
+import (
+	"errors"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	v "github.com/qor5/x/v3/ui/vuetify"
+	h "github.com/theplant/htmlgo"
+
+	"github.com/qor5/admin/v3/presets"
+)
+
+const consistencyActionDeleteOrphans = "DeleteOrphans"
+
+// configureConsistency lists the issues of the last check, the orphans are only deleted once they are selected
+// and the deletion is confirmed
+func (b *Builder) configureConsistency(pb *presets.Builder) error {
+	if err := b.migrateConsistency(); err != nil {
+		return err
+	}
+
+	mb := pb.Model(&ConsistencyIssue{}).URIName("publish-consistency-issues").MenuIcon("mdi-file-compare")
+	mb.LabelName(func(evCtx *web.EventContext, singular bool) string {
+		msgr := i18n.MustGetModuleMessages(evCtx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return msgr.ConsistencyIssues
+	})
+
+	eb := mb.Editing()
+	eb.SaveFunc(func(obj any, id string, ctx *web.EventContext) error {
+		return errors.New("should not be used")
+	})
+	eb.DeleteFunc(func(obj any, id string, ctx *web.EventContext) error {
+		return errors.New("should not be used")
+	})
+
+	lb := mb.Listing("Kind", "Url", "ModelName", "CreatedAt").SearchColumns("url")
+	lb.NewButtonFunc(func(ctx *web.EventContext) h.HTMLComponent { return nil })
+	lb.RowMenu().Empty()
+	lb.WrapColumns(presets.CustomizeColumnLabel(func(evCtx *web.EventContext) (map[string]string, error) {
+		msgr := i18n.MustGetModuleMessages(evCtx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return map[string]string{
+			"Kind":      msgr.ConsistencyKind,
+			"Url":       msgr.ConsistencyUrl,
+			"ModelName": msgr.ConsistencyModel,
+			"CreatedAt": msgr.ConsistencyCheckedAt,
+		}, nil
+	}))
+	lb.Field("Kind").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return h.Td(consistencyChip(obj.(*ConsistencyIssue), msgr))
+	})
+	lb.Field("ModelName").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		issue := obj.(*ConsistencyIssue)
+		if issue.ModelName == "" {
+			return h.Td()
+		}
+		label := i18n.T(ctx.R, presets.ModelsI18nModuleKey, issue.ModelName) + " " + issue.ModelKeys
+		if cmb, ok := b.consistencyModels[issue.ModelName]; ok {
+			return h.Td(h.A(h.Text(label)).Href(cmb.Info().DetailingHref(issue.ModelKeys)).Attr("@click.stop", ""))
+		}
+		return h.Td(h.Text(label))
+	})
+	lb.Field("CreatedAt").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return h.Td(h.Text(obj.(*ConsistencyIssue).CreatedAt.Local().Format(timeFormatSchedule)))
+	})
+
+	lb.Action("CheckConsistency").ButtonCompFunc(func(ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return v.VBtn(msgr.CheckConsistency).
+			PrependIcon("mdi-play").
+			Variant(v.VariantTonal).
+			Color(v.ColorPrimary).
+			Class("ml-2").
+			Attr("@click", web.Plaid().EventFunc(eventCheckConsistency).Go())
+	})
+	lb.Action("RepairConsistency").ButtonCompFunc(func(ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return v.VBtn(msgr.RepairConsistency).
+			PrependIcon("mdi-refresh").
+			Variant(v.VariantTonal).
+			Color(v.ColorPrimary).
+			Class("ml-2").
+			Attr("@click", web.Plaid().EventFunc(eventRepairConsistency).Go())
+	})
+	mb.RegisterEventFunc(eventCheckConsistency, b.startConsistencyCheck(mb, false))
+	mb.RegisterEventFunc(eventRepairConsistency, b.startConsistencyCheck(mb, true))
+
+	lb.BulkAction(consistencyActionDeleteOrphans).
+		ButtonColor(v.ColorError).
+		SelectedIdsProcessorFunc(func(selectedIds []string, ctx *web.EventContext) ([]string, error) {
+			var ids []string
+			err := b.db.Model(&ConsistencyIssue{}).Where("id IN ? AND kind = ?", selectedIds, ConsistencyOrphan).
+				Pluck("id", &ids).Error
+			return ids, err
+		}).
+		ComponentFunc(func(selectedIds []string, ctx *web.EventContext) h.HTMLComponent {
+			msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+			return v.VCardText(h.Text(msgr.ConsistencyDeleteOrphansConfirm(len(selectedIds))))
+		}).
+		UpdateFunc(func(selectedIds []string, ctx *web.EventContext, r *web.EventResponse) (err error) {
+			msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+			if err = mb.Info().Verifier().Do(presets.PermUpdate).WithReq(ctx.R).IsAllowed(); err != nil {
+				return
+			}
+			var urls []string
+			if err = b.db.Model(&ConsistencyIssue{}).Where("id IN ? AND kind = ?", selectedIds, ConsistencyOrphan).
+				Pluck("url", &urls).Error; err != nil {
+				return
+			}
+			if len(urls) == 0 {
+				presets.ShowMessage(r, msgr.ConsistencyNoOrphansSelected, v.ColorWarning)
+				return
+			}
+			if _, err = b.consistencyWorker.AddJob(ctx.R.Context(), ConsistencyOrphansJobName, &ConsistencyOrphansJobArgs{Urls: urls}); err != nil {
+				return
+			}
+			presets.ShowMessage(r, msgr.ConsistencyDeleteOrphansStarted, v.ColorSuccess)
+			return
+		})
+	return nil
+}
+
+func (b *Builder) startConsistencyCheck(mb *presets.ModelBuilder, repair bool) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		if err = mb.Info().Verifier().Do(presets.PermUpdate).WithReq(ctx.R).IsAllowed(); err != nil {
+			return
+		}
+		if _, err = b.consistencyWorker.AddJob(ctx.R.Context(), ConsistencyJobName, &ConsistencyJobArgs{Repair: repair}); err != nil {
+			return
+		}
+		if repair {
+			presets.ShowMessage(&r, msgr.ConsistencyRepairStarted, v.ColorSuccess)
+		} else {
+			presets.ShowMessage(&r, msgr.ConsistencyCheckStarted, v.ColorSuccess)
+		}
+		return
+	}
+}
+
+func consistencyChip(issue *ConsistencyIssue, msgr *Messages) h.HTMLComponent {
+	switch issue.Kind {
+	case ConsistencyMissing:
+		return v.VChip(h.Text(msgr.ConsistencyMissing)).Color(v.ColorError).Size(v.SizeSmall).Variant(v.VariantTonal)
+	case ConsistencyStale:
+		return v.VChip(h.Text(msgr.ConsistencyStale)).Color(v.ColorWarning).Size(v.SizeSmall).Variant(v.VariantTonal)
+	}
+	return v.VChip(h.Text(msgr.ConsistencyOrphan)).Color(v.ColorInfo).Size(v.SizeSmall).Variant(v.VariantTonal)
+}
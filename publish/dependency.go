@@ -0,0 +1,341 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/qor5/admin/v3/utils"
+)
+
+// DependencyInterface is implemented by models whose published content refers to other publishable records,
+// e.g. a page listing products, the returned records are fetched models
+type DependencyInterface interface {
+	PublishDependencies(ctx context.Context, db *gorm.DB) ([]any, error)
+}
+
+type DependencyMode string
+
+const (
+	// DependencyModeWarn refuses to publish a record with unpublished dependencies
+	// and to unpublish a record that live records depend on
+	DependencyModeWarn DependencyMode = "warn"
+	// DependencyModeAutoPublish publishes the unpublished dependencies together with the record
+	DependencyModeAutoPublish DependencyMode = "auto"
+	// DependencyModeIgnore skips all dependency checks, it is used to force publishing and unpublishing
+	DependencyModeIgnore DependencyMode = "ignore"
+)
+
+// Dependency is an edge of the dependency graph of online records, it is saved when the record is published,
+// Target is empty on the default target
+type Dependency struct {
+	ID                  uint   `gorm:"primarykey"`
+	Target              string `gorm:"size:255;default:''"`
+	ModelName           string `gorm:"index:idx_publish_dependencies_model"`
+	ModelKeys           string `gorm:"index:idx_publish_dependencies_model"`
+	DependencyModelName string `gorm:"index:idx_publish_dependencies_dependency"`
+	DependencyKeys      string `gorm:"index:idx_publish_dependencies_dependency"`
+}
+
+func (Dependency) TableName() string {
+	return "publish_dependencies"
+}
+
+func (d *Dependency) Label() string {
+	return d.ModelName + " " + d.ModelKeys
+}
+
+type UnpublishedDependenciesError struct {
+	Dependencies []any
+}
+
+func (e *UnpublishedDependenciesError) Error() string {
+	return fmt.Sprintf("publish: unpublished dependencies: %s", strings.Join(lo.Map(e.Dependencies, func(d any, _ int) string {
+		return recordLabel(d)
+	}), ", "))
+}
+
+type LiveDependentsError struct {
+	Dependents []*Dependency
+}
+
+func (e *LiveDependentsError) Error() string {
+	return fmt.Sprintf("publish: still used by online records: %s", strings.Join(lo.Map(e.Dependents, func(d *Dependency, _ int) string {
+		return d.Label()
+	}), ", "))
+}
+
+type ctxKeyDependencyMode struct{}
+
+// WithDependencyMode overrides the dependency mode of the builder for one call of Publish or UnPublish
+func WithDependencyMode(ctx context.Context, mode DependencyMode) context.Context {
+	return context.WithValue(ctx, ctxKeyDependencyMode{}, mode)
+}
+
+func (b *Builder) DependencyMode(v DependencyMode) (r *Builder) {
+	b.dependencyMode = v
+	return b
+}
+
+func (b *Builder) dependencyModeFromContext(ctx context.Context) DependencyMode {
+	if mode, ok := ctx.Value(ctxKeyDependencyMode{}).(DependencyMode); ok && mode != "" {
+		return mode
+	}
+	return b.dependencyMode
+}
+
+func (b *Builder) migrateDependencies() error {
+	b.dependencyMigrateOnce.Do(func() {
+		if b.dependencyMigrateErr = b.db.AutoMigrate(&Dependency{}); b.dependencyMigrateErr == nil {
+			b.dependencyTableMu.Lock()
+			b.dependencyTable = true
+			b.dependencyTableMu.Unlock()
+		}
+	})
+	return b.dependencyMigrateErr
+}
+
+// dependencyTableRecheck is how long a missing dependency table is remembered,
+// it is created by the replica publishing the first record with dependencies
+const dependencyTableRecheck = time.Minute
+
+// hasDependencyTable caches the check of the table, once created it stays
+func (b *Builder) hasDependencyTable(db *gorm.DB) bool {
+	b.dependencyTableMu.Lock()
+	defer b.dependencyTableMu.Unlock()
+	if b.dependencyTable || time.Since(b.dependencyTableAt) < dependencyTableRecheck {
+		return b.dependencyTable
+	}
+	b.dependencyTable = db.Migrator().HasTable(&Dependency{})
+	b.dependencyTableAt = time.Now()
+	return b.dependencyTable
+}
+
+var dependencySchemaCache = &sync.Map{}
+
+// dependencyKeys identifies a record regardless of its version, so any online version satisfies a dependency
+func dependencyKeys(db *gorm.DB, record any) (string, error) {
+	s, err := schema.Parse(record, dependencySchemaCache, db.NamingStrategy)
+	if err != nil {
+		return "", err
+	}
+	var keys []string
+	for _, p := range s.PrimaryFields {
+		if p.Name == "Version" {
+			continue
+		}
+		val, _ := p.ValueOf(context.Background(), reflect.Indirect(reflect.ValueOf(record)))
+		keys = append(keys, fmt.Sprint(val))
+	}
+	return strings.Join(keys, "_"), nil
+}
+
+func recordLabel(record any) string {
+	label := utils.GetObjectName(record)
+	if v, ok := record.(interface{ PrimarySlug() string }); ok {
+		label += " " + v.PrimarySlug()
+	}
+	return label
+}
+
+func isOnline(record any) bool {
+	status, ok := record.(StatusInterface)
+	return !ok || status.EmbedStatus().Status == StatusOnline
+}
+
+// dependencyTarget is the target of the dependency rows written in ctx
+func dependencyTarget(ctx context.Context) string {
+	if isDefaultTarget(ctx) {
+		return ""
+	}
+	return TargetFromContext(ctx)
+}
+
+// hasOnlineVersion reports whether record, or another version of it, is online on the target of ctx
+func (b *Builder) hasOnlineVersion(ctx context.Context, db *gorm.DB, record any) (bool, error) {
+	if !isDefaultTarget(ctx) {
+		if _, ok := record.(StatusInterface); !ok {
+			return true, nil
+		}
+		ts, err := b.GetTargetStatus(ctx, TargetFromContext(ctx), record)
+		return ts != nil && ts.Status == StatusOnline, err
+	}
+	if isOnline(record) {
+		return true, nil
+	}
+	if _, ok := record.(VersionInterface); !ok {
+		return false, nil
+	}
+	s, err := schema.Parse(record, dependencySchemaCache, db.NamingStrategy)
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	err = setPrimaryKeysConditionWithoutVersion(db.Model(reflect.New(s.ModelType).Interface()), record, s).
+		Where("status = ?", StatusOnline).Count(&count).Error
+	return count > 0, err
+}
+
+// UnpublishedDependencies walks the dependency graph of record and returns the records of which no version is online
+// on the target of ctx, dependencies come before the records depending on them
+func (b *Builder) UnpublishedDependencies(ctx context.Context, record any) (deps []any, err error) {
+	db := b.dbFromContext(ctx)
+	visited := map[string]bool{}
+	var walk func(record any) error
+	walk = func(record any) error {
+		d, ok := record.(DependencyInterface)
+		if !ok {
+			return nil
+		}
+		children, err := d.PublishDependencies(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			keys, err := dependencyKeys(db, child)
+			if err != nil {
+				return err
+			}
+			key := utils.GetObjectName(child) + "_" + keys
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			if err = walk(child); err != nil {
+				return err
+			}
+			online, err := b.hasOnlineVersion(ctx, db, child)
+			if err != nil {
+				return err
+			}
+			if !online {
+				deps = append(deps, child)
+			}
+		}
+		return nil
+	}
+	keys, err := dependencyKeys(db, record)
+	if err != nil {
+		return
+	}
+	visited[utils.GetObjectName(record)+"_"+keys] = true
+	err = walk(record)
+	return
+}
+
+// LiveDependents returns the records online on the target of ctx that depend on record
+func (b *Builder) LiveDependents(ctx context.Context, record any) (dependents []*Dependency, err error) {
+	db := b.dbFromContext(ctx)
+	// the table is created when the first record with dependencies is published
+	if !b.hasDependencyTable(db) {
+		return
+	}
+	keys, err := dependencyKeys(db, record)
+	if err != nil {
+		return
+	}
+	name := utils.GetObjectName(record)
+	err = db.Where("target = ? AND dependency_model_name = ? AND dependency_keys = ?", dependencyTarget(ctx), name, keys).
+		Not("model_name = ? AND model_keys = ?", name, keys).
+		Order("id").Find(&dependents).Error
+	return
+}
+
+func (b *Builder) publishDependencies(ctx context.Context, record any) error {
+	mode := b.dependencyModeFromContext(ctx)
+	if mode == DependencyModeIgnore {
+		return nil
+	}
+	deps, err := b.UnpublishedDependencies(ctx, record)
+	if err != nil || len(deps) == 0 {
+		return err
+	}
+	if mode != DependencyModeAutoPublish {
+		return &UnpublishedDependenciesError{Dependencies: deps}
+	}
+	for _, dep := range deps {
+		if isDefaultTarget(ctx) {
+			if err = b.CheckPublish(ctx, dep); err != nil {
+				return &PublishCheckError{Err: err}
+			}
+		}
+		if err = b.publish(ctx, dep); err != nil {
+			return err
+		}
+		if err = b.saveDependencies(ctx, dep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Builder) checkLiveDependents(ctx context.Context, record any) error {
+	if b.dependencyModeFromContext(ctx) == DependencyModeIgnore {
+		return nil
+	}
+	dependents, err := b.LiveDependents(ctx, record)
+	if err != nil || len(dependents) == 0 {
+		return err
+	}
+	return &LiveDependentsError{Dependents: dependents}
+}
+
+func (b *Builder) saveDependencies(ctx context.Context, record any) error {
+	d, ok := record.(DependencyInterface)
+	if !ok {
+		return nil
+	}
+	db := b.dbFromContext(ctx)
+	if err := b.deleteDependencies(ctx, record); err != nil {
+		return err
+	}
+	children, err := d.PublishDependencies(ctx, db)
+	if err != nil {
+		return err
+	}
+	name := utils.GetObjectName(record)
+	keys, err := dependencyKeys(db, record)
+	if err != nil {
+		return err
+	}
+	var rows []*Dependency
+	for _, child := range children {
+		childKeys, err := dependencyKeys(db, child)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, &Dependency{
+			Target:              dependencyTarget(ctx),
+			ModelName:           name,
+			ModelKeys:           keys,
+			DependencyModelName: utils.GetObjectName(child),
+			DependencyKeys:      childKeys,
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return db.Create(&rows).Error
+}
+
+func (b *Builder) deleteDependencies(ctx context.Context, record any) error {
+	if _, ok := record.(DependencyInterface); !ok {
+		return nil
+	}
+	db := b.dbFromContext(ctx)
+	if !b.hasDependencyTable(db) {
+		return nil
+	}
+	keys, err := dependencyKeys(db, record)
+	if err != nil {
+		return err
+	}
+	return db.Where("target = ? AND model_name = ? AND model_keys = ?", dependencyTarget(ctx), utils.GetObjectName(record), keys).Delete(&Dependency{}).Error
+}
@@ -0,0 +1,151 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gorm.io/gorm"
+)
+
+// DryRunAction is a storage action of publishing compared with the object currently in the storage
+type DryRunAction struct {
+	Url      string
+	Size     int
+	IsDelete bool
+	// List is set for the pages written by the list publisher
+	List    bool
+	Exists  bool
+	Changed bool
+	// Diff is the unified diff of the text content, it is empty for large or unchanged objects
+	Diff string
+}
+
+const dryRunDiffMaxSize = 256 << 10
+
+var errDryRun = errors.New("publish: dry run")
+
+// DryRunPublish returns the storage actions publishing record would run, including the list pages,
+// the record is not changed and neither the storage nor the database is written
+func (b *Builder) DryRunPublish(ctx context.Context, record any) ([]*DryRunAction, error) {
+	return b.dryRun(ctx, record, false)
+}
+
+// DryRunUnPublish is DryRunPublish for unpublishing
+func (b *Builder) DryRunUnPublish(ctx context.Context, record any) ([]*DryRunAction, error) {
+	return b.dryRun(ctx, record, true)
+}
+
+func (b *Builder) dryRun(ctx context.Context, record any, unpublish bool) (actions []*DryRunAction, err error) {
+	ctx = b.WithContextValues(ctx)
+	if err = b.checkTarget(ctx); err != nil {
+		return
+	}
+	record = copyRecord(record)
+
+	var objs, listObjs []*PublishAction
+	// the status is changed in a transaction rolled back at the end, so the list pages are planned as after publishing
+	err = b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		ctx := context.WithValue(ctx, ctxKeyTx{}, tx)
+		if !isDefaultTarget(ctx) {
+			// a target only gets the record itself, the list pages stay on the default target
+			var onTarget any
+			if onTarget, _, err = b.recordOnTarget(ctx, record); err != nil {
+				return
+			}
+			if unpublish {
+				objs, err = b.getUnPublishActions(ctx, onTarget)
+			} else {
+				objs, err = b.getPublishActions(ctx, onTarget)
+			}
+			if err != nil {
+				return
+			}
+			return errDryRun
+		}
+		if unpublish {
+			if objs, err = b.getUnPublishActions(ctx, record); err != nil {
+				return
+			}
+			err = updateUnPublishStatus(tx, record)
+		} else {
+			if objs, err = b.getPublishActions(ctx, record); err != nil {
+				return
+			}
+			err = updatePublishStatus(tx, record)
+		}
+		if err != nil {
+			return
+		}
+		if _, ok := record.(ListInterface); ok {
+			if _, ok := record.(ListPublisher); ok {
+				model := reflect.ValueOf(record).Elem().Interface()
+				if listObjs, err = NewListPublishBuilder(tx, b.storage).DryRun(ctx, model); err != nil {
+					return
+				}
+			}
+		}
+		return errDryRun
+	})
+	if !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	for i, obj := range append(objs, listObjs...) {
+		action, err := b.compareAction(ctx, obj, i >= len(objs))
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// compareAction reads the object of obj from the storage, only a missing object counts as not existing
+func (b *Builder) compareAction(ctx context.Context, obj *PublishAction, list bool) (*DryRunAction, error) {
+	action := &DryRunAction{
+		Url:      obj.Url,
+		Size:     len(obj.Content),
+		IsDelete: obj.IsDelete,
+		List:     list,
+	}
+	var current []byte
+	r, err := b.storageFor(ctx).GetStream(ctx, obj.Url)
+	if err == nil {
+		current, err = io.ReadAll(r)
+		r.Close()
+	}
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+	action.Exists = err == nil
+	if obj.IsDelete {
+		action.Size = len(current)
+		action.Changed = action.Exists
+		return action, nil
+	}
+	action.Changed = !action.Exists || string(current) != obj.Content
+	if action.Exists && action.Changed && len(current) <= dryRunDiffMaxSize && len(obj.Content) <= dryRunDiffMaxSize {
+		action.Diff, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(current)),
+			B:        difflib.SplitLines(obj.Content),
+			FromFile: "current",
+			ToFile:   "new",
+			Context:  3,
+		})
+	}
+	return action, nil
+}
+
+// copyRecord makes a shallow copy so that the dry run does not change the fields of record
+func copyRecord(record any) any {
+	rv := reflect.ValueOf(record)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return record
+	}
+	c := reflect.New(rv.Elem().Type())
+	c.Elem().Set(rv.Elem())
+	return c.Interface()
+}
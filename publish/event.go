@@ -11,18 +11,56 @@ const (
 	EventUnpublish = "publish_EventUnpublish"
 
 	EventDuplicateVersion      = "publish_EventDuplicateVersion"
+	EventRollbackVersion       = "publish_EventRollbackVersion"
 	eventSchedulePublishDialog = "publish_eventSchedulePublishDialog"
 	eventSchedulePublish       = "publish_eventSchedulePublish"
 
-	eventRenameVersionDialog = "publish_eventRenameVersionDialog"
-	eventRenameVersion       = "publish_eventRenameVersion"
-	eventDeleteVersionDialog = "publish_eventDeleteVersionDialog"
-	eventDeleteVersion       = "publish_eventDeleteVersion"
+	eventRenameVersionDialog   = "publish_eventRenameVersionDialog"
+	eventRenameVersion         = "publish_eventRenameVersion"
+	eventDeleteVersionDialog   = "publish_eventDeleteVersionDialog"
+	eventDeleteVersion         = "publish_eventDeleteVersion"
+	eventCompareVersionDialog  = "publish_eventCompareVersionDialog"
+	eventRollbackVersionDialog = "publish_eventRollbackVersionDialog"
+
+	eventReviewDialog  = "publish_eventReviewDialog"
+	eventSubmitReview  = "publish_eventSubmitReview"
+	eventApproveReview = "publish_eventApproveReview"
+	eventRejectReview  = "publish_eventRejectReview"
+
+	eventPublishDryRunDialog = "publish_eventPublishDryRunDialog"
+	eventRetryPublishRun     = "publish_eventRetryPublishRun"
+
+	eventAddToReleaseDialog = "publish_eventAddToReleaseDialog"
+	eventAddToRelease       = "publish_eventAddToRelease"
+	eventRemoveReleaseItem  = "publish_eventRemoveReleaseItem"
+
+	eventPromoteTargetDialog = "publish_eventPromoteTargetDialog"
+	eventPromoteTarget       = "publish_eventPromoteTarget"
+
+	eventShareLinkDialog = "publish_eventShareLinkDialog"
+	eventCreateShareLink = "publish_eventCreateShareLink"
+	eventRevokeShareLink = "publish_eventRevokeShareLink"
+
+	eventPurgeCDN = "publish_eventPurgeCDN"
+
+	eventCheckConsistency  = "publish_eventCheckConsistency"
+	eventRepairConsistency = "publish_eventRepairConsistency"
 
 	ActivityPublish   = "Publish"
 	ActivityRepublish = "Republish"
 	ActivityUnPublish = "UnPublish"
 
+	ActivitySubmitReview = "SubmitReview"
+	ActivityApprove      = "Approve"
+	ActivityReject       = "Reject"
+	ActivityRollback     = "Rollback"
+
+	ActivityPublishRelease   = "PublishRelease"
+	ActivityUnPublishRelease = "UnPublishRelease"
+
+	ActivityPromoteTarget = "PromoteTarget"
+	ActivityPrune         = "Prune"
+
 	ParamScriptAfterPublish = "publish_param_script_after_publish"
 )
 
@@ -30,15 +68,26 @@ func registerEventFuncsForResource(db *gorm.DB, mb *presets.ModelBuilder, publis
 	mb.RegisterEventFunc(EventPublish, publishAction(db, mb, publisher, ActivityPublish))
 	mb.RegisterEventFunc(EventRepublish, publishAction(db, mb, publisher, ActivityRepublish))
 	mb.RegisterEventFunc(EventUnpublish, unpublishAction(db, mb, publisher, ActivityUnPublish))
+	mb.RegisterEventFunc(eventPublishDryRunDialog, publishDryRunDialog(mb, publisher))
 
 	mb.RegisterEventFunc(EventDuplicateVersion, duplicateVersionAction(mb, db))
-	mb.RegisterEventFunc(eventSchedulePublishDialog, scheduleDialog(db, mb))
-	mb.RegisterEventFunc(eventSchedulePublish, schedule(db, mb))
+	mb.RegisterEventFunc(eventSchedulePublishDialog, scheduleDialog(db, mb, publisher))
+	mb.RegisterEventFunc(eventSchedulePublish, schedule(db, mb, publisher))
+	mb.RegisterEventFunc(eventPromoteTargetDialog, promoteTargetDialog(mb, publisher))
+	mb.RegisterEventFunc(eventPromoteTarget, promoteTarget(mb, publisher))
+
+	mb.RegisterEventFunc(eventReviewDialog, reviewDialog(mb))
+	mb.RegisterEventFunc(eventSubmitReview, submitReview(mb, publisher))
+	mb.RegisterEventFunc(eventApproveReview, approveReview(mb, publisher))
+	mb.RegisterEventFunc(eventRejectReview, rejectReview(mb, publisher))
 }
 
-func registerEventFuncsForVersion(mb *presets.ModelBuilder, db *gorm.DB) {
+func registerEventFuncsForVersion(mb *presets.ModelBuilder, pm *presets.ModelBuilder, pb *Builder, db *gorm.DB) {
 	mb.RegisterEventFunc(eventRenameVersionDialog, renameVersionDialog(mb))
 	mb.RegisterEventFunc(eventRenameVersion, renameVersion(mb))
 	mb.RegisterEventFunc(eventDeleteVersionDialog, deleteVersionDialog(mb))
 	mb.RegisterEventFunc(eventDeleteVersion, deleteVersion(mb, db))
+	mb.RegisterEventFunc(eventCompareVersionDialog, compareVersionDialog(mb, pb))
+	mb.RegisterEventFunc(eventRollbackVersionDialog, rollbackVersionDialog(mb))
+	mb.RegisterEventFunc(EventRollbackVersion, rollbackVersionAction(pm, db, pb))
 }
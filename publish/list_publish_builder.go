@@ -3,6 +3,7 @@ package publish
 import (
 	"context"
 	"errors"
+	"log"
 	"reflect"
 	"slices"
 	"strconv"
@@ -21,6 +22,7 @@ type ListPublishBuilder struct {
 	getOldItemsFunc    func(record interface{}) (result []interface{}, err error)
 	totalNumberPerPage int
 	publishActionsFunc func(db *gorm.DB, lp ListPublisher, result []*OnePageItems, indexPage *OnePageItems) (objs []*PublishAction)
+	publisher          *Builder
 }
 
 func NewListPublishBuilder(db *gorm.DB, storage oss.StorageInterface) *ListPublishBuilder {
@@ -98,6 +100,71 @@ type ListPublisher interface {
 // model is a empty struct
 // example: Product{}
 func (b *ListPublishBuilder) Run(ctx context.Context, model interface{}) (err error) {
+	objs, needPublishResults, deleteItems, err := b.plan(model)
+	if err != nil || len(needPublishResults) == 0 && len(deleteItems) == 0 {
+		return
+	}
+
+	ctx, journal, ownJournal := withStorageJournal(ctx, b.publisher != nil && b.publisher.publishRuns)
+	defer func() {
+		if err != nil && ownJournal {
+			if rerr := journal.revert(ctx); rerr != nil {
+				log.Printf("error: %s\n", rerr)
+			}
+		}
+		if err == nil && ownJournal && b.publisher != nil {
+			b.publisher.purgeJournal(ctx, journal)
+		}
+	}()
+
+	err = utils.Transact(b.db, func(tx *gorm.DB) (err1 error) {
+		if err1 = UploadOrDelete(ctx, objs, b.storage); err1 != nil {
+			return
+		}
+
+		for _, items := range needPublishResults {
+			for _, item := range items.Items {
+				if listItem, ok := item.(ListInterface); ok {
+					if err1 = b.db.Model(item).Updates(map[string]interface{}{
+						"list_updated": listItem.EmbedList().ListUpdated,
+						"list_deleted": listItem.EmbedList().ListDeleted,
+						"page_number":  listItem.EmbedList().PageNumber,
+						"position":     listItem.EmbedList().Position,
+					}).Error; err1 != nil {
+						return
+					}
+				} else {
+					return errors.New("model must be ListInterface")
+				}
+			}
+		}
+
+		for _, item := range deleteItems {
+			if _, ok := item.(ListInterface); ok {
+				if err1 = b.db.Model(item).Updates(map[string]interface{}{
+					"list_updated": false,
+					"list_deleted": false,
+					"page_number":  0,
+					"position":     0,
+				}).Error; err1 != nil {
+					return
+				}
+			} else {
+				return errors.New("model must be ListInterface")
+			}
+		}
+		return
+	})
+	return
+}
+
+// DryRun returns the list pages the next Run of model would write, the storage and the database are not changed
+func (b *ListPublishBuilder) DryRun(_ context.Context, model interface{}) (objs []*PublishAction, err error) {
+	objs, _, _, err = b.plan(model)
+	return
+}
+
+func (b *ListPublishBuilder) plan(model interface{}) (objs []*PublishAction, needPublishResults []*OnePageItems, deleteItems []interface{}, err error) {
 	// If model is Product{}
 	// Generate a records: []*Product{}
 	records := reflect.MakeSlice(reflect.SliceOf(reflect.New(reflect.TypeOf(model)).Type()), 0, 0).Interface()
@@ -106,7 +173,7 @@ func (b *ListPublishBuilder) Run(ctx context.Context, model interface{}) (err er
 	if err != nil {
 		return
 	}
-	deleteItems, err := getDeleteItems(b.db, records)
+	deleteItems, err = getDeleteItems(b.db, records)
 	if err != nil {
 		return
 	}
@@ -116,7 +183,7 @@ func (b *ListPublishBuilder) Run(ctx context.Context, model interface{}) (err er
 	}
 
 	if len(deleteItems) == 0 && len(addItems) == 0 && len(republishItems) == 0 {
-		return nil
+		return
 	}
 
 	oldItems, err := b.getOldItemsFunc(records)
@@ -163,47 +230,7 @@ func (b *ListPublishBuilder) Run(ctx context.Context, model interface{}) (err er
 
 	needPublishResults, indexResult := getNeedPublishResultsAndIndexResult(oldResult, newResult, republishResult)
 
-	var objs []*PublishAction
 	objs = b.publishActionsFunc(b.db, lp, needPublishResults, indexResult)
-
-	err = utils.Transact(b.db, func(tx *gorm.DB) (err1 error) {
-		if err1 = UploadOrDelete(ctx, objs, b.storage); err1 != nil {
-			return
-		}
-
-		for _, items := range needPublishResults {
-			for _, item := range items.Items {
-				if listItem, ok := item.(ListInterface); ok {
-					if err1 = b.db.Model(item).Updates(map[string]interface{}{
-						"list_updated": listItem.EmbedList().ListUpdated,
-						"list_deleted": listItem.EmbedList().ListDeleted,
-						"page_number":  listItem.EmbedList().PageNumber,
-						"position":     listItem.EmbedList().Position,
-					}).Error; err1 != nil {
-						return
-					}
-				} else {
-					return errors.New("model must be ListInterface")
-				}
-			}
-		}
-
-		for _, item := range deleteItems {
-			if _, ok := item.(ListInterface); ok {
-				if err1 = b.db.Model(item).Updates(map[string]interface{}{
-					"list_updated": false,
-					"list_deleted": false,
-					"page_number":  0,
-					"position":     0,
-				}).Error; err1 != nil {
-					return
-				}
-			} else {
-				return errors.New("model must be ListInterface")
-			}
-		}
-		return
-	})
 	return
 }
 
@@ -227,6 +254,12 @@ func (b *ListPublishBuilder) PublishActionsFunc(f func(db *gorm.DB, lp ListPubli
 	return b
 }
 
+// Publisher purges the list pages written by Run through the purger of v
+func (b *ListPublishBuilder) Publisher(v *Builder) *ListPublishBuilder {
+	b.publisher = v
+	return b
+}
+
 type OnePageItems struct {
 	Items      []interface{}
 	PageNumber int
@@ -1,6 +1,9 @@
 package publish
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+)
 
 type Messages struct {
 	StatusDraft                             string
@@ -57,6 +60,124 @@ type Messages struct {
 
 	HeaderDraftCount string
 	HeaderLive       string
+
+	SubmitForReview             string
+	Approve                     string
+	Reject                      string
+	ReviewComment               string
+	RejectCommentRequired       string
+	SuccessfullySubmitForReview string
+	SuccessfullyApprove         string
+	SuccessfullyReject          string
+	ReviewStatusPending         string
+	ReviewStatusApproved        string
+	ReviewStatusRejected        string
+	ReviewStatusWithdrawn       string
+	ReviewQueue                 string
+	FilterTabPendingReviews     string
+	FilterTabAllReviews         string
+
+	Compare                      string
+	CompareVersionsTitleTemplate string
+	DiffField                    string
+	NoDifferences                string
+
+	Rollback                                string
+	RollbackVersionConfirmationTextTemplate string
+	PublishImmediately                      string
+	SuccessfullyRollback                    string
+
+	UnpublishedDependencies string
+	PublishWithDependencies string
+	LiveDependents          string
+	UnpublishAnyway         string
+
+	Releases                     string
+	Release                      string
+	AddToRelease                 string
+	ReleaseItems                 string
+	NoReleaseItems               string
+	NoDraftReleases              string
+	PreviewRelease               string
+	NoPreview                    string
+	ReleaseNameRequired          string
+	OnlyDraftReleaseCanBeChanged string
+	SuccessfullyAddToRelease     string
+
+	DryRunDescription   string
+	DryRunNoActions     string
+	DryRunAction        string
+	DryRunURL           string
+	DryRunSize          string
+	DryRunCreate        string
+	DryRunWrite         string
+	DryRunUnchanged     string
+	DryRunDelete        string
+	DryRunDeleteMissing string
+	DryRunListPage      string
+	DryRunShowDiff      string
+
+	PublishRuns             string
+	PublishRunStatusSuccess string
+	PublishRunStatusFailed  string
+	PublishRunStatusRetried string
+	FilterTabFailedRuns     string
+	FilterTabAllRuns        string
+	Retry                   string
+	SuccessfullyRetry       string
+
+	Target      string
+	Promote     string
+	PromoteFrom string
+
+	NotOnlineOnTargets string
+
+	Share                     string
+	NoShareLinks              string
+	ShareLinkExpiresAt        string
+	ShareLinkPassword         string
+	ShareLinkPasswordOptional string
+	CopyShareLink             string
+	ShareLinkCopied           string
+	RevokeShareLink           string
+	CreateShareLink           string
+	ShareLinkOneDay           string
+	ShareLinkOneWeek          string
+	ShareLinkOneMonth         string
+	ShareLinkPasswordRequired string
+	ShareLinkWrongPassword    string
+	ShareLinkView             string
+
+	PurgeCDN             string
+	SuccessfullyPurgeCDN string
+
+	NotificationSchedulePublishFailed   string
+	NotificationScheduleUnPublishFailed string
+	NotificationReviewSubmitted         string
+	NotificationReviewApproved          string
+	NotificationReviewRejected          string
+
+	CompareVersionsOfDifferentRecords string
+
+	RollbackOnlyOffline string
+
+	Preview string
+
+	ConsistencyIssues                       string
+	ConsistencyKind                         string
+	ConsistencyUrl                          string
+	ConsistencyModel                        string
+	ConsistencyCheckedAt                    string
+	ConsistencyMissing                      string
+	ConsistencyStale                        string
+	ConsistencyOrphan                       string
+	CheckConsistency                        string
+	RepairConsistency                       string
+	ConsistencyCheckStarted                 string
+	ConsistencyRepairStarted                string
+	ConsistencyDeleteOrphansConfirmTemplate string
+	ConsistencyDeleteOrphansStarted         string
+	ConsistencyNoOrphansSelected            string
 }
 
 func (msgr *Messages) DeleteVersionConfirmationText(versionName string) string {
@@ -78,6 +199,23 @@ func (msgr *Messages) ToStatusOffline(versionName string, scheduleTime string) s
 	).Replace(msgr.ToStatusOfflineTemplate)
 }
 
+func (msgr *Messages) RollbackVersionConfirmationText(versionName string) string {
+	return strings.NewReplacer("{VersionName}", versionName).
+		Replace(msgr.RollbackVersionConfirmationTextTemplate)
+}
+
+func (msgr *Messages) CompareVersionsTitle(oldVersionName string, newVersionName string) string {
+	return strings.NewReplacer(
+		"{Old}", oldVersionName,
+		"{New}", newVersionName,
+	).Replace(msgr.CompareVersionsTitleTemplate)
+}
+
+func (msgr *Messages) ConsistencyDeleteOrphansConfirm(count int) string {
+	return strings.NewReplacer("{Count}", strconv.Itoa(count)).
+		Replace(msgr.ConsistencyDeleteOrphansConfirmTemplate)
+}
+
 var Messages_en_US = &Messages{
 	StatusDraft:                             "Draft",
 	StatusOnline:                            "Online",
@@ -133,6 +271,124 @@ var Messages_en_US = &Messages{
 
 	HeaderDraftCount: "Draft Count",
 	HeaderLive:       "Live",
+
+	SubmitForReview:             "Submit for Review",
+	Approve:                     "Approve",
+	Reject:                      "Reject",
+	ReviewComment:               "Comment",
+	RejectCommentRequired:       "Please leave a comment when rejecting",
+	SuccessfullySubmitForReview: "Successfully Submitted for Review",
+	SuccessfullyApprove:         "Successfully Approved",
+	SuccessfullyReject:          "Successfully Rejected",
+	ReviewStatusPending:         "In Review",
+	ReviewStatusApproved:        "Approved",
+	ReviewStatusRejected:        "Rejected",
+	ReviewStatusWithdrawn:       "Withdrawn",
+	ReviewQueue:                 "Review Queue",
+	FilterTabPendingReviews:     "Pending",
+	FilterTabAllReviews:         "All",
+
+	Compare:                      "Compare",
+	CompareVersionsTitleTemplate: "Compare {Old} with {New}",
+	DiffField:                    "Field",
+	NoDifferences:                "No differences between the two versions",
+
+	Rollback:                                "Rollback",
+	RollbackVersionConfirmationTextTemplate: "A new draft will be created from version {VersionName}.",
+	PublishImmediately:                      "Publish immediately",
+	SuccessfullyRollback:                    "Successfully Rollback",
+
+	UnpublishedDependencies: "These records are not online yet",
+	PublishWithDependencies: "Publish All",
+	LiveDependents:          "These online records still use it",
+	UnpublishAnyway:         "Unpublish Anyway",
+
+	Releases:                     "Releases",
+	Release:                      "Release",
+	AddToRelease:                 "Add to Release",
+	ReleaseItems:                 "Items",
+	NoReleaseItems:               "No items in this release",
+	NoDraftReleases:              "There is no draft release",
+	PreviewRelease:               "Preview Release",
+	NoPreview:                    "No preview available",
+	ReleaseNameRequired:          "Name is required",
+	OnlyDraftReleaseCanBeChanged: "Only draft releases can be changed",
+	SuccessfullyAddToRelease:     "Successfully Added to Release",
+
+	DryRunDescription:   "The following objects will be changed in the storage",
+	DryRunNoActions:     "Nothing will be changed in the storage",
+	DryRunAction:        "Action",
+	DryRunURL:           "URL",
+	DryRunSize:          "Size",
+	DryRunCreate:        "Create",
+	DryRunWrite:         "Overwrite",
+	DryRunUnchanged:     "Unchanged",
+	DryRunDelete:        "Delete",
+	DryRunDeleteMissing: "Not Found",
+	DryRunListPage:      "(list page)",
+	DryRunShowDiff:      "Show diff",
+
+	PublishRuns:             "Publish History",
+	PublishRunStatusSuccess: "Success",
+	PublishRunStatusFailed:  "Failed",
+	PublishRunStatusRetried: "Retried",
+	FilterTabFailedRuns:     "Failed",
+	FilterTabAllRuns:        "All",
+	Retry:                   "Retry",
+	SuccessfullyRetry:       "Successfully Retried",
+
+	Target:      "Target",
+	Promote:     "Promote",
+	PromoteFrom: "From",
+
+	NotOnlineOnTargets: "This record is not online on any other target",
+
+	Share:                     "Share",
+	NoShareLinks:              "No active share links",
+	ShareLinkExpiresAt:        "Expires At",
+	ShareLinkPassword:         "Password",
+	ShareLinkPasswordOptional: "Optional",
+	CopyShareLink:             "Copy",
+	ShareLinkCopied:           "Link copied",
+	RevokeShareLink:           "Revoke",
+	CreateShareLink:           "Create Link",
+	ShareLinkOneDay:           "1 day",
+	ShareLinkOneWeek:          "7 days",
+	ShareLinkOneMonth:         "30 days",
+	ShareLinkPasswordRequired: "This preview is protected by a password",
+	ShareLinkWrongPassword:    "Wrong password",
+	ShareLinkView:             "View",
+
+	PurgeCDN:             "Purge CDN",
+	SuccessfullyPurgeCDN: "Successfully Purge CDN",
+
+	NotificationSchedulePublishFailed:   "Scheduled publish of {model} {keys} failed",
+	NotificationScheduleUnPublishFailed: "Scheduled unpublish of {model} {keys} failed",
+	NotificationReviewSubmitted:         "{model} {label} is waiting for your review",
+	NotificationReviewApproved:          "{model} {label} is approved",
+	NotificationReviewRejected:          "{model} {label} is rejected",
+
+	CompareVersionsOfDifferentRecords: "Only versions of the same record can be compared",
+
+	RollbackOnlyOffline: "Only offline versions can be rolled back, the online version is already live and a draft can be published as it is",
+
+	Preview: "Preview",
+
+	ConsistencyIssues:                       "Storage Issues",
+	ConsistencyKind:                         "Issue",
+	ConsistencyUrl:                          "Path",
+	ConsistencyModel:                        "Record",
+	ConsistencyCheckedAt:                    "Checked At",
+	ConsistencyMissing:                      "Missing",
+	ConsistencyStale:                        "Outdated",
+	ConsistencyOrphan:                       "Orphan",
+	CheckConsistency:                        "Check Storage",
+	RepairConsistency:                       "Republish Missing and Outdated",
+	ConsistencyCheckStarted:                 "The storage check has started, the issues are listed once it is done",
+	ConsistencyRepairStarted:                "The missing and outdated objects are being republished",
+	ConsistencyDeleteOrphansConfirmTemplate: "{Count} orphaned objects will be deleted from the storage and can't be restored.",
+	ConsistencyDeleteOrphansStarted:         "The orphaned objects are being deleted",
+	ConsistencyNoOrphansSelected:            "No orphaned objects are selected",
 }
 
 var Messages_zh_CN = &Messages{
@@ -190,6 +446,124 @@ var Messages_zh_CN = &Messages{
 
 	HeaderDraftCount: "草稿数",
 	HeaderLive:       "发布状态",
+
+	SubmitForReview:             "提交审核",
+	Approve:                     "批准",
+	Reject:                      "驳回",
+	ReviewComment:               "备注",
+	RejectCommentRequired:       "驳回时请填写备注",
+	SuccessfullySubmitForReview: "已成功提交审核",
+	SuccessfullyApprove:         "已成功批准",
+	SuccessfullyReject:          "已成功驳回",
+	ReviewStatusPending:         "审核中",
+	ReviewStatusApproved:        "已批准",
+	ReviewStatusRejected:        "已驳回",
+	ReviewStatusWithdrawn:       "已撤回",
+	ReviewQueue:                 "审核队列",
+	FilterTabPendingReviews:     "待审核",
+	FilterTabAllReviews:         "全部",
+
+	Compare:                      "对比",
+	CompareVersionsTitleTemplate: "对比 {Old} 与 {New}",
+	DiffField:                    "字段",
+	NoDifferences:                "两个版本之间没有差异",
+
+	Rollback:                                "回滚",
+	RollbackVersionConfirmationTextTemplate: "将从版本 {VersionName} 创建一个新的草稿。",
+	PublishImmediately:                      "立即发布",
+	SuccessfullyRollback:                    "回滚成功",
+
+	UnpublishedDependencies: "以下记录尚未上线",
+	PublishWithDependencies: "全部发布",
+	LiveDependents:          "以下在线记录仍在使用它",
+	UnpublishAnyway:         "仍然下线",
+
+	Releases:                     "发布包",
+	Release:                      "发布包",
+	AddToRelease:                 "加入发布包",
+	ReleaseItems:                 "内容",
+	NoReleaseItems:               "发布包中没有内容",
+	NoDraftReleases:              "没有草稿状态的发布包",
+	PreviewRelease:               "预览发布包",
+	NoPreview:                    "无法预览",
+	ReleaseNameRequired:          "名称不能为空",
+	OnlyDraftReleaseCanBeChanged: "只能修改草稿状态的发布包",
+	SuccessfullyAddToRelease:     "已加入发布包",
+
+	DryRunDescription:   "存储中的以下对象将被修改",
+	DryRunNoActions:     "存储中没有需要修改的对象",
+	DryRunAction:        "操作",
+	DryRunURL:           "URL",
+	DryRunSize:          "大小",
+	DryRunCreate:        "新建",
+	DryRunWrite:         "覆盖",
+	DryRunUnchanged:     "无变化",
+	DryRunDelete:        "删除",
+	DryRunDeleteMissing: "不存在",
+	DryRunListPage:      "(列表页)",
+	DryRunShowDiff:      "查看差异",
+
+	PublishRuns:             "发布记录",
+	PublishRunStatusSuccess: "成功",
+	PublishRunStatusFailed:  "失败",
+	PublishRunStatusRetried: "已重试",
+	FilterTabFailedRuns:     "失败",
+	FilterTabAllRuns:        "全部",
+	Retry:                   "重试",
+	SuccessfullyRetry:       "重试成功",
+
+	Target:      "发布目标",
+	Promote:     "推送",
+	PromoteFrom: "来自",
+
+	NotOnlineOnTargets: "该记录未在其他发布目标上线",
+
+	Share:                     "分享",
+	NoShareLinks:              "暂无有效的分享链接",
+	ShareLinkExpiresAt:        "过期时间",
+	ShareLinkPassword:         "密码",
+	ShareLinkPasswordOptional: "可选",
+	CopyShareLink:             "复制",
+	ShareLinkCopied:           "链接已复制",
+	RevokeShareLink:           "撤销",
+	CreateShareLink:           "创建链接",
+	ShareLinkOneDay:           "1 天",
+	ShareLinkOneWeek:          "7 天",
+	ShareLinkOneMonth:         "30 天",
+	ShareLinkPasswordRequired: "此预览受密码保护",
+	ShareLinkWrongPassword:    "密码错误",
+	ShareLinkView:             "查看",
+
+	PurgeCDN:             "刷新 CDN",
+	SuccessfullyPurgeCDN: "已提交 CDN 刷新",
+
+	NotificationSchedulePublishFailed:   "{model} {keys} 的定时发布失败",
+	NotificationScheduleUnPublishFailed: "{model} {keys} 的定时下线失败",
+	NotificationReviewSubmitted:         "{model} {label} 等待你的审核",
+	NotificationReviewApproved:          "{model} {label} 已通过审核",
+	NotificationReviewRejected:          "{model} {label} 已被驳回",
+
+	CompareVersionsOfDifferentRecords: "只能对比同一记录的版本",
+
+	RollbackOnlyOffline: "只能回滚已下线的版本，在线版本已经生效，草稿可以直接发布",
+
+	Preview: "预览",
+
+	ConsistencyIssues:                       "存储问题",
+	ConsistencyKind:                         "问题",
+	ConsistencyUrl:                          "路径",
+	ConsistencyModel:                        "记录",
+	ConsistencyCheckedAt:                    "检查时间",
+	ConsistencyMissing:                      "缺失",
+	ConsistencyStale:                        "过期",
+	ConsistencyOrphan:                       "孤立",
+	CheckConsistency:                        "检查存储",
+	RepairConsistency:                       "重新发布缺失和过期的内容",
+	ConsistencyCheckStarted:                 "存储检查已开始，完成后将列出问题",
+	ConsistencyRepairStarted:                "正在重新发布缺失和过期的内容",
+	ConsistencyDeleteOrphansConfirmTemplate: "{Count} 个孤立对象将从存储中删除，且无法恢复。",
+	ConsistencyDeleteOrphansStarted:         "正在删除孤立对象",
+	ConsistencyNoOrphansSelected:            "未选择孤立对象",
 }
 
 var Messages_ja_JP = &Messages{
@@ -247,4 +621,122 @@ var Messages_ja_JP = &Messages{
 
 	HeaderDraftCount: "下書き数",
 	HeaderLive:       "公開ステータス",
+
+	SubmitForReview:             "レビューを依頼",
+	Approve:                     "承認",
+	Reject:                      "却下",
+	ReviewComment:               "コメント",
+	RejectCommentRequired:       "却下する場合はコメントを入力してください",
+	SuccessfullySubmitForReview: "レビューを依頼しました",
+	SuccessfullyApprove:         "承認しました",
+	SuccessfullyReject:          "却下しました",
+	ReviewStatusPending:         "レビュー中",
+	ReviewStatusApproved:        "承認済み",
+	ReviewStatusRejected:        "却下済み",
+	ReviewStatusWithdrawn:       "取り下げ",
+	ReviewQueue:                 "レビュー待ち",
+	FilterTabPendingReviews:     "未処理",
+	FilterTabAllReviews:         "すべて",
+
+	Compare:                      "比較",
+	CompareVersionsTitleTemplate: "{Old} と {New} の比較",
+	DiffField:                    "フィールド",
+	NoDifferences:                "2つのバージョンに違いはありません",
+
+	Rollback:                                "ロールバック",
+	RollbackVersionConfirmationTextTemplate: "バージョン {VersionName} から新しい下書きを作成します。",
+	PublishImmediately:                      "すぐに公開する",
+	SuccessfullyRollback:                    "ロールバックに成功しました",
+
+	UnpublishedDependencies: "以下のレコードはまだ公開されていません",
+	PublishWithDependencies: "すべて公開",
+	LiveDependents:          "以下の公開中のレコードがまだ使用しています",
+	UnpublishAnyway:         "それでも非公開にする",
+
+	Releases:                     "リリース",
+	Release:                      "リリース",
+	AddToRelease:                 "リリースに追加",
+	ReleaseItems:                 "アイテム",
+	NoReleaseItems:               "このリリースにアイテムはありません",
+	NoDraftReleases:              "下書きのリリースがありません",
+	PreviewRelease:               "リリースをプレビュー",
+	NoPreview:                    "プレビューできません",
+	ReleaseNameRequired:          "名前は必須です",
+	OnlyDraftReleaseCanBeChanged: "下書きのリリースのみ変更できます",
+	SuccessfullyAddToRelease:     "リリースに追加しました",
+
+	DryRunDescription:   "ストレージの次のオブジェクトが変更されます",
+	DryRunNoActions:     "ストレージに変更はありません",
+	DryRunAction:        "操作",
+	DryRunURL:           "URL",
+	DryRunSize:          "サイズ",
+	DryRunCreate:        "作成",
+	DryRunWrite:         "上書き",
+	DryRunUnchanged:     "変更なし",
+	DryRunDelete:        "削除",
+	DryRunDeleteMissing: "存在しません",
+	DryRunListPage:      "(リストページ)",
+	DryRunShowDiff:      "差分を表示",
+
+	PublishRuns:             "公開履歴",
+	PublishRunStatusSuccess: "成功",
+	PublishRunStatusFailed:  "失敗",
+	PublishRunStatusRetried: "再試行済み",
+	FilterTabFailedRuns:     "失敗",
+	FilterTabAllRuns:        "すべて",
+	Retry:                   "再試行",
+	SuccessfullyRetry:       "再試行に成功しました",
+
+	Target:      "公開先",
+	Promote:     "昇格",
+	PromoteFrom: "昇格元",
+
+	NotOnlineOnTargets: "このレコードは他の公開先で公開されていません",
+
+	Share:                     "共有",
+	NoShareLinks:              "有効な共有リンクはありません",
+	ShareLinkExpiresAt:        "有効期限",
+	ShareLinkPassword:         "パスワード",
+	ShareLinkPasswordOptional: "任意",
+	CopyShareLink:             "コピー",
+	ShareLinkCopied:           "リンクをコピーしました",
+	RevokeShareLink:           "取り消し",
+	CreateShareLink:           "リンクを作成",
+	ShareLinkOneDay:           "1日",
+	ShareLinkOneWeek:          "7日",
+	ShareLinkOneMonth:         "30日",
+	ShareLinkPasswordRequired: "このプレビューはパスワードで保護されています",
+	ShareLinkWrongPassword:    "パスワードが違います",
+	ShareLinkView:             "表示",
+
+	PurgeCDN:             "CDN をパージ",
+	SuccessfullyPurgeCDN: "CDN のパージを送信しました",
+
+	NotificationSchedulePublishFailed:   "{model} {keys} の予約公開に失敗しました",
+	NotificationScheduleUnPublishFailed: "{model} {keys} の予約非公開に失敗しました",
+	NotificationReviewSubmitted:         "{model} {label} があなたのレビューを待っています",
+	NotificationReviewApproved:          "{model} {label} が承認されました",
+	NotificationReviewRejected:          "{model} {label} が却下されました",
+
+	CompareVersionsOfDifferentRecords: "同じレコードのバージョンのみ比較できます",
+
+	RollbackOnlyOffline: "ロールバックできるのはオフラインのバージョンのみです。オンラインのバージョンは公開中で、下書きはそのまま公開できます",
+
+	Preview: "プレビュー",
+
+	ConsistencyIssues:                       "ストレージの問題",
+	ConsistencyKind:                         "問題",
+	ConsistencyUrl:                          "パス",
+	ConsistencyModel:                        "レコード",
+	ConsistencyCheckedAt:                    "チェック日時",
+	ConsistencyMissing:                      "欠落",
+	ConsistencyStale:                        "古い内容",
+	ConsistencyOrphan:                       "孤立",
+	CheckConsistency:                        "ストレージをチェック",
+	RepairConsistency:                       "欠落と古い内容を再公開",
+	ConsistencyCheckStarted:                 "ストレージのチェックを開始しました。完了後に問題が表示されます",
+	ConsistencyRepairStarted:                "欠落と古い内容を再公開しています",
+	ConsistencyDeleteOrphansConfirmTemplate: "{Count} 個の孤立したオブジェクトがストレージから削除され、元に戻せません。",
+	ConsistencyDeleteOrphansStarted:         "孤立したオブジェクトを削除しています",
+	ConsistencyNoOrphansSelected:            "孤立したオブジェクトが選択されていません",
 }
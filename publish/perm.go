@@ -12,6 +12,11 @@ const (
 	PermUnpublish = "publish:unpublish"
 	PermSchedule  = "publish:schedule"  // Prerequisite: PermPublish/PermUnpublish
 	PermDuplicate = "publish:duplicate" // Prerequisite: presets.PermUpdate
+
+	PermSubmitReview = "publish:submit_review"
+	PermReview       = "publish:review" // approve or reject
+
+	PermShareLink = "publish:share_link"
 )
 
 func DeniedDo(verifier *perm.Verifier, obj any, r *http.Request, actions ...string) bool {
@@ -1,11 +1,20 @@
 package publish
 
 import (
+	"cmp"
+	"errors"
+
+	"github.com/dustin/go-humanize"
+
 	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
 	"github.com/qor5/web/v3"
 	"github.com/qor5/x/v3/i18n"
 	"github.com/qor5/x/v3/perm"
 	v "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
 	"gorm.io/gorm"
 )
 
@@ -24,7 +33,25 @@ func publishAction(_ *gorm.DB, mb *presets.ModelBuilder, publisher *Builder, act
 		}
 
 		reqCtx := publisher.WithContextValues(ctx.R.Context())
+		if mode := dependencyModeFromParam(ctx); mode != "" {
+			reqCtx = WithDependencyMode(reqCtx, mode)
+		}
+		if target := ctx.R.FormValue(paramTarget); target != "" {
+			reqCtx = WithTarget(reqCtx, target)
+		}
 		err = publisher.Publish(reqCtx, obj)
+		var checkErr *PublishCheckError
+		if errors.As(err, &checkErr) {
+			presets.ShowMessage(&r, checkErr.Error(), "warning")
+			return r, nil
+		}
+		var depErr *UnpublishedDependenciesError
+		if errors.As(err, &depErr) {
+			msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+			showDependencyDialog(ctx, &r, mb, msgr.UnpublishedDependencies, msgr.PublishWithDependencies, DependencyModeAutoPublish,
+				lo.Map(depErr.Dependencies, func(d any, _ int) string { return recordLabel(d) }))
+			return r, nil
+		}
 		if err != nil {
 			return
 		}
@@ -62,7 +89,20 @@ func unpublishAction(_ *gorm.DB, mb *presets.ModelBuilder, publisher *Builder, a
 		}
 
 		reqCtx := publisher.WithContextValues(ctx.R.Context())
+		if mode := dependencyModeFromParam(ctx); mode != "" {
+			reqCtx = WithDependencyMode(reqCtx, mode)
+		}
+		if target := ctx.R.FormValue(paramTarget); target != "" {
+			reqCtx = WithTarget(reqCtx, target)
+		}
 		err = publisher.UnPublish(reqCtx, obj)
+		var depErr *LiveDependentsError
+		if errors.As(err, &depErr) {
+			msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+			showDependencyDialog(ctx, &r, mb, msgr.LiveDependents, msgr.UnpublishAnyway, DependencyModeIgnore,
+				lo.Map(depErr.Dependents, func(d *Dependency, _ int) string { return d.Label() }))
+			return r, nil
+		}
 		if err != nil {
 			return
 		}
@@ -80,3 +120,179 @@ func unpublishAction(_ *gorm.DB, mb *presets.ModelBuilder, publisher *Builder, a
 		return
 	}
 }
+
+const paramDependencyMode = "dependency_mode"
+
+func dependencyModeFromParam(ctx *web.EventContext) DependencyMode {
+	switch mode := DependencyMode(ctx.R.FormValue(paramDependencyMode)); mode {
+	case DependencyModeAutoPublish, DependencyModeIgnore:
+		return mode
+	}
+	return ""
+}
+
+// showDependencyDialog lists the records blocking the action, confirming posts the same event again with mode
+func showDependencyDialog(ctx *web.EventContext, r *web.EventResponse, mb *presets.ModelBuilder, title string, okText string, mode DependencyMode, labels []string) {
+	utilMsgr := i18n.MustGetModuleMessages(ctx.R, utils.I18nUtilsKey, utils.Messages_en_US).(*utils.Messages)
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+		Name: presets.DialogPortalName,
+		Body: web.Scope(
+			vx.VXDialog(
+				v.VList(lo.Map(labels, func(label string, _ int) h.HTMLComponent {
+					return v.VListItem(v.VListItemTitle(h.Text(label)))
+				})...).Density(v.DensityCompact),
+			).Title(title).
+				Type("warn").
+				CancelText(utilMsgr.Cancel).
+				OkText(okText).
+				Attr("@click:ok", web.Plaid().
+					EventFunc(ctx.R.FormValue(web.EventFuncIDName)).
+					URL(mb.Info().ListingHref()).
+					Queries(ctx.Queries()).
+					Query(paramDependencyMode, string(mode)).
+					ThenScript("locals.dependencyDialog = false").
+					Go()).
+				Attr("v-model", "locals.dependencyDialog"),
+		).Init("{dependencyDialog:true}").VSlot("{locals}"),
+	})
+}
+
+const (
+	paramPublishEvent = "publish_event"
+	paramTarget       = "publish_target"
+)
+
+// publishDryRunDialog lists the storage actions of the publish event before running it
+func publishDryRunDialog(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		slug := ctx.Param(presets.ParamID)
+		obj := mb.NewModel()
+		obj, err = mb.Editing().Fetcher(obj, slug, ctx)
+		if err != nil {
+			return
+		}
+
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		event := ctx.R.FormValue(paramPublishEvent)
+		target := cmp.Or(ctx.R.FormValue(paramTarget), DefaultTarget)
+		reqCtx := WithTarget(ctx.R.Context(), target)
+		var (
+			title   string
+			actions []*DryRunAction
+		)
+		switch event {
+		case EventPublish, EventRepublish:
+			if DeniedDo(mb.Info().Verifier(), obj, ctx.R, PermPublish) {
+				return r, perm.PermissionDenied
+			}
+			title = lo.Ternary(event == EventPublish, msgr.Publish, msgr.Republish)
+			actions, err = publisher.DryRunPublish(reqCtx, obj)
+		case EventUnpublish:
+			if DeniedDo(mb.Info().Verifier(), obj, ctx.R, PermUnpublish) {
+				return r, perm.PermissionDenied
+			}
+			title = msgr.Unpublish
+			actions, err = publisher.DryRunUnPublish(reqCtx, obj)
+		default:
+			return r, errInvalidObject
+		}
+		if err != nil {
+			return
+		}
+
+		utilMsgr := i18n.MustGetModuleMessages(ctx.R, utils.I18nUtilsKey, utils.Messages_en_US).(*utils.Messages)
+		r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+			Name: presets.DialogPortalName,
+			Body: web.Scope(
+				vx.VXDialog(
+					targetSelect(mb, publisher, slug, event, target, msgr),
+					h.Div(h.Text(msgr.DryRunDescription)).Class("mb-2"),
+					dryRunActionsTable(actions, msgr),
+				).Title(title).
+					Width(900).
+					CancelText(utilMsgr.Cancel).
+					OkText(title).
+					Attr("@click:ok", web.Plaid().
+						EventFunc(event).
+						Query(presets.ParamID, slug).
+						Query(paramTarget, target).
+						URL(mb.Info().ListingHref()).
+						ThenScript("locals.publishDryRunDialog = false").
+						Go()).
+					Attr("v-model", "locals.publishDryRunDialog"),
+			).Init("{publishDryRunDialog:true}").VSlot("{locals}"),
+		})
+		return
+	}
+}
+
+// targetSelect reopens the dry run on the chosen target, it is hidden without targets
+func targetSelect(mb *presets.ModelBuilder, publisher *Builder, slug, event, target string, msgr *Messages) h.HTMLComponent {
+	if len(publisher.targets) == 0 {
+		return nil
+	}
+	return v.VSelect().
+		Label(msgr.Target).
+		Items(publisher.TargetNames()).
+		ModelValue(target).
+		Density(v.DensityCompact).
+		Variant(v.VariantOutlined).
+		HideDetails(true).
+		Class("mb-4").
+		Attr("@update:model-value", web.Plaid().
+			EventFunc(eventPublishDryRunDialog).
+			Query(presets.ParamID, slug).
+			Query(paramPublishEvent, event).
+			Query(paramTarget, web.Var("$event")).
+			URL(mb.Info().ListingHref()).
+			Go())
+}
+
+func dryRunActionsTable(actions []*DryRunAction, msgr *Messages) h.HTMLComponent {
+	if len(actions) == 0 {
+		return h.Div(h.Text(msgr.DryRunNoActions)).Class("text-medium-emphasis")
+	}
+	rows := lo.Map(actions, func(a *DryRunAction, _ int) h.HTMLComponent {
+		text, color := msgr.DryRunWrite, v.ColorPrimary
+		switch {
+		case a.IsDelete && !a.Exists:
+			text, color = msgr.DryRunDeleteMissing, v.ColorSecondary
+		case a.IsDelete:
+			text, color = msgr.DryRunDelete, v.ColorError
+		case !a.Exists:
+			text, color = msgr.DryRunCreate, v.ColorSuccess
+		case !a.Changed:
+			text, color = msgr.DryRunUnchanged, v.ColorSecondary
+		}
+		url := h.Div(h.Text(a.Url)).Class("text-break")
+		if a.List {
+			url.AppendChildren(h.Span(msgr.DryRunListPage).Class("text-caption text-medium-emphasis ml-1"))
+		}
+		if a.Diff != "" {
+			url.AppendChildren(h.Details(
+				h.Summary(h.Text(msgr.DryRunShowDiff)).Class("text-caption"),
+				h.Pre(a.Diff).Class("text-caption").Style("max-height:320px;overflow:auto;"),
+			))
+		}
+		return h.Tr(
+			h.Td(v.VChip(h.Span(text)).Density(v.DensityCompact).Color(color).Size(v.SizeSmall)),
+			h.Td(url),
+			h.Td(h.Text(humanize.Bytes(uint64(a.Size)))).Class("text-no-wrap"),
+		)
+	})
+	return v.VTable(
+		h.Thead(h.Tr(
+			h.Th(msgr.DryRunAction),
+			h.Th(msgr.DryRunURL),
+			h.Th(msgr.DryRunSize),
+		)),
+		h.Tbody(rows...),
+	).Density(v.DensityCompact)
+}
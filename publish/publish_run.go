@@ -0,0 +1,197 @@
+package publish
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	PublishRunStatusSuccess = "success"
+	PublishRunStatusFailed  = "failed"
+
+	PublishRunActionPublish   = "publish"
+	PublishRunActionUnPublish = "unpublish"
+
+	PublishRunActionStatusDone     = "done"
+	PublishRunActionStatusFailed   = "failed"
+	PublishRunActionStatusSkipped  = "skipped"
+	PublishRunActionStatusReverted = "reverted"
+)
+
+// PublishRun records one call of Publish or UnPublish with the outcome of each storage action
+type PublishRun struct {
+	gorm.Model
+
+	Action     string `gorm:"index"`
+	Target     string
+	ModelName  string `gorm:"index:idx_publish_runs_model"`
+	ModelKeys  string `gorm:"index:idx_publish_runs_model"`
+	ModelLabel string
+	UserID     string
+	Status     string `gorm:"index"`
+	Error      string
+	StartedAt  time.Time
+	DurationMs int64
+	Actions    []*PublishRunAction `gorm:"serializer:json;type:json"`
+	RetryOfID  uint
+	RetriedAt  *time.Time
+}
+
+func (PublishRun) TableName() string {
+	return "publish_runs"
+}
+
+type PublishRunAction struct {
+	Url      string
+	IsDelete bool
+	Size     int
+	Status   string
+	Error    string `json:",omitempty"`
+}
+
+type (
+	ctxKeyPublishRun        struct{}
+	ctxKeyPublishRunRetryOf struct{}
+)
+
+// EnablePublishRuns stores a PublishRun for every publish and unpublish and adds the listing of the runs
+func (b *Builder) EnablePublishRuns(v bool) (r *Builder) {
+	b.publishRuns = v
+	return b
+}
+
+func (b *Builder) migratePublishRuns() error {
+	b.publishRunMigrateOnce.Do(func() {
+		b.publishRunMigrateErr = b.db.AutoMigrate(&PublishRun{})
+	})
+	return b.publishRunMigrateErr
+}
+
+func publishRunFromContext(ctx context.Context) *PublishRun {
+	run, _ := ctx.Value(ctxKeyPublishRun{}).(*PublishRun)
+	return run
+}
+
+// addActions records the outcome of objs, the actions after the failed one are skipped
+func (run *PublishRun) addActions(objs []*PublishAction, done int, err error) {
+	for i, obj := range objs {
+		action := &PublishRunAction{
+			Url:      obj.Url,
+			IsDelete: obj.IsDelete,
+			Size:     len(obj.Content),
+			Status:   PublishRunActionStatusDone,
+		}
+		switch {
+		case i == done && err != nil:
+			action.Status = PublishRunActionStatusFailed
+			action.Error = err.Error()
+		case i >= done:
+			action.Status = PublishRunActionStatusSkipped
+		}
+		run.Actions = append(run.Actions, action)
+	}
+}
+
+func (run *PublishRun) markReverted() {
+	for _, action := range run.Actions {
+		if action.Status == PublishRunActionStatusDone {
+			action.Status = PublishRunActionStatusReverted
+		}
+	}
+}
+
+// track runs f with a storage journal, the objects f created are deleted when it fails. When publish runs
+// are enabled the run is stored and the objects f changed are put back as well
+func (b *Builder) track(ctx context.Context, action string, record any, f func(ctx context.Context) error) (err error) {
+	ctx, journal, ownJournal := withStorageJournal(ctx, b.publishRuns)
+
+	var run *PublishRun
+	if b.publishRuns && publishRunFromContext(ctx) == nil {
+		run = &PublishRun{
+			Action:    action,
+			Target:    TargetFromContext(ctx),
+			ModelName: utils.GetObjectName(record),
+			UserID:    b.currentUserID(ctx),
+			StartedAt: b.db.NowFunc(),
+		}
+		if slugger, ok := record.(presets.SlugEncoder); ok {
+			run.ModelKeys = slugger.PrimarySlug()
+		}
+		if version, ok := record.(VersionInterface); ok {
+			run.ModelLabel = version.EmbedVersion().VersionName
+		}
+		run.RetryOfID, _ = ctx.Value(ctxKeyPublishRunRetryOf{}).(uint)
+		ctx = context.WithValue(ctx, ctxKeyPublishRun{}, run)
+	}
+
+	err = f(ctx)
+	if err != nil && ownJournal {
+		if rerr := journal.revert(ctx); rerr != nil {
+			log.Printf("error: %s\n", rerr)
+		} else if run != nil && journal.revertible {
+			run.markReverted()
+		}
+	}
+	if err == nil && ownJournal {
+		b.purgeJournal(ctx, journal)
+	}
+	if run != nil {
+		b.savePublishRun(run, err)
+	}
+	return
+}
+
+func (b *Builder) savePublishRun(run *PublishRun, err error) {
+	run.DurationMs = b.db.NowFunc().Sub(run.StartedAt).Milliseconds()
+	run.Status = PublishRunStatusSuccess
+	if err != nil {
+		run.Status = PublishRunStatusFailed
+		run.Error = err.Error()
+	}
+	// the run is kept even when the transaction of the publishing rolls back
+	if merr := b.migratePublishRuns(); merr != nil {
+		log.Printf("error: %s\n", merr)
+		return
+	}
+	if serr := b.db.Create(run).Error; serr != nil {
+		log.Printf("error: %s\n", serr)
+	}
+}
+
+// RetryPublishRun runs the action of the failed run again on the current data of the record,
+// a publishing is retried only when the record passes CheckPublish, whatever its target
+func (b *Builder) RetryPublishRun(ctx context.Context, run *PublishRun) error {
+	mb, ok := b.publishRunModels[run.ModelName]
+	if !ok {
+		return errInvalidObject
+	}
+	obj := mb.NewModel()
+	if err := utils.PrimarySluggerWhere(b.db, obj, run.ModelKeys).First(obj).Error; err != nil {
+		return err
+	}
+
+	ctx = context.WithValue(b.WithContextValues(ctx), ctxKeyPublishRunRetryOf{}, run.ID)
+	if run.Target != "" {
+		ctx = WithTarget(ctx, run.Target)
+	}
+	var err error
+	if run.Action == PublishRunActionUnPublish {
+		err = b.UnPublish(ctx, obj)
+	} else if err = b.CheckPublish(ctx, obj); err != nil {
+		err = &PublishCheckError{Err: err}
+	} else {
+		err = b.Publish(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+	now := b.db.NowFunc()
+	run.RetriedAt = &now
+	return b.db.Model(run).Update("retried_at", run.RetriedAt).Error
+}
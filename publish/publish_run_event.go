@@ -0,0 +1,199 @@
+package publish
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/dustin/go-humanize"
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	v "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
+
+	"github.com/qor5/admin/v3/presets"
+)
+
+func (b *Builder) configurePublishRuns(pb *presets.Builder) error {
+	if err := b.migratePublishRuns(); err != nil {
+		return err
+	}
+
+	mb := pb.Model(&PublishRun{}).URIName("publish-runs").MenuIcon("mdi-history")
+	mb.LabelName(func(evCtx *web.EventContext, singular bool) string {
+		msgr := i18n.MustGetModuleMessages(evCtx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return msgr.PublishRuns
+	})
+
+	eb := mb.Editing()
+	eb.SaveFunc(func(obj any, id string, ctx *web.EventContext) error {
+		return errors.New("should not be used")
+	})
+	eb.DeleteFunc(func(obj any, id string, ctx *web.EventContext) error {
+		return errors.New("should not be used")
+	})
+
+	lb := mb.Listing("CreatedAt", "Action", "ModelName", "ModelLabel", "UserID", "Status", "DurationMs", "Actions", "Error")
+	lb.NewButtonFunc(func(ctx *web.EventContext) h.HTMLComponent { return nil })
+	lb.Field("CreatedAt").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return h.Td(h.Text(obj.(*PublishRun).CreatedAt.Local().Format(timeFormatSchedule)))
+	})
+	lb.Field("Action").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return h.Td(h.Text(lo.Ternary(obj.(*PublishRun).Action == PublishRunActionUnPublish, msgr.Unpublish, msgr.Publish)))
+	})
+	lb.Field("ModelName").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return h.Td(h.Text(i18n.T(ctx.R, presets.ModelsI18nModuleKey, obj.(*PublishRun).ModelName)))
+	})
+	lb.Field("ModelLabel").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		run := obj.(*PublishRun)
+		label := cmp.Or(run.ModelLabel, run.ModelKeys)
+		if rmb, ok := b.publishRunModels[run.ModelName]; ok && run.ModelKeys != "" {
+			return h.Td(h.A(h.Text(label)).Href(rmb.Info().DetailingHref(run.ModelKeys)).Attr("@click.stop", ""))
+		}
+		return h.Td(h.Text(label))
+	})
+	lb.Field("Status").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return h.Td(publishRunChip(obj.(*PublishRun), msgr))
+	})
+	lb.Field("DurationMs").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return h.Td(h.Text(fmt.Sprintf("%d ms", obj.(*PublishRun).DurationMs)))
+	})
+	lb.Field("Actions").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return h.Td(publishRunActions(obj.(*PublishRun), msgr)).Attr("@click.stop", "")
+	})
+
+	lb.RowMenu().Empty()
+	lb.RowMenu().RowMenuItem("Retry").ComponentFunc(func(obj interface{}, id string, ctx *web.EventContext) h.HTMLComponent {
+		run := obj.(*PublishRun)
+		if run.Status != PublishRunStatusFailed || run.RetriedAt != nil {
+			return nil
+		}
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return v.VListItem(
+			web.Slot(v.VIcon("mdi-refresh")).Name("prepend"),
+			v.VListItemTitle(h.Text(msgr.Retry)),
+		).Attr("@click", web.Plaid().
+			EventFunc(eventRetryPublishRun).
+			Query(presets.ParamID, id).
+			URL(mb.Info().ListingHref()).
+			Go())
+	})
+	mb.RegisterEventFunc(eventRetryPublishRun, b.retryPublishRun(mb))
+
+	lb.FilterDataFunc(func(ctx *web.EventContext) vx.FilterData {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return []*vx.FilterItem{
+			{
+				Key:          "status",
+				Label:        msgr.HeaderStatus,
+				ItemType:     vx.ItemTypeSelect,
+				SQLCondition: `status %s ?`,
+				Options: []*vx.SelectItem{
+					{Text: msgr.PublishRunStatusSuccess, Value: PublishRunStatusSuccess},
+					{Text: msgr.PublishRunStatusFailed, Value: PublishRunStatusFailed},
+				},
+			},
+		}
+	})
+	lb.FilterTabsFunc(func(ctx *web.EventContext) []*presets.FilterTab {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return []*presets.FilterTab{
+			{
+				Label: msgr.FilterTabFailedRuns,
+				Query: url.Values{"status": []string{PublishRunStatusFailed}},
+			},
+			{
+				Label: msgr.FilterTabAllRuns,
+				Query: url.Values{},
+			},
+		}
+	})
+	return nil
+}
+
+func publishRunChip(run *PublishRun, msgr *Messages) h.HTMLComponent {
+	text, color := msgr.PublishRunStatusSuccess, v.ColorSuccess
+	if run.Status == PublishRunStatusFailed {
+		text, color = msgr.PublishRunStatusFailed, v.ColorError
+		if run.RetriedAt != nil {
+			text, color = msgr.PublishRunStatusRetried, v.ColorSecondary
+		}
+	}
+	return v.VChip(h.Span(text)).Density(v.DensityCompact).Color(color).Size(v.SizeSmall)
+}
+
+func publishRunActions(run *PublishRun, msgr *Messages) h.HTMLComponent {
+	if len(run.Actions) == 0 {
+		return nil
+	}
+	done := lo.CountBy(run.Actions, func(a *PublishRunAction) bool {
+		return a.Status == PublishRunActionStatusDone
+	})
+	rows := lo.Map(run.Actions, func(a *PublishRunAction, _ int) h.HTMLComponent {
+		color := v.ColorSuccess
+		switch a.Status {
+		case PublishRunActionStatusFailed:
+			color = v.ColorError
+		case PublishRunActionStatusSkipped, PublishRunActionStatusReverted:
+			color = v.ColorSecondary
+		}
+		return h.Tr(
+			h.Td(v.VChip(h.Span(a.Status)).Density(v.DensityCompact).Color(color).Size(v.SizeSmall)),
+			h.Td(h.Text(lo.Ternary(a.IsDelete, msgr.DryRunDelete, msgr.DryRunWrite))),
+			h.Td(h.Text(a.Url)).Class("text-break"),
+			h.Td(h.Text(humanize.Bytes(uint64(a.Size)))).Class("text-no-wrap"),
+			h.Td(h.Text(a.Error)),
+		)
+	})
+	return h.Details(
+		h.Summary(h.Text(fmt.Sprintf("%d/%d", done, len(run.Actions)))),
+		v.VTable(h.Tbody(rows...)).Density(v.DensityCompact),
+	)
+}
+
+func (b *Builder) retryPublishRun(mb *presets.ModelBuilder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		run := &PublishRun{}
+		if err = b.db.First(run, ctx.Param(presets.ParamID)).Error; err != nil {
+			return
+		}
+		rmb, ok := b.publishRunModels[run.ModelName]
+		if !ok {
+			return r, errInvalidObject
+		}
+		obj := rmb.NewModel()
+		if obj, err = rmb.Editing().Fetcher(obj, run.ModelKeys, ctx); err != nil {
+			return
+		}
+		if DeniedDo(rmb.Info().Verifier(), obj, ctx.R, lo.Ternary(run.Action == PublishRunActionUnPublish, PermUnpublish, PermPublish)) {
+			return r, perm.PermissionDenied
+		}
+
+		err = b.RetryPublishRun(ctx.R.Context(), run)
+		// the new run shows up in the listing whatever the outcome is
+		r.Emit(mb.NotifModelsUpdated(), presets.PayloadModelsUpdated{
+			Ids:    []string{fmt.Sprint(run.ID)},
+			Models: map[string]any{fmt.Sprint(run.ID): run},
+		})
+		if err != nil {
+			return
+		}
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		presets.ShowMessage(&r, msgr.SuccessfullyRetry, "")
+		return
+	}
+}
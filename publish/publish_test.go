@@ -2,19 +2,32 @@ package publish_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/presets/gorm2op"
 	"github.com/qor5/admin/v3/publish"
+	"github.com/qor5/web/v3"
 	"github.com/qor5/x/v3/oss"
 	"github.com/stretchr/testify/require"
 	"github.com/theplant/sliceutils"
 	"github.com/theplant/testenv"
 	"github.com/theplant/testingutils"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
@@ -111,6 +124,14 @@ func (p *ProductWithoutVersion) getUrl() string {
 	return fmt.Sprintf("test/product_no_version/%s/index.html", p.Code)
 }
 
+func (p *ProductWithoutVersion) PrimarySlug() string {
+	return fmt.Sprint(p.ID)
+}
+
+func (p *ProductWithoutVersion) PrimaryColumnValuesBySlug(slug string) map[string]string {
+	return map[string]string{"id": slug}
+}
+
 func (p *ProductWithoutVersion) GetPublishActions(ctx context.Context, db *gorm.DB, storage oss.StorageInterface) (actions []*publish.PublishAction, err error) {
 	actions = append(actions, &publish.PublishAction{
 		Url:      p.getUrl(),
@@ -188,6 +209,14 @@ func (m *MockStorage) Get(ctx context.Context, path string) (f *os.File, err err
 	return
 }
 
+func (m *MockStorage) GetStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	content, exist := m.Objects[path]
+	if !exist {
+		return nil, fmt.Errorf("NoSuchKey: %s: %w", path, fs.ErrNotExist)
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
 func (m *MockStorage) Put(ctx context.Context, path string, r io.Reader) (*oss.Object, error) {
 	fmt.Println("Calling mock s3 client - Put: ", path)
 	b, err := io.ReadAll(r)
@@ -208,6 +237,16 @@ func (m *MockStorage) Delete(ctx context.Context, path string) error {
 	return nil
 }
 
+func (m *MockStorage) List(ctx context.Context, path string) (objects []*oss.Object, err error) {
+	for p := range m.Objects {
+		if strings.HasPrefix(p, path) {
+			objects = append(objects, &oss.Object{Path: p})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	return
+}
+
 var TestDB *gorm.DB
 
 func TestMain(m *testing.M) {
@@ -656,6 +695,55 @@ func TestSchedulePublish(t *testing.T) {
 	}
 }
 
+func TestSchedulerLease(t *testing.T) {
+	db := TestDB
+	publisher := publish.New(db, &MockStorage{})
+
+	s1 := publish.NewScheduler(publisher).Name("test-scheduler").Holder("replica-1")
+	s2 := publish.NewScheduler(publisher).Name("test-scheduler").Holder("replica-2")
+	require.NoError(t, s1.AutoMigrate())
+	db.Where("name = ?", "test-scheduler").Delete(&publish.SchedulerLease{})
+
+	leader, err := s1.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.True(t, leader)
+
+	leader, err = s2.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.False(t, leader)
+
+	// the leader keeps renewing its lease
+	leader, err = s1.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.True(t, leader)
+
+	// another replica takes over an expired lease
+	require.NoError(t, db.Model(&publish.SchedulerLease{}).Where("name = ?", "test-scheduler").
+		Update("expires_at", db.NowFunc().Add(-time.Minute)).Error)
+	leader, err = s2.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.True(t, leader)
+}
+
+func TestSchedulerLeaseSQLite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "scheduler.db")), &gorm.Config{})
+	require.NoError(t, err)
+	publisher := publish.New(db, &MockStorage{})
+
+	// CURRENT_TIMESTAMP is text in SQLite
+	s1 := publish.NewScheduler(publisher).Name("test-scheduler").Holder("replica-1")
+	s2 := publish.NewScheduler(publisher).Name("test-scheduler").Holder("replica-2")
+	require.NoError(t, s1.AutoMigrate())
+
+	leader, err := s1.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.True(t, leader)
+
+	leader, err = s2.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.False(t, leader)
+}
+
 func TestPublishContentWithoutVersionToS3(t *testing.T) {
 	db := TestDB
 	db.AutoMigrate(&ProductWithoutVersion{})
@@ -796,3 +884,983 @@ func assertUploadFile(t *testing.T, content string, url string, storage oss.Stor
 		t.Error(diff)
 	}
 }
+
+type ProductBundle struct {
+	gorm.Model
+	Name      string
+	ProductID uint
+
+	publish.Status
+}
+
+func (p *ProductBundle) GetPublishActions(ctx context.Context, db *gorm.DB, storage oss.StorageInterface) (actions []*publish.PublishAction, err error) {
+	p.OnlineUrl = fmt.Sprintf("test/bundle/%d/index.html", p.ID)
+	actions = append(actions, &publish.PublishAction{
+		Url:     p.OnlineUrl,
+		Content: p.Name,
+	})
+	return
+}
+
+func (p *ProductBundle) GetUnPublishActions(ctx context.Context, db *gorm.DB, storage oss.StorageInterface) (actions []*publish.PublishAction, err error) {
+	actions = append(actions, &publish.PublishAction{
+		Url:      p.OnlineUrl,
+		IsDelete: true,
+	})
+	return
+}
+
+func (p *ProductBundle) PrimarySlug() string {
+	return fmt.Sprint(p.ID)
+}
+
+func (p *ProductBundle) PrimaryColumnValuesBySlug(slug string) map[string]string {
+	return map[string]string{"id": slug}
+}
+
+func (p *ProductBundle) PublishDependencies(ctx context.Context, db *gorm.DB) ([]any, error) {
+	product := &ProductWithoutVersion{}
+	if err := db.First(product, p.ProductID).Error; err != nil {
+		return nil, err
+	}
+	return []any{product}, nil
+}
+
+func TestPublishDependencies(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}, &ProductBundle{}))
+	storage := &MockStorage{}
+	ctx := context.Background()
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 10},
+		Code:   "0010",
+		Name:   "coffee",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+	bundle := ProductBundle{
+		Model:     gorm.Model{ID: 1},
+		Name:      "breakfast",
+		ProductID: product.ID,
+		Status:    publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&bundle)
+
+	p := publish.New(db, storage)
+
+	var depErr *publish.UnpublishedDependenciesError
+	require.ErrorAs(t, p.Publish(ctx, &bundle), &depErr)
+	require.Len(t, depErr.Dependencies, 1)
+
+	require.NoError(t, p.Publish(publish.WithDependencyMode(ctx, publish.DependencyModeAutoPublish), &bundle))
+	assertNoVersionUpdateStatus(t, db, &product, publish.StatusOnline, product.getUrl())
+	require.Equal(t, "breakfast", storage.Objects["test/bundle/1/index.html"])
+
+	require.NoError(t, db.First(&product, product.ID).Error)
+	var dependentsErr *publish.LiveDependentsError
+	require.ErrorAs(t, p.UnPublish(ctx, &product), &dependentsErr)
+	require.Len(t, dependentsErr.Dependents, 1)
+
+	require.NoError(t, p.UnPublish(ctx, &bundle))
+	require.NoError(t, p.UnPublish(ctx, &product))
+}
+
+type ProductVersionBundle struct {
+	gorm.Model
+	Name string
+
+	publish.Status
+}
+
+func (p *ProductVersionBundle) GetPublishActions(ctx context.Context, db *gorm.DB, storage oss.StorageInterface) (actions []*publish.PublishAction, err error) {
+	return
+}
+
+func (p *ProductVersionBundle) GetUnPublishActions(ctx context.Context, db *gorm.DB, storage oss.StorageInterface) (actions []*publish.PublishAction, err error) {
+	return
+}
+
+// PublishDependencies refers to the latest version of the product, whatever its status
+func (p *ProductVersionBundle) PublishDependencies(ctx context.Context, db *gorm.DB) ([]any, error) {
+	product := &ProductVersion{}
+	if err := db.Where("id = ?", 70).Order("version DESC").First(product).Error; err != nil {
+		return nil, err
+	}
+	return []any{product}, nil
+}
+
+func TestPublishDependenciesOnlineVersion(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductVersion{}, &ProductVersionBundle{}))
+	db.Exec("DELETE FROM product_versions WHERE id = 70")
+	ctx := publish.WithDependencyMode(context.Background(), publish.DependencyModeAutoPublish)
+
+	for _, version := range []ProductVersion{
+		{Model: gorm.Model{ID: 70}, Name: "live", Version: publish.Version{Version: "2024-02-01-v01"}, Status: publish.Status{Status: publish.StatusOnline}},
+		{Model: gorm.Model{ID: 70}, Name: "wip", Version: publish.Version{Version: "2024-02-02-v01"}, Status: publish.Status{Status: publish.StatusDraft}},
+	} {
+		require.NoError(t, db.Create(&version).Error)
+	}
+	bundle := ProductVersionBundle{Model: gorm.Model{ID: 70}, Name: "set", Status: publish.Status{Status: publish.StatusDraft}}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&bundle)
+
+	p := publish.New(db, &MockStorage{Objects: map[string]string{}})
+	deps, err := p.UnpublishedDependencies(ctx, &bundle)
+	require.NoError(t, err)
+	require.Empty(t, deps, "the online version satisfies the dependency")
+
+	// the draft is not published over the online version
+	require.NoError(t, p.Publish(ctx, &bundle))
+	var statuses []string
+	db.Model(&ProductVersion{}).Where("id = 70").Order("version").Pluck("status", &statuses)
+	require.Equal(t, []string{publish.StatusOnline, publish.StatusDraft}, statuses)
+}
+
+func TestPublishRelease(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}, &ProductBundle{}, &publish.Release{}, &publish.ReleaseItem{}))
+	storage := &MockStorage{}
+	ctx := context.Background()
+
+	p := publish.New(db, storage).EnableReleases(true)
+	pb := presets.New()
+	require.NoError(t, p.ModelInstall(pb, pb.Model(&ProductWithoutVersion{})))
+	require.NoError(t, p.ModelInstall(pb, pb.Model(&ProductBundle{})))
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 20},
+		Code:   "0020",
+		Name:   "tea",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+	bundle := ProductBundle{
+		Model:     gorm.Model{ID: 2},
+		Name:      "afternoon",
+		ProductID: product.ID,
+		Status:    publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&bundle)
+
+	rel := &publish.Release{Name: "launch"}
+	require.NoError(t, db.Create(rel).Error)
+	require.NoError(t, p.AddToRelease(ctx, rel.ID, &bundle))
+
+	// the product is not in the release, the upload of the bundle is put back
+	require.Error(t, p.PublishRelease(ctx, rel))
+	require.NotContains(t, storage.Objects, "test/bundle/2/index.html")
+	require.NoError(t, db.First(&bundle, bundle.ID).Error)
+	require.Equal(t, publish.StatusDraft, bundle.Status.Status)
+	require.NoError(t, db.First(rel, rel.ID).Error)
+	require.Equal(t, publish.ReleaseStatusDraft, rel.Status)
+	require.NotEmpty(t, rel.LastError)
+
+	require.NoError(t, p.AddToRelease(ctx, rel.ID, &product))
+	require.NoError(t, p.PublishRelease(ctx, rel))
+	require.Equal(t, "afternoon", storage.Objects["test/bundle/2/index.html"])
+	assertNoVersionUpdateStatus(t, db, &product, publish.StatusOnline, product.getUrl())
+	require.NoError(t, db.First(rel, rel.ID).Error)
+	require.Equal(t, publish.ReleaseStatusOnline, rel.Status)
+	require.Empty(t, rel.LastError)
+
+	require.NoError(t, p.UnPublishRelease(ctx, rel))
+	require.NotContains(t, storage.Objects, "test/bundle/2/index.html")
+	require.NoError(t, db.First(&product, product.ID).Error)
+	require.Equal(t, publish.StatusOffline, product.Status.Status)
+}
+
+func TestDryRunPublish(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	storage := &MockStorage{Objects: map[string]string{}}
+	ctx := context.Background()
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 30},
+		Code:   "0030",
+		Name:   "juice",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+
+	p := publish.New(db, storage)
+	actions, err := p.DryRunPublish(ctx, &product)
+	require.NoError(t, err)
+	require.NotEmpty(t, actions)
+	require.Equal(t, product.getUrl(), actions[0].Url)
+	require.False(t, actions[0].Exists)
+	require.True(t, actions[0].Changed)
+	require.True(t, actions[len(actions)-1].List)
+	require.Empty(t, storage.Objects)
+	require.Equal(t, publish.StatusDraft, product.Status.Status)
+	assertNoVersionUpdateStatus(t, db, &product, publish.StatusDraft, "")
+
+	require.NoError(t, p.Publish(ctx, &product))
+	product.Name = "orange juice"
+	actions, err = p.DryRunPublish(ctx, &product)
+	require.NoError(t, err)
+	require.True(t, actions[0].Exists)
+	require.True(t, actions[0].Changed)
+	require.Contains(t, actions[0].Diff, "+0030orange juice")
+	require.Equal(t, "0030juice", storage.Objects[product.getUrl()])
+}
+
+type failingStorage struct {
+	*MockStorage
+	failPath string
+}
+
+func (s *failingStorage) Delete(ctx context.Context, path string) error {
+	if path == s.failPath {
+		return fmt.Errorf("failed to delete %s", path)
+	}
+	return s.MockStorage.Delete(ctx, path)
+}
+
+// unreadableStorage cannot read the objects, like a storage timing out
+type unreadableStorage struct {
+	*MockStorage
+}
+
+func (s *unreadableStorage) GetStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("read %s: connection reset", path)
+}
+
+func TestPublishUnreadableStorage(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	storage := &unreadableStorage{MockStorage: &MockStorage{Objects: map[string]string{}}}
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 46},
+		Code:   "0046",
+		Name:   "unreadable",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+
+	// the previous content could not be put back on a rollback, so nothing is written
+	require.ErrorContains(t, publish.New(db, storage).EnablePublishRuns(true).Publish(context.Background(), &product), "connection reset")
+	require.Empty(t, storage.Objects)
+	var reloaded ProductWithoutVersion
+	require.NoError(t, db.First(&reloaded, product.ID).Error)
+	require.Equal(t, publish.StatusDraft, reloaded.Status.Status)
+
+	// without publish runs the previous content is not read
+	require.NoError(t, publish.New(db, storage).Publish(context.Background(), &product))
+	require.Equal(t, product.getContent(), storage.Objects[product.getUrl()])
+}
+
+func TestPublishRun(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	storage := &failingStorage{
+		MockStorage: &MockStorage{Objects: map[string]string{"test/product_no_version/old/index.html": "old"}},
+		failPath:    "test/product_no_version/old/index.html",
+	}
+	ctx := context.Background()
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 40},
+		Code:   "0040",
+		Name:   "milk",
+		Status: publish.Status{Status: publish.StatusOnline, OnlineUrl: "test/product_no_version/old/index.html"},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+	var checkErr error
+	p := publish.New(db, storage).EnablePublishRuns(true).PublishCheck(func(ctx context.Context, obj any) error {
+		return checkErr
+	})
+	pb := presets.New()
+	require.NoError(t, p.ModelInstall(pb, pb.Model(&ProductWithoutVersion{})))
+	require.Error(t, p.Publish(ctx, &product))
+	// the new page uploaded before the failure is removed again
+	require.NotContains(t, storage.Objects, product.getUrl())
+	require.Equal(t, "old", storage.Objects["test/product_no_version/old/index.html"])
+
+	run := &publish.PublishRun{}
+	require.NoError(t, db.Where("model_name = ? AND model_keys = ?", "ProductWithoutVersion", "40").Order("id DESC").First(run).Error)
+	require.Equal(t, publish.PublishRunStatusFailed, run.Status)
+	require.Len(t, run.Actions, 2)
+	require.Equal(t, publish.PublishRunActionStatusReverted, run.Actions[0].Status)
+	require.Equal(t, publish.PublishRunActionStatusFailed, run.Actions[1].Status)
+
+	storage.failPath = ""
+	// a retry is checked like any publishing
+	checkErr = errors.New("not ready")
+	var publishCheckErr *publish.PublishCheckError
+	require.ErrorAs(t, p.RetryPublishRun(ctx, run), &publishCheckErr)
+	require.Nil(t, run.RetriedAt)
+	checkErr = nil
+	require.NoError(t, p.RetryPublishRun(ctx, run))
+	require.NotNil(t, run.RetriedAt)
+	require.Equal(t, "0040milk", storage.Objects[product.getUrl()])
+
+	retry := &publish.PublishRun{}
+	require.NoError(t, db.Where("retry_of_id = ?", run.ID).First(retry).Error)
+	require.Equal(t, publish.PublishRunStatusSuccess, retry.Status)
+}
+
+func TestPublishRollbackWithoutRuns(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	storage := &failingStorage{
+		MockStorage: &MockStorage{Objects: map[string]string{"test/product_no_version/old41/index.html": "old"}},
+		failPath:    "test/product_no_version/old41/index.html",
+	}
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 41},
+		Code:   "0041",
+		Name:   "tea",
+		Status: publish.Status{Status: publish.StatusOnline, OnlineUrl: "test/product_no_version/old41/index.html"},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+	require.Error(t, publish.New(db, storage).Publish(context.Background(), &product))
+	// the new page uploaded before the failure is removed even though the run is not tracked
+	require.NotContains(t, storage.Objects, product.getUrl())
+	require.Equal(t, "old", storage.Objects["test/product_no_version/old41/index.html"])
+}
+
+func TestPublishTargets(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	db.Exec("DELETE FROM publish_target_statuses")
+	production := &MockStorage{Objects: map[string]string{}}
+	staging := &MockStorage{Objects: map[string]string{}}
+	ctx := context.Background()
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 41},
+		Code:   "0041",
+		Name:   "tea",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+
+	var checkErr error
+	p := publish.New(db, production).Target("staging", staging, "https://staging.example.com").
+		PublishCheck(func(ctx context.Context, obj any) error {
+			return checkErr
+		})
+	pb := presets.New()
+	require.NoError(t, p.ModelInstall(pb, pb.Model(&ProductWithoutVersion{})))
+	require.Error(t, p.Publish(publish.WithTarget(ctx, "qa"), &product))
+
+	stagingCtx := publish.WithTarget(ctx, "staging")
+	require.NoError(t, p.Publish(stagingCtx, &product))
+	require.Equal(t, "0041tea", staging.Objects[product.getUrl()])
+	require.NotContains(t, production.Objects, product.getUrl())
+
+	// the status of the record is its status on the default target
+	var reloaded ProductWithoutVersion
+	require.NoError(t, db.First(&reloaded, product.ID).Error)
+	require.Equal(t, publish.StatusDraft, reloaded.Status.Status)
+	ts, err := p.GetTargetStatus(ctx, "staging", &product)
+	require.NoError(t, err)
+	require.Equal(t, publish.StatusOnline, ts.Status)
+	require.Equal(t, product.getUrl(), ts.OnlineUrl)
+
+	// the promoted version is checked like any publishing to the default target
+	checkErr = errors.New("not approved")
+	var publishCheckErr *publish.PublishCheckError
+	require.ErrorAs(t, p.PromoteTarget(ctx, "staging", &product), &publishCheckErr)
+	require.NotContains(t, production.Objects, product.getUrl())
+	checkErr = nil
+	require.NoError(t, p.PromoteTarget(ctx, "staging", &product))
+	require.Equal(t, "0041tea", production.Objects[product.getUrl()])
+	require.NoError(t, db.First(&reloaded, product.ID).Error)
+	require.Equal(t, publish.StatusOnline, reloaded.Status.Status)
+
+	require.NoError(t, p.UnPublish(stagingCtx, &reloaded))
+	require.NotContains(t, staging.Objects, product.getUrl())
+	require.Equal(t, "0041tea", production.Objects[product.getUrl()])
+	ts, err = p.GetTargetStatus(ctx, "staging", &product)
+	require.NoError(t, err)
+	require.Equal(t, publish.StatusOffline, ts.Status)
+}
+
+func TestPublishTargetDependencies(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}, &ProductBundle{}))
+	db.Exec("DELETE FROM publish_target_statuses")
+	staging := &MockStorage{Objects: map[string]string{}}
+	ctx := context.Background()
+
+	// the product is online on the default target only
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 42},
+		Code:   "0042",
+		Name:   "cocoa",
+		Status: publish.Status{Status: publish.StatusOnline},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+	bundle := ProductBundle{
+		Model:     gorm.Model{ID: 3},
+		Name:      "evening",
+		ProductID: product.ID,
+		Status:    publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&bundle)
+
+	p := publish.New(db, &MockStorage{Objects: map[string]string{}}).Target("staging", staging, "")
+	stagingCtx := publish.WithTarget(ctx, "staging")
+	var depErr *publish.UnpublishedDependenciesError
+	require.ErrorAs(t, p.Publish(stagingCtx, &bundle), &depErr)
+	require.Empty(t, staging.Objects)
+
+	require.NoError(t, p.Publish(publish.WithDependencyMode(stagingCtx, publish.DependencyModeAutoPublish), &bundle))
+	require.Equal(t, "0042cocoa", staging.Objects[product.getUrl()])
+	require.Equal(t, "evening", staging.Objects["test/bundle/3/index.html"])
+
+	var dependentsErr *publish.LiveDependentsError
+	require.ErrorAs(t, p.UnPublish(stagingCtx, &product), &dependentsErr)
+	require.NoError(t, p.UnPublish(stagingCtx, &bundle))
+	require.NoError(t, p.UnPublish(stagingCtx, &product))
+}
+
+type productPreview struct{}
+
+func (productPreview) PreviewHTML(obj interface{}) string {
+	return "<h1>" + obj.(*ProductWithoutVersion).Name + "</h1>"
+}
+
+func (productPreview) ExistedL10n() bool { return false }
+
+func TestShareLink(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	ctx := context.Background()
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 42},
+		Code:   "0042",
+		Name:   "draft coffee",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+
+	p := publish.New(db, &MockStorage{Objects: map[string]string{}}).
+		ShareLinks([]byte("secret"), "https://admin.example.com/share")
+	pb := presets.New()
+	require.NoError(t, p.ModelInstall(pb, pb.Model(&ProductWithoutVersion{})))
+	_, err := p.CreateShareLink(ctx, &product, time.Hour, "")
+	require.ErrorIs(t, err, publish.ErrShareLinkNoPreview)
+	p.ContextValueFuncs(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, "ProductWithoutVersion", productPreview{})
+	})
+	handler := p.ShareLinkHandler()
+	serve := func(method, rawURL string, form url.Values) *httptest.ResponseRecorder {
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		req := httptest.NewRequest(method, rawURL, body)
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	link, err := p.CreateShareLink(ctx, &product, time.Hour, "")
+	require.NoError(t, err)
+	w := serve(http.MethodGet, p.ShareLinkURL(link), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "draft coffee")
+
+	// changing the expiry breaks the signature
+	tampered := strings.Replace(p.ShareLinkURL(link), fmt.Sprint(link.ExpiresAt.Unix()), fmt.Sprint(link.ExpiresAt.Add(time.Hour).Unix()), 1)
+	w = serve(http.MethodGet, tampered, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, http.StatusText(http.StatusNotFound), strings.TrimSpace(w.Body.String()))
+
+	locked, err := p.CreateShareLink(ctx, &product, time.Hour, "pass")
+	require.NoError(t, err)
+	w = serve(http.MethodGet, p.ShareLinkURL(locked), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), "draft coffee")
+	require.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, p.ShareLinkURL(locked), url.Values{"password": {"wrong"}}).Code)
+	w = serve(http.MethodPost, p.ShareLinkURL(locked), url.Values{"password": {"pass"}})
+	require.Contains(t, w.Body.String(), "draft coffee")
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.True(t, cookies[0].Secure)
+	require.True(t, cookies[0].HttpOnly)
+
+	links, err := p.ActiveShareLinks(ctx, &product)
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	require.NoError(t, p.RevokeShareLink(ctx, link))
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet, p.ShareLinkURL(link), nil).Code)
+}
+
+func TestSitemap(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	db.Exec("UPDATE product_without_versions SET status = ?", publish.StatusOffline)
+	storage := &MockStorage{Objects: map[string]string{}}
+	ctx := context.Background()
+
+	online := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 43},
+		Code:   "0043",
+		Name:   "juice",
+		Status: publish.Status{Status: publish.StatusOnline, OnlineUrl: "test/product_no_version/0043/index.html"},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&online)
+	draft := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 44},
+		Code:   "0044",
+		Name:   "soda",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&draft)
+
+	p := publish.New(db, storage)
+	pb := presets.New()
+	require.NoError(t, p.ModelInstall(pb, pb.Model(&ProductWithoutVersion{})))
+	// the sitemap can be enabled after the models are installed
+	p.Sitemap("https://www.example.com/").RobotsRules("User-agent: *", "Disallow: /admin").Debounce(time.Hour)
+
+	require.NoError(t, p.Sitemap("https://www.example.com/").Generate(ctx))
+	child := storage.Objects["sitemap-product-without-versions.xml"]
+	require.Contains(t, child, "<loc>https://www.example.com/test/product_no_version/0043/</loc>")
+	require.NotContains(t, child, "0044")
+	require.Contains(t, storage.Objects["sitemap.xml"], "<loc>https://www.example.com/sitemap-product-without-versions.xml</loc>")
+	require.Equal(t, "User-agent: *\nDisallow: /admin\n\nSitemap: https://www.example.com/sitemap.xml\n", storage.Objects["robots.txt"])
+
+	// publishing regenerates the sitemap of the model after the debounce delay
+	require.NoError(t, p.Publish(ctx, &draft))
+	require.NotContains(t, storage.Objects["sitemap-product-without-versions.xml"], "0044")
+	require.NoError(t, p.Sitemap("https://www.example.com/").Flush(ctx))
+	require.Contains(t, storage.Objects["sitemap-product-without-versions.xml"], "test/product_no_version/0044/")
+
+	// the pages beyond the current count are deleted
+	storage.Objects["sitemap-product-without-versions-2.xml"] = "stale"
+	require.NoError(t, p.UnPublish(ctx, &online))
+	require.NoError(t, p.Sitemap("https://www.example.com/").Flush(ctx))
+	require.NotContains(t, storage.Objects["sitemap-product-without-versions.xml"], "0043")
+	require.NotContains(t, storage.Objects, "sitemap-product-without-versions-2.xml")
+}
+
+func TestPurge(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	ctx := context.Background()
+
+	// the server only hands the requests over, they are checked in the test goroutine
+	var (
+		mu   sync.Mutex
+		fail = 1
+	)
+	requests := make(chan []string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		busy := fail > 0
+		fail--
+		mu.Unlock()
+		if busy {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct{ Urls []string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests <- body.Urls
+	}))
+	defer srv.Close()
+	receive := func() []string {
+		select {
+		case urls := <-requests:
+			return urls
+		case <-time.After(5 * time.Second):
+			t.Fatal("no purge request")
+			return nil
+		}
+	}
+
+	purger := publish.NewHTTPPurger(srv.URL)
+	purger.BaseURL = "https://cdn.example.com"
+	p := publish.New(db, &MockStorage{Objects: map[string]string{}}).Purger(purger).PurgeBatchSize(1)
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 45},
+		Code:   "0045",
+		Name:   "water",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+	require.NoError(t, p.Publish(ctx, &product))
+
+	// the failed batch is retried and the index page is purged with its directory
+	require.Equal(t, []string{"https://cdn.example.com/test/product_no_version/0045/"}, receive())
+	require.Equal(t, []string{"https://cdn.example.com/test/product_no_version/0045/index.html"}, receive())
+
+	// without retries the first failure is returned
+	mu.Lock()
+	fail = 2
+	mu.Unlock()
+	p.PurgeRetries(0)
+	require.Error(t, p.Purge(ctx, []string{"test/product_no_version/0045/index.html"}))
+	mu.Lock()
+	require.Equal(t, 1, fail)
+	mu.Unlock()
+}
+
+type ProductVersion struct {
+	gorm.Model
+	Name string
+
+	publish.Version
+	publish.Schedule
+	publish.Status
+}
+
+func (p *ProductVersion) PrimarySlug() string {
+	return fmt.Sprintf("%d_%s", p.ID, p.Version.Version)
+}
+
+func (p *ProductVersion) PrimaryColumnValuesBySlug(slug string) map[string]string {
+	id, version, _ := strings.Cut(slug, "_")
+	return map[string]string{"id": id, "version": version}
+}
+
+func (p *ProductVersion) GetPublishActions(ctx context.Context, db *gorm.DB, storage oss.StorageInterface) (actions []*publish.PublishAction, err error) {
+	return
+}
+
+func (p *ProductVersion) GetUnPublishActions(ctx context.Context, db *gorm.DB, storage oss.StorageInterface) (actions []*publish.PublishAction, err error) {
+	return
+}
+
+func TestRollbackVersion(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductVersion{}))
+	db.Exec("DELETE FROM product_versions")
+
+	for _, version := range []ProductVersion{
+		{Model: gorm.Model{ID: 60}, Name: "old", Version: publish.Version{Version: "2024-01-01-v01", VersionName: "v1"}, Status: publish.Status{Status: publish.StatusOffline}},
+		{Model: gorm.Model{ID: 60}, Name: "bad", Version: publish.Version{Version: "2024-01-02-v01", VersionName: "v2"}, Status: publish.Status{Status: publish.StatusOnline}},
+		{Model: gorm.Model{ID: 60}, Name: "wip", Version: publish.Version{Version: "2024-01-03-v01", VersionName: "v3"}, Status: publish.Status{Status: publish.StatusDraft}},
+	} {
+		require.NoError(t, db.Create(&version).Error)
+	}
+
+	mb := presets.New().DataOperator(gorm2op.DataOperator(db)).Model(&ProductVersion{})
+	p := publish.New(db, &MockStorage{Objects: map[string]string{}})
+	ctx := &web.EventContext{R: httptest.NewRequest(http.MethodPost, "/", nil)}
+	load := func(version string) *ProductVersion {
+		obj := &ProductVersion{}
+		require.NoError(t, db.Where("id = ? AND version = ?", 60, version).First(obj).Error)
+		return obj
+	}
+
+	// the online version is live and the draft can be published as it is
+	for _, version := range []string{"2024-01-02-v01", "2024-01-03-v01"} {
+		_, err := p.RollbackVersion(ctx, mb, load(version), false)
+		require.ErrorIs(t, err, publish.ErrRollbackNotOffline)
+	}
+
+	obj := load("2024-01-01-v01")
+	slug, err := p.RollbackVersion(ctx, mb, obj, false)
+	require.NoError(t, err)
+	require.Equal(t, obj.PrimarySlug(), slug)
+	restored := load(obj.Version.Version)
+	require.Equal(t, "old", restored.Name)
+	require.Equal(t, publish.StatusDraft, restored.Status.Status)
+	require.Equal(t, "2024-01-01-v01", restored.ParentVersion)
+	require.Equal(t, publish.StatusOnline, load("2024-01-02-v01").Status.Status)
+
+	obj = load("2024-01-01-v01")
+	_, err = p.RollbackVersion(ctx, mb, obj, true)
+	require.NoError(t, err)
+	require.Equal(t, publish.StatusOnline, load(obj.Version.Version).Status.Status)
+	require.Equal(t, publish.StatusOffline, load("2024-01-02-v01").Status.Status)
+	require.Equal(t, publish.StatusOffline, load("2024-01-01-v01").Status.Status, "the source version is not changed")
+}
+
+func TestPruneVersions(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductVersion{}))
+	db.Exec("DELETE FROM product_versions")
+	ctx := context.Background()
+
+	old := db.NowFunc().AddDate(0, 0, -30)
+	scheduled := db.NowFunc().AddDate(0, 0, 1)
+	for i, status := range []string{publish.StatusDraft, publish.StatusDraft, publish.StatusOnline, publish.StatusDraft, publish.StatusDraft} {
+		version := ProductVersion{
+			Model:   gorm.Model{ID: 50, CreatedAt: old, UpdatedAt: old},
+			Name:    "juice",
+			Version: publish.Version{Version: fmt.Sprintf("2024-01-0%d-v01", 5-i), VersionName: fmt.Sprintf("v%d", 5-i)},
+			Status:  publish.Status{Status: status},
+		}
+		if i == 4 {
+			version.ScheduledStartAt = &scheduled
+		}
+		require.NoError(t, db.Create(&version).Error)
+	}
+
+	p := publish.New(db, &MockStorage{Objects: map[string]string{}})
+	p.Retention(presets.New().Model(&ProductVersion{})).KeepLast(1).KeepDays(7)
+
+	// the latest, online and scheduled versions are kept
+	report, err := p.Prune(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, report.Versions, 2)
+	require.Equal(t, "v4", report.Versions[0].VersionName)
+	require.Equal(t, "v2", report.Versions[1].VersionName)
+	var count int64
+	db.Model(&ProductVersion{}).Count(&count)
+	require.EqualValues(t, 5, count)
+
+	_, err = p.Prune(ctx, false)
+	require.NoError(t, err)
+	var names []string
+	db.Model(&ProductVersion{}).Order("version DESC").Pluck("version_name", &names)
+	require.Equal(t, []string{"v5", "v3", "v1"}, names)
+	db.Unscoped().Model(&ProductVersion{}).Count(&count)
+	require.EqualValues(t, 3, count, "the pruned versions are not soft deleted")
+}
+
+func TestCheckConsistency(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	db.Exec("DELETE FROM product_without_versions")
+	ctx := context.Background()
+	storage := &MockStorage{Objects: map[string]string{}}
+
+	p := publish.New(db, storage).ConsistencyPrefixes("test/product_no_version/").
+		StoragePaths(func(ctx context.Context, db *gorm.DB) ([]string, error) {
+			return []string{"/test/product_no_version/rules.json"}, nil
+		})
+	pb := presets.New()
+	require.NoError(t, p.ModelInstall(pb, pb.Model(&ProductWithoutVersion{})))
+
+	var products []*ProductWithoutVersion
+	for i, name := range []string{"bread", "cake", "pie"} {
+		product := &ProductWithoutVersion{
+			Model:  gorm.Model{ID: uint(51 + i)},
+			Code:   fmt.Sprintf("00%d", 51+i),
+			Name:   name,
+			Status: publish.Status{Status: publish.StatusDraft},
+		}
+		require.NoError(t, db.Create(product).Error)
+		require.NoError(t, p.Publish(ctx, product))
+		products = append(products, product)
+	}
+	delete(storage.Objects, products[0].getUrl())
+	storage.Objects[products[1].getUrl()] = "old cake"
+	storage.Objects["test/product_no_version/0050/index.html"] = "removed"
+	storage.Objects["test/product_no_version/rules.json"] = "{}"
+
+	report, err := p.CheckConsistency(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Records)
+	require.Equal(t, []*publish.ConsistencyIssue{
+		{Kind: publish.ConsistencyMissing, Url: products[0].getUrl(), ModelName: "ProductWithoutVersion", ModelKeys: "51"},
+		{Kind: publish.ConsistencyStale, Url: products[1].getUrl(), ModelName: "ProductWithoutVersion", ModelKeys: "52"},
+		{Kind: publish.ConsistencyOrphan, Url: "test/product_no_version/0050/index.html"},
+	}, report.Issues)
+
+	// the repair only republishes, the orphans are deleted once confirmed
+	require.NoError(t, p.RepairConsistency(ctx, report.Issues))
+	report, err = p.CheckConsistency(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*publish.ConsistencyIssue{
+		{Kind: publish.ConsistencyOrphan, Url: "test/product_no_version/0050/index.html"},
+	}, report.Issues)
+
+	deleted, err := p.DeleteOrphans(ctx, []string{"test/product_no_version/0050/index.html", products[2].getUrl()})
+	require.NoError(t, err)
+	require.Equal(t, []string{"test/product_no_version/0050/index.html"}, deleted)
+	require.Contains(t, storage.Objects, products[2].getUrl())
+	report, err = p.CheckConsistency(ctx)
+	require.NoError(t, err)
+	require.Empty(t, report.Issues)
+}
+
+func TestSchedulerTask(t *testing.T) {
+	db := TestDB
+	publisher := publish.New(db, &MockStorage{})
+
+	var windows [][2]time.Time
+	publisher.ScheduleTask("test-task", func(ctx context.Context, since, now time.Time) error {
+		windows = append(windows, [2]time.Time{since, now})
+		return nil
+	})
+	s := publish.NewScheduler(publisher).Name("test-task-scheduler").Holder("replica-1")
+	require.NoError(t, s.AutoMigrate())
+	db.Where("name = ?", "test-task-scheduler").Delete(&publish.SchedulerLease{})
+	db.Where("job = ? AND model_name = ?", "task", "test-task").Delete(&publish.ScheduleRun{})
+
+	for i := 0; i < 2; i++ {
+		leader, err := s.RunOnce(context.Background())
+		require.NoError(t, err)
+		require.True(t, leader)
+	}
+	require.Len(t, windows, 2)
+	// the first run looks back one interval, the next starts where the previous one started
+	require.WithinDuration(t, windows[0][1].Add(-publish.DefaultSchedulerInterval), windows[0][0], time.Second)
+	require.False(t, windows[1][0].After(windows[0][1]))
+	require.True(t, windows[1][0].After(windows[0][0]))
+}
+
+func TestSchedulerLeaseLost(t *testing.T) {
+	db := TestDB
+	publisher := publish.New(db, &MockStorage{})
+
+	var ran []string
+	publisher.ScheduleTask("lease-a", func(ctx context.Context, since, now time.Time) error {
+		ran = append(ran, "lease-a")
+		// another replica took over while the task ran
+		return db.Model(&publish.SchedulerLease{}).Where("name = ?", "test-lost-scheduler").
+			Updates(map[string]any{"holder": "replica-2", "expires_at": now.Add(time.Hour)}).Error
+	})
+	publisher.ScheduleTask("lease-b", func(ctx context.Context, since, now time.Time) error {
+		ran = append(ran, "lease-b")
+		return nil
+	})
+	s := publish.NewScheduler(publisher).Name("test-lost-scheduler").Holder("replica-1").RetryInterval(0)
+	require.NoError(t, s.AutoMigrate())
+	db.Where("name = ?", "test-lost-scheduler").Delete(&publish.SchedulerLease{})
+
+	leader, err := s.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.False(t, leader, "the round stops once the lease is lost")
+	require.Equal(t, []string{"lease-a"}, ran)
+}
+
+func TestReviewWorkflow(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}, &publish.ReviewRequest{}))
+	require.NoError(t, db.Unscoped().Where("model_name = ?", "ProductWithoutVersion").Delete(&publish.ReviewRequest{}).Error)
+	storage := &MockStorage{Objects: map[string]string{}}
+	ctx := context.Background()
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 45},
+		Code:   "0045",
+		Name:   "reviewed tea",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+
+	failPublish := false
+	user := "alice"
+	mb := presets.New().Model(&ProductWithoutVersion{})
+	p := publish.New(db, storage).CurrentUserIDFunc(func(ctx context.Context) (string, error) {
+		return user, nil
+	}).WrapPublish(func(in publish.PublishFunc) publish.PublishFunc {
+		return func(ctx context.Context, record any) error {
+			if failPublish {
+				return fmt.Errorf("storage is down")
+			}
+			return in(ctx, record)
+		}
+	})
+	p.RequireReview(mb).DisableDirectPublish(true)
+
+	// publishing in any way needs an approved review
+	err := p.Publish(ctx, &product)
+	var checkErr *publish.PublishCheckError
+	require.ErrorAs(t, err, &checkErr)
+	require.ErrorIs(t, err, publish.ErrReviewRequired)
+	require.NotContains(t, storage.Objects, product.getUrl())
+
+	_, _, err = p.ApproveReview(ctx, &product, "")
+	require.Error(t, err, "the version is not in review")
+
+	rr, err := p.SubmitReview(ctx, mb, &product, "please review")
+	require.NoError(t, err)
+	require.Equal(t, publish.ReviewStatusPending, rr.Status)
+	_, err = p.SubmitReview(ctx, mb, &product, "again")
+	require.Error(t, err, "the version is already in review")
+
+	rr, err = p.RejectReview(ctx, &product, "too bitter")
+	require.NoError(t, err)
+	require.Equal(t, publish.ReviewStatusRejected, rr.Status)
+	require.ErrorIs(t, p.Publish(ctx, &product), publish.ErrReviewRequired)
+
+	// the submitter cannot approve their own review
+	_, err = p.SubmitReview(ctx, mb, &product, "fixed")
+	require.NoError(t, err)
+	_, _, err = p.ApproveReview(ctx, &product, "ok")
+	require.ErrorIs(t, err, publish.ErrSelfApproval)
+	require.NotContains(t, storage.Objects, product.getUrl())
+
+	// a failed publishing rolls the approval back
+	user = "bob"
+	failPublish = true
+	_, _, err = p.ApproveReview(ctx, &product, "ok")
+	require.Error(t, err)
+	latest, err := publish.LatestReview(db, &product)
+	require.NoError(t, err)
+	require.Equal(t, publish.ReviewStatusPending, latest.Status)
+	require.NotContains(t, storage.Objects, product.getUrl())
+
+	failPublish = false
+	rr, published, err := p.ApproveReview(ctx, &product, "ok")
+	require.NoError(t, err)
+	require.True(t, published)
+	require.Equal(t, publish.ReviewStatusApproved, rr.Status)
+	require.Equal(t, "alice", rr.SubmitterID)
+	require.Equal(t, "bob", rr.ReviewerID)
+	require.Equal(t, product.getContent(), storage.Objects[product.getUrl()])
+	var reloaded ProductWithoutVersion
+	require.NoError(t, db.First(&reloaded, product.ID).Error)
+	require.Equal(t, publish.StatusOnline, reloaded.Status.Status)
+}
+
+func TestAfterCommit(t *testing.T) {
+	db := TestDB
+	require.NoError(t, db.AutoMigrate(&ProductWithoutVersion{}))
+	storage := &MockStorage{Objects: map[string]string{}}
+	ctx := context.Background()
+
+	product := ProductWithoutVersion{
+		Model:  gorm.Model{ID: 46},
+		Code:   "0046",
+		Name:   "committed tea",
+		Status: publish.Status{Status: publish.StatusDraft},
+	}
+	db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&product)
+
+	var (
+		committed   []string
+		failPublish bool
+	)
+	p := publish.New(db, storage).WrapPublish(func(in publish.PublishFunc) publish.PublishFunc {
+		return func(ctx context.Context, record any) error {
+			if err := in(ctx, record); err != nil {
+				return err
+			}
+			publish.AfterCommit(ctx, func(ctx context.Context) {
+				var status string
+				db.Model(&ProductWithoutVersion{}).Select("status").Where("id = ?", 46).Scan(&status)
+				committed = append(committed, status)
+			})
+			require.Empty(t, committed, "f runs after the transaction")
+			if failPublish {
+				return fmt.Errorf("storage is down")
+			}
+			return nil
+		}
+	})
+
+	failPublish = true
+	require.Error(t, p.Publish(ctx, &product))
+	require.Empty(t, committed, "f is dropped when the transaction rolls back")
+
+	failPublish = false
+	product.Status = publish.Status{Status: publish.StatusDraft}
+	require.NoError(t, p.Publish(ctx, &product))
+	require.Equal(t, []string{publish.StatusOnline}, committed, "f reads the committed records")
+
+	publish.AfterCommit(ctx, func(ctx context.Context) { committed = nil })
+	require.Nil(t, committed, "f runs right away without a transaction")
+}
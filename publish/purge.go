@@ -0,0 +1,209 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/qor5/admin/v3/worker"
+)
+
+const (
+	PurgeJobName = "publish-cdn-purge"
+
+	defaultPurgeBatchSize = 100
+	defaultPurgeRetries   = 3
+)
+
+// Purger removes the given paths of the storage from a CDN cache
+type Purger interface {
+	Purge(ctx context.Context, paths []string) error
+}
+
+type PurgerFunc func(ctx context.Context, paths []string) error
+
+func (f PurgerFunc) Purge(ctx context.Context, paths []string) error {
+	return f(ctx, paths)
+}
+
+// HTTPPurger sends the urls to purge as JSON to an endpoint, e.g. the purge API of a CDN or a proxy in front of it
+type HTTPPurger struct {
+	Endpoint string
+	// BaseURL is put before the paths, the paths are sent as they are when it is empty
+	BaseURL string
+	Header  http.Header
+	Client  *http.Client
+	// Body encodes the request body, by default {"urls": [...]}
+	Body func(urls []string) ([]byte, error)
+}
+
+func NewHTTPPurger(endpoint string) *HTTPPurger {
+	return &HTTPPurger{Endpoint: endpoint, Header: http.Header{}}
+}
+
+func (p *HTTPPurger) Purge(ctx context.Context, paths []string) error {
+	urls := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if p.BaseURL != "" {
+			path = strings.TrimSuffix(p.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+		}
+		urls = append(urls, path)
+	}
+	encode := p.Body
+	if encode == nil {
+		encode = func(urls []string) ([]byte, error) {
+			return json.Marshal(map[string][]string{"urls": urls})
+		}
+	}
+	body, err := encode(urls)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, vs := range p.Header {
+		req.Header[k] = vs
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("publish: purge %s: %s %s", p.Endpoint, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+type PurgeJobArgs struct {
+	Paths []string
+}
+
+// Purger is called with the paths written by each publish, unpublish and list publishing once they succeed
+func (b *Builder) Purger(v Purger) (r *Builder) {
+	b.purger = v
+	return b
+}
+
+// PurgeBatchSize is the number of paths sent to the Purger at a time, 100 by default
+func (b *Builder) PurgeBatchSize(v int) (r *Builder) {
+	if v <= 0 {
+		v = defaultPurgeBatchSize
+	}
+	b.purgeBatchSize = v
+	return b
+}
+
+// PurgeRetries is the number of times a failed batch is sent again, 3 by default, 0 disables the retries
+func (b *Builder) PurgeRetries(v int) (r *Builder) {
+	b.purgeRetries = max(v, 0)
+	return b
+}
+
+// PurgeWorker runs the purges after the publishing as jobs of w, with their attempts in the job log.
+// The job is added to w here, before w is installed on the admin
+func (b *Builder) PurgeWorker(w *worker.Builder) (r *Builder) {
+	b.purgeWorker = w
+	w.NewJob(PurgeJobName).
+		Resource(&PurgeJobArgs{}).
+		Handler(func(ctx context.Context, job worker.QorJobInterface) error {
+			info, err := job.GetJobInfo()
+			if err != nil {
+				return err
+			}
+			return b.runPurge(ctx, info.Argument.(*PurgeJobArgs).Paths, func(msg string) {
+				_ = job.AddLog(msg)
+			})
+		})
+	return b
+}
+
+// purgePaths adds the directory of the index pages, which is the url the pages are usually served at
+func purgePaths(paths []string) []string {
+	var r []string
+	for _, path := range paths {
+		r = append(r, path)
+		if dir, ok := strings.CutSuffix(path, "index.html"); ok {
+			r = append(r, dir)
+		}
+	}
+	slices.Sort(r)
+	return slices.Compact(r)
+}
+
+// Purge purges paths from the CDN, through the worker when there is one
+func (b *Builder) Purge(ctx context.Context, paths []string) error {
+	if b.purger == nil || len(paths) == 0 {
+		return nil
+	}
+	paths = purgePaths(paths)
+	if b.purgeWorker != nil {
+		_, err := b.purgeWorker.AddJob(ctx, PurgeJobName, &PurgeJobArgs{Paths: paths})
+		return err
+	}
+	return b.runPurge(ctx, paths, func(msg string) {
+		log.Println(msg)
+	})
+}
+
+func (b *Builder) runPurge(ctx context.Context, paths []string, logf func(msg string)) (err error) {
+	size, retries := b.purgeBatchSize, b.purgeRetries
+	for start := 0; start < len(paths); start += size {
+		batch := paths[start:min(start+size, len(paths))]
+		for attempt := 0; ; attempt++ {
+			if err = b.purger.Purge(ctx, batch); err == nil {
+				break
+			}
+			logf(fmt.Sprintf("publish: purge attempt %d: %v", attempt+1, err))
+			if attempt >= retries {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt+1) * 200 * time.Millisecond):
+			}
+		}
+	}
+	return nil
+}
+
+// purgeJournal purges the paths the journal wrote to the default storage, errors are only logged
+// as the publishing is already done. Without a worker the purge runs in the background so that the publishing
+// request doesn't wait for the CDN and the retries
+func (b *Builder) purgeJournal(ctx context.Context, journal *storageJournal) {
+	if b.purger == nil {
+		return
+	}
+	paths := journal.urls(b.storage)
+	if len(paths) == 0 {
+		return
+	}
+	if b.purgeWorker != nil {
+		if err := b.Purge(ctx, paths); err != nil {
+			log.Printf("error: %s\n", err)
+		}
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := b.Purge(ctx, paths); err != nil {
+			log.Printf("error: %s\n", err)
+		}
+	}()
+}
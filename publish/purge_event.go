@@ -0,0 +1,57 @@
+package publish
+
+import (
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	v "github.com/qor5/x/v3/ui/vuetify"
+	h "github.com/theplant/htmlgo"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+func buildPurgeButton(obj interface{}, mb *presets.ModelBuilder, slug string, msgr *Messages) h.HTMLComponent {
+	b := builderOf(obj)
+	if b == nil {
+		return nil
+	}
+	if _, ok := b.purgeModels[utils.GetObjectName(obj)]; !ok {
+		return nil
+	}
+	if status := EmbedStatus(obj); status.Status != StatusOnline || status.OnlineUrl == "" {
+		return nil
+	}
+	return v.VBtn(msgr.PurgeCDN).
+		Attr("@click", web.Plaid().
+			EventFunc(eventPurgeCDN).
+			Query(presets.ParamID, slug).
+			URL(mb.Info().ListingHref()).Go()).
+		Class("ml-2").Variant(v.VariantOutlined).Color(v.ColorPrimary).Height(36)
+}
+
+func purgeCDN(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		obj := mb.NewModel()
+		obj, err = mb.Editing().Fetcher(obj, ctx.Param(presets.ParamID), ctx)
+		if err != nil {
+			return
+		}
+		if DeniedDo(mb.Info().Verifier(), obj, ctx.R, PermPublish) {
+			return r, perm.PermissionDenied
+		}
+		if err = publisher.Purge(ctx.R.Context(), []string{EmbedStatus(obj).OnlineUrl}); err != nil {
+			return
+		}
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		presets.ShowMessage(&r, msgr.SuccessfullyPurgeCDN, "")
+		return
+	}
+}
@@ -0,0 +1,239 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	ReleaseStatusDraft   = "draft"
+	ReleaseStatusOnline  = "online"
+	ReleaseStatusOffline = "offline"
+)
+
+// Release collects specific versions of records of different models, they go online or offline together
+type Release struct {
+	gorm.Model
+
+	Name        string
+	Description string
+	Status      string     `gorm:"default:'draft';index"`
+	ScheduledAt *time.Time `gorm:"index"`
+	PublishedAt *time.Time
+	LastError   string
+}
+
+func (Release) TableName() string {
+	return "publish_releases"
+}
+
+type ReleaseItem struct {
+	gorm.Model
+
+	ReleaseID  uint   `gorm:"index"`
+	ModelName  string `gorm:"index:idx_publish_release_items_record"`
+	RecordKeys string `gorm:"index:idx_publish_release_items_record"`
+	ModelKeys  string
+	ModelLabel string
+}
+
+func (ReleaseItem) TableName() string {
+	return "publish_release_items"
+}
+
+// EnableReleases adds the release model and an action to add records of the publish models into releases
+func (b *Builder) EnableReleases(v bool) (r *Builder) {
+	b.releases = v
+	return b
+}
+
+func (b *Builder) releaseModelBuilder(name string) (*presets.ModelBuilder, error) {
+	mb, ok := b.releaseModels[name]
+	if !ok {
+		return nil, fmt.Errorf("publish: %s can not be released", name)
+	}
+	return mb, nil
+}
+
+func ReleaseItems(db *gorm.DB, releaseID uint) (items []*ReleaseItem, err error) {
+	err = db.Where("release_id = ?", releaseID).Order("id").Find(&items).Error
+	return
+}
+
+// AddToRelease adds the version of obj into the draft release, it replaces other versions of the same record
+func (b *Builder) AddToRelease(ctx context.Context, releaseID uint, obj any) error {
+	name := utils.GetObjectName(obj)
+	if _, err := b.releaseModelBuilder(name); err != nil {
+		return err
+	}
+	return utils.Transact(b.db.WithContext(ctx), func(tx *gorm.DB) error {
+		rel := &Release{}
+		if err := tx.First(rel, releaseID).Error; err != nil {
+			return err
+		}
+		if rel.Status != ReleaseStatusDraft {
+			return errors.New("publish: only draft releases can be changed")
+		}
+		recordKeys, err := dependencyKeys(tx, obj)
+		if err != nil {
+			return err
+		}
+		if err = tx.Where("release_id = ? AND model_name = ? AND record_keys = ?", releaseID, name, recordKeys).
+			Delete(&ReleaseItem{}).Error; err != nil {
+			return err
+		}
+		item := &ReleaseItem{
+			ReleaseID:  releaseID,
+			ModelName:  name,
+			RecordKeys: recordKeys,
+			ModelKeys:  obj.(presets.SlugEncoder).PrimarySlug(),
+		}
+		if version, ok := obj.(VersionInterface); ok {
+			item.ModelLabel = version.EmbedVersion().VersionName
+		}
+		return tx.Create(item).Error
+	})
+}
+
+func (b *Builder) releaseRecords(db *gorm.DB, rel *Release) (records []any, err error) {
+	items, err := ReleaseItems(db, rel.ID)
+	if err != nil {
+		return
+	}
+	for _, item := range items {
+		mb, err := b.releaseModelBuilder(item.ModelName)
+		if err != nil {
+			return nil, err
+		}
+		obj := mb.NewModel()
+		if err = utils.PrimarySluggerWhere(db, obj, item.ModelKeys).First(obj).Error; err != nil {
+			return nil, fmt.Errorf("%s %s: %w", item.ModelName, item.ModelKeys, err)
+		}
+		records = append(records, obj)
+	}
+	return
+}
+
+// PublishRelease publishes all records of the release in one transaction,
+// if any of them fails the database is rolled back and the objects already written to the storage are put back
+func (b *Builder) PublishRelease(ctx context.Context, rel *Release) error {
+	return b.runRelease(ctx, rel, ActivityPublishRelease, func(ctx context.Context, tx *gorm.DB, records []any) error {
+		// dependencies inside the release are checked after all records are online
+		ignoreCtx := WithDependencyMode(ctx, DependencyModeIgnore)
+		for _, record := range records {
+			if err := b.Publish(ignoreCtx, record); err != nil {
+				return fmt.Errorf("%s: %w", recordLabel(record), err)
+			}
+		}
+		for _, record := range records {
+			if err := b.publishDependencies(ctx, record); err != nil {
+				return fmt.Errorf("%s: %w", recordLabel(record), err)
+			}
+		}
+		now := tx.NowFunc()
+		rel.Status = ReleaseStatusOnline
+		rel.ScheduledAt = nil
+		rel.PublishedAt = &now
+		return nil
+	})
+}
+
+// UnPublishRelease takes the records of the release offline together, versions replaced by newer ones are skipped
+func (b *Builder) UnPublishRelease(ctx context.Context, rel *Release) error {
+	return b.runRelease(ctx, rel, ActivityUnPublishRelease, func(ctx context.Context, tx *gorm.DB, records []any) error {
+		var unpublished []any
+		ignoreCtx := WithDependencyMode(ctx, DependencyModeIgnore)
+		for i := len(records) - 1; i >= 0; i-- {
+			record := records[i]
+			if !isOnline(record) {
+				continue
+			}
+			if err := b.UnPublish(ignoreCtx, record); err != nil {
+				return fmt.Errorf("%s: %w", recordLabel(record), err)
+			}
+			unpublished = append(unpublished, record)
+		}
+		for _, record := range unpublished {
+			if err := b.checkLiveDependents(ctx, record); err != nil {
+				return fmt.Errorf("%s: %w", recordLabel(record), err)
+			}
+		}
+		rel.Status = ReleaseStatusOffline
+		rel.ScheduledAt = nil
+		return nil
+	})
+}
+
+func (b *Builder) runRelease(ctx context.Context, rel *Release, action string, f func(ctx context.Context, tx *gorm.DB, records []any) error) (err error) {
+	ctx = b.WithContextValues(ctx)
+	ctx, journal, ownJournal := withStorageJournal(ctx, true)
+	orig := *rel
+	err = b.transact(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		records, err := b.releaseRecords(tx, rel)
+		if err != nil {
+			return err
+		}
+		if err = f(ctx, tx, records); err != nil {
+			return err
+		}
+		rel.LastError = ""
+		return tx.Select("Status", "ScheduledAt", "PublishedAt", "LastError").Save(rel).Error
+	})
+	if err != nil {
+		if rerr := journal.revert(ctx); rerr != nil {
+			log.Printf("error: %s\n", rerr)
+		}
+		*rel = orig
+		rel.LastError = err.Error()
+		if uerr := b.db.Model(rel).Update("last_error", err.Error()).Error; uerr != nil {
+			log.Printf("error: %s\n", uerr)
+		}
+		return err
+	}
+	b.logRelease(ctx, rel, action)
+	if ownJournal {
+		b.purgeJournal(ctx, journal)
+	}
+	return nil
+}
+
+func (b *Builder) logRelease(ctx context.Context, rel *Release, action string) {
+	if b.ab == nil {
+		return
+	}
+	amb, exist := b.ab.GetModelBuilder(rel)
+	if !exist {
+		return
+	}
+	if _, err := amb.Log(ctx, action, rel, nil); err != nil {
+		log.Printf("error: %s\n", err)
+	}
+}
+
+// runDueReleases publishes the draft releases whose scheduled time has come
+func (b *Builder) runDueReleases(ctx context.Context) (err error) {
+	db := b.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&Release{}) {
+		return nil
+	}
+	var releases []*Release
+	if err = db.Where("status = ? AND scheduled_at <= ?", ReleaseStatusDraft, db.NowFunc()).
+		Order("scheduled_at").Find(&releases).Error; err != nil {
+		return
+	}
+	for _, rel := range releases {
+		if err2 := b.PublishRelease(ctx, rel); err2 != nil {
+			err = multierror.Append(err, fmt.Errorf("release %d: %w", rel.ID, err2)).ErrorOrNil()
+		}
+	}
+	return
+}
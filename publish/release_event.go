@@ -0,0 +1,372 @@
+package publish
+
+import (
+	"cmp"
+	"errors"
+	"strconv"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	v "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	fieldReleaseID      = "ReleaseID"
+	paramReleaseItemID  = "release_item_id"
+	releaseItemsField   = "Items"
+	releaseModelURIName = "publish-releases"
+
+	eventPreviewRelease   = "publish_eventPreviewRelease"
+	eventPublishRelease   = "publish_eventPublishRelease"
+	eventUnpublishRelease = "publish_eventUnpublishRelease"
+)
+
+func addToReleaseDialog(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		cmsgr := i18n.MustGetModuleMessages(ctx.R, presets.CoreI18nModuleKey, Messages_en_US).(*presets.Messages)
+
+		var releases []*Release
+		if err = publisher.db.Where("status = ?", ReleaseStatusDraft).Order("id DESC").Find(&releases).Error; err != nil {
+			return
+		}
+		if len(releases) == 0 {
+			presets.ShowMessage(&r, msgr.NoDraftReleases, "warning")
+			return
+		}
+
+		r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+			Name: PortalAddToReleaseDialog,
+			Body: web.Scope(
+				vx.VXDialog(
+					v.VSelect().Attr(web.VField(fieldReleaseID, releases[0].ID)...).
+						Items(releases).ItemTitle("Name").ItemValue("ID").
+						Label(msgr.Release).HideDetails(true),
+				).Title(msgr.AddToRelease).
+					CancelText(cmsgr.Cancel).
+					OkText(cmsgr.OK).
+					Attr("@click:ok", web.Plaid().
+						EventFunc(eventAddToRelease).
+						Query(presets.ParamID, ctx.Param(presets.ParamID)).
+						URL(mb.Info().ListingHref()).
+						ThenScript("locals.addToReleaseDialog = false").
+						Go()).
+					Attr("v-model", "locals.addToReleaseDialog"),
+			).Init("{addToReleaseDialog:true}").VSlot("{locals}"),
+		})
+		return
+	}
+}
+
+func addToRelease(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		slug := ctx.Param(presets.ParamID)
+		obj := mb.NewModel()
+		obj, err = mb.Editing().Fetcher(obj, slug, ctx)
+		if err != nil {
+			return
+		}
+		if DeniedDo(mb.Info().Verifier(), obj, ctx.R, PermPublish) {
+			return r, perm.PermissionDenied
+		}
+
+		releaseID, err := strconv.ParseUint(ctx.R.FormValue(fieldReleaseID), 10, 64)
+		if err != nil {
+			return
+		}
+		if err = publisher.AddToRelease(ctx.R.Context(), uint(releaseID), obj); err != nil {
+			return
+		}
+
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		presets.ShowMessage(&r, msgr.SuccessfullyAddToRelease, "")
+		return
+	}
+}
+
+func buildAddToReleaseButton(obj interface{}, mb *presets.ModelBuilder, slug string, msgr *Messages, phraseHasPresetsDataChanged string) h.HTMLComponent {
+	if b := builderOf(obj); b == nil || b.releaseModels[utils.GetObjectName(obj)] == nil {
+		return nil
+	}
+	return h.Components(
+		v.VBtn(msgr.AddToRelease).
+			Attr(":disabled", phraseHasPresetsDataChanged).
+			Attr("@click", web.Plaid().
+				EventFunc(eventAddToReleaseDialog).
+				Query(presets.ParamID, slug).
+				URL(mb.Info().ListingHref()).Go()).
+			Class("ml-2").Variant(v.VariantOutlined).Color(v.ColorPrimary).Height(36),
+		web.Portal().Name(PortalAddToReleaseDialog),
+	)
+}
+
+func (b *Builder) configureReleases(pb *presets.Builder) error {
+	if err := b.db.AutoMigrate(&Release{}, &ReleaseItem{}); err != nil {
+		return err
+	}
+	if b.ab != nil {
+		b.ab.RegisterModel(&Release{})
+	}
+
+	mb := pb.Model(&Release{}).URIName(releaseModelURIName).MenuIcon("mdi-rocket-launch-outline")
+	mb.LabelName(func(evCtx *web.EventContext, singular bool) string {
+		msgr := i18n.MustGetModuleMessages(evCtx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return msgr.Releases
+	})
+
+	lb := mb.Listing("Name", "Status", "ScheduledAt", "PublishedAt", "LastError")
+	lb.Field("Status").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return h.Td(releaseChip(obj.(*Release), msgr))
+	})
+	lb.Field("ScheduledAt").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return h.Td(h.Text(ScheduleTimeString(obj.(*Release).ScheduledAt)))
+	})
+	lb.Field("PublishedAt").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return h.Td(h.Text(ScheduleTimeString(obj.(*Release).PublishedAt)))
+	})
+
+	rmb := lb.RowMenu()
+	releaseRowMenuItem(rmb, mb, eventPreviewRelease, "mdi-eye-outline", "", func(msgr *Messages) string { return msgr.Preview })
+	releaseRowMenuItem(rmb, mb, eventPublishRelease, "mdi-publish", PermPublish, func(msgr *Messages) string { return msgr.Publish })
+	releaseRowMenuItem(rmb, mb, eventUnpublishRelease, "mdi-publish-off", PermUnpublish, func(msgr *Messages) string { return msgr.Unpublish })
+	mb.RegisterEventFunc(eventPreviewRelease, func(ctx *web.EventContext) (web.EventResponse, error) {
+		return b.previewRelease(ctx, ctx.R.FormValue(presets.ParamID))
+	})
+	mb.RegisterEventFunc(eventPublishRelease, func(ctx *web.EventContext) (web.EventResponse, error) {
+		return b.runReleaseAction(ctx, mb, ctx.R.FormValue(presets.ParamID), false)
+	})
+	mb.RegisterEventFunc(eventUnpublishRelease, func(ctx *web.EventContext) (web.EventResponse, error) {
+		return b.runReleaseAction(ctx, mb, ctx.R.FormValue(presets.ParamID), true)
+	})
+
+	eb := mb.Editing("Name", "Description", "ScheduledAt", releaseItemsField)
+	eb.ValidateFunc(func(obj interface{}, ctx *web.EventContext) (err web.ValidationErrors) {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		rel := obj.(*Release)
+		if rel.Name == "" {
+			err.FieldError("Name", msgr.ReleaseNameRequired)
+		}
+		if rel.Status != "" && rel.Status != ReleaseStatusDraft {
+			err.GlobalError(msgr.OnlyDraftReleaseCanBeChanged)
+		}
+		return
+	})
+	eb.Field("Description").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return v.VTextarea().Attr(web.VField(field.Name, field.Value(obj))...).Label(field.Label).Rows(3)
+	})
+	eb.Field("ScheduledAt").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return vx.VXDatepicker().Type("datetimepicker").
+			Format("YYYY-MM-DD HH:mm").
+			Clearable(true).
+			Attr(web.VField(field.Name, ScheduleTimeString(obj.(*Release).ScheduledAt))...).
+			Label(field.Label)
+	}).SetterFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) (err error) {
+		obj.(*Release).ScheduledAt, err = parseScheduleTimeValue(ctx.R.FormValue(field.Name))
+		return
+	})
+	eb.Field(releaseItemsField).ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return b.releaseItemsComponent(mb, obj.(*Release), ctx)
+	}).SetterFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) (err error) {
+		return nil
+	})
+
+	mb.RegisterEventFunc(eventRemoveReleaseItem, b.removeReleaseItem)
+	return nil
+}
+
+func releaseChip(rel *Release, msgr *Messages) h.HTMLComponent {
+	text, color := msgr.StatusDraft, v.ColorWarning
+	switch rel.Status {
+	case ReleaseStatusOnline:
+		text, color = msgr.StatusOnline, v.ColorSuccess
+	case ReleaseStatusOffline:
+		text, color = msgr.StatusOffline, v.ColorSecondary
+	}
+	chip := v.VChip(h.Span(text)).Density(v.DensityCompact).Color(color).Size(v.SizeSmall)
+	if rel.LastError != "" {
+		chip.PrependIcon("mdi-alert-circle-outline")
+	}
+	return chip
+}
+
+func (b *Builder) releaseItemsComponent(mb *presets.ModelBuilder, rel *Release, ctx *web.EventContext) h.HTMLComponent {
+	msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+	if rel.ID == 0 {
+		return nil
+	}
+	items, err := ReleaseItems(b.db, rel.ID)
+	if err != nil {
+		return h.Text(err.Error())
+	}
+
+	list := v.VList().Density(v.DensityCompact)
+	for _, item := range items {
+		title := i18n.T(ctx.R, presets.ModelsI18nModuleKey, item.ModelName) + " " + cmp.Or(item.ModelLabel, item.ModelKeys)
+		var link h.HTMLComponent = h.Text(title)
+		if imb, ok := b.releaseModels[item.ModelName]; ok {
+			link = h.A(h.Text(title)).Href(imb.Info().DetailingHref(item.ModelKeys)).Target("_blank")
+		}
+		li := v.VListItem(v.VListItemTitle(link)).Attr("v-if", "!locals.removedItems.includes("+strconv.Itoa(int(item.ID))+")")
+		if rel.Status == ReleaseStatusDraft {
+			li.Children(web.Slot(
+				v.VBtn("").Icon("mdi-delete-outline").Variant(v.VariantText).Size(v.SizeSmall).
+					Attr("@click", web.Plaid().
+						EventFunc(eventRemoveReleaseItem).
+						Query(presets.ParamID, rel.ID).
+						Query(paramReleaseItemID, item.ID).
+						URL(mb.Info().ListingHref()).
+						ThenScript("locals.removedItems.push("+strconv.Itoa(int(item.ID))+")").
+						Go()),
+			).Name("append"))
+		}
+		list.AppendChildren(li)
+	}
+	if len(items) == 0 {
+		list.AppendChildren(v.VListItem(v.VListItemSubtitle(h.Text(msgr.NoReleaseItems))))
+	}
+	return web.Scope(
+		h.Div(h.Label(msgr.ReleaseItems).Class("text-subtitle-2")),
+		list,
+	).VSlot("{locals}").Init("{removedItems:[]}")
+}
+
+func (b *Builder) removeReleaseItem(ctx *web.EventContext) (r web.EventResponse, err error) {
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), "error")
+			err = nil
+		}
+	}()
+
+	rel := &Release{}
+	if err = b.db.First(rel, ctx.Param(presets.ParamID)).Error; err != nil {
+		return
+	}
+	if rel.Status != ReleaseStatusDraft {
+		return r, errors.New("publish: only draft releases can be changed")
+	}
+	err = b.db.Where("release_id = ? AND id = ?", rel.ID, ctx.Param(paramReleaseItemID)).Delete(&ReleaseItem{}).Error
+	return
+}
+
+func (b *Builder) previewRelease(ctx *web.EventContext, id string) (r web.EventResponse, err error) {
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), "error")
+			err = nil
+		}
+	}()
+
+	rel := &Release{}
+	if err = b.db.First(rel, id).Error; err != nil {
+		return
+	}
+	records, err := b.releaseRecords(b.db, rel)
+	if err != nil {
+		return
+	}
+
+	msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+	cmsgr := i18n.MustGetModuleMessages(ctx.R, presets.CoreI18nModuleKey, Messages_en_US).(*presets.Messages)
+	previewCtx := b.WithContextValues(ctx.R.Context())
+	var previews h.HTMLComponents
+	for _, record := range records {
+		content, err := b.getPublishContent(previewCtx, record)
+		if err != nil {
+			return r, err
+		}
+		var body h.HTMLComponent = h.Div(h.Text(msgr.NoPreview)).Class("text-medium-emphasis")
+		if content != "" {
+			body = h.Iframe().Attr("srcdoc", content).Style("width:100%;height:480px;border:0;")
+		}
+		previews = append(previews, h.Div(
+			h.Div(h.Text(recordLabel(record))).Class("text-subtitle-1 mb-2"),
+			body,
+		).Class("mb-6"))
+	}
+	if len(previews) == 0 {
+		previews = append(previews, h.Text(msgr.NoReleaseItems))
+	}
+
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+		Name: presets.DialogPortalName,
+		Body: web.Scope(
+			vx.VXDialog(previews...).
+				Title(msgr.PreviewRelease).
+				HideCancel(true).
+				OkText(cmsgr.OK).
+				Width(1200).
+				Attr("@click:ok", "locals.previewReleaseDialog = false").
+				Attr("v-model", "locals.previewReleaseDialog"),
+		).Init("{previewReleaseDialog:true}").VSlot("{locals}"),
+	})
+	return
+}
+
+// releaseRowMenuItem adds the row menu item of a release action, its label is translated by label
+func releaseRowMenuItem(rmb *presets.RowMenuBuilder, mb *presets.ModelBuilder, event string, icon string, permAction string, label func(msgr *Messages) string) {
+	rmb.RowMenuItem(event).ComponentFunc(func(obj interface{}, id string, ctx *web.EventContext) h.HTMLComponent {
+		if permAction != "" && DeniedDo(mb.Info().Verifier(), obj, ctx.R, permAction) {
+			return nil
+		}
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return v.VListItem(
+			web.Slot(v.VIcon(icon)).Name("prepend"),
+			v.VListItemTitle(h.Text(label(msgr))),
+		).Attr("@click", web.Plaid().
+			EventFunc(event).
+			Query(presets.ParamID, id).
+			URL(mb.Info().ListingHref()).
+			Go())
+	})
+}
+
+func (b *Builder) runReleaseAction(ctx *web.EventContext, mb *presets.ModelBuilder, id string, unpublish bool) (r web.EventResponse, err error) {
+	defer func() {
+		if err != nil {
+			presets.ShowMessage(&r, err.Error(), "error")
+			err = nil
+		}
+	}()
+
+	rel := &Release{}
+	if err = b.db.First(rel, id).Error; err != nil {
+		return
+	}
+	if DeniedDo(mb.Info().Verifier(), rel, ctx.R, lo.Ternary(unpublish, PermUnpublish, PermPublish)) {
+		return r, perm.PermissionDenied
+	}
+	msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+	message := msgr.SuccessfullyPublish
+	if unpublish {
+		err = b.UnPublishRelease(ctx.R.Context(), rel)
+		message = msgr.SuccessfullyUnPublish
+	} else {
+		err = b.PublishRelease(ctx.R.Context(), rel)
+	}
+	// the last error is shown in the listing as well
+	r.Emit(mb.NotifModelsUpdated(), presets.PayloadModelsUpdated{
+		Ids:    []string{id},
+		Models: map[string]any{id: rel},
+	})
+	if err != nil {
+		return
+	}
+	presets.ShowMessage(&r, message, "")
+	return
+}
@@ -0,0 +1,304 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sunfmin/reflectutils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+	"github.com/qor5/admin/v3/worker"
+)
+
+const PruneJobName = "publish-prune-versions"
+
+// PruneFunc deletes one version removed by the retention policy, tx is the transaction of the prune
+type PruneFunc func(ctx context.Context, tx *gorm.DB, record any) error
+
+// RetentionBuilder is the retention policy of the versions of a model, a version is pruned when
+// neither rule keeps it, the latest, online and scheduled versions and the versions waiting
+// in a release or on a publish target are never pruned
+type RetentionBuilder struct {
+	publisher *Builder
+	mb        *presets.ModelBuilder
+	keepLast  int
+	keepDays  int
+}
+
+// PrunedVersion is a version removed by Prune, or that would be removed by a dry run
+type PrunedVersion struct {
+	ModelName   string
+	ModelKeys   string
+	VersionName string
+	UpdatedAt   time.Time
+}
+
+type PruneReport struct {
+	DryRun   bool
+	Versions []*PrunedVersion
+}
+
+type PruneJobArgs struct {
+	worker.Schedule
+	DryRun bool
+}
+
+// Retention returns the retention policy of the versions of mb, which Prune and the scheduler apply
+func (b *Builder) Retention(mb *presets.ModelBuilder) (r *RetentionBuilder) {
+	obj := mb.NewModel()
+	_ = obj.(VersionInterface)
+	name := utils.GetObjectName(obj)
+	if rb, ok := b.retentionModels[name]; ok {
+		return rb
+	}
+	r = &RetentionBuilder{
+		publisher: b,
+		mb:        mb,
+	}
+	b.retentionModels[name] = r
+	return r
+}
+
+// KeepLast keeps the newest v versions of each record
+func (rb *RetentionBuilder) KeepLast(v int) (r *RetentionBuilder) {
+	rb.keepLast = v
+	return rb
+}
+
+// KeepDays keeps the versions updated in the last v days
+func (rb *RetentionBuilder) KeepDays(v int) (r *RetentionBuilder) {
+	rb.keepDays = v
+	return rb
+}
+
+// WrapPrune wraps the deletion of pruned versions, e.g. to delete the data depending on them. The default one deletes
+// the rows for good, also for the models with a gorm.DeletedAt
+func (b *Builder) WrapPrune(w func(in PruneFunc) PruneFunc) (r *Builder) {
+	b.prune = w(b.prune)
+	return b
+}
+
+// PruneWorker runs the prunes of the scheduler as jobs of w, which also lets them be started or
+// dry run from the worker. Like the other jobs of w it has to be added before w is installed
+func (b *Builder) PruneWorker(w *worker.Builder) (r *Builder) {
+	b.pruneWorker = w
+	w.NewJob(PruneJobName).
+		Resource(&PruneJobArgs{}).
+		Handler(func(ctx context.Context, job worker.QorJobInterface) error {
+			info, err := job.GetJobInfo()
+			if err != nil {
+				return err
+			}
+			report, err := b.Prune(ctx, info.Argument.(*PruneJobArgs).DryRun)
+			if report != nil {
+				for _, v := range report.Versions {
+					_ = job.AddLog(fmt.Sprintf("%s %s %s", v.ModelName, v.ModelKeys, v.VersionName))
+				}
+				_ = job.AddLog(report.String())
+			}
+			return err
+		})
+	return b
+}
+
+func (r *PruneReport) String() string {
+	if r.DryRun {
+		return fmt.Sprintf("%d versions would be pruned", len(r.Versions))
+	}
+	return fmt.Sprintf("%d versions pruned", len(r.Versions))
+}
+
+func (b *Builder) defaultPrune(_ context.Context, tx *gorm.DB, record any) error {
+	// a soft delete would keep the version rows the retention policy is meant to remove
+	return tx.Unscoped().Delete(record).Error
+}
+
+// Prune deletes the versions removed by the retention policies, a dry run only reports them
+func (b *Builder) Prune(ctx context.Context, dryRun bool) (report *PruneReport, err error) {
+	report = &PruneReport{DryRun: dryRun}
+	for _, name := range sortedKeys(b.retentionModels) {
+		rb := b.retentionModels[name]
+		records, err := rb.plan(ctx)
+		if err != nil {
+			return report, err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		if !dryRun {
+			if err = b.transact(ctx, func(ctx context.Context, tx *gorm.DB) error {
+				for _, record := range records {
+					if err := b.prune(ctx, tx, record); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return report, err
+			}
+		}
+		for _, record := range records {
+			report.Versions = append(report.Versions, &PrunedVersion{
+				ModelName:   name,
+				ModelKeys:   record.(presets.SlugEncoder).PrimarySlug(),
+				VersionName: EmbedVersion(record).VersionName,
+				UpdatedAt:   versionTime(record),
+			})
+			if !dryRun {
+				b.logPrune(ctx, rb.mb, record)
+			}
+		}
+	}
+	return report, nil
+}
+
+// runPrune is called by the scheduler, through the worker when there is one
+func (b *Builder) runPrune(ctx context.Context) error {
+	if b.pruneWorker != nil {
+		_, err := b.pruneWorker.AddJob(ctx, PruneJobName, &PruneJobArgs{})
+		return err
+	}
+	_, err := b.Prune(ctx, false)
+	return err
+}
+
+// plan returns the versions of the model the policy prunes, newest first. Only the records with more versions
+// than the kept ones are loaded, one record at a time
+func (rb *RetentionBuilder) plan(ctx context.Context) (records []any, err error) {
+	if rb.keepLast <= 0 && rb.keepDays <= 0 {
+		return nil, nil
+	}
+	db := rb.publisher.db.WithContext(ctx)
+	obj := rb.mb.NewModel()
+	s, err := schema.Parse(obj, dependencySchemaCache, db.NamingStrategy)
+	if err != nil {
+		return
+	}
+	var columns []string
+	for _, p := range s.PrimaryFields {
+		if p.Name != "Version" {
+			columns = append(columns, p.DBName)
+		}
+	}
+	groups := reflect.New(reflect.SliceOf(reflect.TypeOf(obj)))
+	if err = db.Model(obj).Select(columns).Group(strings.Join(columns, ", ")).
+		Having("COUNT(*) > ?", max(rb.keepLast, 1)).Find(groups.Interface()).Error; err != nil {
+		return
+	}
+	if groups.Elem().Len() == 0 {
+		return
+	}
+	protected, err := rb.publisher.protectedVersions(db, utils.GetObjectName(obj))
+	if err != nil {
+		return
+	}
+
+	deadline := db.NowFunc().AddDate(0, 0, -rb.keepDays)
+	for i := 0; i < groups.Elem().Len(); i++ {
+		versions := reflect.New(reflect.SliceOf(reflect.TypeOf(obj)))
+		if err = setPrimaryKeysConditionWithoutVersion(db.Model(obj), groups.Elem().Index(i).Interface(), s).
+			Order("version DESC").Find(versions.Interface()).Error; err != nil {
+			return
+		}
+		for j := 0; j < versions.Elem().Len(); j++ {
+			// the latest version is always kept
+			if j == 0 || j < rb.keepLast {
+				continue
+			}
+			record := versions.Elem().Index(j).Interface()
+			if rb.keepDays > 0 && versionTime(record).After(deadline) {
+				continue
+			}
+			if !prunable(record) || protected[record.(presets.SlugEncoder).PrimarySlug()] {
+				continue
+			}
+			records = append(records, record)
+		}
+	}
+	return
+}
+
+// versionTime is the last update of the version, or the day it was created when the model has no UpdatedAt
+func versionTime(obj any) time.Time {
+	if t, err := reflectutils.Get(obj, "UpdatedAt"); err == nil {
+		if t, ok := t.(time.Time); ok && !t.IsZero() {
+			return t
+		}
+	}
+	version := EmbedVersion(obj).Version
+	if len(version) >= 10 {
+		if t, err := time.ParseInLocation("2006-01-02", version[:10], time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func prunable(obj any) bool {
+	if EmbedStatus(obj).Status == StatusOnline {
+		return false
+	}
+	if s, ok := obj.(ScheduleInterface); ok {
+		if s.EmbedSchedule().ScheduledStartAt != nil || s.EmbedSchedule().ScheduledEndAt != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// protectedVersions are the versions online or scheduled on a publish target and the ones in draft releases
+func (b *Builder) protectedVersions(db *gorm.DB, modelName string) (map[string]bool, error) {
+	protected := map[string]bool{}
+	if db.Migrator().HasTable(&TargetStatus{}) {
+		var statuses []*TargetStatus
+		if err := db.Where("model_name = ?", modelName).Find(&statuses).Error; err != nil {
+			return nil, err
+		}
+		for _, ts := range statuses {
+			if ts.Status == StatusOnline {
+				protected[ts.ModelKeys] = true
+			}
+			if ts.ScheduledKeys != "" {
+				protected[ts.ScheduledKeys] = true
+			}
+		}
+	}
+	if db.Migrator().HasTable(&Release{}) {
+		var keys []string
+		if err := db.Model(&ReleaseItem{}).
+			Joins("JOIN publish_releases ON publish_releases.id = publish_release_items.release_id AND publish_releases.deleted_at IS NULL").
+			Where("publish_release_items.model_name = ? AND publish_releases.status = ?", modelName, ReleaseStatusDraft).
+			Pluck("publish_release_items.model_keys", &keys).Error; err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			protected[k] = true
+		}
+	}
+	return protected, nil
+}
+
+func (b *Builder) logPrune(ctx context.Context, mb *presets.ModelBuilder, obj any) {
+	if b.ab == nil {
+		return
+	}
+	amb, exist := b.ab.GetModelBuilder(mb)
+	if !exist {
+		return
+	}
+	version := EmbedVersion(obj)
+	detail := map[string]string{
+		"Version":     version.Version,
+		"VersionName": version.VersionName,
+	}
+	if _, err := amb.Log(ctx, ActivityPrune, obj, detail); err != nil {
+		log.Printf("error: %s\n", err)
+	}
+}
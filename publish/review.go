@@ -0,0 +1,351 @@
+package publish
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	h "github.com/theplant/htmlgo"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	ReviewStatusPending   = "pending"
+	ReviewStatusApproved  = "approved"
+	ReviewStatusRejected  = "rejected"
+	ReviewStatusWithdrawn = "withdrawn"
+)
+
+var (
+	ErrReviewRequired = errors.New("publish: an approved review is required before publishing")
+	ErrSelfApproval   = errors.New("publish: the submitter of a review cannot approve it")
+)
+
+// ReviewRequest records one review round of a version, the rows of a record form its review history
+type ReviewRequest struct {
+	gorm.Model
+
+	ModelName     string `gorm:"index:idx_publish_review_requests_model"`
+	ModelKeys     string `gorm:"index:idx_publish_review_requests_model"`
+	ModelLabel    string
+	ModelLink     string
+	Status        string `gorm:"index"`
+	SubmitterID   string
+	SubmitComment string
+	ReviewerID    string
+	ReviewComment string
+	ReviewedAt    *time.Time
+}
+
+func (ReviewRequest) TableName() string {
+	return "publish_review_requests"
+}
+
+type ReviewBuilder struct {
+	publisher            *Builder
+	mb                   *presets.ModelBuilder
+	reviewerRoles        []string
+	disableDirectPublish bool
+}
+
+// RequireReview enables the review workflow for the model, editors with PermSubmitReview submit versions and
+// users with PermReview approve or reject them. Install only adds the review queue when a model requires reviews
+func (b *Builder) RequireReview(mb *presets.ModelBuilder) (r *ReviewBuilder) {
+	name := utils.GetObjectName(mb.NewModel())
+	if rb, ok := b.reviews[name]; ok {
+		return rb
+	}
+	r = &ReviewBuilder{
+		publisher: b,
+		mb:        mb,
+	}
+	b.reviews[name] = r
+
+	mb.Editing().WrapSaveFunc(func(in presets.SaveFunc) presets.SaveFunc {
+		return func(obj interface{}, id string, ctx *web.EventContext) (err error) {
+			if err = in(obj, id, ctx); err != nil {
+				return
+			}
+			if id == "" || ctx.R.FormValue(web.EventFuncIDName) == eventSchedulePublish {
+				return
+			}
+			// content changed after submitting, the previous review no longer applies
+			return b.withdrawReview(b.db, obj)
+		}
+	})
+	return r
+}
+
+// ReviewerRoles are notified when a version is submitted for review
+func (rb *ReviewBuilder) ReviewerRoles(v ...string) (r *ReviewBuilder) {
+	rb.reviewerRoles = v
+	return rb
+}
+
+// DisableDirectPublish only allows publishing versions whose latest review is approved
+func (rb *ReviewBuilder) DisableDirectPublish(v bool) (r *ReviewBuilder) {
+	rb.disableDirectPublish = v
+	return rb
+}
+
+func (b *Builder) reviewBuilder(obj interface{}) *ReviewBuilder {
+	return b.reviews[utils.GetObjectName(obj)]
+}
+
+// getReviewBuilder is the review builder of obj for the components built without the publisher
+func getReviewBuilder(obj interface{}) *ReviewBuilder {
+	if b := builderOf(obj); b != nil {
+		return b.reviewBuilder(obj)
+	}
+	return nil
+}
+
+func reviewModelKeys(obj interface{}) string {
+	return obj.(presets.SlugEncoder).PrimarySlug()
+}
+
+// LatestReview returns the latest review request of the version, nil if it was never submitted
+func LatestReview(db *gorm.DB, obj interface{}) (*ReviewRequest, error) {
+	rr := &ReviewRequest{}
+	err := db.Where("model_name = ? AND model_keys = ?", utils.GetObjectName(obj), reviewModelKeys(obj)).
+		Order("id DESC").First(rr).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rr, nil
+}
+
+// CheckReview returns ErrReviewRequired if the model disables direct publish and the version is not approved
+func (b *Builder) CheckReview(obj interface{}) error {
+	return b.checkReview(b.db, obj)
+}
+
+func (b *Builder) checkReview(db *gorm.DB, obj interface{}) error {
+	rb := b.reviewBuilder(obj)
+	if rb == nil || !rb.disableDirectPublish {
+		return nil
+	}
+	rr, err := LatestReview(db, obj)
+	if err != nil {
+		return err
+	}
+	if rr == nil || rr.Status != ReviewStatusApproved {
+		return ErrReviewRequired
+	}
+	return nil
+}
+
+// PublishCheckFunc returns why obj should not be published, the error is shown to the user
+type PublishCheckFunc func(ctx context.Context, obj any) error
+
+// PublishCheckError is returned by Publish when CheckPublish refuses the record
+type PublishCheckError struct {
+	Err error
+}
+
+func (e *PublishCheckError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PublishCheckError) Unwrap() error {
+	return e.Err
+}
+
+// PublishCheck adds a check run with the review check before a version is published to the default target
+func (b *Builder) PublishCheck(f PublishCheckFunc) (r *Builder) {
+	b.publishChecks = append(b.publishChecks, f)
+	return b
+}
+
+// CheckPublish runs the review check and the publish checks, Publish runs it before publishing to
+// the default target and every way of publishing goes through it
+func (b *Builder) CheckPublish(ctx context.Context, obj any) error {
+	if err := b.checkReview(b.dbFromContext(ctx), obj); err != nil {
+		return err
+	}
+	ctx = b.WithContextValues(ctx)
+	for _, f := range b.publishChecks {
+		if err := f(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Builder) currentUserID(ctx context.Context) string {
+	if b.currentUserIDFunc == nil {
+		return ""
+	}
+	uid, _ := b.currentUserIDFunc(ctx)
+	return uid
+}
+
+// SubmitReview submits the version for review, mb is the model builder linking to it from the review queue
+func (b *Builder) SubmitReview(ctx context.Context, mb *presets.ModelBuilder, obj interface{}, comment string) (rr *ReviewRequest, err error) {
+	err = utils.Transact(b.db, func(tx *gorm.DB) error {
+		latest, err := LatestReview(tx, obj)
+		if err != nil {
+			return err
+		}
+		if latest != nil && latest.Status == ReviewStatusPending {
+			return errors.New("publish: the version is already in review")
+		}
+		rr = &ReviewRequest{
+			ModelName:     utils.GetObjectName(obj),
+			ModelKeys:     reviewModelKeys(obj),
+			ModelLink:     mb.Info().DetailingHref(reviewModelKeys(obj)),
+			Status:        ReviewStatusPending,
+			SubmitterID:   b.currentUserID(ctx),
+			SubmitComment: comment,
+		}
+		if version, ok := obj.(VersionInterface); ok {
+			rr.ModelLabel = version.EmbedVersion().VersionName
+		}
+		return tx.Create(rr).Error
+	})
+	return
+}
+
+// ApproveReview approves the pending review of the version and publishes it unless it is scheduled for later,
+// both are committed together so the review stays pending when publishing fails. The submitter cannot approve
+// their own review
+func (b *Builder) ApproveReview(ctx context.Context, obj interface{}, comment string) (rr *ReviewRequest, published bool, err error) {
+	scheduled := false
+	if sc, ok := obj.(ScheduleInterface); ok {
+		if startAt := sc.EmbedSchedule().ScheduledStartAt; startAt != nil && startAt.After(b.db.NowFunc()) {
+			scheduled = true
+		}
+	}
+	err = b.transact(ctx, func(ctx context.Context, tx *gorm.DB) (err error) {
+		if rr, err = b.reviewVersion(ctx, tx, obj, ReviewStatusApproved, comment); err != nil {
+			return
+		}
+		if scheduled {
+			return
+		}
+		return b.Publish(ctx, obj)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return rr, !scheduled, nil
+}
+
+// RejectReview rejects the pending review of the version
+func (b *Builder) RejectReview(ctx context.Context, obj interface{}, comment string) (rr *ReviewRequest, err error) {
+	return b.reviewVersion(ctx, b.db, obj, ReviewStatusRejected, comment)
+}
+
+func (b *Builder) reviewVersion(ctx context.Context, db *gorm.DB, obj interface{}, status string, comment string) (rr *ReviewRequest, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		rr, err = LatestReview(tx, obj)
+		if err != nil {
+			return err
+		}
+		if rr == nil || rr.Status != ReviewStatusPending {
+			return errors.New("publish: the version is not in review")
+		}
+		reviewerID := b.currentUserID(ctx)
+		if status == ReviewStatusApproved && rr.SubmitterID != "" && rr.SubmitterID == reviewerID {
+			return ErrSelfApproval
+		}
+		now := tx.NowFunc()
+		rr.Status = status
+		rr.ReviewerID = reviewerID
+		rr.ReviewComment = comment
+		rr.ReviewedAt = &now
+		return tx.Save(rr).Error
+	})
+	return
+}
+
+func (b *Builder) withdrawReview(db *gorm.DB, obj interface{}) error {
+	if status, ok := obj.(StatusInterface); ok && status.EmbedStatus().Status != StatusDraft {
+		return nil
+	}
+	return db.Model(&ReviewRequest{}).
+		Where("model_name = ? AND model_keys = ? AND status IN ?", utils.GetObjectName(obj), reviewModelKeys(obj),
+			[]string{ReviewStatusPending, ReviewStatusApproved}).
+		Update("status", ReviewStatusWithdrawn).Error
+}
+
+func (b *Builder) configureReviewQueue(pb *presets.Builder) error {
+	if err := b.db.AutoMigrate(&ReviewRequest{}); err != nil {
+		return err
+	}
+
+	mb := pb.Model(&ReviewRequest{}).URIName("publish-review-requests").MenuIcon("mdi-clipboard-check-outline")
+	mb.LabelName(func(evCtx *web.EventContext, singular bool) string {
+		msgr := i18n.MustGetModuleMessages(evCtx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return msgr.ReviewQueue
+	})
+
+	eb := mb.Editing()
+	eb.SaveFunc(func(obj any, id string, ctx *web.EventContext) error {
+		return errors.New("should not be used")
+	})
+	eb.DeleteFunc(func(obj any, id string, ctx *web.EventContext) error {
+		return errors.New("should not be used")
+	})
+
+	lb := mb.Listing("CreatedAt", "ModelName", "ModelLabel", "Status", "SubmitterID", "SubmitComment", "ReviewerID", "ReviewComment")
+	lb.NewButtonFunc(func(ctx *web.EventContext) h.HTMLComponent { return nil })
+	lb.RowMenu().Empty()
+	lb.Field("CreatedAt").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return h.Td(h.Text(obj.(*ReviewRequest).CreatedAt.Local().Format(timeFormatSchedule)))
+	})
+	lb.Field("ModelName").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		return h.Td(h.Text(i18n.T(ctx.R, presets.ModelsI18nModuleKey, obj.(*ReviewRequest).ModelName)))
+	})
+	lb.Field("ModelLabel").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		rr := obj.(*ReviewRequest)
+		return h.Td(h.A(h.Text(cmp.Or(rr.ModelLabel, rr.ModelKeys))).Href(rr.ModelLink).Attr("@click.stop", ""))
+	})
+	lb.Field("Status").ComponentFunc(func(obj interface{}, field *presets.FieldContext, ctx *web.EventContext) h.HTMLComponent {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return h.Td(reviewChip(obj.(*ReviewRequest), msgr))
+	})
+
+	lb.FilterDataFunc(func(ctx *web.EventContext) vx.FilterData {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return []*vx.FilterItem{
+			{
+				Key:          "status",
+				Label:        msgr.HeaderStatus,
+				ItemType:     vx.ItemTypeSelect,
+				SQLCondition: `status %s ?`,
+				Options: []*vx.SelectItem{
+					{Text: msgr.ReviewStatusPending, Value: ReviewStatusPending},
+					{Text: msgr.ReviewStatusApproved, Value: ReviewStatusApproved},
+					{Text: msgr.ReviewStatusRejected, Value: ReviewStatusRejected},
+					{Text: msgr.ReviewStatusWithdrawn, Value: ReviewStatusWithdrawn},
+				},
+			},
+		}
+	})
+	lb.FilterTabsFunc(func(ctx *web.EventContext) []*presets.FilterTab {
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		return []*presets.FilterTab{
+			{
+				Label: msgr.FilterTabPendingReviews,
+				Query: url.Values{"status": []string{ReviewStatusPending}},
+			},
+			{
+				Label: msgr.FilterTabAllReviews,
+				Query: url.Values{},
+			},
+		}
+	})
+	return nil
+}
@@ -0,0 +1,229 @@
+package publish
+
+import (
+	"context"
+	"log"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	v "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+
+	"github.com/qor5/admin/v3/notification"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	paramReviewAction  = "review_action"
+	fieldReviewComment = "ReviewComment"
+
+	reviewActionSubmit  = "submit"
+	reviewActionApprove = "approve"
+	reviewActionReject  = "reject"
+)
+
+func reviewDialog(mb *presets.ModelBuilder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		var (
+			slug   = ctx.Param(presets.ParamID)
+			action = ctx.Param(paramReviewAction)
+			msgr   = i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+			cmsgr  = i18n.MustGetModuleMessages(ctx.R, presets.CoreI18nModuleKey, Messages_en_US).(*presets.Messages)
+			title  string
+			event  string
+		)
+		switch action {
+		case reviewActionSubmit:
+			title, event = msgr.SubmitForReview, eventSubmitReview
+		case reviewActionApprove:
+			title, event = msgr.Approve, eventApproveReview
+		case reviewActionReject:
+			title, event = msgr.Reject, eventRejectReview
+		default:
+			return r, errInvalidObject
+		}
+
+		r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+			Name: PortalReviewDialog,
+			Body: web.Scope(
+				vx.VXDialog(
+					v.VTextarea().Attr(web.VField(fieldReviewComment, "")...).
+						Label(msgr.ReviewComment).Rows(3).HideDetails(true),
+				).Title(title).
+					CancelText(cmsgr.Cancel).
+					OkText(cmsgr.OK).
+					Attr("@click:ok", web.Plaid().
+						EventFunc(event).
+						Query(presets.ParamID, slug).
+						URL(mb.Info().ListingHref()).
+						ThenScript("locals.reviewDialog = false").
+						Go()).
+					Attr("v-model", "locals.reviewDialog"),
+			).Init("{reviewDialog:true}").VSlot("{locals}"),
+		})
+		return
+	}
+}
+
+func submitReview(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		slug := ctx.Param(presets.ParamID)
+		obj := mb.NewModel()
+		obj, err = mb.Editing().Fetcher(obj, slug, ctx)
+		if err != nil {
+			return
+		}
+		if DeniedDo(mb.Info().Verifier(), obj, ctx.R, PermSubmitReview) {
+			return r, perm.PermissionDenied
+		}
+
+		comment := ctx.R.FormValue(fieldReviewComment)
+		rr, err := publisher.SubmitReview(ctx.R.Context(), mb, obj, comment)
+		if err != nil {
+			return
+		}
+		publisher.logReview(ctx.R.Context(), mb, obj, ActivitySubmitReview, comment)
+		if rb := publisher.reviewBuilder(obj); rb != nil && publisher.nb != nil && len(rb.reviewerRoles) > 0 {
+			msg := reviewNotification("NotificationReviewSubmitted", obj, rr)
+			msg.Body = comment
+			if err := publisher.nb.SendToRoles(ctx.R.Context(), msg, rb.reviewerRoles...); err != nil {
+				log.Printf("error: %s\n", err)
+			}
+		}
+
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		web.AppendRunScripts(&r, web.Plaid().MergeQuery(true).
+			ThenScript(presets.ShowSnackbarScript(msgr.SuccessfullySubmitForReview, v.ColorSuccess)).
+			Go(),
+		)
+		return
+	}
+}
+
+func approveReview(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		slug := ctx.Param(presets.ParamID)
+		obj := mb.NewModel()
+		obj, err = mb.Editing().Fetcher(obj, slug, ctx)
+		if err != nil {
+			return
+		}
+		if DeniedDo(mb.Info().Verifier(), obj, ctx.R, PermReview) {
+			return r, perm.PermissionDenied
+		}
+
+		comment := ctx.R.FormValue(fieldReviewComment)
+		// a version with a future schedule is left to the scheduler, otherwise it goes online right away
+		rr, published, err := publisher.ApproveReview(publisher.WithContextValues(ctx.R.Context()), obj, comment)
+		if err != nil {
+			return
+		}
+		publisher.logReview(ctx.R.Context(), mb, obj, ActivityApprove, comment)
+		if published {
+			publisher.logReview(ctx.R.Context(), mb, obj, ActivityPublish, "")
+		}
+
+		if publisher.nb != nil && rr.SubmitterID != "" {
+			msg := reviewNotification("NotificationReviewApproved", obj, rr)
+			msg.Body = comment
+			msg.Severity = notification.SeveritySuccess
+			if err := publisher.nb.SendToUsers(ctx.R.Context(), msg, rr.SubmitterID); err != nil {
+				log.Printf("error: %s\n", err)
+			}
+		}
+
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		web.AppendRunScripts(&r, web.Plaid().MergeQuery(true).
+			ThenScript(presets.ShowSnackbarScript(msgr.SuccessfullyApprove, v.ColorSuccess)).
+			Go(),
+		)
+		return
+	}
+}
+
+func rejectReview(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		slug := ctx.Param(presets.ParamID)
+		obj := mb.NewModel()
+		obj, err = mb.Editing().Fetcher(obj, slug, ctx)
+		if err != nil {
+			return
+		}
+		if DeniedDo(mb.Info().Verifier(), obj, ctx.R, PermReview) {
+			return r, perm.PermissionDenied
+		}
+
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		comment := ctx.R.FormValue(fieldReviewComment)
+		if comment == "" {
+			presets.ShowMessage(&r, msgr.RejectCommentRequired, "warning")
+			return
+		}
+		rr, err := publisher.RejectReview(ctx.R.Context(), obj, comment)
+		if err != nil {
+			return
+		}
+		publisher.logReview(ctx.R.Context(), mb, obj, ActivityReject, comment)
+
+		if publisher.nb != nil && rr.SubmitterID != "" {
+			msg := reviewNotification("NotificationReviewRejected", obj, rr)
+			msg.Body = comment
+			msg.Severity = notification.SeverityWarning
+			if err := publisher.nb.SendToUsers(ctx.R.Context(), msg, rr.SubmitterID); err != nil {
+				log.Printf("error: %s\n", err)
+			}
+		}
+
+		web.AppendRunScripts(&r, web.Plaid().MergeQuery(true).
+			ThenScript(presets.ShowSnackbarScript(msgr.SuccessfullyReject, v.ColorSuccess)).
+			Go(),
+		)
+		return
+	}
+}
+
+func reviewNotification(key string, obj any, rr *ReviewRequest) *notification.Message {
+	msg := notification.NewMessage(I18nPublishKey, Messages_en_US, key, "{model}", utils.GetObjectName(obj), "{label}", rr.ModelLabel)
+	msg.Link = rr.ModelLink
+	return msg
+}
+
+func (b *Builder) logReview(ctx context.Context, mb *presets.ModelBuilder, obj interface{}, action string, comment string) {
+	if b.ab == nil {
+		return
+	}
+	amb, exist := b.ab.GetModelBuilder(mb)
+	if !exist {
+		return
+	}
+	var detail any
+	if comment != "" {
+		detail = map[string]string{"Comment": comment}
+	}
+	if _, err := amb.Log(ctx, action, obj, detail); err != nil {
+		log.Printf("error: %s\n", err)
+	}
+}
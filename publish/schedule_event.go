@@ -34,7 +34,7 @@ func ScheduleTimeString(t *time.Time) string {
 	return t.Local().Format(timeFormatSchedule)
 }
 
-func scheduleDialog(_ *gorm.DB, mb *presets.ModelBuilder) web.EventFunc {
+func scheduleDialog(_ *gorm.DB, mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
 	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
 		slug := ctx.Param(presets.ParamID)
 		obj := mb.NewModel()
@@ -51,7 +51,8 @@ func scheduleDialog(_ *gorm.DB, mb *presets.ModelBuilder) web.EventFunc {
 		valStartAt := ScheduleTimeString(sc.EmbedSchedule().ScheduledStartAt)
 		valEndAt := ScheduleTimeString(sc.EmbedSchedule().ScheduledEndAt)
 
-		displayStartAtPicker := EmbedStatus(sc).Status != StatusOnline
+		// a record online on the default target can still be scheduled to go online on another target
+		displayStartAtPicker := EmbedStatus(sc).Status != StatusOnline || len(publisher.targets) > 0
 		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
 		cmsgr := i18n.MustGetModuleMessages(ctx.R, presets.CoreI18nModuleKey, Messages_en_US).(*presets.Messages)
 		maxWidthStr := lo.If(displayStartAtPicker, "480").Else("280")
@@ -64,6 +65,16 @@ func scheduleDialog(_ *gorm.DB, mb *presets.ModelBuilder) web.EventFunc {
 			Name: PortalSchedulePublishDialog,
 			Body: web.Scope().VSlot("{locals}").Init("{schedulePublishDialog:true}").Children(
 				vx.VXDialog(
+					h.Iff(len(publisher.targets) > 0, func() h.HTMLComponent {
+						return v.VSelect().
+							Label(msgr.Target).
+							Items(publisher.TargetNames()).
+							Density(v.DensityCompact).
+							Variant(v.VariantOutlined).
+							HideDetails(true).
+							Class("mb-4").
+							Attr(web.VField(paramTarget, DefaultTarget)...)
+					}),
 					v.VRow().Class("justify-center").Children(
 						h.If(displayStartAtPicker, v.VCol().Children(
 							vx.VXDatepicker().Type("datetimepicker").
@@ -82,7 +93,7 @@ func scheduleDialog(_ *gorm.DB, mb *presets.ModelBuilder) web.EventFunc {
 					),
 				).Attr("v-model", "locals.schedulePublishDialog").
 					Title(msgr.SchedulePublishTime).
-					ContentHeight(88).
+					ContentHeight(lo.Ternary(len(publisher.targets) > 0, 148, 88)).
 					CancelText(cmsgr.Cancel).
 					OkText(cmsgr.Update).
 					Attr(":disable-ok", "isFetching").
@@ -97,7 +108,7 @@ func scheduleDialog(_ *gorm.DB, mb *presets.ModelBuilder) web.EventFunc {
 	}
 }
 
-func schedule(db *gorm.DB, mb *presets.ModelBuilder) web.EventFunc {
+func schedule(db *gorm.DB, mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
 	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
 		defer func() {
 			if err != nil {
@@ -120,6 +131,21 @@ func schedule(db *gorm.DB, mb *presets.ModelBuilder) web.EventFunc {
 		if !ok {
 			return r, errInvalidObject
 		}
+		if target := ctx.R.FormValue(paramTarget); target != "" && target != DefaultTarget {
+			var startAt, endAt *time.Time
+			if startAt, endAt, err = targetScheduleFromForm(ctx, db); err != nil {
+				return
+			}
+			if err = publisher.ScheduleTarget(ctx.R.Context(), target, obj, startAt, endAt); err != nil {
+				return
+			}
+			web.AppendRunScripts(&r, "locals.schedulePublishDialog = false")
+			r.Emit(mb.NotifModelsUpdated(), presets.PayloadModelsUpdated{
+				Ids:    []string{slug},
+				Models: map[string]any{slug: obj},
+			})
+			return
+		}
 		if err := setScheduledTimesFromForm(ctx, sc, db, mb); err != nil {
 			return r, err
 		}
@@ -151,6 +177,28 @@ func parseScheduleTimeValue(val string) (*time.Time, error) {
 	return &t, nil
 }
 
+// targetScheduleFromForm checks the times scheduled on a target, the record is not online there so only the order matters
+func targetScheduleFromForm(ctx *web.EventContext, db *gorm.DB) (startAt, endAt *time.Time, err error) {
+	if startAt, err = parseScheduleTimeValue(ctx.R.FormValue(fieldScheduledStartAt)); err != nil {
+		return
+	}
+	if endAt, err = parseScheduleTimeValue(ctx.R.FormValue(fieldScheduledEndAt)); err != nil {
+		return
+	}
+	msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+	now := db.NowFunc()
+	if startAt != nil && !startAt.After(now) {
+		return nil, nil, errors.New(msgr.ScheduledStartAtShouldLaterThanNow)
+	}
+	if startAt != nil && endAt != nil && !endAt.After(*startAt) {
+		return nil, nil, errors.New(msgr.ScheduledEndAtShouldLaterThanStartAt)
+	}
+	if endAt != nil && !endAt.After(now) {
+		return nil, nil, errors.New(msgr.ScheduledEndAtShouldLaterThanNowOrEmpty)
+	}
+	return
+}
+
 func setScheduledTimesFromForm(ctx *web.EventContext, sc ScheduleInterface, db *gorm.DB, mb *presets.ModelBuilder) error {
 	startAt, err := parseScheduleTimeValue(ctx.R.FormValue(fieldScheduledStartAt))
 	if err != nil {
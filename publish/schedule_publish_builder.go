@@ -2,20 +2,55 @@ package publish
 
 import (
 	"context"
+	"errors"
 	"log"
 	"reflect"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/notification"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
 )
 
 type SchedulePublishBuilder struct {
-	publisher *Builder
+	publisher     *Builder
+	retries       int
+	retryInterval time.Duration
 }
 
 func NewSchedulePublishBuilder(publisher *Builder) *SchedulePublishBuilder {
 	return &SchedulePublishBuilder{
-		publisher: publisher,
+		publisher:     publisher,
+		retryInterval: time.Second,
+	}
+}
+
+// Retries sets how many more times a failed publish or unpublish of a record is tried in the same run,
+// records still failing stay scheduled and are picked up again by the next run
+func (b *SchedulePublishBuilder) Retries(times int, interval time.Duration) (r *SchedulePublishBuilder) {
+	b.retries = times
+	b.retryInterval = interval
+	return b
+}
+
+func (b *SchedulePublishBuilder) try(ctx context.Context, f func(ctx context.Context, record any) error, record any) (err error) {
+	for i := 0; ; i++ {
+		if err = f(ctx, record); err == nil || i >= b.retries {
+			return
+		}
+		// a refused record is not retried
+		var checkErr *PublishCheckError
+		if errors.As(err, &checkErr) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(b.retryInterval):
+		}
 	}
 }
 
@@ -52,7 +87,7 @@ func (b *SchedulePublishBuilder) Run(ctx context.Context, model interface{}) (er
 
 	{
 		tempRecords := records
-		scope := b.publisher.db
+		scope := b.publisher.db.WithContext(ctx)
 
 		fn, ok := ctx.Value(ctxKeyScheduleRecordsFinder{}).(ScheduleRecordsFinderFunc)
 		if ok && fn != nil {
@@ -80,8 +115,9 @@ func (b *SchedulePublishBuilder) Run(ctx context.Context, model interface{}) (er
 				}
 			}
 			record := needUnpublishReflectValues.Index(i).Interface()
-			if err2 := b.publisher.UnPublish(reqCtx, record); err2 != nil {
+			if err2 := b.try(reqCtx, b.publisher.UnPublish, record); err2 != nil {
 				log.Printf("error: %s\n", err2)
+				b.notifyFailure(reqCtx, ScheduleOperationUnPublish, record, err2)
 				err = multierror.Append(err, err2).ErrorOrNil()
 			}
 		}
@@ -89,7 +125,7 @@ func (b *SchedulePublishBuilder) Run(ctx context.Context, model interface{}) (er
 
 	{
 		tempRecords := records
-		scope := b.publisher.db
+		scope := b.publisher.db.WithContext(ctx)
 
 		fn, ok := ctx.Value(ctxKeyScheduleRecordsFinder{}).(ScheduleRecordsFinderFunc)
 		if ok && fn != nil {
@@ -110,18 +146,44 @@ func (b *SchedulePublishBuilder) Run(ctx context.Context, model interface{}) (er
 		needPublishReflectValues := reflect.ValueOf(tempRecords)
 		for i := 0; i < needPublishReflectValues.Len(); i++ {
 			record := needPublishReflectValues.Index(i).Interface()
-			if err2 := b.publisher.Publish(reqCtx, record); err2 != nil {
+			if err2 := b.try(reqCtx, b.publisher.Publish, record); err2 != nil {
+				// not approved yet, try again in the next round
+				if errors.Is(err2, ErrReviewRequired) {
+					continue
+				}
 				log.Printf("error: %s\n", err2)
+				b.notifyFailure(reqCtx, ScheduleOperationPublish, record, err2)
 				err = multierror.Append(err, err2).ErrorOrNil()
 			}
 		}
 	}
 
 	for _, record := range unpublishAfterPublishRecords {
-		if err2 := b.publisher.UnPublish(reqCtx, record); err2 != nil {
+		if err2 := b.try(reqCtx, b.publisher.UnPublish, record); err2 != nil {
 			log.Printf("error: %s\n", err2)
+			b.notifyFailure(reqCtx, ScheduleOperationUnPublish, record, err2)
 			err = multierror.Append(err, err2).ErrorOrNil()
 		}
 	}
 	return
 }
+
+func (b *SchedulePublishBuilder) notifyFailure(ctx context.Context, operation ScheduleOperation, record any, cause error) {
+	if b.publisher.nb == nil || len(b.publisher.notifyRoles) == 0 {
+		return
+	}
+	key := "NotificationSchedulePublishFailed"
+	if operation == ScheduleOperationUnPublish {
+		key = "NotificationScheduleUnPublishFailed"
+	}
+	var keys string
+	if slugger, ok := record.(presets.SlugEncoder); ok {
+		keys = slugger.PrimarySlug()
+	}
+	msg := notification.NewMessage(I18nPublishKey, Messages_en_US, key, "{model}", utils.GetObjectName(record), "{keys}", keys)
+	msg.Body = cause.Error()
+	msg.Severity = notification.SeverityError
+	if err := b.publisher.nb.SendToRoles(ctx, msg, b.publisher.notifyRoles...); err != nil {
+		log.Printf("error: %s\n", err)
+	}
+}
@@ -0,0 +1,354 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/qor5/x/v3/oss"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	DefaultSchedulerName     = "publish-scheduler"
+	DefaultSchedulerInterval = time.Minute
+	DefaultSchedulerTimeout  = 5 * time.Minute
+
+	scheduleRunJobSchedule = "schedule"
+	scheduleRunJobList     = "list"
+	scheduleRunJobRelease  = "release"
+	scheduleRunJobTarget   = "target"
+	scheduleRunJobPrune    = "prune"
+	scheduleRunJobTask     = "task"
+)
+
+// ScheduleTaskFunc is run by the scheduler every round, since is the start of its last successful run
+// or one interval ago for the first run, the windows of two runs may overlap a little
+type ScheduleTaskFunc func(ctx context.Context, since, now time.Time) error
+
+// SchedulerLease makes sure only one replica runs the scheduled publishing at a time,
+// the holder renews it every round and another replica takes over once it expires
+type SchedulerLease struct {
+	Name      string `gorm:"primaryKey;size:255"`
+	Holder    string `gorm:"size:255"`
+	ExpiresAt time.Time
+}
+
+func (SchedulerLease) TableName() string {
+	return "publish_scheduler_leases"
+}
+
+// ScheduleRun records one run of a model by the scheduler
+type ScheduleRun struct {
+	gorm.Model
+
+	Holder     string
+	Job        string
+	ModelName  string `gorm:"index"`
+	StartedAt  time.Time
+	FinishedAt time.Time
+	DurationMs int64
+	Error      string
+}
+
+func (ScheduleRun) TableName() string {
+	return "publish_schedule_runs"
+}
+
+type Scheduler struct {
+	publisher *Builder
+	db        *gorm.DB
+	storage   oss.StorageInterface
+	name      string
+	holder    string
+	interval  time.Duration
+	timeout   time.Duration
+	retries   int
+
+	retryInterval time.Duration
+
+	pruneInterval time.Duration
+}
+
+func NewScheduler(publisher *Builder) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		publisher: publisher,
+		db:        publisher.db,
+		storage:   publisher.storage,
+		name:      DefaultSchedulerName,
+		holder:    fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+		interval:  DefaultSchedulerInterval,
+		timeout:   DefaultSchedulerTimeout,
+
+		retryInterval: time.Second,
+	}
+}
+
+// Storage is used by the list publisher, the storage of the publisher by default
+func (s *Scheduler) Storage(v oss.StorageInterface) (r *Scheduler) {
+	s.storage = v
+	return s
+}
+
+// Name of the lease, schedulers with the same name elect one leader
+func (s *Scheduler) Name(v string) (r *Scheduler) {
+	s.name = v
+	return s
+}
+
+// Holder identifies this replica, hostname, pid and start time by default
+func (s *Scheduler) Holder(v string) (r *Scheduler) {
+	s.holder = v
+	return s
+}
+
+// Interval between two rounds
+func (s *Scheduler) Interval(v time.Duration) (r *Scheduler) {
+	s.interval = v
+	return s
+}
+
+// Timeout cancels the run of one model, the scheduler goes on with the next one
+func (s *Scheduler) Timeout(v time.Duration) (r *Scheduler) {
+	s.timeout = v
+	return s
+}
+
+// Retries sets how many more times a failed record is tried in the same run
+func (s *Scheduler) Retries(v int) (r *Scheduler) {
+	s.retries = v
+	return s
+}
+
+// RetryInterval is the wait before trying a failed record again, one second by default
+func (s *Scheduler) RetryInterval(v time.Duration) (r *Scheduler) {
+	s.retryInterval = v
+	return s
+}
+
+// PruneInterval enables pruning the versions by the retention policies, at most once per interval
+func (s *Scheduler) PruneInterval(v time.Duration) (r *Scheduler) {
+	s.pruneInterval = v
+	return s
+}
+
+func (s *Scheduler) AutoMigrate() error {
+	return s.db.AutoMigrate(&SchedulerLease{}, &ScheduleRun{})
+}
+
+// Run blocks and publishes or unpublishes due records every interval while this replica holds the lease,
+// it releases the lease and returns when ctx is done
+func (s *Scheduler) Run(ctx context.Context) error {
+	if err := s.AutoMigrate(); err != nil {
+		return err
+	}
+	defer s.release()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunOnce(ctx); err != nil {
+			log.Printf("publish scheduler error: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type scheduleRunJob struct {
+	job  string
+	name string
+	f    func(ctx context.Context) error
+}
+
+// RunOnce runs one round if the lease is acquired, it reports whether this replica is the leader.
+// The lease is renewed before each model and the round stops as soon as it is lost.
+func (s *Scheduler) RunOnce(ctx context.Context) (leader bool, err error) {
+	for _, j := range s.jobs() {
+		if leader, err = s.acquire(ctx); err != nil || !leader {
+			return
+		}
+		s.runModel(ctx, j.job, j.name, j.f)
+	}
+	return
+}
+
+// jobs of one round in their run order
+func (s *Scheduler) jobs() (jobs []scheduleRunJob) {
+	scheduleP := NewSchedulePublishBuilder(s.publisher).Retries(s.retries, s.retryInterval)
+	for _, name := range sortedKeys(NonVersionPublishModels) {
+		model := NonVersionPublishModels[name]
+		jobs = append(jobs, scheduleRunJob{scheduleRunJobSchedule, name, func(ctx context.Context) error {
+			return scheduleP.Run(ctx, model)
+		}})
+	}
+	for _, name := range sortedKeys(VersionPublishModels) {
+		model := VersionPublishModels[name]
+		jobs = append(jobs, scheduleRunJob{scheduleRunJobSchedule, name, func(ctx context.Context) error {
+			return scheduleP.Run(ctx, model)
+		}})
+	}
+
+	jobs = append(jobs,
+		scheduleRunJob{scheduleRunJobRelease, utils.GetObjectName(&Release{}), s.publisher.runDueReleases},
+		scheduleRunJob{scheduleRunJobTarget, utils.GetObjectName(&TargetStatus{}), s.publisher.runDueTargetSchedules},
+	)
+
+	for _, name := range sortedKeys(s.publisher.scheduleTasks) {
+		name, f := name, s.publisher.scheduleTasks[name]
+		jobs = append(jobs, scheduleRunJob{scheduleRunJobTask, name, func(ctx context.Context) error {
+			return f(ctx, s.lastRunAt(scheduleRunJobTask, name), s.db.NowFunc())
+		}})
+	}
+
+	listP := NewListPublishBuilder(s.db, s.storage).Publisher(s.publisher)
+	for _, name := range sortedKeys(ListPublishModels) {
+		model := ListPublishModels[name]
+		jobs = append(jobs, scheduleRunJob{scheduleRunJobList, name, func(ctx context.Context) error {
+			return listP.Run(ctx, model)
+		}})
+	}
+
+	if s.pruneDue() {
+		jobs = append(jobs, scheduleRunJob{scheduleRunJobPrune, PruneJobName, s.publisher.runPrune})
+	}
+	return
+}
+
+func (s *Scheduler) pruneDue() bool {
+	if s.pruneInterval <= 0 || len(s.publisher.retentionModels) == 0 {
+		return false
+	}
+	var last ScheduleRun
+	err := s.db.Where("job = ?", scheduleRunJobPrune).Order("started_at DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		log.Printf("error: %s\n", err)
+		return false
+	}
+	return last.ID == 0 || last.StartedAt.Before(s.db.NowFunc().Add(-s.pruneInterval))
+}
+
+// lastRunAt is the start of the last successful run of the job, one interval ago when there is none
+func (s *Scheduler) lastRunAt(job string, name string) time.Time {
+	var last ScheduleRun
+	err := s.db.Where("job = ? AND model_name = ? AND error = ''", job, name).Order("started_at DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		log.Printf("error: %s\n", err)
+	}
+	if last.ID == 0 {
+		return s.db.NowFunc().Add(-s.interval)
+	}
+	return last.StartedAt
+}
+
+func (s *Scheduler) runModel(ctx context.Context, job string, name string, f func(ctx context.Context) error) {
+	if ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	run := &ScheduleRun{
+		Holder:    s.holder,
+		Job:       job,
+		ModelName: name,
+		StartedAt: s.db.NowFunc(),
+	}
+	if err := f(ctx); err != nil {
+		log.Printf("publish scheduler %s %s error: %v\n", job, name, err)
+		run.Error = err.Error()
+	}
+	run.FinishedAt = s.db.NowFunc()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	if err := s.db.Create(run).Error; err != nil {
+		log.Printf("error: %s\n", err)
+	}
+}
+
+// leaseTTL outlasts the longest run of a model, so the lease renewed before it does not expire while it runs
+func (s *Scheduler) leaseTTL() time.Duration {
+	return max(3*s.interval, s.timeout+s.interval)
+}
+
+// sqliteTimestamp is the format of CURRENT_TIMESTAMP of SQLite, in UTC
+const sqliteTimestamp = "2006-01-02 15:04:05"
+
+// dbNow is the clock of the database, the lease is compared with one clock whatever the clocks of the replicas.
+// SQLite returns it as text in UTC, the drivers returning it as text in the time zone of the session,
+// like MySQL without parseTime, use the clock of the replica
+func dbNow(db *gorm.DB) (now time.Time, err error) {
+	var v any
+	if err = db.Raw("SELECT CURRENT_TIMESTAMP").Row().Scan(&v); err != nil {
+		return
+	}
+	var text string
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	}
+	if db.Dialector.Name() == "sqlite" {
+		if now, err = time.ParseInLocation(sqliteTimestamp, text, time.UTC); err == nil {
+			return
+		}
+	}
+	return db.NowFunc(), nil
+}
+
+// acquire takes the lease when it is free or expired, or renews it when this replica holds it
+func (s *Scheduler) acquire(ctx context.Context) (bool, error) {
+	db := s.db.WithContext(ctx)
+	now, err := dbNow(db)
+	if err != nil {
+		return false, err
+	}
+	expiresAt := now.Add(s.leaseTTL())
+
+	result := db.Model(&SchedulerLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", s.name, s.holder, now).
+		Updates(map[string]any{"holder": s.holder, "expires_at": expiresAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	result = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&SchedulerLease{
+		Name:      s.name,
+		Holder:    s.holder,
+		ExpiresAt: expiresAt,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// release lets another replica take over right away instead of waiting for the lease to expire
+func (s *Scheduler) release() {
+	if err := s.db.Model(&SchedulerLease{}).
+		Where("name = ? AND holder = ?", s.name, s.holder).
+		Update("expires_at", time.Time{}).Error; err != nil {
+		log.Printf("error: %s\n", err)
+	}
+}
+
+// ScheduleTask adds a task run by the schedulers of the publisher every round, like republishing the records
+// whose content depends on the time
+func (b *Builder) ScheduleTask(name string, f ScheduleTaskFunc) (r *Builder) {
+	if b.scheduleTasks == nil {
+		b.scheduleTasks = map[string]ScheduleTaskFunc{}
+	}
+	b.scheduleTasks[name] = f
+	return b
+}
@@ -0,0 +1,295 @@
+package publish
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qor5/x/v3/i18n"
+	h "github.com/theplant/htmlgo"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	shareLinkParamToken   = "token"
+	shareLinkParamExpires = "expires"
+	shareLinkParamSig     = "sig"
+	shareLinkParamPass    = "password"
+	shareLinkCookiePrefix = "publish_share_"
+)
+
+var (
+	ErrShareLinkInvalid = errors.New("publish: invalid share link")
+	ErrShareLinkExpired = errors.New("publish: share link expired")
+	// ErrShareLinkNoPreview is returned for records whose model has no PreviewBuilderInterface to render them
+	ErrShareLinkNoPreview = errors.New("publish: share links need a preview of the model")
+)
+
+// ShareLink lets people without an admin account preview one version of a record until it expires or is revoked
+type ShareLink struct {
+	gorm.Model
+
+	ModelName    string `gorm:"index:idx_publish_share_links_model"`
+	ModelKeys    string `gorm:"index:idx_publish_share_links_model"`
+	ModelLabel   string
+	Token        string `gorm:"uniqueIndex"`
+	PasswordHash string `json:"-"`
+	ExpiresAt    time.Time
+	RevokedAt    *time.Time
+	CreatedBy    string
+}
+
+func (ShareLink) TableName() string {
+	return "publish_share_links"
+}
+
+func (link *ShareLink) HasPassword() bool {
+	return link.PasswordHash != ""
+}
+
+// ShareLinks enables share links signed with secret, handlerURL is the public url ShareLinkHandler is mounted on
+func (b *Builder) ShareLinks(secret []byte, handlerURL string) (r *Builder) {
+	b.shareLinkSecret = secret
+	b.shareLinkURL = handlerURL
+	return b
+}
+
+func (b *Builder) migrateShareLinks() error {
+	b.shareLinkMigrateOnce.Do(func() {
+		b.shareLinkMigrateErr = b.db.AutoMigrate(&ShareLink{})
+	})
+	return b.shareLinkMigrateErr
+}
+
+func (b *Builder) signShareLink(token string, expires int64) string {
+	mac := hmac.New(sha256.New, b.shareLinkSecret)
+	fmt.Fprintf(mac, "%s:%d", token, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateShareLink creates a link to the version of record valid for ttl, an empty password makes it open to anyone with the url
+func (b *Builder) CreateShareLink(ctx context.Context, record any, ttl time.Duration, password string) (*ShareLink, error) {
+	if len(b.shareLinkSecret) == 0 {
+		return nil, errors.New("publish: share links are not enabled")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("publish: share link ttl should be positive")
+	}
+	slugger, ok := record.(presets.SlugEncoder)
+	if !ok {
+		return nil, errInvalidObject
+	}
+	if _, ok = b.shareLinkModels[utils.GetObjectName(record)]; !ok {
+		return nil, errors.New("publish: share links are not enabled for the model")
+	}
+	if _, ok = b.previewBuilder(ctx, record); !ok {
+		return nil, ErrShareLinkNoPreview
+	}
+	if err := b.migrateShareLinks(); err != nil {
+		return nil, err
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	link := &ShareLink{
+		ModelName: utils.GetObjectName(record),
+		ModelKeys: slugger.PrimarySlug(),
+		Token:     hex.EncodeToString(token),
+		ExpiresAt: b.db.NowFunc().Add(ttl),
+		CreatedBy: b.currentUserID(ctx),
+	}
+	if version, ok := record.(VersionInterface); ok {
+		link.ModelLabel = version.EmbedVersion().VersionName
+	}
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		link.PasswordHash = string(hash)
+	}
+	return link, b.db.WithContext(ctx).Create(link).Error
+}
+
+// ShareLinkURL returns the signed url of link
+func (b *Builder) ShareLinkURL(link *ShareLink) string {
+	expires := link.ExpiresAt.Unix()
+	q := url.Values{}
+	q.Set(shareLinkParamToken, link.Token)
+	q.Set(shareLinkParamExpires, strconv.FormatInt(expires, 10))
+	q.Set(shareLinkParamSig, b.signShareLink(link.Token, expires))
+	sep := "?"
+	if strings.Contains(b.shareLinkURL, "?") {
+		sep = "&"
+	}
+	return b.shareLinkURL + sep + q.Encode()
+}
+
+func (b *Builder) RevokeShareLink(ctx context.Context, link *ShareLink) error {
+	now := b.db.NowFunc()
+	link.RevokedAt = &now
+	return b.db.WithContext(ctx).Model(link).Update("revoked_at", link.RevokedAt).Error
+}
+
+// ActiveShareLinks returns the links of the version of record that are neither expired nor revoked
+func (b *Builder) ActiveShareLinks(ctx context.Context, record any) (links []*ShareLink, err error) {
+	slugger, ok := record.(presets.SlugEncoder)
+	if !ok {
+		return nil, errInvalidObject
+	}
+	db := b.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&ShareLink{}) {
+		return nil, nil
+	}
+	err = db.Where("model_name = ? AND model_keys = ? AND revoked_at IS NULL AND expires_at > ?",
+		utils.GetObjectName(record), slugger.PrimarySlug(), db.NowFunc()).
+		Order("id DESC").Find(&links).Error
+	return
+}
+
+// verifyShareLink checks the signature and the expiry of the url before loading the link
+func (b *Builder) verifyShareLink(q url.Values) (*ShareLink, error) {
+	token := q.Get(shareLinkParamToken)
+	expires, err := strconv.ParseInt(q.Get(shareLinkParamExpires), 10, 64)
+	if token == "" || err != nil || len(b.shareLinkSecret) == 0 {
+		return nil, ErrShareLinkInvalid
+	}
+	if !hmac.Equal([]byte(q.Get(shareLinkParamSig)), []byte(b.signShareLink(token, expires))) {
+		return nil, ErrShareLinkInvalid
+	}
+	now := b.db.NowFunc()
+	if now.Unix() >= expires {
+		return nil, ErrShareLinkExpired
+	}
+	link := &ShareLink{}
+	if err = b.db.Where("token = ?", token).First(link).Error; err != nil {
+		return nil, ErrShareLinkInvalid
+	}
+	if link.RevokedAt != nil || link.ExpiresAt.Unix() != expires {
+		return nil, ErrShareLinkInvalid
+	}
+	return link, nil
+}
+
+// ShareLinkHandler renders the preview of shared versions, it should be mounted outside of the admin login.
+// The password page follows the language of the request once the publisher is installed on the admin
+func (b *Builder) ShareLinkHandler() http.Handler {
+	handler := b.shareLinkHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b.i18nBuilder != nil {
+			b.i18nBuilder.EnsureLanguage(handler).ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (b *Builder) shareLinkHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		w.Header().Set("Cache-Control", "no-store")
+
+		link, err := b.verifyShareLink(r.URL.Query())
+		if err != nil {
+			status := http.StatusNotFound
+			if errors.Is(err, ErrShareLinkExpired) {
+				status = http.StatusGone
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		if link.HasPassword() {
+			cookieName := shareLinkCookiePrefix + link.Token
+			cookieValue := b.signShareLink(shareLinkParamPass+":"+link.Token, link.ExpiresAt.Unix())
+			c, cerr := r.Cookie(cookieName)
+			if cerr != nil || !hmac.Equal([]byte(c.Value), []byte(cookieValue)) {
+				if r.Method != http.MethodPost ||
+					bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(r.FormValue(shareLinkParamPass))) != nil {
+					w.Header().Set("Content-Type", "text/html; charset=utf-8")
+					if r.Method == http.MethodPost {
+						w.WriteHeader(http.StatusUnauthorized)
+					}
+					_ = h.Fprint(w, shareLinkPasswordPage(r, r.Method == http.MethodPost), r.Context())
+					return
+				}
+				http.SetCookie(w, &http.Cookie{
+					Name:     cookieName,
+					Value:    cookieValue,
+					Path:     "/",
+					Expires:  link.ExpiresAt,
+					HttpOnly: true,
+					Secure:   true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+		}
+
+		html, err := b.shareLinkPreview(r.Context(), link)
+		if err != nil {
+			if !errors.Is(err, ErrShareLinkInvalid) && !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("error: %s\n", err)
+			}
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(html))
+	})
+}
+
+func (b *Builder) shareLinkPreview(ctx context.Context, link *ShareLink) (string, error) {
+	mb, ok := b.shareLinkModels[link.ModelName]
+	if !ok {
+		return "", ErrShareLinkInvalid
+	}
+	obj := mb.NewModel()
+	if err := utils.PrimarySluggerWhere(b.db, obj, link.ModelKeys).First(obj).Error; err != nil {
+		return "", err
+	}
+	pb, ok := b.previewBuilder(ctx, obj)
+	if !ok {
+		return "", ErrShareLinkInvalid
+	}
+	return pb.PreviewHTML(obj), nil
+}
+
+// previewBuilder returns the preview of the model of obj provided by the context value funcs
+func (b *Builder) previewBuilder(ctx context.Context, obj any) (PreviewBuilderInterface, bool) {
+	pb, ok := b.WithContextValues(ctx).Value(utils.GetObjectName(obj)).(PreviewBuilderInterface)
+	return pb, ok
+}
+
+func shareLinkPasswordPage(r *http.Request, wrong bool) h.HTMLComponent {
+	msgr := i18n.MustGetModuleMessages(r, I18nPublishKey, Messages_en_US).(*Messages)
+	return h.HTML(
+		h.Head(
+			h.Meta().Charset("utf-8"),
+			h.Meta().Name("robots").Content("noindex, nofollow"),
+			h.Title(msgr.ShareLinkPasswordRequired),
+		),
+		h.Body(
+			h.Form(
+				h.P(h.Text(msgr.ShareLinkPasswordRequired)),
+				h.If(wrong, h.P(h.Text(msgr.ShareLinkWrongPassword)).Style("color:#d32f2f")),
+				h.Input(shareLinkParamPass).Type("password").Attr("autofocus", true),
+				h.Button(msgr.ShareLinkView).Type("submit"),
+			).Method(http.MethodPost).Style("max-width:320px;margin:80px auto;font-family:sans-serif"),
+		),
+	)
+}
@@ -0,0 +1,194 @@
+package publish
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	v "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	fieldShareLinkTTLHours = "ShareLinkTTLHours"
+	fieldShareLinkPassword = "ShareLinkPassword"
+	paramShareLinkID       = "share_link_id"
+)
+
+func buildShareLinkButton(obj interface{}, ctx *web.EventContext, mb *presets.ModelBuilder, slug string, msgr *Messages, phraseHasPresetsDataChanged string) h.HTMLComponent {
+	b := builderOf(obj)
+	if b == nil {
+		return nil
+	}
+	if _, ok := b.shareLinkModels[utils.GetObjectName(obj)]; !ok {
+		return nil
+	}
+	if _, ok := b.previewBuilder(ctx.R.Context(), obj); !ok {
+		return nil
+	}
+	return h.Components(
+		v.VBtn(msgr.Share).
+			Attr(":disabled", phraseHasPresetsDataChanged).
+			Attr("@click", web.Plaid().
+				EventFunc(eventShareLinkDialog).
+				Query(presets.ParamID, slug).
+				URL(mb.Info().ListingHref()).Go()).
+			Class("ml-2").Variant(v.VariantOutlined).Color(v.ColorPrimary).Height(36),
+		web.Portal().Name(PortalShareLinkDialog),
+	)
+}
+
+func shareLinkDialog(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		obj, err := fetchShareLinkRecord(ctx, mb)
+		if err != nil {
+			return
+		}
+		return r, updateShareLinkDialog(ctx, &r, mb, publisher, obj)
+	}
+}
+
+func fetchShareLinkRecord(ctx *web.EventContext, mb *presets.ModelBuilder) (obj any, err error) {
+	obj = mb.NewModel()
+	if obj, err = mb.Editing().Fetcher(obj, ctx.Param(presets.ParamID), ctx); err != nil {
+		return
+	}
+	if DeniedDo(mb.Info().Verifier(), obj, ctx.R, PermShareLink) {
+		return nil, perm.PermissionDenied
+	}
+	return
+}
+
+// updateShareLinkDialog renders the active links of the version and the form creating a new one
+func updateShareLinkDialog(ctx *web.EventContext, r *web.EventResponse, mb *presets.ModelBuilder, publisher *Builder, obj any) error {
+	links, err := publisher.ActiveShareLinks(ctx.R.Context(), obj)
+	if err != nil {
+		return err
+	}
+	msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+	cmsgr := i18n.MustGetModuleMessages(ctx.R, presets.CoreI18nModuleKey, Messages_en_US).(*presets.Messages)
+	slug := ctx.Param(presets.ParamID)
+
+	var list h.HTMLComponent = h.Div(h.Text(msgr.NoShareLinks)).Class("text-medium-emphasis mb-4")
+	if len(links) > 0 {
+		list = v.VTable(
+			h.Thead(h.Tr(
+				h.Th(msgr.ShareLinkExpiresAt),
+				h.Th(msgr.ShareLinkPassword),
+				h.Th(""),
+			)),
+			h.Tbody(lo.Map(links, func(link *ShareLink, _ int) h.HTMLComponent {
+				return h.Tr(
+					h.Td(h.Text(link.ExpiresAt.Local().Format(timeFormatSchedule))).Class("text-no-wrap"),
+					h.Td(h.If(link.HasPassword(), v.VIcon("mdi-lock").Size(v.SizeSmall))),
+					h.Td(
+						v.VBtn(msgr.CopyShareLink).Variant(v.VariantText).Size(v.SizeSmall).Color(v.ColorPrimary).
+							Attr("@click", fmt.Sprintf(`$event.view.window.navigator.clipboard.writeText(%q);vars.presetsMessage = { show: true, message: %q, color: %q}`,
+								publisher.ShareLinkURL(link), msgr.ShareLinkCopied, v.ColorSuccess)),
+						v.VBtn(msgr.RevokeShareLink).Variant(v.VariantText).Size(v.SizeSmall).Color(v.ColorError).
+							Attr("@click", web.Plaid().
+								EventFunc(eventRevokeShareLink).
+								Query(presets.ParamID, slug).
+								Query(paramShareLinkID, link.ID).
+								URL(mb.Info().ListingHref()).
+								Go()),
+					).Class("text-right text-no-wrap"),
+				)
+			})...),
+		).Density(v.DensityCompact).Class("mb-4")
+	}
+
+	r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+		Name: PortalShareLinkDialog,
+		Body: web.Scope(
+			vx.VXDialog(
+				list,
+				v.VRow(
+					v.VCol(
+						v.VSelect().Attr(web.VField(fieldShareLinkTTLHours, 24*7)...).
+							Items([]map[string]any{
+								{"title": msgr.ShareLinkOneDay, "value": 24},
+								{"title": msgr.ShareLinkOneWeek, "value": 24 * 7},
+								{"title": msgr.ShareLinkOneMonth, "value": 24 * 30},
+							}).
+							Label(msgr.ShareLinkExpiresAt).HideDetails(true),
+					),
+					v.VCol(
+						v.VTextField().Attr(web.VField(fieldShareLinkPassword, "")...).
+							Type("password").
+							Label(msgr.ShareLinkPassword).
+							Placeholder(msgr.ShareLinkPasswordOptional).
+							HideDetails(true),
+					),
+				),
+			).Title(msgr.Share).
+				Width(640).
+				CancelText(cmsgr.Cancel).
+				OkText(msgr.CreateShareLink).
+				Attr("@click:ok", web.Plaid().
+					EventFunc(eventCreateShareLink).
+					Query(presets.ParamID, slug).
+					URL(mb.Info().ListingHref()).
+					Go()).
+				Attr("v-model", "locals.shareLinkDialog"),
+		).Init("{shareLinkDialog:true}").VSlot("{locals}"),
+	})
+	return nil
+}
+
+func createShareLink(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		obj, err := fetchShareLinkRecord(ctx, mb)
+		if err != nil {
+			return
+		}
+		hours, err := strconv.Atoi(ctx.R.FormValue(fieldShareLinkTTLHours))
+		if err != nil {
+			return
+		}
+		if _, err = publisher.CreateShareLink(ctx.R.Context(), obj, time.Duration(hours)*time.Hour, ctx.R.FormValue(fieldShareLinkPassword)); err != nil {
+			return
+		}
+		return r, updateShareLinkDialog(ctx, &r, mb, publisher, obj)
+	}
+}
+
+func revokeShareLink(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		obj, err := fetchShareLinkRecord(ctx, mb)
+		if err != nil {
+			return
+		}
+		link := &ShareLink{}
+		if err = publisher.db.Where("model_name = ? AND model_keys = ?", utils.GetObjectName(obj), ctx.Param(presets.ParamID)).
+			First(link, ctx.R.FormValue(paramShareLinkID)).Error; err != nil {
+			return
+		}
+		if err = publisher.RevokeShareLink(ctx.R.Context(), link); err != nil {
+			return
+		}
+		return r, updateShareLinkDialog(ctx, &r, mb, publisher, obj)
+	}
+}
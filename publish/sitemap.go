@@ -0,0 +1,411 @@
+package publish
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunfmin/reflectutils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const (
+	sitemapIndexPath   = "sitemap.xml"
+	sitemapRobotsPath  = "robots.txt"
+	sitemapMaxURLs     = 50000
+	sitemapXMLNS       = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sitemapXHTMLNS     = "http://www.w3.org/1999/xhtml"
+	sitemapLocaleField = "LocaleCode"
+
+	defaultSitemapDebounce = 10 * time.Second
+)
+
+// SitemapBuilder writes sitemap.xml and robots.txt for the online records with an OnlineUrl,
+// one child sitemap per model and locale. The child sitemaps of the records published or unpublished are regenerated
+// outside of the publishing transaction once no other record changed during the debounce delay
+type SitemapBuilder struct {
+	publisher   *Builder
+	baseURL     string
+	hreflang    func(localeCode string) string
+	robotsRules []string
+	debounce    time.Duration
+
+	mu      sync.Mutex
+	pending map[sitemapChild]bool
+	timer   *time.Timer
+}
+
+// sitemapChild is the model and the locale of the child sitemaps waiting for the regeneration
+type sitemapChild struct {
+	model  string
+	locale string
+}
+
+type (
+	sitemapURLSet struct {
+		XMLName xml.Name     `xml:"urlset"`
+		XMLNS   string       `xml:"xmlns,attr"`
+		XHTML   string       `xml:"xmlns:xhtml,attr,omitempty"`
+		URLs    []sitemapURL `xml:"url"`
+	}
+	sitemapURL struct {
+		Loc        string             `xml:"loc"`
+		LastMod    string             `xml:"lastmod,omitempty"`
+		Alternates []sitemapAlternate `xml:"xhtml:link"`
+	}
+	sitemapAlternate struct {
+		Rel      string `xml:"rel,attr"`
+		Hreflang string `xml:"hreflang,attr"`
+		Href     string `xml:"href,attr"`
+	}
+	sitemapIndex struct {
+		XMLName  xml.Name       `xml:"sitemapindex"`
+		XMLNS    string         `xml:"xmlns,attr"`
+		Sitemaps []sitemapEntry `xml:"sitemap"`
+	}
+	sitemapEntry struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod,omitempty"`
+	}
+)
+
+// Sitemap enables the sitemap of the site served at baseURL for the models with a status installed on b
+func (b *Builder) Sitemap(baseURL string) (r *SitemapBuilder) {
+	if b.sitemap == nil {
+		b.sitemap = &SitemapBuilder{
+			publisher: b,
+			hreflang:  func(localeCode string) string { return localeCode },
+			debounce:  defaultSitemapDebounce,
+			pending:   map[sitemapChild]bool{},
+		}
+		b.StoragePaths(func(_ context.Context, db *gorm.DB) ([]string, error) {
+			children, err := b.sitemap.childPaths(db)
+			return append(children, sitemapIndexPath, sitemapRobotsPath), err
+		})
+	}
+	b.sitemap.baseURL = strings.TrimSuffix(baseURL, "/")
+	return b.sitemap
+}
+
+// Hreflang maps the locale codes of l10n to the language codes of the alternates, by default the locale code is used
+func (b *SitemapBuilder) Hreflang(v func(localeCode string) string) (r *SitemapBuilder) {
+	b.hreflang = v
+	return b
+}
+
+// RobotsRules are written to robots.txt before the Sitemap line, "User-agent: *" followed by "Allow: /" by default
+func (b *SitemapBuilder) RobotsRules(v ...string) (r *SitemapBuilder) {
+	b.robotsRules = v
+	return b
+}
+
+// Debounce is how long the regeneration waits for other records to be published, 10 seconds by default
+func (b *SitemapBuilder) Debounce(v time.Duration) (r *SitemapBuilder) {
+	b.debounce = v
+	return b
+}
+
+// Generate writes the child sitemaps of all models, the index and robots.txt
+func (b *SitemapBuilder) Generate(ctx context.Context) error {
+	b.mu.Lock()
+	b.pending = map[sitemapChild]bool{}
+	b.mu.Unlock()
+
+	ctx = b.publisher.WithContextValues(ctx)
+	db := b.publisher.db.WithContext(ctx)
+	for _, name := range sortedKeys(b.publisher.sitemapModels) {
+		mb := b.publisher.sitemapModels[name]
+		locales, err := b.locales(db, mb.NewModel())
+		if err != nil {
+			return err
+		}
+		for _, locale := range locales {
+			if err = b.writeChildren(ctx, db, mb, locale); err != nil {
+				return err
+			}
+		}
+	}
+	return b.writeIndex(ctx, db)
+}
+
+// schedule queues the child sitemaps of the model and locale of record, they are written by Flush after the debounce delay
+func (b *SitemapBuilder) schedule(record any) {
+	name := utils.GetObjectName(record)
+	if _, ok := b.publisher.sitemapModels[name]; !ok {
+		return
+	}
+	locale, _ := sitemapLocale(record)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[sitemapChild{model: name, locale: locale}] = true
+	if b.timer != nil {
+		b.timer.Reset(b.debounce)
+		return
+	}
+	b.timer = time.AfterFunc(b.debounce, func() {
+		if err := b.Flush(context.Background()); err != nil {
+			log.Printf("error: %s\n", err)
+		}
+	})
+}
+
+// Flush writes the child sitemaps queued by publishing and the index without waiting for the debounce delay
+func (b *SitemapBuilder) Flush(ctx context.Context) (err error) {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = map[sitemapChild]bool{}
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			// they are written with the next publishing
+			b.mu.Lock()
+			for c := range pending {
+				b.pending[c] = true
+			}
+			b.mu.Unlock()
+		}
+	}()
+
+	ctx = b.publisher.WithContextValues(ctx)
+	db := b.publisher.db.WithContext(ctx)
+	for c := range pending {
+		mb, ok := b.publisher.sitemapModels[c.model]
+		if !ok {
+			continue
+		}
+		if err = b.writeChildren(ctx, db, mb, c.locale); err != nil {
+			return
+		}
+	}
+	return b.writeIndex(ctx, db)
+}
+
+func sitemapLocale(obj any) (string, bool) {
+	v, err := reflectutils.Get(obj, sitemapLocaleField)
+	if err != nil {
+		return "", false
+	}
+	locale, ok := v.(string)
+	return locale, ok
+}
+
+func sitemapOnline(tx *gorm.DB, obj any) *gorm.DB {
+	return tx.Model(obj).Where("status = ? AND online_url <> ''", StatusOnline)
+}
+
+func (b *SitemapBuilder) locales(tx *gorm.DB, obj any) (locales []string, err error) {
+	if _, ok := sitemapLocale(obj); !ok {
+		return []string{""}, nil
+	}
+	err = sitemapOnline(tx, obj).Distinct("locale_code").Order("locale_code").Pluck("locale_code", &locales).Error
+	return
+}
+
+type sitemapCount struct {
+	LocaleCode string
+	Count      int64
+}
+
+// counts returns the number of online records of each locale of the model of obj in one query
+func (b *SitemapBuilder) counts(tx *gorm.DB, obj any) (counts []sitemapCount, err error) {
+	if _, ok := sitemapLocale(obj); !ok {
+		var count int64
+		err = sitemapOnline(tx, obj).Count(&count).Error
+		return []sitemapCount{{Count: count}}, err
+	}
+	err = sitemapOnline(tx, obj).Select("locale_code, COUNT(*) AS count").
+		Group("locale_code").Order("locale_code").Scan(&counts).Error
+	return
+}
+
+func (b *SitemapBuilder) childPath(mb *presets.ModelBuilder, locale string, page int) string {
+	name := "sitemap-" + mb.Info().URIName()
+	if locale != "" {
+		name += "-" + strings.ToLower(locale)
+	}
+	if page > 0 {
+		name += fmt.Sprintf("-%d", page+1)
+	}
+	return name + ".xml"
+}
+
+func (b *SitemapBuilder) loc(onlineUrl string) string {
+	return b.baseURL + "/" + strings.TrimPrefix(strings.TrimSuffix(onlineUrl, "index.html"), "/")
+}
+
+// alternateKeys identifies the localized copies of a record, which only differ in the locale code and the version
+func alternateKeys(tx *gorm.DB, obj any) string {
+	s, err := schema.Parse(obj, dependencySchemaCache, tx.NamingStrategy)
+	if err != nil {
+		return ""
+	}
+	var keys []string
+	for _, p := range s.PrimaryFields {
+		if p.Name == "Version" || p.Name == sitemapLocaleField {
+			continue
+		}
+		val, _ := p.ValueOf(context.Background(), reflect.Indirect(reflect.ValueOf(obj)))
+		keys = append(keys, fmt.Sprint(val))
+	}
+	return strings.Join(keys, "_")
+}
+
+func (b *SitemapBuilder) onlineRecords(tx *gorm.DB, mb *presets.ModelBuilder, locale string) ([]any, error) {
+	obj := mb.NewModel()
+	records := reflect.New(reflect.SliceOf(reflect.TypeOf(obj)))
+	db := sitemapOnline(tx, obj)
+	if _, ok := sitemapLocale(obj); ok && locale != "" {
+		db = db.Where("locale_code = ?", locale)
+	}
+	if err := db.Order("online_url").Find(records.Interface()).Error; err != nil {
+		return nil, err
+	}
+	var r []any
+	for i := 0; i < records.Elem().Len(); i++ {
+		r = append(r, records.Elem().Index(i).Interface())
+	}
+	return r, nil
+}
+
+func (b *SitemapBuilder) writeChildren(ctx context.Context, tx *gorm.DB, mb *presets.ModelBuilder, locale string) error {
+	records, err := b.onlineRecords(tx, mb, locale)
+	if err != nil {
+		return err
+	}
+
+	// the copies of the records in the other locales become the hreflang alternates
+	alternates := map[string][]sitemapAlternate{}
+	if locale != "" {
+		all, err := b.onlineRecords(tx, mb, "")
+		if err != nil {
+			return err
+		}
+		for _, obj := range all {
+			objLocale, _ := sitemapLocale(obj)
+			key := alternateKeys(tx, obj)
+			alternates[key] = append(alternates[key], sitemapAlternate{
+				Rel:      "alternate",
+				Hreflang: b.hreflang(objLocale),
+				Href:     b.loc(EmbedStatus(obj).OnlineUrl),
+			})
+		}
+	}
+
+	var urls []sitemapURL
+	for _, obj := range records {
+		u := sitemapURL{Loc: b.loc(EmbedStatus(obj).OnlineUrl)}
+		if t, err := reflectutils.Get(obj, "UpdatedAt"); err == nil {
+			if t, ok := t.(time.Time); ok && !t.IsZero() {
+				u.LastMod = t.UTC().Format(time.RFC3339)
+			}
+		}
+		if alts := alternates[alternateKeys(tx, obj)]; len(alts) > 1 {
+			u.Alternates = alts
+		}
+		urls = append(urls, u)
+	}
+
+	// a locale without online records has no sitemap, the one without locales keeps an empty one listed in the index
+	pages := (len(urls) + sitemapMaxURLs - 1) / sitemapMaxURLs
+	if pages == 0 && locale == "" {
+		pages = 1
+	}
+	var objs []*PublishAction
+	for page := 0; page < pages; page++ {
+		end := min((page+1)*sitemapMaxURLs, len(urls))
+		set := sitemapURLSet{XMLNS: sitemapXMLNS, URLs: urls[page*sitemapMaxURLs : end]}
+		if locale != "" {
+			set.XHTML = sitemapXHTMLNS
+		}
+		content, err := sitemapXML(set)
+		if err != nil {
+			return err
+		}
+		objs = append(objs, &PublishAction{Url: b.childPath(mb, locale, page), Content: content})
+	}
+	// the pages left from a longer sitemap
+	for page := pages; ; page++ {
+		path := b.childPath(mb, locale, page)
+		f, err := b.publisher.storage.GetStream(ctx, path)
+		if err != nil {
+			if isNotExist(err) {
+				break
+			}
+			return err
+		}
+		f.Close()
+		objs = append(objs, &PublishAction{Url: path, IsDelete: true})
+	}
+	return UploadOrDelete(ctx, objs, b.publisher.storage)
+}
+
+// childPaths are the child sitemaps of the online records listed in the index
+func (b *SitemapBuilder) childPaths(tx *gorm.DB) (paths []string, err error) {
+	for _, name := range sortedKeys(b.publisher.sitemapModels) {
+		mb := b.publisher.sitemapModels[name]
+		counts, err := b.counts(tx, mb.NewModel())
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range counts {
+			for page := 0; page == 0 || int64(page*sitemapMaxURLs) < c.Count; page++ {
+				paths = append(paths, b.childPath(mb, c.LocaleCode, page))
+			}
+		}
+	}
+	return
+}
+
+func (b *SitemapBuilder) writeIndex(ctx context.Context, tx *gorm.DB) error {
+	index := sitemapIndex{XMLNS: sitemapXMLNS}
+	lastMod := tx.NowFunc().UTC().Format(time.RFC3339)
+	children, err := b.childPaths(tx)
+	if err != nil {
+		return err
+	}
+	for _, path := range children {
+		index.Sitemaps = append(index.Sitemaps, sitemapEntry{
+			Loc:     b.baseURL + "/" + path,
+			LastMod: lastMod,
+		})
+	}
+	content, err := sitemapXML(index)
+	if err != nil {
+		return err
+	}
+
+	rules := b.robotsRules
+	if len(rules) == 0 {
+		rules = []string{"User-agent: *", "Allow: /"}
+	}
+	robots := strings.Join(rules, "\n") + "\n\nSitemap: " + b.baseURL + "/" + sitemapIndexPath + "\n"
+	return UploadOrDelete(ctx, []*PublishAction{
+		{Url: sitemapIndexPath, Content: content},
+		{Url: sitemapRobotsPath, Content: robots},
+	}, b.publisher.storage)
+}
+
+func sitemapXML(v any) (string, error) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(out), nil
+}
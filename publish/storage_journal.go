@@ -0,0 +1,142 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/qor5/x/v3/oss"
+)
+
+// storageJournal keeps the urls written by UploadOrDelete and whether they existed, so the objects created are
+// deleted when the transaction publishing them rolls back. When it is revertible it keeps their previous content
+// as well, so the changed objects are put back too
+type storageJournal struct {
+	mu         sync.Mutex
+	revertible bool
+	entries    []*storageJournalEntry
+	seen       map[storageJournalKey]bool
+}
+
+type storageJournalEntry struct {
+	storage oss.StorageInterface
+	url     string
+	existed bool
+	content []byte
+}
+
+// objects are tracked per storage since the same url can be written to several publish targets
+type storageJournalKey struct {
+	storage oss.StorageInterface
+	url     string
+}
+
+type ctxKeyStorageJournal struct{}
+
+// withStorageJournal returns the journal already carried by ctx, nested publishing shares the outermost one,
+// reading the previous content of every object is costly, so only a revertible journal does it
+func withStorageJournal(ctx context.Context, revertible bool) (context.Context, *storageJournal, bool) {
+	if j, ok := ctx.Value(ctxKeyStorageJournal{}).(*storageJournal); ok {
+		return ctx, j, false
+	}
+	j := &storageJournal{revertible: revertible, seen: map[storageJournalKey]bool{}}
+	return context.WithValue(ctx, ctxKeyStorageJournal{}, j), j, true
+}
+
+func storageJournalFromContext(ctx context.Context) *storageJournal {
+	j, _ := ctx.Value(ctxKeyStorageJournal{}).(*storageJournal)
+	return j
+}
+
+// record saves whether url exists, and its content for a revertible journal, before its first change
+func (j *storageJournal) record(ctx context.Context, storage oss.StorageInterface, url string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	key := storageJournalKey{storage: storage, url: url}
+	if j.seen[key] {
+		return nil
+	}
+	j.seen[key] = true
+
+	entry := &storageJournalEntry{storage: storage, url: url}
+	r, err := storage.GetStream(ctx, url)
+	if !j.revertible {
+		// an object whose existence is unknown is kept on a rollback
+		entry.existed = err == nil || !isNotExist(err)
+		if err == nil {
+			r.Close()
+		}
+		j.entries = append(j.entries, entry)
+		return nil
+	}
+	switch {
+	case err == nil:
+		defer r.Close()
+		if entry.content, err = io.ReadAll(r); err != nil {
+			j.seen[key] = false
+			return err
+		}
+		entry.existed = true
+	case !isNotExist(err):
+		// the object could not be put back, so it is not written
+		j.seen[key] = false
+		return err
+	}
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+// isNotExist reports whether err of a storage tells the object does not exist,
+// the file system storage returns fs.ErrNotExist and the cloud storages an error code
+func isNotExist(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+	var coder interface{ ErrorCode() string }
+	if errors.As(err, &coder) {
+		switch coder.ErrorCode() {
+		case "NoSuchKey", "NotFound":
+			return true
+		}
+	}
+	return false
+}
+
+// urls returns the urls written to storage
+func (j *storageJournal) urls(storage oss.StorageInterface) (r []string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, entry := range j.entries {
+		if entry.storage == storage {
+			r = append(r, entry.url)
+		}
+	}
+	return
+}
+
+// revert deletes the objects created and puts back the changed ones in reverse order, a journal that is
+// not revertible leaves the changed objects as they are
+func (j *storageJournal) revert(ctx context.Context) (err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		entry := j.entries[i]
+		var err2 error
+		switch {
+		case !entry.existed:
+			err2 = entry.storage.Delete(ctx, entry.url)
+		case j.revertible:
+			_, err2 = entry.storage.Put(ctx, entry.url, bytes.NewReader(entry.content))
+		}
+		if err2 != nil {
+			err = multierror.Append(err, err2).ErrorOrNil()
+		}
+	}
+	j.entries = nil
+	j.seen = map[storageJournalKey]bool{}
+	return
+}
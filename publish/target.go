@@ -0,0 +1,319 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/qor5/x/v3/oss"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+// DefaultTarget is the storage given to New, the status of a record is its status on this target
+const DefaultTarget = "production"
+
+// Target is another storage records are published to, e.g. a staging bucket for QA
+type Target struct {
+	Name    string
+	Storage oss.StorageInterface
+	// BaseURL replaces the endpoint of the storage in FullUrl
+	BaseURL string
+}
+
+// TargetStatus tracks a record on a target other than the default one,
+// one version of a record is online on a target at a time
+type TargetStatus struct {
+	ID               uint   `gorm:"primarykey"`
+	Target           string `gorm:"uniqueIndex:idx_publish_target_statuses_record"`
+	ModelName        string `gorm:"uniqueIndex:idx_publish_target_statuses_record"`
+	RecordKeys       string `gorm:"uniqueIndex:idx_publish_target_statuses_record"`
+	ModelKeys        string
+	Status           string
+	OnlineUrl        string
+	PublishedAt      *time.Time
+	ScheduledKeys    string
+	ScheduledStartAt *time.Time `gorm:"index"`
+	ScheduledEndAt   *time.Time `gorm:"index"`
+}
+
+func (TargetStatus) TableName() string {
+	return "publish_target_statuses"
+}
+
+// Target adds a named target publishing to storage, it is chosen per call with WithTarget
+func (b *Builder) Target(name string, storage oss.StorageInterface, baseURL string) (r *Builder) {
+	if name == DefaultTarget {
+		panic(fmt.Sprintf("publish: %s is the storage of the builder", DefaultTarget))
+	}
+	b.targets = append(b.targets, &Target{Name: name, Storage: storage, BaseURL: baseURL})
+	return b
+}
+
+// TargetNames returns the default target followed by the added ones
+func (b *Builder) TargetNames() []string {
+	names := []string{DefaultTarget}
+	for _, t := range b.targets {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+type ctxKeyTarget struct{}
+
+// WithTarget makes Publish, UnPublish and the dry runs work on the named target
+func WithTarget(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ctxKeyTarget{}, name)
+}
+
+// TargetFromContext returns the target set by WithTarget, DefaultTarget if none
+func TargetFromContext(ctx context.Context) string {
+	if name, ok := ctx.Value(ctxKeyTarget{}).(string); ok && name != "" {
+		return name
+	}
+	return DefaultTarget
+}
+
+func isDefaultTarget(ctx context.Context) bool {
+	return TargetFromContext(ctx) == DefaultTarget
+}
+
+func (b *Builder) getTarget(name string) (*Target, error) {
+	if name == DefaultTarget {
+		return &Target{Name: DefaultTarget, Storage: b.storage}, nil
+	}
+	for _, t := range b.targets {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("publish: unknown target %s", name)
+}
+
+// storageFor returns the storage of the target of ctx, unknown targets are refused by Publish and UnPublish before
+func (b *Builder) storageFor(ctx context.Context) oss.StorageInterface {
+	if t, err := b.getTarget(TargetFromContext(ctx)); err == nil {
+		return t.Storage
+	}
+	return b.storage
+}
+
+// checkTarget refuses unknown targets and creates the status table before the first publishing to a target
+func (b *Builder) checkTarget(ctx context.Context) error {
+	if isDefaultTarget(ctx) {
+		return nil
+	}
+	if _, err := b.getTarget(TargetFromContext(ctx)); err != nil {
+		return err
+	}
+	return b.migrateTargets()
+}
+
+func (b *Builder) migrateTargets() error {
+	b.targetMigrateOnce.Do(func() {
+		b.targetMigrateErr = b.db.AutoMigrate(&TargetStatus{})
+	})
+	return b.targetMigrateErr
+}
+
+// GetTargetStatus returns the status of record on the target, nil if it was never published there
+func (b *Builder) GetTargetStatus(ctx context.Context, name string, record any) (*TargetStatus, error) {
+	db := b.dbFromContext(ctx)
+	if !db.Migrator().HasTable(&TargetStatus{}) {
+		return nil, nil
+	}
+	keys, err := dependencyKeys(db, record)
+	if err != nil {
+		return nil, err
+	}
+	ts := &TargetStatus{}
+	err = db.Where("target = ? AND model_name = ? AND record_keys = ?", name, utils.GetObjectName(record), keys).First(ts).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return ts, err
+}
+
+// recordOnTarget copies record with the status it has on the target, so the actions delete the right urls
+func (b *Builder) recordOnTarget(ctx context.Context, record any) (any, *TargetStatus, error) {
+	ts, err := b.GetTargetStatus(ctx, TargetFromContext(ctx), record)
+	if err != nil {
+		return nil, nil, err
+	}
+	record = copyRecord(record)
+	if st, ok := record.(StatusInterface); ok {
+		st.EmbedStatus().Status = StatusDraft
+		st.EmbedStatus().OnlineUrl = ""
+		if ts != nil {
+			st.EmbedStatus().Status = ts.Status
+			st.EmbedStatus().OnlineUrl = ts.OnlineUrl
+		}
+	}
+	return record, ts, nil
+}
+
+func (b *Builder) publishToTarget(ctx context.Context, record any) error {
+	slugger, ok := record.(presets.SlugEncoder)
+	if !ok {
+		return errInvalidObject
+	}
+	return b.transact(ctx, func(ctx context.Context, tx *gorm.DB) (err error) {
+		onTarget, ts, err := b.recordOnTarget(ctx, record)
+		if err != nil {
+			return
+		}
+		objs, err := b.getPublishActions(ctx, onTarget)
+		if err != nil {
+			return
+		}
+		storage := b.storageFor(ctx)
+		if err = UploadOrDelete(ctx, objs, storage); err != nil {
+			return
+		}
+		if r, ok := onTarget.(AfterPublishInterface); ok {
+			if err = r.AfterPublish(ctx, tx, storage); err != nil {
+				return
+			}
+		}
+
+		if ts == nil {
+			ts = &TargetStatus{Target: TargetFromContext(ctx), ModelName: utils.GetObjectName(record)}
+			if ts.RecordKeys, err = dependencyKeys(tx, record); err != nil {
+				return
+			}
+		}
+		now := tx.NowFunc()
+		ts.ModelKeys = slugger.PrimarySlug()
+		ts.Status = StatusOnline
+		ts.PublishedAt = &now
+		if st, ok := onTarget.(StatusInterface); ok {
+			ts.OnlineUrl = st.EmbedStatus().OnlineUrl
+		}
+		if ts.ScheduledKeys == ts.ModelKeys {
+			ts.ScheduledKeys, ts.ScheduledStartAt = "", nil
+		}
+		return tx.Save(ts).Error
+	})
+}
+
+func (b *Builder) unpublishFromTarget(ctx context.Context, record any) error {
+	return b.transact(ctx, func(ctx context.Context, tx *gorm.DB) (err error) {
+		onTarget, ts, err := b.recordOnTarget(ctx, record)
+		if err != nil {
+			return
+		}
+		if ts == nil || ts.Status != StatusOnline {
+			return nil
+		}
+		objs, err := b.getUnPublishActions(ctx, onTarget)
+		if err != nil {
+			return
+		}
+		storage := b.storageFor(ctx)
+		if err = UploadOrDelete(ctx, objs, storage); err != nil {
+			return
+		}
+		if r, ok := onTarget.(AfterUnPublishInterface); ok {
+			if err = r.AfterUnPublish(ctx, tx, storage); err != nil {
+				return
+			}
+		}
+		ts.Status = StatusOffline
+		ts.ScheduledEndAt = nil
+		return tx.Select("Status", "ScheduledEndAt").Save(ts).Error
+	})
+}
+
+// PromoteTarget publishes the version of record that is online on the target to the default target,
+// like any publishing to the default target it is refused when the version does not pass CheckPublish
+func (b *Builder) PromoteTarget(ctx context.Context, from string, record any) error {
+	ts, err := b.GetTargetStatus(ctx, from, record)
+	if err != nil {
+		return err
+	}
+	if ts == nil || ts.Status != StatusOnline {
+		return fmt.Errorf("publish: %s is not online on %s", recordLabel(record), from)
+	}
+	obj, err := b.targetRecord(ts.ModelName, ts.ModelKeys)
+	if err != nil {
+		return err
+	}
+	return b.Publish(WithTarget(ctx, DefaultTarget), obj)
+}
+
+func (b *Builder) targetRecord(modelName, modelKeys string) (any, error) {
+	mb, ok := b.targetModels[modelName]
+	if !ok {
+		return nil, fmt.Errorf("publish: %s has no targets", modelName)
+	}
+	obj := mb.NewModel()
+	if err := utils.PrimarySluggerWhere(b.db, obj, modelKeys).First(obj).Error; err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// ScheduleTarget sets when record goes online and offline on a target other than the default one
+func (b *Builder) ScheduleTarget(ctx context.Context, name string, record any, startAt, endAt *time.Time) error {
+	slugger, ok := record.(presets.SlugEncoder)
+	if !ok {
+		return errInvalidObject
+	}
+	if _, err := b.getTarget(name); err != nil {
+		return err
+	}
+	if err := b.migrateTargets(); err != nil {
+		return err
+	}
+	ts, err := b.GetTargetStatus(ctx, name, record)
+	if err != nil {
+		return err
+	}
+	if ts == nil {
+		ts = &TargetStatus{Target: name, ModelName: utils.GetObjectName(record), Status: StatusDraft}
+		if ts.RecordKeys, err = dependencyKeys(b.db, record); err != nil {
+			return err
+		}
+	}
+	ts.ScheduledKeys = slugger.PrimarySlug()
+	ts.ScheduledStartAt = startAt
+	ts.ScheduledEndAt = endAt
+	return b.dbFromContext(ctx).Save(ts).Error
+}
+
+// runDueTargetSchedules publishes and unpublishes the records scheduled on the targets
+func (b *Builder) runDueTargetSchedules(ctx context.Context) (err error) {
+	db := b.db.WithContext(ctx)
+	if len(b.targets) == 0 || !db.Migrator().HasTable(&TargetStatus{}) {
+		return nil
+	}
+	now := db.NowFunc()
+	var due []*TargetStatus
+	if err = db.Where("scheduled_start_at <= ? OR scheduled_end_at <= ?", now, now).Order("id").Find(&due).Error; err != nil {
+		return
+	}
+	for _, ts := range due {
+		tctx := WithTarget(ctx, ts.Target)
+		var err2 error
+		if ts.ScheduledStartAt != nil && !ts.ScheduledStartAt.After(now) {
+			var obj any
+			if obj, err2 = b.targetRecord(ts.ModelName, ts.ScheduledKeys); err2 == nil {
+				err2 = b.Publish(tctx, obj)
+			}
+		} else if ts.Status == StatusOnline {
+			var obj any
+			if obj, err2 = b.targetRecord(ts.ModelName, ts.ModelKeys); err2 == nil {
+				err2 = b.UnPublish(tctx, obj)
+			}
+		} else {
+			err2 = db.Model(ts).Update("scheduled_end_at", nil).Error
+		}
+		if err2 != nil {
+			err = multierror.Append(err, fmt.Errorf("%s %s %s: %w", ts.Target, ts.ModelName, ts.RecordKeys, err2)).ErrorOrNil()
+		}
+	}
+	return
+}
@@ -0,0 +1,164 @@
+package publish
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	v "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+// hasTargets reports whether obj is a model of a publisher with targets
+func hasTargets(obj any) bool {
+	b := builderOf(obj)
+	return b != nil && b.targetModels[utils.GetObjectName(obj)] != nil
+}
+
+// onlineTargetStatuses returns the targets obj is online on, without targets or before any publishing to one it is empty
+func onlineTargetStatuses(db *gorm.DB, obj any) []*TargetStatus {
+	if !hasTargets(obj) || !db.Migrator().HasTable(&TargetStatus{}) {
+		return nil
+	}
+	keys, err := dependencyKeys(db, obj)
+	if err != nil {
+		return nil
+	}
+	var statuses []*TargetStatus
+	db.Where("model_name = ? AND record_keys = ? AND status = ?", utils.GetObjectName(obj), keys, StatusOnline).
+		Order("target").Find(&statuses)
+	return statuses
+}
+
+func targetVersionName(db *gorm.DB, obj any, ts *TargetStatus) string {
+	online := reflect.New(reflect.TypeOf(obj).Elem()).Interface()
+	if err := utils.PrimarySluggerWhere(db, online, ts.ModelKeys).First(online).Error; err != nil {
+		return ts.ModelKeys
+	}
+	if version, ok := online.(VersionInterface); ok {
+		return version.EmbedVersion().VersionName
+	}
+	return ts.ModelKeys
+}
+
+func targetChips(db *gorm.DB, obj any) h.HTMLComponent {
+	statuses := onlineTargetStatuses(db, obj)
+	if len(statuses) == 0 {
+		return nil
+	}
+	return h.Components(lo.Map(statuses, func(ts *TargetStatus, _ int) h.HTMLComponent {
+		return v.VChip(h.Span(fmt.Sprintf("%s: %s", ts.Target, targetVersionName(db, obj, ts)))).
+			Density(v.DensityProminent).Color(v.ColorInfo).Size(v.SizeSmall).Class("ml-2")
+	})...)
+}
+
+func buildPromoteTargetButton(obj interface{}, mb *presets.ModelBuilder, slug string, msgr *Messages, phraseHasPresetsDataChanged string) h.HTMLComponent {
+	if !hasTargets(obj) {
+		return nil
+	}
+	if _, ok := obj.(StatusInterface); !ok {
+		return nil
+	}
+	return h.Components(
+		v.VBtn(msgr.Promote).
+			Attr(":disabled", phraseHasPresetsDataChanged).
+			Attr("@click", web.Plaid().
+				EventFunc(eventPromoteTargetDialog).
+				Query(presets.ParamID, slug).
+				URL(mb.Info().ListingHref()).Go()).
+			Class("ml-2").Variant(v.VariantOutlined).Color(v.ColorPrimary).Height(36),
+		web.Portal().Name(PortalPromoteTargetDialog),
+	)
+}
+
+// promoteTargetDialog lets the user choose which of the targets the record is online on to promote from
+func promoteTargetDialog(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		slug := ctx.Param(presets.ParamID)
+		obj := mb.NewModel()
+		obj, err = mb.Editing().Fetcher(obj, slug, ctx)
+		if err != nil {
+			return
+		}
+
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		cmsgr := i18n.MustGetModuleMessages(ctx.R, presets.CoreI18nModuleKey, Messages_en_US).(*presets.Messages)
+		statuses := onlineTargetStatuses(publisher.db, obj)
+		if len(statuses) == 0 {
+			presets.ShowMessage(&r, msgr.NotOnlineOnTargets, "warning")
+			return
+		}
+		items := lo.Map(statuses, func(ts *TargetStatus, _ int) map[string]string {
+			return map[string]string{
+				"title": fmt.Sprintf("%s: %s", ts.Target, targetVersionName(publisher.db, obj, ts)),
+				"value": ts.Target,
+			}
+		})
+
+		r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+			Name: PortalPromoteTargetDialog,
+			Body: web.Scope(
+				vx.VXDialog(
+					v.VSelect().Attr(web.VField(paramTarget, statuses[0].Target)...).
+						Items(items).
+						Label(msgr.PromoteFrom).HideDetails(true),
+				).Title(msgr.Promote).
+					CancelText(cmsgr.Cancel).
+					OkText(msgr.Promote).
+					Attr("@click:ok", web.Plaid().
+						EventFunc(eventPromoteTarget).
+						Query(presets.ParamID, slug).
+						URL(mb.Info().ListingHref()).
+						ThenScript("locals.promoteTargetDialog = false").
+						Go()).
+					Attr("v-model", "locals.promoteTargetDialog"),
+			).Init("{promoteTargetDialog:true}").VSlot("{locals}"),
+		})
+		return
+	}
+}
+
+func promoteTarget(mb *presets.ModelBuilder, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		slug := ctx.Param(presets.ParamID)
+		obj := mb.NewModel()
+		obj, err = mb.Editing().Fetcher(obj, slug, ctx)
+		if err != nil {
+			return
+		}
+		if DeniedDo(mb.Info().Verifier(), obj, ctx.R, PermPublish) {
+			return r, perm.PermissionDenied
+		}
+
+		if err = publisher.PromoteTarget(publisher.WithContextValues(ctx.R.Context()), ctx.R.FormValue(paramTarget), obj); err != nil {
+			return
+		}
+		if publisher.ab != nil {
+			if amb, exist := publisher.ab.GetModelBuilder(mb); exist {
+				amb.Log(ctx.R.Context(), ActivityPromoteTarget, obj, nil)
+			}
+		}
+
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		web.AppendRunScripts(&r, web.Plaid().MergeQuery(true).
+			ThenScript(presets.ShowSnackbarScript(msgr.SuccessfullyPublish, v.ColorSuccess)).
+			Go(),
+		)
+		return
+	}
+}
@@ -4,54 +4,29 @@ import (
 	"context"
 	"log"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/qor5/web/v3"
 	"github.com/qor5/x/v3/i18n"
 	"github.com/qor5/x/v3/oss"
+	"github.com/samber/lo"
 	"gorm.io/gorm"
 
 	vx "github.com/qor5/x/v3/ui/vuetifyx"
 )
 
-const (
-	schedulePublishJobNamePrefix = "schedule-publisher"
-	listPublishJobNamePrefix     = "list-publisher"
-)
-
+// RunPublisher blocks and runs the scheduled publishing until ctx is done, only one of the replicas sharing db publishes at a time
 func RunPublisher(ctx context.Context, db *gorm.DB, storage oss.StorageInterface, publisher *Builder) {
-	{ // schedule publisher
-		scheduleP := NewSchedulePublishBuilder(publisher)
-
-		for name, model := range NonVersionPublishModels {
-			go RunJob(schedulePublishJobNamePrefix+"-"+name, time.Minute, time.Minute*5, func() {
-				if err := scheduleP.Run(ctx, model); err != nil {
-					log.Printf("schedule publisher error: %v\n", err)
-				}
-			})
-		}
-
-		for name, model := range VersionPublishModels {
-			go RunJob(schedulePublishJobNamePrefix+"-"+name, time.Minute, time.Minute*5, func() {
-				if err := scheduleP.Run(ctx, model); err != nil {
-					log.Printf("schedule publisher error: %v\n", err)
-				}
-			})
-		}
-	}
-
-	{ // list publisher
-		listP := NewListPublishBuilder(db, storage)
-		for name, model := range ListPublishModels {
-			go RunJob(listPublishJobNamePrefix+"-"+name, time.Minute, time.Minute*5, func() {
-				if err := listP.Run(ctx, model); err != nil {
-					log.Printf("schedule publisher error: %v\n", err)
-				}
-			})
-		}
+	s := NewScheduler(publisher).Storage(storage)
+	s.db = db
+	if err := s.Run(ctx); err != nil {
+		log.Printf("schedule publisher error: %v\n", err)
 	}
 }
 
+// RunJob runs f every interval at the first second of the next minute, and exits the process when f takes longer than timeout.
+// Every replica running it runs f, use Scheduler to run the scheduled publishing on one replica at a time.
 func RunJob(jobName string, interval time.Duration, timeout time.Duration, f func()) {
 	second := 1
 	ticker := time.NewTicker(interval)
@@ -82,6 +57,13 @@ func RunJob(jobName string, interval time.Duration, timeout time.Duration, f fun
 	}
 }
 
+// sortedKeys are the names of a registry in a stable order
+func sortedKeys[V any](m map[string]V) []string {
+	names := lo.Keys(m)
+	sort.Strings(names)
+	return names
+}
+
 const FilterKeyLive = "live"
 
 func NewLiveFilterItem(ctx context.Context, columnPrefix string) (*vx.FilterItem, error) {
@@ -93,10 +93,28 @@ func DefaultVersionComponentFunc(mb *presets.ModelBuilder, cfg ...VersionCompone
 		deniedPublish := DeniedDo(verifier, obj, ctx.R, PermPublish)
 		deniedUnpublish := DeniedDo(verifier, obj, ctx.R, PermUnpublish)
 
+		if rb := getReviewBuilder(obj); rb != nil {
+			latest, _ := LatestReview(rb.publisher.db, obj)
+			if rb.disableDirectPublish && (latest == nil || latest.Status != ReviewStatusApproved) {
+				deniedPublish = true
+			}
+			div.AppendChildren(buildReviewButtons(obj, mb, slug, latest, ctx, msgr, phraseHasPresetsDataChanged))
+		}
+
 		if _, ok := obj.(StatusInterface); ok {
 			div.AppendChildren(buildPublishButton(obj, field, ctx, config, msgr, phraseHasPresetsDataChanged, deniedPublish, deniedUnpublish))
 		}
 
+		if !deniedPublish {
+			div.AppendChildren(buildAddToReleaseButton(obj, mb, slug, msgr, phraseHasPresetsDataChanged))
+			div.AppendChildren(buildPromoteTargetButton(obj, mb, slug, msgr, phraseHasPresetsDataChanged))
+			div.AppendChildren(buildPurgeButton(obj, mb, slug, msgr))
+		}
+
+		if !DeniedDo(verifier, obj, ctx.R, PermShareLink) {
+			div.AppendChildren(buildShareLinkButton(obj, ctx, mb, slug, msgr, phraseHasPresetsDataChanged))
+		}
+
 		if _, ok := obj.(ScheduleInterface); ok {
 			div.AppendChildren(buildScheduleButton(obj, ctx, mb, slug, config, msgr, phraseHasPresetsDataChanged, deniedPublish, deniedUnpublish))
 		}
@@ -145,11 +163,12 @@ func buildPublishButton(obj interface{}, field *presets.FieldContext, ctx *web.E
 		return nil
 	}
 
+	slug := obj.(presets.SlugEncoder).PrimarySlug()
 	var publishBtn h.HTMLComponent
 	switch status.EmbedStatus().Status {
 	case StatusDraft, StatusOffline:
 		if !deniedPublish {
-			publishEvent := fmt.Sprintf(`locals.action=%q;locals.commonConfirmDialog = true`, EventPublish)
+			publishEvent := dryRunDialogEvent(field, slug, EventPublish)
 			if config.PublishEvent != nil {
 				publishEvent = config.PublishEvent(obj, field, ctx)
 			}
@@ -164,13 +183,13 @@ func buildPublishButton(obj interface{}, field *presets.FieldContext, ctx *web.E
 	case StatusOnline:
 		var unPublishEvent, rePublishEvent string
 		if !deniedUnpublish {
-			unPublishEvent = fmt.Sprintf(`locals.action=%q;locals.commonConfirmDialog = true`, EventUnpublish)
+			unPublishEvent = dryRunDialogEvent(field, slug, EventUnpublish)
 			if config.UnPublishEvent != nil {
 				unPublishEvent = config.UnPublishEvent(obj, field, ctx)
 			}
 		}
 		if !deniedPublish {
-			rePublishEvent = fmt.Sprintf(`locals.action=%q;locals.commonConfirmDialog = true`, EventRepublish)
+			rePublishEvent = dryRunDialogEvent(field, slug, EventRepublish)
 			if config.RePublishEvent != nil {
 				rePublishEvent = config.RePublishEvent(obj, field, ctx)
 			}
@@ -204,6 +223,85 @@ func buildPublishButton(obj interface{}, field *presets.FieldContext, ctx *web.E
 	return nil
 }
 
+func dryRunDialogEvent(field *presets.FieldContext, slug string, event string) string {
+	return web.Plaid().
+		EventFunc(eventPublishDryRunDialog).
+		Query(presets.ParamID, slug).
+		Query(paramPublishEvent, event).
+		URL(field.ModelInfo.ListingHref()).Go()
+}
+
+func buildReviewButtons(obj interface{}, mb *presets.ModelBuilder, slug string, latest *ReviewRequest, ctx *web.EventContext, msgr *Messages, phraseHasPresetsDataChanged string) h.HTMLComponent {
+	if status, ok := obj.(StatusInterface); ok && status.EmbedStatus().Status == StatusOnline {
+		return nil
+	}
+	openDialog := func(action string) string {
+		return web.Plaid().
+			EventFunc(eventReviewDialog).
+			Query(presets.ParamID, slug).
+			Query(paramReviewAction, action).
+			URL(mb.Info().ListingHref()).Go()
+	}
+	verifier := mb.Info().Verifier()
+	var btns h.HTMLComponents
+	if latest != nil && latest.Status == ReviewStatusPending {
+		if DeniedDo(verifier, obj, ctx.R, PermReview) {
+			return nil
+		}
+		btns = append(btns,
+			v.VBtn(msgr.Reject).
+				Attr(":disabled", phraseHasPresetsDataChanged).
+				Attr("@click", openDialog(reviewActionReject)).
+				Class("ml-2").Variant(v.VariantOutlined).Color(v.ColorError).Height(36),
+			v.VBtn(msgr.Approve).
+				Attr(":disabled", phraseHasPresetsDataChanged).
+				Attr("@click", openDialog(reviewActionApprove)).
+				Class("ml-2").Variant(v.VariantElevated).Color(v.ColorSuccess).Height(36),
+		)
+	} else if latest == nil || latest.Status != ReviewStatusApproved {
+		if DeniedDo(verifier, obj, ctx.R, PermSubmitReview) {
+			return nil
+		}
+		btns = append(btns,
+			v.VBtn(msgr.SubmitForReview).
+				Attr(":disabled", phraseHasPresetsDataChanged).
+				Attr("@click", openDialog(reviewActionSubmit)).
+				Class("ml-2").Variant(v.VariantOutlined).Color(v.ColorPrimary).Height(36),
+		)
+	}
+	if len(btns) == 0 {
+		return nil
+	}
+	return h.Components(btns, web.Portal().Name(PortalReviewDialog))
+}
+
+func reviewChip(rr *ReviewRequest, msgr *Messages) h.HTMLComponent {
+	if rr == nil {
+		return nil
+	}
+	var (
+		text  string
+		color string
+	)
+	switch rr.Status {
+	case ReviewStatusPending:
+		text, color = msgr.ReviewStatusPending, v.ColorWarning
+	case ReviewStatusApproved:
+		text, color = msgr.ReviewStatusApproved, v.ColorSuccess
+	case ReviewStatusRejected:
+		text, color = msgr.ReviewStatusRejected, v.ColorError
+	default:
+		return nil
+	}
+	chip := v.VChip(h.Span(text)).Density(v.DensityCompact).Color(color).Size(v.SizeSmall).Class("mr-2")
+	if rr.Status == ReviewStatusRejected && rr.ReviewComment != "" {
+		return v.VTooltip(
+			web.Slot(chip.Attr("v-bind", "props")).Name("activator").Scope("{ props }"),
+		).Text(rr.ReviewComment).Location(v.LocationBottom)
+	}
+	return chip
+}
+
 func buildScheduleButton(obj interface{}, ctx *web.EventContext, mb *presets.ModelBuilder, slug string, config VersionComponentConfig, msgr *Messages, phraseHasPresetsDataChanged string, deniedPublish, deniedUnpublish bool) h.HTMLComponent {
 	_, ok := obj.(ScheduleInterface)
 	if !ok {
@@ -248,6 +346,14 @@ func DefaultVersionBar(db *gorm.DB) presets.ObjectComponentFunc {
 		slugDncoderIf := obj.(presets.SlugDecoder)
 		mp := slugDncoderIf.PrimaryColumnValuesBySlug(slugEncoderIf.PrimarySlug())
 
+		if getReviewBuilder(obj) != nil {
+			if rr, err := LatestReview(db, obj); err == nil {
+				res.AppendChildren(reviewChip(rr, msgr))
+			}
+		}
+
+		res.AppendChildren(targetChips(db, obj))
+
 		currentObj := reflect.New(reflect.TypeOf(obj).Elem()).Interface()
 		err := db.Where("id = ?", mp["id"]).Where("status = ?", StatusOnline).First(&currentObj).Error
 		if err != nil {
@@ -314,7 +420,7 @@ func configureVersionListDialog(db *gorm.DB, pb *Builder, b *presets.Builder, pm
 	})
 
 	listingHref := mb.Info().ListingHref()
-	registerEventFuncsForVersion(mb, db)
+	registerEventFuncsForVersion(mb, pm, pb, db)
 	listingFields := []string{"Version", "Status", "StartAt", "EndAt", "Option"}
 	if pb.ab != nil {
 		defer func() {
@@ -418,6 +524,8 @@ func configureVersionListDialog(db *gorm.DB, pb *Builder, b *presets.Builder, pm
 		verifier := mb.Info().Verifier()
 		deniedUpdate := DeniedDo(verifier, obj, ctx.R, presets.PermUpdate)
 		deniedDelete := DeniedDo(verifier, obj, ctx.R, presets.PermDelete)
+		deniedRollback := DeniedDo(verifier, obj, ctx.R, presets.PermUpdate, PermDuplicate)
+		selected := MustFilterQuery(presets.ListingCompoFromEventContext(ctx)).Get(filterKeySelected)
 		return h.Td().Children(
 			v.VBtn(msgr.Rename).Disabled(disable || deniedUpdate).PrependIcon("mdi-rename-box").Size(v.SizeXSmall).Color(v.ColorPrimary).Variant(v.VariantText).
 				On("click.stop", web.Plaid().
@@ -437,6 +545,25 @@ func configureVersionListDialog(db *gorm.DB, pb *Builder, b *presets.Builder, pm
 					Query(paramVersionName, versionName).
 					Go(),
 				),
+			v.VBtn(msgr.Compare).Disabled(selected == "" || selected == id).PrependIcon("mdi-compare-horizontal").Size(v.SizeXSmall).Color(v.ColorPrimary).Variant(v.VariantText).
+				On("click.stop", web.Plaid().
+					URL(listingHref).
+					EventFunc(eventCompareVersionDialog).
+					Query(presets.ParamOverlay, actions.Dialog).
+					Query(presets.ParamID, id).
+					Query(paramCompareWith, selected).
+					Go(),
+				),
+			// only offline versions are restored, see RollbackVersion
+			v.VBtn(msgr.Rollback).Disabled(status != StatusOffline || deniedRollback).PrependIcon("mdi-restore").Size(v.SizeXSmall).Color(v.ColorPrimary).Variant(v.VariantText).
+				On("click.stop", web.Plaid().
+					URL(listingHref).
+					EventFunc(eventRollbackVersionDialog).
+					Query(presets.ParamOverlay, actions.Dialog).
+					Query(presets.ParamID, id).
+					Query(paramVersionName, versionName).
+					Go(),
+				),
 		)
 	})
 	lb.NewButtonFunc(func(ctx *web.EventContext) h.HTMLComponent { return nil })
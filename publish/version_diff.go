@@ -0,0 +1,128 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	v "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	"github.com/samber/lo"
+	h "github.com/theplant/htmlgo"
+
+	"github.com/qor5/admin/v3/activity"
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+// VersionDiffFunc compares two versions of the same record, old and new are fetched models
+type VersionDiffFunc func(ctx context.Context, old, new any) ([]activity.Diff, error)
+
+const paramCompareWith = "compare_with"
+
+// fields that always differ between versions
+var versionDiffIgnoredFields = []string{"Version", "Status", "Schedule"}
+
+func (b *Builder) WrapVersionDiff(w func(in VersionDiffFunc) VersionDiffFunc) (r *Builder) {
+	b.versionDiff = w(b.versionDiff)
+	return b
+}
+
+func (b *Builder) defaultVersionDiff(_ context.Context, old, new any) ([]activity.Diff, error) {
+	var amb *activity.ModelBuilder
+	if b.ab != nil {
+		amb, _ = b.ab.GetModelBuilder(new)
+	}
+	diffs, err := activity.NewDiffBuilder(amb).Diff(old, new)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(diffs, func(d activity.Diff, _ int) bool {
+		top, _, _ := strings.Cut(d.Field, ".")
+		return !lo.Contains(versionDiffIgnoredFields, top)
+	}), nil
+}
+
+// DiffVersions returns the field level differences from version old to version new
+func (b *Builder) DiffVersions(ctx context.Context, old, new any) ([]activity.Diff, error) {
+	return b.versionDiff(ctx, old, new)
+}
+
+func compareVersionDialog(mb *presets.ModelBuilder, pb *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		utilMsgr := i18n.MustGetModuleMessages(ctx.R, utils.I18nUtilsKey, utils.Messages_en_US).(*utils.Messages)
+
+		oldID, newID := ctx.R.FormValue(paramCompareWith), ctx.R.FormValue(presets.ParamID)
+		slugDecoder := mb.NewModel().(presets.SlugDecoder)
+		if oldID == "" || slugWithoutVersion(slugDecoder, oldID) != slugWithoutVersion(slugDecoder, newID) {
+			return r, errors.New(msgr.CompareVersionsOfDifferentRecords)
+		}
+		oldObj, err := mb.Editing().Fetcher(mb.NewModel(), oldID, ctx)
+		if err != nil {
+			return
+		}
+		newObj, err := mb.Editing().Fetcher(mb.NewModel(), newID, ctx)
+		if err != nil {
+			return
+		}
+		if DeniedDo(mb.Info().Verifier(), oldObj, ctx.R, presets.PermGet) ||
+			DeniedDo(mb.Info().Verifier(), newObj, ctx.R, presets.PermGet) {
+			return r, perm.PermissionDenied
+		}
+
+		diffs, err := pb.DiffVersions(ctx.R.Context(), oldObj, newObj)
+		if err != nil {
+			return
+		}
+
+		oldName := oldObj.(VersionInterface).EmbedVersion().VersionName
+		newName := newObj.(VersionInterface).EmbedVersion().VersionName
+
+		var body h.HTMLComponent
+		if len(diffs) == 0 {
+			body = h.Div(h.Text(msgr.NoDifferences)).Class("text-center pa-4")
+		} else {
+			rows := lo.Map(diffs, func(d activity.Diff, _ int) h.HTMLComponent {
+				return h.Tr(
+					h.Td(h.Text(d.Field)),
+					h.Td(h.Div().Text(d.Old).Style("white-space: pre-wrap").Attr("v-pre", true)).Class(lo.Ternary(d.Old != "", "bg-red-lighten-5", "")),
+					h.Td(h.Div().Text(d.New).Style("white-space: pre-wrap").Attr("v-pre", true)).Class(lo.Ternary(d.New != "", "bg-green-lighten-5", "")),
+				)
+			})
+			body = v.VTable(
+				h.Thead(h.Tr(
+					h.Th(msgr.DiffField),
+					h.Th(oldName),
+					h.Th(newName),
+				)),
+				h.Tbody(rows...),
+			).Density(v.DensityCompact)
+		}
+
+		r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+			Name: presets.DialogPortalName,
+			Body: web.Scope(
+				vx.VXDialog(
+					h.Div(body).Style("max-height: 60vh").Class("overflow-y-auto"),
+				).Title(msgr.CompareVersionsTitle(oldName, newName)).
+					HideCancel(true).
+					OkText(utilMsgr.OK).
+					Attr("@click:ok", "locals.compareVersionDialog = false").
+					Attr("v-model", "locals.compareVersionDialog").
+					Width(900),
+			).Init("{compareVersionDialog:true}").VSlot("{locals}"),
+		})
+		return
+	}
+}
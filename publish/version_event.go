@@ -17,6 +17,10 @@ import (
 const (
 	PortalSchedulePublishDialog = "publish_PortalSchedulePublishDialog"
 	PortalPublishCustomDialog   = "publish_PortalPublishCustomDialog"
+	PortalReviewDialog          = "publish_PortalReviewDialog"
+	PortalAddToReleaseDialog    = "publish_PortalAddToReleaseDialog"
+	PortalPromoteTargetDialog   = "publish_PortalPromoteTargetDialog"
+	PortalShareLinkDialog       = "publish_PortalShareLinkDialog"
 
 	paramVersionName = "version_name"
 )
@@ -41,44 +45,7 @@ func duplicateVersionAction(mb *presets.ModelBuilder, db *gorm.DB) web.EventFunc
 			return r, perm.PermissionDenied
 		}
 
-		version := EmbedVersion(obj)
-		if version == nil {
-			err = errInvalidObject
-			return
-		}
-
-		oldVersion := version.Version
-		newVersion, err := version.CreateVersion(db, slug, mb.NewModel())
-		if err != nil {
-			return
-		}
-		*version = Version{newVersion, newVersion, oldVersion}
-
-		status := EmbedStatus(obj)
-		if status != nil {
-			*status = Status{Status: StatusDraft}
-		}
-
-		sched := EmbedSchedule(obj)
-		if sched != nil {
-			*sched = Schedule{}
-		}
-
-		_, err = reflectutils.Get(obj, "CreatedAt")
-		if err == nil {
-			if err = reflectutils.Set(obj, "CreatedAt", time.Time{}); err != nil {
-				return
-			}
-		}
-		_, err = reflectutils.Get(obj, "UpdatedAt")
-		if err == nil {
-			if err = reflectutils.Set(obj, "UpdatedAt", time.Time{}); err != nil {
-				return
-			}
-		}
-
-		slug = obj.(presets.SlugEncoder).PrimarySlug()
-		if err = mb.Editing().Creating().Saver(obj, slug, ctx); err != nil {
+		if slug, err = duplicateVersion(db, mb, obj, slug, ctx); err != nil {
 			return
 		}
 
@@ -94,6 +61,46 @@ func duplicateVersionAction(mb *presets.ModelBuilder, db *gorm.DB) web.EventFunc
 	}
 }
 
+// duplicateVersion saves obj as a new draft version created from the version of slug and returns the new slug
+func duplicateVersion(db *gorm.DB, mb *presets.ModelBuilder, obj interface{}, slug string, ctx *web.EventContext) (newSlug string, err error) {
+	version := EmbedVersion(obj)
+	if version == nil {
+		return "", errInvalidObject
+	}
+
+	oldVersion := version.Version
+	newVersion, err := version.CreateVersion(db, slug, mb.NewModel())
+	if err != nil {
+		return
+	}
+	*version = Version{newVersion, newVersion, oldVersion}
+
+	status := EmbedStatus(obj)
+	if status != nil {
+		*status = Status{Status: StatusDraft}
+	}
+
+	sched := EmbedSchedule(obj)
+	if sched != nil {
+		*sched = Schedule{}
+	}
+
+	if _, err = reflectutils.Get(obj, "CreatedAt"); err == nil {
+		if err = reflectutils.Set(obj, "CreatedAt", time.Time{}); err != nil {
+			return
+		}
+	}
+	if _, err = reflectutils.Get(obj, "UpdatedAt"); err == nil {
+		if err = reflectutils.Set(obj, "UpdatedAt", time.Time{}); err != nil {
+			return
+		}
+	}
+
+	newSlug = obj.(presets.SlugEncoder).PrimarySlug()
+	err = mb.Editing().Creating().Saver(obj, newSlug, ctx)
+	return
+}
+
 func renameVersionDialog(_ *presets.ModelBuilder) web.EventFunc {
 	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
 		utilMsgr := i18n.MustGetModuleMessages(ctx.R, utils.I18nUtilsKey, Messages_en_US).(*utils.Messages)
@@ -0,0 +1,166 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/qor5/web/v3"
+	"github.com/qor5/x/v3/i18n"
+	"github.com/qor5/x/v3/perm"
+	v "github.com/qor5/x/v3/ui/vuetify"
+	vx "github.com/qor5/x/v3/ui/vuetifyx"
+	h "github.com/theplant/htmlgo"
+	"gorm.io/gorm"
+
+	"github.com/qor5/admin/v3/presets"
+	"github.com/qor5/admin/v3/utils"
+)
+
+const fieldPublishNow = "PublishNow"
+
+var ErrRollbackNotOffline = errors.New("only offline versions can be rolled back")
+
+// IsDuplicatingVersion reports whether the event creates a new version by copying an existing one,
+// savers use it to copy the data stored outside of the model
+func IsDuplicatingVersion(ctx *web.EventContext) bool {
+	switch ctx.R.FormValue(web.EventFuncIDName) {
+	case EventDuplicateVersion, EventRollbackVersion:
+		return true
+	}
+	return false
+}
+
+func rollbackVersionDialog(_ *presets.ModelBuilder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		utilMsgr := i18n.MustGetModuleMessages(ctx.R, utils.I18nUtilsKey, Messages_en_US).(*utils.Messages)
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+
+		versionName := ctx.R.FormValue(paramVersionName)
+		r.UpdatePortals = append(r.UpdatePortals, &web.PortalUpdate{
+			Name: presets.DialogPortalName,
+			Body: web.Scope(
+				vx.VXDialog(
+					h.Div(h.Text(msgr.RollbackVersionConfirmationText(versionName))),
+					v.VCheckbox().Attr(web.VField(fieldPublishNow, false)...).
+						Label(msgr.PublishImmediately).HideDetails(true),
+				).Title(msgr.Rollback).
+					CancelText(utilMsgr.Cancel).
+					OkText(utilMsgr.OK).
+					Attr("@click:ok", web.Plaid().
+						URL(ctx.R.URL.Path).
+						EventFunc(EventRollbackVersion).
+						Queries(ctx.Queries()).
+						ThenScript("locals.rollbackVersionDialog = false").
+						Go()).
+					Attr("v-model", "locals.rollbackVersionDialog"),
+			).Init("{rollbackVersionDialog:true}").VSlot("{locals}"),
+		})
+		return
+	}
+}
+
+// rollbackVersionAction copies a historical version of pm into a new draft and optionally publishes it,
+// it is called from the version list but saves through pm so that savers wrapped on pm are applied
+func rollbackVersionAction(pm *presets.ModelBuilder, _ *gorm.DB, publisher *Builder) web.EventFunc {
+	return func(ctx *web.EventContext) (r web.EventResponse, err error) {
+		defer func() {
+			if err != nil {
+				presets.ShowMessage(&r, err.Error(), "error")
+				err = nil
+			}
+		}()
+
+		slug := ctx.Param(presets.ParamID)
+		obj := pm.NewModel()
+		obj, err = pm.Editing().Fetcher(obj, slug, ctx)
+		if err != nil {
+			return
+		}
+
+		publishNow := ctx.R.FormValue(fieldPublishNow) == "true"
+		perms := []string{presets.PermUpdate, PermDuplicate}
+		if publishNow {
+			perms = append(perms, PermPublish)
+		}
+		if DeniedDo(pm.Info().Verifier(), obj, ctx.R, perms...) {
+			return r, perm.PermissionDenied
+		}
+
+		msgr := i18n.MustGetModuleMessages(ctx.R, I18nPublishKey, Messages_en_US).(*Messages)
+		if slug, err = publisher.RollbackVersion(ctx, pm, obj, false); err != nil {
+			if errors.Is(err, ErrRollbackNotOffline) {
+				err = errors.New(msgr.RollbackOnlyOffline)
+			}
+			return
+		}
+
+		web.AppendRunScripts(&r, presets.CloseListingDialogVarScript)
+		r.Emit(pm.NotifModelsCreated(), presets.PayloadModelsCreated{
+			Models: []any{obj},
+		})
+		r.Emit(NotifVersionSelected(pm), PayloadVersionSelected{Slug: slug})
+
+		if publishNow {
+			// the draft is kept when it still needs a review
+			if err = publisher.publishRollback(ctx, obj); err != nil {
+				var checkErr *PublishCheckError
+				if errors.As(err, &checkErr) {
+					presets.ShowMessage(&r, checkErr.Error(), "warning")
+					return r, nil
+				}
+				return
+			}
+			publisher.logReview(ctx.R.Context(), pm, obj, ActivityPublish, "")
+		}
+		presets.ShowMessage(&r, msgr.SuccessfullyRollback, "")
+		return
+	}
+}
+
+// RollbackVersion restores the offline version obj of mb as a new draft, obj becomes the draft and publishNow publishes it.
+// Only offline versions are restored: the online version is already live and a draft can be edited or published as it is.
+func (b *Builder) RollbackVersion(ctx *web.EventContext, mb *presets.ModelBuilder, obj any, publishNow bool) (slug string, err error) {
+	status := EmbedStatus(obj)
+	sourceVersion := EmbedVersion(obj)
+	if status == nil || sourceVersion == nil {
+		return "", errInvalidObject
+	}
+	if status.Status != StatusOffline {
+		return "", ErrRollbackNotOffline
+	}
+	source := *sourceVersion
+
+	if slug, err = duplicateVersion(b.db, mb, obj, obj.(presets.SlugEncoder).PrimarySlug(), ctx); err != nil {
+		return
+	}
+	b.logRollback(ctx.R.Context(), mb, obj, source)
+	if publishNow {
+		err = b.publishRollback(ctx, obj)
+	}
+	return
+}
+
+func (b *Builder) publishRollback(ctx *web.EventContext, obj any) error {
+	return b.Publish(b.WithContextValues(ctx.R.Context()), obj)
+}
+
+func (b *Builder) logRollback(ctx context.Context, mb *presets.ModelBuilder, obj interface{}, source Version) {
+	if b.ab == nil {
+		return
+	}
+	amb, exist := b.ab.GetModelBuilder(mb)
+	if !exist {
+		return
+	}
+	target := EmbedVersion(obj)
+	detail := map[string]string{
+		"SourceVersion":     source.Version,
+		"SourceVersionName": source.VersionName,
+		"TargetVersion":     target.Version,
+		"TargetVersionName": target.VersionName,
+	}
+	if _, err := amb.Log(ctx, ActivityRollback, obj, detail); err != nil {
+		log.Printf("error: %s\n", err)
+	}
+}
@@ -293,6 +293,16 @@ func NewNonModelSEOSlice(name string, locales ...string) []NonModelSEO {
 // b := New(db, []string{"en"})
 // b.Render(NewNonModelSEO("About Us"))
 func (b *Builder) Render(obj interface{}, req *http.Request) h.HTMLComponent {
+	setting, metaProperties, ok := b.Resolve(obj, req)
+	if !ok {
+		return h.RawHTML("")
+	}
+	return setting.HTMLComponent(metaProperties)
+}
+
+// Resolve returns the setting of obj with the global and model defaults and the variables applied,
+// and the meta properties Render outputs, ok is false when the seo of obj is not registered
+func (b *Builder) Resolve(obj interface{}, req *http.Request) (setting Setting, metaProperties map[string]string, ok bool) {
 	var seo *SEO
 	var locale string
 	objV := reflect.ValueOf(obj)
@@ -305,9 +315,9 @@ func (b *Builder) Render(obj interface{}, req *http.Request) h.HTMLComponent {
 		objV = reflect.Indirect(objV)
 		seo = b.registeredSEO[objV.Type()]
 	}
-	// if the seo is not registered, return empty html component.
+	// the seo is not registered
 	if seo == nil {
-		return h.RawHTML("")
+		return
 	}
 	if v, ok := obj.(l10n.LocaleInterface); ok {
 		locale = v.EmbedLocale().LocaleCode
@@ -316,7 +326,8 @@ func (b *Builder) Render(obj interface{}, req *http.Request) h.HTMLComponent {
 		locale = b.locales[0]
 	}
 	localeFinalSeoSetting := seo.getLocaleFinalQorSEOSetting(locale, b.db)
-	return b.render(obj, localeFinalSeoSetting, seo, req)
+	setting, metaProperties = b.resolve(obj, localeFinalSeoSetting, seo, req)
+	return setting, metaProperties, true
 }
 
 // BatchRender rendering multiple SEOs at once.
@@ -389,8 +400,12 @@ func (b *Builder) BatchRender(objs interface{}, req *http.Request) []h.HTMLCompo
 }
 
 func (b *Builder) render(obj interface{}, defaultSEOSetting *QorSEOSetting, seo *SEO, req *http.Request) h.HTMLComponent {
+	setting, metaProperties := b.resolve(obj, defaultSEOSetting, seo, req)
+	return setting.HTMLComponent(metaProperties)
+}
+
+func (b *Builder) resolve(obj interface{}, defaultSEOSetting *QorSEOSetting, seo *SEO, req *http.Request) (setting Setting, metaProperties map[string]string) {
 	// get setting
-	var setting Setting
 	{
 		setting = defaultSEOSetting.Setting
 		if _, ok := obj.(string); !ok {
@@ -432,13 +447,12 @@ func (b *Builder) render(obj interface{}, defaultSEOSetting *QorSEOSetting, seo
 		}
 	}
 
-	metaProperties := map[string]string{}
+	metaProperties = map[string]string{}
 	finalMetaProperties := seo.getFinalMetaProps()
 	for propName, propFunc := range finalMetaProperties {
 		metaProperties[propName] = propFunc(obj, &setting, req)
 	}
-
-	return setting.HTMLComponent(metaProperties)
+	return
 }
 
 var regex = regexp.MustCompile("{{([a-zA-Z0-9]*)}}")
@@ -22,6 +22,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/qor5/admin/v3/activity"
+	"github.com/qor5/admin/v3/notification"
 	"github.com/qor5/admin/v3/presets"
 )
 
@@ -34,6 +35,7 @@ type Builder struct {
 	mb                   *presets.ModelBuilder
 	getCurrentUserIDFunc func(r *http.Request) string
 	ab                   *activity.Builder
+	nb                   *notification.Builder
 }
 
 func New(db *gorm.DB) *Builder {
@@ -80,6 +82,12 @@ func (b *Builder) Activity(ab *activity.Builder) *Builder {
 	return b
 }
 
+// Notification sets Notification Builder to notify the operator when a job is done or failed
+func (b *Builder) Notification(nb *notification.Builder) *Builder {
+	b.nb = nb
+	return b
+}
+
 func (b *Builder) NewJob(name string) *JobBuilder {
 	for _, jb := range b.jbs {
 		if jb.name == name {
@@ -450,6 +458,29 @@ func (b *Builder) createJob(ctx *web.EventContext, qorJob *QorJob) (j *QorJob, e
 	return
 }
 
+// AddJob queues a job outside of a request, e.g. from a background process, the args should be of the type of the job resource
+func (b *Builder) AddJob(ctx context.Context, jobName string, args interface{}) (j *QorJob, err error) {
+	jb := b.getJobBuilder(jobName)
+	if jb == nil {
+		return nil, fmt.Errorf("job %s not found", jobName)
+	}
+	err = b.db.Transaction(func(tx *gorm.DB) error {
+		j = &QorJob{
+			Job:    jobName,
+			Status: JobStatusNew,
+		}
+		if err := tx.Create(j).Error; err != nil {
+			return err
+		}
+		inst, err := jb.newJobInstance(nil, j.ID, jobName, args, map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+		return b.q.Add(ctx, inst)
+	})
+	return
+}
+
 func (b *Builder) eventSelectJob(ctx *web.EventContext) (er web.EventResponse, err error) {
 	job := ctx.R.FormValue("jobName")
 	er.UpdatePortals = append(er.UpdatePortals,
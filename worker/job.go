@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/qor5/admin/v3/notification"
 	"github.com/qor5/admin/v3/presets"
 	"github.com/qor5/web/v3"
 	"github.com/qor5/x/v3/i18n"
@@ -200,7 +201,7 @@ func (jb *JobBuilder) newJobInstance(
 		Job:      qorJobName,
 		Status:   JobStatusNew,
 	}
-	if jb.b.getCurrentUserIDFunc != nil {
+	if r != nil && jb.b.getCurrentUserIDFunc != nil {
 		inst.Operator = jb.b.getCurrentUserIDFunc(r)
 	}
 	err := jb.b.db.Create(&inst).Error
@@ -304,12 +305,39 @@ func (job *QorJobInstance) SetStatus(status string) error {
 	}
 
 	if job.shouldCallSave() {
-		return job.callSave()
+		if err := job.callSave(); err != nil {
+			return err
+		}
 	}
 
+	job.notifyOperator()
 	return nil
 }
 
+func (job *QorJobInstance) notifyOperator() {
+	if job.jb == nil || job.jb.b.nb == nil || job.Operator == "" {
+		return
+	}
+	var msg *notification.Message
+	switch job.Status {
+	case JobStatusDone:
+		msg = notification.NewMessage(I18nWorkerKey, Messages_en_US, "NotificationJobDone", "{job}", job.Job)
+		msg.Severity = notification.SeveritySuccess
+	case JobStatusException:
+		msg = notification.NewMessage(I18nWorkerKey, Messages_en_US, "NotificationJobFailed", "{job}", job.Job)
+		msg.Body = job.ProgressText
+		msg.Severity = notification.SeverityError
+	default:
+		return
+	}
+	if mb := job.jb.b.mb; mb != nil {
+		msg.Link = mb.Info().DetailingHref(fmt.Sprint(job.QorJobID))
+	}
+	if err := job.jb.b.nb.SendToUsers(context.Background(), msg, job.Operator); err != nil {
+		log.Println(err)
+	}
+}
+
 func (job *QorJobInstance) SetProgress(progress uint) error {
 	job.mutex.Lock()
 	defer job.mutex.Unlock()
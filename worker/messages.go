@@ -34,6 +34,9 @@ type Messages struct {
 	DateTimePickerClearText  string
 	DateTimePickerOkText     string
 	PleaseSelectJob          string
+
+	NotificationJobDone   string
+	NotificationJobFailed string
 }
 
 var Messages_en_US = &Messages{
@@ -61,6 +64,9 @@ var Messages_en_US = &Messages{
 	DateTimePickerClearText:  "Clear",
 	DateTimePickerOkText:     "OK",
 	PleaseSelectJob:          "Please select job",
+
+	NotificationJobDone:   "Job {job} is done",
+	NotificationJobFailed: "Job {job} failed",
 }
 
 var Messages_zh_CN = &Messages{
@@ -88,6 +94,9 @@ var Messages_zh_CN = &Messages{
 	DateTimePickerClearText:  "清空",
 	DateTimePickerOkText:     "确定",
 	PleaseSelectJob:          "请选择Job",
+
+	NotificationJobDone:   "任务 {job} 已完成",
+	NotificationJobFailed: "任务 {job} 失败",
 }
 
 func getTStatus(msgr *Messages, status string) string {